@@ -83,12 +83,12 @@ type Order struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Id            uint64                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
 	UserId        uint64                 `protobuf:"varint,2,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
-	ProductName   string                 `protobuf:"bytes,3,opt,name=product_name,json=productName,proto3" json:"product_name,omitempty"`
-	Price         float64                `protobuf:"fixed64,4,opt,name=price,proto3" json:"price,omitempty"`
-	Quantity      int32                  `protobuf:"varint,5,opt,name=quantity,proto3" json:"quantity,omitempty"`
 	Status        OrderStatus            `protobuf:"varint,6,opt,name=status,proto3,enum=order.OrderStatus" json:"status,omitempty"`
 	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
 	UpdatedAt     *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	Items         []*OrderItem           `protobuf:"bytes,9,rep,name=items,proto3" json:"items,omitempty"`
+	Total         *Money                 `protobuf:"bytes,11,opt,name=total,proto3" json:"total,omitempty"`
+	Currency      string                 `protobuf:"bytes,12,opt,name=currency,proto3" json:"currency,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -137,62 +137,204 @@ func (x *Order) GetUserId() uint64 {
 	return 0
 }
 
-func (x *Order) GetProductName() string {
+func (x *Order) GetStatus() OrderStatus {
 	if x != nil {
-		return x.ProductName
+		return x.Status
+	}
+	return OrderStatus_PENDING
+}
+
+func (x *Order) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Order) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+func (x *Order) GetItems() []*OrderItem {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+func (x *Order) GetTotal() *Money {
+	if x != nil {
+		return x.Total
+	}
+	return nil
+}
+
+func (x *Order) GetCurrency() string {
+	if x != nil {
+		return x.Currency
 	}
 	return ""
 }
 
-func (x *Order) GetPrice() float64 {
+// OrderItem message
+type OrderItem struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            uint64                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	ProductName   string                 `protobuf:"bytes,2,opt,name=product_name,json=productName,proto3" json:"product_name,omitempty"`
+	Quantity      int32                  `protobuf:"varint,4,opt,name=quantity,proto3" json:"quantity,omitempty"`
+	UnitPrice     *Money                 `protobuf:"bytes,6,opt,name=unit_price,json=unitPrice,proto3" json:"unit_price,omitempty"`
+	Subtotal      *Money                 `protobuf:"bytes,7,opt,name=subtotal,proto3" json:"subtotal,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OrderItem) Reset() {
+	*x = OrderItem{}
+	mi := &file_api_proto_order_order_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OrderItem) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OrderItem) ProtoMessage() {}
+
+func (x *OrderItem) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_order_order_proto_msgTypes[1]
 	if x != nil {
-		return x.Price
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OrderItem.ProtoReflect.Descriptor instead.
+func (*OrderItem) Descriptor() ([]byte, []int) {
+	return file_api_proto_order_order_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *OrderItem) GetId() uint64 {
+	if x != nil {
+		return x.Id
 	}
 	return 0
 }
 
-func (x *Order) GetQuantity() int32 {
+func (x *OrderItem) GetProductName() string {
+	if x != nil {
+		return x.ProductName
+	}
+	return ""
+}
+
+func (x *OrderItem) GetQuantity() int32 {
 	if x != nil {
 		return x.Quantity
 	}
 	return 0
 }
 
-func (x *Order) GetStatus() OrderStatus {
+func (x *OrderItem) GetUnitPrice() *Money {
 	if x != nil {
-		return x.Status
+		return x.UnitPrice
 	}
-	return OrderStatus_PENDING
+	return nil
 }
 
-func (x *Order) GetCreatedAt() *timestamppb.Timestamp {
+func (x *OrderItem) GetSubtotal() *Money {
 	if x != nil {
-		return x.CreatedAt
+		return x.Subtotal
 	}
 	return nil
 }
 
-func (x *Order) GetUpdatedAt() *timestamppb.Timestamp {
+// Money is an exact amount of an ISO-4217 currency
+type Money struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Amount in the smallest unit of the currency, e.g. cents for USD
+	MinorUnits int64 `protobuf:"varint,1,opt,name=minor_units,json=minorUnits,proto3" json:"minor_units,omitempty"`
+	// ISO-4217 currency code, e.g. USD
+	Currency      string `protobuf:"bytes,2,opt,name=currency,proto3" json:"currency,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Money) Reset() {
+	*x = Money{}
+	mi := &file_api_proto_order_order_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Money) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Money) ProtoMessage() {}
+
+func (x *Money) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_order_order_proto_msgTypes[2]
 	if x != nil {
-		return x.UpdatedAt
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
 	}
-	return nil
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Money.ProtoReflect.Descriptor instead.
+func (*Money) Descriptor() ([]byte, []int) {
+	return file_api_proto_order_order_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Money) GetMinorUnits() int64 {
+	if x != nil {
+		return x.MinorUnits
+	}
+	return 0
+}
+
+func (x *Money) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
 }
 
 // CreateOrderRequest
 type CreateOrderRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	UserId        uint64                 `protobuf:"varint,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
-	ProductName   string                 `protobuf:"bytes,2,opt,name=product_name,json=productName,proto3" json:"product_name,omitempty"`
-	Price         float64                `protobuf:"fixed64,3,opt,name=price,proto3" json:"price,omitempty"`
-	Quantity      int32                  `protobuf:"varint,4,opt,name=quantity,proto3" json:"quantity,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	UserId uint64                 `protobuf:"varint,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	// Deprecated single-product shape, use items instead
+	//
+	// Deprecated: Marked as deprecated in api/proto/order/order.proto.
+	ProductName string `protobuf:"bytes,2,opt,name=product_name,json=productName,proto3" json:"product_name,omitempty"`
+	// Deprecated: Marked as deprecated in api/proto/order/order.proto.
+	Price float64 `protobuf:"fixed64,3,opt,name=price,proto3" json:"price,omitempty"`
+	// Deprecated: Marked as deprecated in api/proto/order/order.proto.
+	Quantity int32              `protobuf:"varint,4,opt,name=quantity,proto3" json:"quantity,omitempty"`
+	Items    []*CreateOrderItem `protobuf:"bytes,5,rep,name=items,proto3" json:"items,omitempty"`
+	// Defaults to the currency of the item prices, or USD
+	Currency string `protobuf:"bytes,6,opt,name=currency,proto3" json:"currency,omitempty"`
+	// Retries with the same key and request return the original response
+	IdempotencyKey string `protobuf:"bytes,7,opt,name=idempotency_key,json=idempotencyKey,proto3" json:"idempotency_key,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
 }
 
 func (x *CreateOrderRequest) Reset() {
 	*x = CreateOrderRequest{}
-	mi := &file_api_proto_order_order_proto_msgTypes[1]
+	mi := &file_api_proto_order_order_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -204,7 +346,7 @@ func (x *CreateOrderRequest) String() string {
 func (*CreateOrderRequest) ProtoMessage() {}
 
 func (x *CreateOrderRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_api_proto_order_order_proto_msgTypes[1]
+	mi := &file_api_proto_order_order_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -217,7 +359,7 @@ func (x *CreateOrderRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CreateOrderRequest.ProtoReflect.Descriptor instead.
 func (*CreateOrderRequest) Descriptor() ([]byte, []int) {
-	return file_api_proto_order_order_proto_rawDescGZIP(), []int{1}
+	return file_api_proto_order_order_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *CreateOrderRequest) GetUserId() uint64 {
@@ -227,6 +369,7 @@ func (x *CreateOrderRequest) GetUserId() uint64 {
 	return 0
 }
 
+// Deprecated: Marked as deprecated in api/proto/order/order.proto.
 func (x *CreateOrderRequest) GetProductName() string {
 	if x != nil {
 		return x.ProductName
@@ -234,6 +377,7 @@ func (x *CreateOrderRequest) GetProductName() string {
 	return ""
 }
 
+// Deprecated: Marked as deprecated in api/proto/order/order.proto.
 func (x *CreateOrderRequest) GetPrice() float64 {
 	if x != nil {
 		return x.Price
@@ -241,6 +385,7 @@ func (x *CreateOrderRequest) GetPrice() float64 {
 	return 0
 }
 
+// Deprecated: Marked as deprecated in api/proto/order/order.proto.
 func (x *CreateOrderRequest) GetQuantity() int32 {
 	if x != nil {
 		return x.Quantity
@@ -248,6 +393,100 @@ func (x *CreateOrderRequest) GetQuantity() int32 {
 	return 0
 }
 
+func (x *CreateOrderRequest) GetItems() []*CreateOrderItem {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+func (x *CreateOrderRequest) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+func (x *CreateOrderRequest) GetIdempotencyKey() string {
+	if x != nil {
+		return x.IdempotencyKey
+	}
+	return ""
+}
+
+// CreateOrderItem
+type CreateOrderItem struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	ProductName string                 `protobuf:"bytes,1,opt,name=product_name,json=productName,proto3" json:"product_name,omitempty"`
+	// Deprecated floating point price, use unit_price instead
+	//
+	// Deprecated: Marked as deprecated in api/proto/order/order.proto.
+	Price         float64 `protobuf:"fixed64,2,opt,name=price,proto3" json:"price,omitempty"`
+	Quantity      int32   `protobuf:"varint,3,opt,name=quantity,proto3" json:"quantity,omitempty"`
+	UnitPrice     *Money  `protobuf:"bytes,4,opt,name=unit_price,json=unitPrice,proto3" json:"unit_price,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateOrderItem) Reset() {
+	*x = CreateOrderItem{}
+	mi := &file_api_proto_order_order_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateOrderItem) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateOrderItem) ProtoMessage() {}
+
+func (x *CreateOrderItem) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_order_order_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateOrderItem.ProtoReflect.Descriptor instead.
+func (*CreateOrderItem) Descriptor() ([]byte, []int) {
+	return file_api_proto_order_order_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *CreateOrderItem) GetProductName() string {
+	if x != nil {
+		return x.ProductName
+	}
+	return ""
+}
+
+// Deprecated: Marked as deprecated in api/proto/order/order.proto.
+func (x *CreateOrderItem) GetPrice() float64 {
+	if x != nil {
+		return x.Price
+	}
+	return 0
+}
+
+func (x *CreateOrderItem) GetQuantity() int32 {
+	if x != nil {
+		return x.Quantity
+	}
+	return 0
+}
+
+func (x *CreateOrderItem) GetUnitPrice() *Money {
+	if x != nil {
+		return x.UnitPrice
+	}
+	return nil
+}
+
 // GetOrderByIDRequest
 type GetOrderByIDRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -258,7 +497,7 @@ type GetOrderByIDRequest struct {
 
 func (x *GetOrderByIDRequest) Reset() {
 	*x = GetOrderByIDRequest{}
-	mi := &file_api_proto_order_order_proto_msgTypes[2]
+	mi := &file_api_proto_order_order_proto_msgTypes[5]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -270,7 +509,7 @@ func (x *GetOrderByIDRequest) String() string {
 func (*GetOrderByIDRequest) ProtoMessage() {}
 
 func (x *GetOrderByIDRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_api_proto_order_order_proto_msgTypes[2]
+	mi := &file_api_proto_order_order_proto_msgTypes[5]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -283,7 +522,7 @@ func (x *GetOrderByIDRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetOrderByIDRequest.ProtoReflect.Descriptor instead.
 func (*GetOrderByIDRequest) Descriptor() ([]byte, []int) {
-	return file_api_proto_order_order_proto_rawDescGZIP(), []int{2}
+	return file_api_proto_order_order_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *GetOrderByIDRequest) GetId() uint64 {
@@ -297,13 +536,15 @@ func (x *GetOrderByIDRequest) GetId() uint64 {
 type GetOrdersByUserIDRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	UserId        uint64                 `protobuf:"varint,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	PageSize      int32                  `protobuf:"varint,2,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	PageToken     string                 `protobuf:"bytes,3,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *GetOrdersByUserIDRequest) Reset() {
 	*x = GetOrdersByUserIDRequest{}
-	mi := &file_api_proto_order_order_proto_msgTypes[3]
+	mi := &file_api_proto_order_order_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -315,7 +556,7 @@ func (x *GetOrdersByUserIDRequest) String() string {
 func (*GetOrdersByUserIDRequest) ProtoMessage() {}
 
 func (x *GetOrdersByUserIDRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_api_proto_order_order_proto_msgTypes[3]
+	mi := &file_api_proto_order_order_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -328,7 +569,7 @@ func (x *GetOrdersByUserIDRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetOrdersByUserIDRequest.ProtoReflect.Descriptor instead.
 func (*GetOrdersByUserIDRequest) Descriptor() ([]byte, []int) {
-	return file_api_proto_order_order_proto_rawDescGZIP(), []int{3}
+	return file_api_proto_order_order_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *GetOrdersByUserIDRequest) GetUserId() uint64 {
@@ -338,18 +579,36 @@ func (x *GetOrdersByUserIDRequest) GetUserId() uint64 {
 	return 0
 }
 
+func (x *GetOrdersByUserIDRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *GetOrdersByUserIDRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
 // GetOrdersByUserIDResponse
 type GetOrdersByUserIDResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Orders        []*Order               `protobuf:"bytes,1,rep,name=orders,proto3" json:"orders,omitempty"`
-	Error         string                 `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Orders []*Order               `protobuf:"bytes,1,rep,name=orders,proto3" json:"orders,omitempty"`
+	// Deprecated: errors are returned as gRPC status codes
+	//
+	// Deprecated: Marked as deprecated in api/proto/order/order.proto.
+	Error         string `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	NextPageToken string `protobuf:"bytes,3,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *GetOrdersByUserIDResponse) Reset() {
 	*x = GetOrdersByUserIDResponse{}
-	mi := &file_api_proto_order_order_proto_msgTypes[4]
+	mi := &file_api_proto_order_order_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -361,7 +620,7 @@ func (x *GetOrdersByUserIDResponse) String() string {
 func (*GetOrdersByUserIDResponse) ProtoMessage() {}
 
 func (x *GetOrdersByUserIDResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_api_proto_order_order_proto_msgTypes[4]
+	mi := &file_api_proto_order_order_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -374,7 +633,7 @@ func (x *GetOrdersByUserIDResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetOrdersByUserIDResponse.ProtoReflect.Descriptor instead.
 func (*GetOrdersByUserIDResponse) Descriptor() ([]byte, []int) {
-	return file_api_proto_order_order_proto_rawDescGZIP(), []int{4}
+	return file_api_proto_order_order_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *GetOrdersByUserIDResponse) GetOrders() []*Order {
@@ -384,6 +643,7 @@ func (x *GetOrdersByUserIDResponse) GetOrders() []*Order {
 	return nil
 }
 
+// Deprecated: Marked as deprecated in api/proto/order/order.proto.
 func (x *GetOrdersByUserIDResponse) GetError() string {
 	if x != nil {
 		return x.Error
@@ -391,16 +651,40 @@ func (x *GetOrdersByUserIDResponse) GetError() string {
 	return ""
 }
 
+func (x *GetOrdersByUserIDResponse) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
 // GetAllOrdersRequest
 type GetAllOrdersRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Page size, defaults to 20 and is capped at 100
+	PageSize int32 `protobuf:"varint,1,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	// Token returned as next_page_token by a previous call
+	PageToken     string                 `protobuf:"bytes,2,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
+	Status        *OrderStatus           `protobuf:"varint,3,opt,name=status,proto3,enum=order.OrderStatus,oneof" json:"status,omitempty"`
+	UserId        uint64                 `protobuf:"varint,4,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	CreatedAfter  *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=created_after,json=createdAfter,proto3" json:"created_after,omitempty"`
+	CreatedBefore *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=created_before,json=createdBefore,proto3" json:"created_before,omitempty"`
+	// One of id, created_at, price
+	SortBy string `protobuf:"bytes,9,opt,name=sort_by,json=sortBy,proto3" json:"sort_by,omitempty"`
+	// asc or desc
+	SortOrder string `protobuf:"bytes,10,opt,name=sort_order,json=sortOrder,proto3" json:"sort_order,omitempty"`
+	// ISO-4217 currency code, required with min_price and max_price
+	Currency string `protobuf:"bytes,11,opt,name=currency,proto3" json:"currency,omitempty"`
+	// Bounds of the order total as decimal amounts in currency, e.g. "10.00"
+	MinPrice      string `protobuf:"bytes,12,opt,name=min_price,json=minPrice,proto3" json:"min_price,omitempty"`
+	MaxPrice      string `protobuf:"bytes,13,opt,name=max_price,json=maxPrice,proto3" json:"max_price,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *GetAllOrdersRequest) Reset() {
 	*x = GetAllOrdersRequest{}
-	mi := &file_api_proto_order_order_proto_msgTypes[5]
+	mi := &file_api_proto_order_order_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -412,7 +696,7 @@ func (x *GetAllOrdersRequest) String() string {
 func (*GetAllOrdersRequest) ProtoMessage() {}
 
 func (x *GetAllOrdersRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_api_proto_order_order_proto_msgTypes[5]
+	mi := &file_api_proto_order_order_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -425,21 +709,103 @@ func (x *GetAllOrdersRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetAllOrdersRequest.ProtoReflect.Descriptor instead.
 func (*GetAllOrdersRequest) Descriptor() ([]byte, []int) {
-	return file_api_proto_order_order_proto_rawDescGZIP(), []int{5}
+	return file_api_proto_order_order_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *GetAllOrdersRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *GetAllOrdersRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
+func (x *GetAllOrdersRequest) GetStatus() OrderStatus {
+	if x != nil && x.Status != nil {
+		return *x.Status
+	}
+	return OrderStatus_PENDING
+}
+
+func (x *GetAllOrdersRequest) GetUserId() uint64 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *GetAllOrdersRequest) GetCreatedAfter() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAfter
+	}
+	return nil
+}
+
+func (x *GetAllOrdersRequest) GetCreatedBefore() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedBefore
+	}
+	return nil
+}
+
+func (x *GetAllOrdersRequest) GetSortBy() string {
+	if x != nil {
+		return x.SortBy
+	}
+	return ""
+}
+
+func (x *GetAllOrdersRequest) GetSortOrder() string {
+	if x != nil {
+		return x.SortOrder
+	}
+	return ""
+}
+
+func (x *GetAllOrdersRequest) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+func (x *GetAllOrdersRequest) GetMinPrice() string {
+	if x != nil {
+		return x.MinPrice
+	}
+	return ""
+}
+
+func (x *GetAllOrdersRequest) GetMaxPrice() string {
+	if x != nil {
+		return x.MaxPrice
+	}
+	return ""
 }
 
 // GetAllOrdersResponse
 type GetAllOrdersResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Orders        []*Order               `protobuf:"bytes,1,rep,name=orders,proto3" json:"orders,omitempty"`
-	Error         string                 `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Orders []*Order               `protobuf:"bytes,1,rep,name=orders,proto3" json:"orders,omitempty"`
+	// Deprecated: errors are returned as gRPC status codes
+	//
+	// Deprecated: Marked as deprecated in api/proto/order/order.proto.
+	Error string `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	// Empty when there are no more pages
+	NextPageToken string `protobuf:"bytes,3,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *GetAllOrdersResponse) Reset() {
 	*x = GetAllOrdersResponse{}
-	mi := &file_api_proto_order_order_proto_msgTypes[6]
+	mi := &file_api_proto_order_order_proto_msgTypes[9]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -451,7 +817,7 @@ func (x *GetAllOrdersResponse) String() string {
 func (*GetAllOrdersResponse) ProtoMessage() {}
 
 func (x *GetAllOrdersResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_api_proto_order_order_proto_msgTypes[6]
+	mi := &file_api_proto_order_order_proto_msgTypes[9]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -464,7 +830,7 @@ func (x *GetAllOrdersResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetAllOrdersResponse.ProtoReflect.Descriptor instead.
 func (*GetAllOrdersResponse) Descriptor() ([]byte, []int) {
-	return file_api_proto_order_order_proto_rawDescGZIP(), []int{6}
+	return file_api_proto_order_order_proto_rawDescGZIP(), []int{9}
 }
 
 func (x *GetAllOrdersResponse) GetOrders() []*Order {
@@ -474,6 +840,7 @@ func (x *GetAllOrdersResponse) GetOrders() []*Order {
 	return nil
 }
 
+// Deprecated: Marked as deprecated in api/proto/order/order.proto.
 func (x *GetAllOrdersResponse) GetError() string {
 	if x != nil {
 		return x.Error
@@ -481,18 +848,26 @@ func (x *GetAllOrdersResponse) GetError() string {
 	return ""
 }
 
+func (x *GetAllOrdersResponse) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
 // UpdateOrderStatusRequest
 type UpdateOrderStatusRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Id            uint64                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
 	Status        OrderStatus            `protobuf:"varint,2,opt,name=status,proto3,enum=order.OrderStatus" json:"status,omitempty"`
+	Reason        string                 `protobuf:"bytes,3,opt,name=reason,proto3" json:"reason,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *UpdateOrderStatusRequest) Reset() {
 	*x = UpdateOrderStatusRequest{}
-	mi := &file_api_proto_order_order_proto_msgTypes[7]
+	mi := &file_api_proto_order_order_proto_msgTypes[10]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -504,7 +879,7 @@ func (x *UpdateOrderStatusRequest) String() string {
 func (*UpdateOrderStatusRequest) ProtoMessage() {}
 
 func (x *UpdateOrderStatusRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_api_proto_order_order_proto_msgTypes[7]
+	mi := &file_api_proto_order_order_proto_msgTypes[10]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -517,7 +892,7 @@ func (x *UpdateOrderStatusRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UpdateOrderStatusRequest.ProtoReflect.Descriptor instead.
 func (*UpdateOrderStatusRequest) Descriptor() ([]byte, []int) {
-	return file_api_proto_order_order_proto_rawDescGZIP(), []int{7}
+	return file_api_proto_order_order_proto_rawDescGZIP(), []int{10}
 }
 
 func (x *UpdateOrderStatusRequest) GetId() uint64 {
@@ -534,6 +909,201 @@ func (x *UpdateOrderStatusRequest) GetStatus() OrderStatus {
 	return OrderStatus_PENDING
 }
 
+func (x *UpdateOrderStatusRequest) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+// GetOrderStatusHistoryRequest
+type GetOrderStatusHistoryRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            uint64                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetOrderStatusHistoryRequest) Reset() {
+	*x = GetOrderStatusHistoryRequest{}
+	mi := &file_api_proto_order_order_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetOrderStatusHistoryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetOrderStatusHistoryRequest) ProtoMessage() {}
+
+func (x *GetOrderStatusHistoryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_order_order_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetOrderStatusHistoryRequest.ProtoReflect.Descriptor instead.
+func (*GetOrderStatusHistoryRequest) Descriptor() ([]byte, []int) {
+	return file_api_proto_order_order_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *GetOrderStatusHistoryRequest) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+// OrderStatusChange records a single status transition
+type OrderStatusChange struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Unset for the entry recorded when the order was created
+	FromStatus    *OrderStatus           `protobuf:"varint,1,opt,name=from_status,json=fromStatus,proto3,enum=order.OrderStatus,oneof" json:"from_status,omitempty"`
+	ToStatus      OrderStatus            `protobuf:"varint,2,opt,name=to_status,json=toStatus,proto3,enum=order.OrderStatus" json:"to_status,omitempty"`
+	ActorId       uint64                 `protobuf:"varint,3,opt,name=actor_id,json=actorId,proto3" json:"actor_id,omitempty"`
+	Actor         string                 `protobuf:"bytes,4,opt,name=actor,proto3" json:"actor,omitempty"`
+	Reason        string                 `protobuf:"bytes,5,opt,name=reason,proto3" json:"reason,omitempty"`
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OrderStatusChange) Reset() {
+	*x = OrderStatusChange{}
+	mi := &file_api_proto_order_order_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OrderStatusChange) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OrderStatusChange) ProtoMessage() {}
+
+func (x *OrderStatusChange) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_order_order_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OrderStatusChange.ProtoReflect.Descriptor instead.
+func (*OrderStatusChange) Descriptor() ([]byte, []int) {
+	return file_api_proto_order_order_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *OrderStatusChange) GetFromStatus() OrderStatus {
+	if x != nil && x.FromStatus != nil {
+		return *x.FromStatus
+	}
+	return OrderStatus_PENDING
+}
+
+func (x *OrderStatusChange) GetToStatus() OrderStatus {
+	if x != nil {
+		return x.ToStatus
+	}
+	return OrderStatus_PENDING
+}
+
+func (x *OrderStatusChange) GetActorId() uint64 {
+	if x != nil {
+		return x.ActorId
+	}
+	return 0
+}
+
+func (x *OrderStatusChange) GetActor() string {
+	if x != nil {
+		return x.Actor
+	}
+	return ""
+}
+
+func (x *OrderStatusChange) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *OrderStatusChange) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+// GetOrderStatusHistoryResponse
+type GetOrderStatusHistoryResponse struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Changes []*OrderStatusChange   `protobuf:"bytes,1,rep,name=changes,proto3" json:"changes,omitempty"`
+	// Deprecated: errors are returned as gRPC status codes
+	//
+	// Deprecated: Marked as deprecated in api/proto/order/order.proto.
+	Error         string `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetOrderStatusHistoryResponse) Reset() {
+	*x = GetOrderStatusHistoryResponse{}
+	mi := &file_api_proto_order_order_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetOrderStatusHistoryResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetOrderStatusHistoryResponse) ProtoMessage() {}
+
+func (x *GetOrderStatusHistoryResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_order_order_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetOrderStatusHistoryResponse.ProtoReflect.Descriptor instead.
+func (*GetOrderStatusHistoryResponse) Descriptor() ([]byte, []int) {
+	return file_api_proto_order_order_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *GetOrderStatusHistoryResponse) GetChanges() []*OrderStatusChange {
+	if x != nil {
+		return x.Changes
+	}
+	return nil
+}
+
+// Deprecated: Marked as deprecated in api/proto/order/order.proto.
+func (x *GetOrderStatusHistoryResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
 // DeleteOrderRequest
 type DeleteOrderRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -544,7 +1114,7 @@ type DeleteOrderRequest struct {
 
 func (x *DeleteOrderRequest) Reset() {
 	*x = DeleteOrderRequest{}
-	mi := &file_api_proto_order_order_proto_msgTypes[8]
+	mi := &file_api_proto_order_order_proto_msgTypes[14]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -556,7 +1126,7 @@ func (x *DeleteOrderRequest) String() string {
 func (*DeleteOrderRequest) ProtoMessage() {}
 
 func (x *DeleteOrderRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_api_proto_order_order_proto_msgTypes[8]
+	mi := &file_api_proto_order_order_proto_msgTypes[14]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -569,7 +1139,7 @@ func (x *DeleteOrderRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DeleteOrderRequest.ProtoReflect.Descriptor instead.
 func (*DeleteOrderRequest) Descriptor() ([]byte, []int) {
-	return file_api_proto_order_order_proto_rawDescGZIP(), []int{8}
+	return file_api_proto_order_order_proto_rawDescGZIP(), []int{14}
 }
 
 func (x *DeleteOrderRequest) GetId() uint64 {
@@ -590,7 +1160,7 @@ type DeleteOrderResponse struct {
 
 func (x *DeleteOrderResponse) Reset() {
 	*x = DeleteOrderResponse{}
-	mi := &file_api_proto_order_order_proto_msgTypes[9]
+	mi := &file_api_proto_order_order_proto_msgTypes[15]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -602,7 +1172,7 @@ func (x *DeleteOrderResponse) String() string {
 func (*DeleteOrderResponse) ProtoMessage() {}
 
 func (x *DeleteOrderResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_api_proto_order_order_proto_msgTypes[9]
+	mi := &file_api_proto_order_order_proto_msgTypes[15]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -615,7 +1185,7 @@ func (x *DeleteOrderResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DeleteOrderResponse.ProtoReflect.Descriptor instead.
 func (*DeleteOrderResponse) Descriptor() ([]byte, []int) {
-	return file_api_proto_order_order_proto_rawDescGZIP(), []int{9}
+	return file_api_proto_order_order_proto_rawDescGZIP(), []int{15}
 }
 
 func (x *DeleteOrderResponse) GetSuccess() bool {
@@ -634,16 +1204,19 @@ func (x *DeleteOrderResponse) GetMessage() string {
 
 // OrderResponse
 type OrderResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Order         *Order                 `protobuf:"bytes,1,opt,name=order,proto3" json:"order,omitempty"`
-	Error         string                 `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Order *Order                 `protobuf:"bytes,1,opt,name=order,proto3" json:"order,omitempty"`
+	// Deprecated: errors are returned as gRPC status codes
+	//
+	// Deprecated: Marked as deprecated in api/proto/order/order.proto.
+	Error         string `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *OrderResponse) Reset() {
 	*x = OrderResponse{}
-	mi := &file_api_proto_order_order_proto_msgTypes[10]
+	mi := &file_api_proto_order_order_proto_msgTypes[16]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -655,7 +1228,7 @@ func (x *OrderResponse) String() string {
 func (*OrderResponse) ProtoMessage() {}
 
 func (x *OrderResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_api_proto_order_order_proto_msgTypes[10]
+	mi := &file_api_proto_order_order_proto_msgTypes[16]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -668,7 +1241,7 @@ func (x *OrderResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use OrderResponse.ProtoReflect.Descriptor instead.
 func (*OrderResponse) Descriptor() ([]byte, []int) {
-	return file_api_proto_order_order_proto_rawDescGZIP(), []int{10}
+	return file_api_proto_order_order_proto_rawDescGZIP(), []int{16}
 }
 
 func (x *OrderResponse) GetOrder() *Order {
@@ -678,6 +1251,7 @@ func (x *OrderResponse) GetOrder() *Order {
 	return nil
 }
 
+// Deprecated: Marked as deprecated in api/proto/order/order.proto.
 func (x *OrderResponse) GetError() string {
 	if x != nil {
 		return x.Error
@@ -689,57 +1263,115 @@ var File_api_proto_order_order_proto protoreflect.FileDescriptor
 
 const file_api_proto_order_order_proto_rawDesc = "" +
 	"\n" +
-	"\x1bapi/proto/order/order.proto\x12\x05order\x1a\x1fgoogle/protobuf/timestamp.proto\"\xa7\x02\n" +
+	"\x1bapi/proto/order/order.proto\x12\x05order\x1a\x1fgoogle/protobuf/timestamp.proto\"\xf1\x02\n" +
 	"\x05Order\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\x04R\x02id\x12\x17\n" +
-	"\auser_id\x18\x02 \x01(\x04R\x06userId\x12!\n" +
-	"\fproduct_name\x18\x03 \x01(\tR\vproductName\x12\x14\n" +
-	"\x05price\x18\x04 \x01(\x01R\x05price\x12\x1a\n" +
-	"\bquantity\x18\x05 \x01(\x05R\bquantity\x12*\n" +
+	"\auser_id\x18\x02 \x01(\x04R\x06userId\x12*\n" +
 	"\x06status\x18\x06 \x01(\x0e2\x12.order.OrderStatusR\x06status\x129\n" +
 	"\n" +
 	"created_at\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
 	"\n" +
-	"updated_at\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\"\x82\x01\n" +
+	"updated_at\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\x12&\n" +
+	"\x05items\x18\t \x03(\v2\x10.order.OrderItemR\x05items\x12\"\n" +
+	"\x05total\x18\v \x01(\v2\f.order.MoneyR\x05total\x12\x1a\n" +
+	"\bcurrency\x18\f \x01(\tR\bcurrencyJ\x04\b\x03\x10\x04J\x04\b\x04\x10\x05J\x04\b\x05\x10\x06J\x04\b\n" +
+	"\x10\vR\fproduct_nameR\x05priceR\bquantity\"\xbd\x01\n" +
+	"\tOrderItem\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\x12!\n" +
+	"\fproduct_name\x18\x02 \x01(\tR\vproductName\x12\x1a\n" +
+	"\bquantity\x18\x04 \x01(\x05R\bquantity\x12+\n" +
+	"\n" +
+	"unit_price\x18\x06 \x01(\v2\f.order.MoneyR\tunitPrice\x12(\n" +
+	"\bsubtotal\x18\a \x01(\v2\f.order.MoneyR\bsubtotalJ\x04\b\x03\x10\x04J\x04\b\x05\x10\x06\"D\n" +
+	"\x05Money\x12\x1f\n" +
+	"\vminor_units\x18\x01 \x01(\x03R\n" +
+	"minorUnits\x12\x1a\n" +
+	"\bcurrency\x18\x02 \x01(\tR\bcurrency\"\x81\x02\n" +
 	"\x12CreateOrderRequest\x12\x17\n" +
-	"\auser_id\x18\x01 \x01(\x04R\x06userId\x12!\n" +
-	"\fproduct_name\x18\x02 \x01(\tR\vproductName\x12\x14\n" +
-	"\x05price\x18\x03 \x01(\x01R\x05price\x12\x1a\n" +
-	"\bquantity\x18\x04 \x01(\x05R\bquantity\"%\n" +
+	"\auser_id\x18\x01 \x01(\x04R\x06userId\x12%\n" +
+	"\fproduct_name\x18\x02 \x01(\tB\x02\x18\x01R\vproductName\x12\x18\n" +
+	"\x05price\x18\x03 \x01(\x01B\x02\x18\x01R\x05price\x12\x1e\n" +
+	"\bquantity\x18\x04 \x01(\x05B\x02\x18\x01R\bquantity\x12,\n" +
+	"\x05items\x18\x05 \x03(\v2\x16.order.CreateOrderItemR\x05items\x12\x1a\n" +
+	"\bcurrency\x18\x06 \x01(\tR\bcurrency\x12'\n" +
+	"\x0fidempotency_key\x18\a \x01(\tR\x0eidempotencyKey\"\x97\x01\n" +
+	"\x0fCreateOrderItem\x12!\n" +
+	"\fproduct_name\x18\x01 \x01(\tR\vproductName\x12\x18\n" +
+	"\x05price\x18\x02 \x01(\x01B\x02\x18\x01R\x05price\x12\x1a\n" +
+	"\bquantity\x18\x03 \x01(\x05R\bquantity\x12+\n" +
+	"\n" +
+	"unit_price\x18\x04 \x01(\v2\f.order.MoneyR\tunitPrice\"%\n" +
 	"\x13GetOrderByIDRequest\x12\x0e\n" +
-	"\x02id\x18\x01 \x01(\x04R\x02id\"3\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\"o\n" +
 	"\x18GetOrdersByUserIDRequest\x12\x17\n" +
-	"\auser_id\x18\x01 \x01(\x04R\x06userId\"W\n" +
+	"\auser_id\x18\x01 \x01(\x04R\x06userId\x12\x1b\n" +
+	"\tpage_size\x18\x02 \x01(\x05R\bpageSize\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\x03 \x01(\tR\tpageToken\"\x83\x01\n" +
 	"\x19GetOrdersByUserIDResponse\x12$\n" +
-	"\x06orders\x18\x01 \x03(\v2\f.order.OrderR\x06orders\x12\x14\n" +
-	"\x05error\x18\x02 \x01(\tR\x05error\"\x15\n" +
-	"\x13GetAllOrdersRequest\"R\n" +
+	"\x06orders\x18\x01 \x03(\v2\f.order.OrderR\x06orders\x12\x18\n" +
+	"\x05error\x18\x02 \x01(\tB\x02\x18\x01R\x05error\x12&\n" +
+	"\x0fnext_page_token\x18\x03 \x01(\tR\rnextPageToken\"\xc4\x03\n" +
+	"\x13GetAllOrdersRequest\x12\x1b\n" +
+	"\tpage_size\x18\x01 \x01(\x05R\bpageSize\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\x02 \x01(\tR\tpageToken\x12/\n" +
+	"\x06status\x18\x03 \x01(\x0e2\x12.order.OrderStatusH\x00R\x06status\x88\x01\x01\x12\x17\n" +
+	"\auser_id\x18\x04 \x01(\x04R\x06userId\x12?\n" +
+	"\rcreated_after\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\fcreatedAfter\x12A\n" +
+	"\x0ecreated_before\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\rcreatedBefore\x12\x17\n" +
+	"\asort_by\x18\t \x01(\tR\x06sortBy\x12\x1d\n" +
+	"\n" +
+	"sort_order\x18\n" +
+	" \x01(\tR\tsortOrder\x12\x1a\n" +
+	"\bcurrency\x18\v \x01(\tR\bcurrency\x12\x1b\n" +
+	"\tmin_price\x18\f \x01(\tR\bminPrice\x12\x1b\n" +
+	"\tmax_price\x18\r \x01(\tR\bmaxPriceB\t\n" +
+	"\a_statusJ\x04\b\a\x10\bJ\x04\b\b\x10\t\"~\n" +
 	"\x14GetAllOrdersResponse\x12$\n" +
-	"\x06orders\x18\x01 \x03(\v2\f.order.OrderR\x06orders\x12\x14\n" +
-	"\x05error\x18\x02 \x01(\tR\x05error\"V\n" +
+	"\x06orders\x18\x01 \x03(\v2\f.order.OrderR\x06orders\x12\x18\n" +
+	"\x05error\x18\x02 \x01(\tB\x02\x18\x01R\x05error\x12&\n" +
+	"\x0fnext_page_token\x18\x03 \x01(\tR\rnextPageToken\"n\n" +
 	"\x18UpdateOrderStatusRequest\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\x04R\x02id\x12*\n" +
-	"\x06status\x18\x02 \x01(\x0e2\x12.order.OrderStatusR\x06status\"$\n" +
+	"\x06status\x18\x02 \x01(\x0e2\x12.order.OrderStatusR\x06status\x12\x16\n" +
+	"\x06reason\x18\x03 \x01(\tR\x06reason\".\n" +
+	"\x1cGetOrderStatusHistoryRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\"\x92\x02\n" +
+	"\x11OrderStatusChange\x128\n" +
+	"\vfrom_status\x18\x01 \x01(\x0e2\x12.order.OrderStatusH\x00R\n" +
+	"fromStatus\x88\x01\x01\x12/\n" +
+	"\tto_status\x18\x02 \x01(\x0e2\x12.order.OrderStatusR\btoStatus\x12\x19\n" +
+	"\bactor_id\x18\x03 \x01(\x04R\aactorId\x12\x14\n" +
+	"\x05actor\x18\x04 \x01(\tR\x05actor\x12\x16\n" +
+	"\x06reason\x18\x05 \x01(\tR\x06reason\x129\n" +
+	"\n" +
+	"created_at\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAtB\x0e\n" +
+	"\f_from_status\"m\n" +
+	"\x1dGetOrderStatusHistoryResponse\x122\n" +
+	"\achanges\x18\x01 \x03(\v2\x18.order.OrderStatusChangeR\achanges\x12\x18\n" +
+	"\x05error\x18\x02 \x01(\tB\x02\x18\x01R\x05error\"$\n" +
 	"\x12DeleteOrderRequest\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\x04R\x02id\"I\n" +
 	"\x13DeleteOrderResponse\x12\x18\n" +
 	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
-	"\amessage\x18\x02 \x01(\tR\amessage\"I\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\"M\n" +
 	"\rOrderResponse\x12\"\n" +
-	"\x05order\x18\x01 \x01(\v2\f.order.OrderR\x05order\x12\x14\n" +
-	"\x05error\x18\x02 \x01(\tR\x05error*T\n" +
+	"\x05order\x18\x01 \x01(\v2\f.order.OrderR\x05order\x12\x18\n" +
+	"\x05error\x18\x02 \x01(\tB\x02\x18\x01R\x05error*T\n" +
 	"\vOrderStatus\x12\v\n" +
 	"\aPENDING\x10\x00\x12\r\n" +
 	"\tCONFIRMED\x10\x01\x12\v\n" +
 	"\aSHIPPED\x10\x02\x12\r\n" +
 	"\tDELIVERED\x10\x03\x12\r\n" +
-	"\tCANCELLED\x10\x042\xc3\x03\n" +
+	"\tCANCELLED\x10\x042\xa7\x04\n" +
 	"\fOrderService\x12>\n" +
 	"\vCreateOrder\x12\x19.order.CreateOrderRequest\x1a\x14.order.OrderResponse\x12@\n" +
 	"\fGetOrderByID\x12\x1a.order.GetOrderByIDRequest\x1a\x14.order.OrderResponse\x12V\n" +
 	"\x11GetOrdersByUserID\x12\x1f.order.GetOrdersByUserIDRequest\x1a .order.GetOrdersByUserIDResponse\x12G\n" +
 	"\fGetAllOrders\x12\x1a.order.GetAllOrdersRequest\x1a\x1b.order.GetAllOrdersResponse\x12J\n" +
-	"\x11UpdateOrderStatus\x12\x1f.order.UpdateOrderStatusRequest\x1a\x14.order.OrderResponse\x12D\n" +
+	"\x11UpdateOrderStatus\x12\x1f.order.UpdateOrderStatusRequest\x1a\x14.order.OrderResponse\x12b\n" +
+	"\x15GetOrderStatusHistory\x12#.order.GetOrderStatusHistoryRequest\x1a$.order.GetOrderStatusHistoryResponse\x12D\n" +
 	"\vDeleteOrder\x12\x19.order.DeleteOrderRequest\x1a\x1a.order.DeleteOrderResponseB\x17Z\x15bweng/api/proto/orderb\x06proto3"
 
 var (
@@ -755,47 +1387,68 @@ func file_api_proto_order_order_proto_rawDescGZIP() []byte {
 }
 
 var file_api_proto_order_order_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_api_proto_order_order_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
+var file_api_proto_order_order_proto_msgTypes = make([]protoimpl.MessageInfo, 17)
 var file_api_proto_order_order_proto_goTypes = []any{
-	(OrderStatus)(0),                  // 0: order.OrderStatus
-	(*Order)(nil),                     // 1: order.Order
-	(*CreateOrderRequest)(nil),        // 2: order.CreateOrderRequest
-	(*GetOrderByIDRequest)(nil),       // 3: order.GetOrderByIDRequest
-	(*GetOrdersByUserIDRequest)(nil),  // 4: order.GetOrdersByUserIDRequest
-	(*GetOrdersByUserIDResponse)(nil), // 5: order.GetOrdersByUserIDResponse
-	(*GetAllOrdersRequest)(nil),       // 6: order.GetAllOrdersRequest
-	(*GetAllOrdersResponse)(nil),      // 7: order.GetAllOrdersResponse
-	(*UpdateOrderStatusRequest)(nil),  // 8: order.UpdateOrderStatusRequest
-	(*DeleteOrderRequest)(nil),        // 9: order.DeleteOrderRequest
-	(*DeleteOrderResponse)(nil),       // 10: order.DeleteOrderResponse
-	(*OrderResponse)(nil),             // 11: order.OrderResponse
-	(*timestamppb.Timestamp)(nil),     // 12: google.protobuf.Timestamp
+	(OrderStatus)(0),                      // 0: order.OrderStatus
+	(*Order)(nil),                         // 1: order.Order
+	(*OrderItem)(nil),                     // 2: order.OrderItem
+	(*Money)(nil),                         // 3: order.Money
+	(*CreateOrderRequest)(nil),            // 4: order.CreateOrderRequest
+	(*CreateOrderItem)(nil),               // 5: order.CreateOrderItem
+	(*GetOrderByIDRequest)(nil),           // 6: order.GetOrderByIDRequest
+	(*GetOrdersByUserIDRequest)(nil),      // 7: order.GetOrdersByUserIDRequest
+	(*GetOrdersByUserIDResponse)(nil),     // 8: order.GetOrdersByUserIDResponse
+	(*GetAllOrdersRequest)(nil),           // 9: order.GetAllOrdersRequest
+	(*GetAllOrdersResponse)(nil),          // 10: order.GetAllOrdersResponse
+	(*UpdateOrderStatusRequest)(nil),      // 11: order.UpdateOrderStatusRequest
+	(*GetOrderStatusHistoryRequest)(nil),  // 12: order.GetOrderStatusHistoryRequest
+	(*OrderStatusChange)(nil),             // 13: order.OrderStatusChange
+	(*GetOrderStatusHistoryResponse)(nil), // 14: order.GetOrderStatusHistoryResponse
+	(*DeleteOrderRequest)(nil),            // 15: order.DeleteOrderRequest
+	(*DeleteOrderResponse)(nil),           // 16: order.DeleteOrderResponse
+	(*OrderResponse)(nil),                 // 17: order.OrderResponse
+	(*timestamppb.Timestamp)(nil),         // 18: google.protobuf.Timestamp
 }
 var file_api_proto_order_order_proto_depIdxs = []int32{
 	0,  // 0: order.Order.status:type_name -> order.OrderStatus
-	12, // 1: order.Order.created_at:type_name -> google.protobuf.Timestamp
-	12, // 2: order.Order.updated_at:type_name -> google.protobuf.Timestamp
-	1,  // 3: order.GetOrdersByUserIDResponse.orders:type_name -> order.Order
-	1,  // 4: order.GetAllOrdersResponse.orders:type_name -> order.Order
-	0,  // 5: order.UpdateOrderStatusRequest.status:type_name -> order.OrderStatus
-	1,  // 6: order.OrderResponse.order:type_name -> order.Order
-	2,  // 7: order.OrderService.CreateOrder:input_type -> order.CreateOrderRequest
-	3,  // 8: order.OrderService.GetOrderByID:input_type -> order.GetOrderByIDRequest
-	4,  // 9: order.OrderService.GetOrdersByUserID:input_type -> order.GetOrdersByUserIDRequest
-	6,  // 10: order.OrderService.GetAllOrders:input_type -> order.GetAllOrdersRequest
-	8,  // 11: order.OrderService.UpdateOrderStatus:input_type -> order.UpdateOrderStatusRequest
-	9,  // 12: order.OrderService.DeleteOrder:input_type -> order.DeleteOrderRequest
-	11, // 13: order.OrderService.CreateOrder:output_type -> order.OrderResponse
-	11, // 14: order.OrderService.GetOrderByID:output_type -> order.OrderResponse
-	5,  // 15: order.OrderService.GetOrdersByUserID:output_type -> order.GetOrdersByUserIDResponse
-	7,  // 16: order.OrderService.GetAllOrders:output_type -> order.GetAllOrdersResponse
-	11, // 17: order.OrderService.UpdateOrderStatus:output_type -> order.OrderResponse
-	10, // 18: order.OrderService.DeleteOrder:output_type -> order.DeleteOrderResponse
-	13, // [13:19] is the sub-list for method output_type
-	7,  // [7:13] is the sub-list for method input_type
-	7,  // [7:7] is the sub-list for extension type_name
-	7,  // [7:7] is the sub-list for extension extendee
-	0,  // [0:7] is the sub-list for field type_name
+	18, // 1: order.Order.created_at:type_name -> google.protobuf.Timestamp
+	18, // 2: order.Order.updated_at:type_name -> google.protobuf.Timestamp
+	2,  // 3: order.Order.items:type_name -> order.OrderItem
+	3,  // 4: order.Order.total:type_name -> order.Money
+	3,  // 5: order.OrderItem.unit_price:type_name -> order.Money
+	3,  // 6: order.OrderItem.subtotal:type_name -> order.Money
+	5,  // 7: order.CreateOrderRequest.items:type_name -> order.CreateOrderItem
+	3,  // 8: order.CreateOrderItem.unit_price:type_name -> order.Money
+	1,  // 9: order.GetOrdersByUserIDResponse.orders:type_name -> order.Order
+	0,  // 10: order.GetAllOrdersRequest.status:type_name -> order.OrderStatus
+	18, // 11: order.GetAllOrdersRequest.created_after:type_name -> google.protobuf.Timestamp
+	18, // 12: order.GetAllOrdersRequest.created_before:type_name -> google.protobuf.Timestamp
+	1,  // 13: order.GetAllOrdersResponse.orders:type_name -> order.Order
+	0,  // 14: order.UpdateOrderStatusRequest.status:type_name -> order.OrderStatus
+	0,  // 15: order.OrderStatusChange.from_status:type_name -> order.OrderStatus
+	0,  // 16: order.OrderStatusChange.to_status:type_name -> order.OrderStatus
+	18, // 17: order.OrderStatusChange.created_at:type_name -> google.protobuf.Timestamp
+	13, // 18: order.GetOrderStatusHistoryResponse.changes:type_name -> order.OrderStatusChange
+	1,  // 19: order.OrderResponse.order:type_name -> order.Order
+	4,  // 20: order.OrderService.CreateOrder:input_type -> order.CreateOrderRequest
+	6,  // 21: order.OrderService.GetOrderByID:input_type -> order.GetOrderByIDRequest
+	7,  // 22: order.OrderService.GetOrdersByUserID:input_type -> order.GetOrdersByUserIDRequest
+	9,  // 23: order.OrderService.GetAllOrders:input_type -> order.GetAllOrdersRequest
+	11, // 24: order.OrderService.UpdateOrderStatus:input_type -> order.UpdateOrderStatusRequest
+	12, // 25: order.OrderService.GetOrderStatusHistory:input_type -> order.GetOrderStatusHistoryRequest
+	15, // 26: order.OrderService.DeleteOrder:input_type -> order.DeleteOrderRequest
+	17, // 27: order.OrderService.CreateOrder:output_type -> order.OrderResponse
+	17, // 28: order.OrderService.GetOrderByID:output_type -> order.OrderResponse
+	8,  // 29: order.OrderService.GetOrdersByUserID:output_type -> order.GetOrdersByUserIDResponse
+	10, // 30: order.OrderService.GetAllOrders:output_type -> order.GetAllOrdersResponse
+	17, // 31: order.OrderService.UpdateOrderStatus:output_type -> order.OrderResponse
+	14, // 32: order.OrderService.GetOrderStatusHistory:output_type -> order.GetOrderStatusHistoryResponse
+	16, // 33: order.OrderService.DeleteOrder:output_type -> order.DeleteOrderResponse
+	27, // [27:34] is the sub-list for method output_type
+	20, // [20:27] is the sub-list for method input_type
+	20, // [20:20] is the sub-list for extension type_name
+	20, // [20:20] is the sub-list for extension extendee
+	0,  // [0:20] is the sub-list for field type_name
 }
 
 func init() { file_api_proto_order_order_proto_init() }
@@ -803,13 +1456,15 @@ func file_api_proto_order_order_proto_init() {
 	if File_api_proto_order_order_proto != nil {
 		return
 	}
+	file_api_proto_order_order_proto_msgTypes[8].OneofWrappers = []any{}
+	file_api_proto_order_order_proto_msgTypes[12].OneofWrappers = []any{}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_api_proto_order_order_proto_rawDesc), len(file_api_proto_order_order_proto_rawDesc)),
 			NumEnums:      1,
-			NumMessages:   11,
+			NumMessages:   17,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -19,21 +19,24 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	OrderService_CreateOrder_FullMethodName       = "/order.OrderService/CreateOrder"
-	OrderService_GetOrderByID_FullMethodName      = "/order.OrderService/GetOrderByID"
-	OrderService_GetOrdersByUserID_FullMethodName = "/order.OrderService/GetOrdersByUserID"
-	OrderService_GetAllOrders_FullMethodName      = "/order.OrderService/GetAllOrders"
-	OrderService_UpdateOrderStatus_FullMethodName = "/order.OrderService/UpdateOrderStatus"
-	OrderService_DeleteOrder_FullMethodName       = "/order.OrderService/DeleteOrder"
+	OrderService_CreateOrder_FullMethodName           = "/order.OrderService/CreateOrder"
+	OrderService_GetOrderByID_FullMethodName          = "/order.OrderService/GetOrderByID"
+	OrderService_GetOrdersByUserID_FullMethodName     = "/order.OrderService/GetOrdersByUserID"
+	OrderService_GetAllOrders_FullMethodName          = "/order.OrderService/GetAllOrders"
+	OrderService_UpdateOrderStatus_FullMethodName     = "/order.OrderService/UpdateOrderStatus"
+	OrderService_GetOrderStatusHistory_FullMethodName = "/order.OrderService/GetOrderStatusHistory"
+	OrderService_DeleteOrder_FullMethodName           = "/order.OrderService/DeleteOrder"
 )
 
 // OrderServiceClient is the client API for OrderService service.
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 //
-// Order service definition
+// Order service definition.
+// Failures are returned as gRPC status codes with google.rpc.ErrorInfo
+// (domain "bweng") and, for invalid requests, google.rpc.BadRequest details.
 type OrderServiceClient interface {
-	// Create a new order
+	// Create a new order, idempotent when idempotency_key is set
 	CreateOrder(ctx context.Context, in *CreateOrderRequest, opts ...grpc.CallOption) (*OrderResponse, error)
 	// Get order by ID
 	GetOrderByID(ctx context.Context, in *GetOrderByIDRequest, opts ...grpc.CallOption) (*OrderResponse, error)
@@ -41,8 +44,10 @@ type OrderServiceClient interface {
 	GetOrdersByUserID(ctx context.Context, in *GetOrdersByUserIDRequest, opts ...grpc.CallOption) (*GetOrdersByUserIDResponse, error)
 	// Get all orders
 	GetAllOrders(ctx context.Context, in *GetAllOrdersRequest, opts ...grpc.CallOption) (*GetAllOrdersResponse, error)
-	// Update order status
+	// Update order status, fails with FAILED_PRECONDITION on illegal transitions
 	UpdateOrderStatus(ctx context.Context, in *UpdateOrderStatusRequest, opts ...grpc.CallOption) (*OrderResponse, error)
+	// Get the status transitions of an order
+	GetOrderStatusHistory(ctx context.Context, in *GetOrderStatusHistoryRequest, opts ...grpc.CallOption) (*GetOrderStatusHistoryResponse, error)
 	// Delete order
 	DeleteOrder(ctx context.Context, in *DeleteOrderRequest, opts ...grpc.CallOption) (*DeleteOrderResponse, error)
 }
@@ -105,6 +110,16 @@ func (c *orderServiceClient) UpdateOrderStatus(ctx context.Context, in *UpdateOr
 	return out, nil
 }
 
+func (c *orderServiceClient) GetOrderStatusHistory(ctx context.Context, in *GetOrderStatusHistoryRequest, opts ...grpc.CallOption) (*GetOrderStatusHistoryResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetOrderStatusHistoryResponse)
+	err := c.cc.Invoke(ctx, OrderService_GetOrderStatusHistory_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *orderServiceClient) DeleteOrder(ctx context.Context, in *DeleteOrderRequest, opts ...grpc.CallOption) (*DeleteOrderResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(DeleteOrderResponse)
@@ -119,9 +134,11 @@ func (c *orderServiceClient) DeleteOrder(ctx context.Context, in *DeleteOrderReq
 // All implementations must embed UnimplementedOrderServiceServer
 // for forward compatibility.
 //
-// Order service definition
+// Order service definition.
+// Failures are returned as gRPC status codes with google.rpc.ErrorInfo
+// (domain "bweng") and, for invalid requests, google.rpc.BadRequest details.
 type OrderServiceServer interface {
-	// Create a new order
+	// Create a new order, idempotent when idempotency_key is set
 	CreateOrder(context.Context, *CreateOrderRequest) (*OrderResponse, error)
 	// Get order by ID
 	GetOrderByID(context.Context, *GetOrderByIDRequest) (*OrderResponse, error)
@@ -129,8 +146,10 @@ type OrderServiceServer interface {
 	GetOrdersByUserID(context.Context, *GetOrdersByUserIDRequest) (*GetOrdersByUserIDResponse, error)
 	// Get all orders
 	GetAllOrders(context.Context, *GetAllOrdersRequest) (*GetAllOrdersResponse, error)
-	// Update order status
+	// Update order status, fails with FAILED_PRECONDITION on illegal transitions
 	UpdateOrderStatus(context.Context, *UpdateOrderStatusRequest) (*OrderResponse, error)
+	// Get the status transitions of an order
+	GetOrderStatusHistory(context.Context, *GetOrderStatusHistoryRequest) (*GetOrderStatusHistoryResponse, error)
 	// Delete order
 	DeleteOrder(context.Context, *DeleteOrderRequest) (*DeleteOrderResponse, error)
 	mustEmbedUnimplementedOrderServiceServer()
@@ -158,6 +177,9 @@ func (UnimplementedOrderServiceServer) GetAllOrders(context.Context, *GetAllOrde
 func (UnimplementedOrderServiceServer) UpdateOrderStatus(context.Context, *UpdateOrderStatusRequest) (*OrderResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method UpdateOrderStatus not implemented")
 }
+func (UnimplementedOrderServiceServer) GetOrderStatusHistory(context.Context, *GetOrderStatusHistoryRequest) (*GetOrderStatusHistoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetOrderStatusHistory not implemented")
+}
 func (UnimplementedOrderServiceServer) DeleteOrder(context.Context, *DeleteOrderRequest) (*DeleteOrderResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DeleteOrder not implemented")
 }
@@ -272,6 +294,24 @@ func _OrderService_UpdateOrderStatus_Handler(srv interface{}, ctx context.Contex
 	return interceptor(ctx, in, info, handler)
 }
 
+func _OrderService_GetOrderStatusHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetOrderStatusHistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrderServiceServer).GetOrderStatusHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: OrderService_GetOrderStatusHistory_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrderServiceServer).GetOrderStatusHistory(ctx, req.(*GetOrderStatusHistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _OrderService_DeleteOrder_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(DeleteOrderRequest)
 	if err := dec(in); err != nil {
@@ -317,6 +357,10 @@ var OrderService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "UpdateOrderStatus",
 			Handler:    _OrderService_UpdateOrderStatus_Handler,
 		},
+		{
+			MethodName: "GetOrderStatusHistory",
+			Handler:    _OrderService_GetOrderStatusHistory_Handler,
+		},
 		{
 			MethodName: "DeleteOrder",
 			Handler:    _OrderService_DeleteOrder_Handler,
@@ -1,17 +1,46 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 
 	_ "bweng/docs"
+	"bweng/api/proto/order"
+	"bweng/internal/converter"
+	"bweng/internal/health"
+	"bweng/internal/lifecycle"
+	"bweng/internal/logging"
+	"bweng/internal/metrics"
+	"bweng/internal/migrate"
+	"bweng/internal/money"
 	"bweng/internal/order/config"
 	"bweng/internal/order/handler"
+	"bweng/internal/order/migrations"
+	"bweng/internal/order/model"
 	"bweng/internal/order/repository"
 	"bweng/internal/order/service"
+	"bweng/internal/pagination"
+	"bweng/internal/outbox"
+	"bweng/internal/policy"
+	"bweng/internal/rpcerror"
+	"bweng/internal/tracing"
 )
 
 // @title Order Service API
@@ -32,47 +61,371 @@ import (
 // @securityDefinitions.apikey ApiKeyAuth
 // @in header
 // @name Authorization
+
+// gRPC server implementation
+type orderGRPCServer struct {
+	order.UnimplementedOrderServiceServer
+	orderService *service.OrderService
+}
+
+// toStatus converts an order service error to a gRPC status error
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, service.ErrOrderNotFound):
+		return rpcerror.New(codes.NotFound, rpcerror.ReasonOrderNotFound, err.Error(), nil)
+	case errors.Is(err, service.ErrUserNotFound):
+		return rpcerror.New(codes.NotFound, rpcerror.ReasonUserNotFound, err.Error(), nil)
+	case errors.Is(err, service.ErrUserServiceUnavailable):
+		return rpcerror.New(codes.Unavailable, rpcerror.ReasonDependencyUnavailable, err.Error(), map[string]string{"dependency": "user-service"})
+	case errors.Is(err, service.ErrInvalidStatusTransition):
+		return rpcerror.New(codes.FailedPrecondition, rpcerror.ReasonInvalidStatusTransition, err.Error(), nil)
+	case errors.Is(err, service.ErrIdempotencyKeyReused):
+		return rpcerror.New(codes.InvalidArgument, rpcerror.ReasonIdempotencyKeyReused, err.Error(), nil)
+	case errors.Is(err, service.ErrIdempotencyKeyInProgress):
+		return rpcerror.New(codes.Aborted, rpcerror.ReasonIdempotencyKeyInProgress, err.Error(), nil)
+	case errors.Is(err, service.ErrInvalidIdempotencyKey):
+		return rpcerror.InvalidArgument(err.Error(), rpcerror.Violation("idempotency_key", err.Error()))
+	case errors.Is(err, service.ErrNoOrderItems), errors.Is(err, service.ErrMixedItems):
+		return rpcerror.InvalidArgument(err.Error(), rpcerror.Violation("items", err.Error()))
+	case errors.Is(err, service.ErrInvalidPrice), errors.Is(err, service.ErrMixedPrices), errors.Is(err, money.ErrInvalidAmount), errors.Is(err, money.ErrOverflow):
+		return rpcerror.InvalidArgument(err.Error(), rpcerror.Violation("items.unit_price", err.Error()))
+	case errors.Is(err, money.ErrUnknownCurrency), errors.Is(err, money.ErrCurrencyMismatch), errors.Is(err, service.ErrCurrencyRequired):
+		return rpcerror.InvalidArgument(err.Error(), rpcerror.Violation("currency", err.Error()))
+	case errors.Is(err, service.ErrInvalidStatus):
+		return rpcerror.InvalidArgument(err.Error(), rpcerror.Violation("status", err.Error()))
+	case errors.Is(err, pagination.ErrInvalidPageToken):
+		return rpcerror.InvalidArgument(err.Error(), rpcerror.Violation("page_token", err.Error()))
+	case errors.Is(err, pagination.ErrInvalidSort):
+		return rpcerror.InvalidArgument(err.Error(), rpcerror.Violation("sort_by", err.Error()))
+	case errors.Is(err, policy.ErrUnauthenticated), errors.Is(err, policy.ErrForbidden):
+		return policy.Status(err)
+	}
+	return rpcerror.Internal(err)
+}
+
+func (s *orderGRPCServer) CreateOrder(ctx context.Context, req *order.CreateOrderRequest) (*order.OrderResponse, error) {
+	createReq := converter.CreateOrderRequestFromProto(req)
+	if err := rpcerror.Validate(createReq); err != nil {
+		return nil, err
+	}
+
+	if err := policy.CanCreateOrder(policy.FromContext(ctx), createReq.UserID); err != nil {
+		return nil, policy.Status(err)
+	}
+
+	orderResp, err := s.orderService.CreateOrder(ctx, createReq)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &order.OrderResponse{Order: converter.OrderToProto(orderResp)}, nil
+}
+
+func (s *orderGRPCServer) GetOrderByID(ctx context.Context, req *order.GetOrderByIDRequest) (*order.OrderResponse, error) {
+	orderResp, err := s.orderService.GetOrderByID(uint(req.Id))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	if err := policy.CanReadOrder(policy.FromContext(ctx), orderResp.UserID); err != nil {
+		return nil, policy.Status(err)
+	}
+
+	return &order.OrderResponse{Order: converter.OrderToProto(orderResp)}, nil
+}
+
+func (s *orderGRPCServer) GetOrdersByUserID(ctx context.Context, req *order.GetOrdersByUserIDRequest) (*order.GetOrdersByUserIDResponse, error) {
+	if err := policy.CanReadOrder(policy.FromContext(ctx), uint(req.UserId)); err != nil {
+		return nil, policy.Status(err)
+	}
+
+	page, err := s.orderService.ListOrders(&model.ListOrdersRequest{
+		PageSize:  int(req.PageSize),
+		PageToken: req.PageToken,
+		UserID:    uint(req.UserId),
+	})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &order.GetOrdersByUserIDResponse{
+		Orders:        converter.OrdersToProto(page.Orders),
+		NextPageToken: page.NextPageToken,
+	}, nil
+}
+
+func (s *orderGRPCServer) GetAllOrders(ctx context.Context, req *order.GetAllOrdersRequest) (*order.GetAllOrdersResponse, error) {
+	// Customers may list their own orders by filtering on their user ID
+	principal := policy.FromContext(ctx)
+	authErr := policy.CanListOrders(principal)
+	if req.UserId != 0 {
+		authErr = policy.CanReadOrder(principal, uint(req.UserId))
+	}
+	if authErr != nil {
+		return nil, policy.Status(authErr)
+	}
+
+	listReq := &model.ListOrdersRequest{
+		PageSize:  int(req.PageSize),
+		PageToken: req.PageToken,
+		UserID:    uint(req.UserId),
+		Currency:  req.Currency,
+		MinPrice:  req.MinPrice,
+		MaxPrice:  req.MaxPrice,
+		SortBy:    req.SortBy,
+		SortOrder: req.SortOrder,
+	}
+	if req.Status != nil {
+		status, ok := converter.OrderStatusFromProto(req.GetStatus())
+		if !ok {
+			return nil, rpcerror.InvalidArgument(service.ErrInvalidStatus.Error(), rpcerror.Violation("status", "unknown order status"))
+		}
+		listReq.Status = status
+	}
+	if req.CreatedAfter != nil {
+		listReq.CreatedAfter = req.CreatedAfter.AsTime()
+	}
+	if req.CreatedBefore != nil {
+		listReq.CreatedBefore = req.CreatedBefore.AsTime()
+	}
+
+	page, err := s.orderService.ListOrders(listReq)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &order.GetAllOrdersResponse{
+		Orders:        converter.OrdersToProto(page.Orders),
+		NextPageToken: page.NextPageToken,
+	}, nil
+}
+
+func (s *orderGRPCServer) UpdateOrderStatus(ctx context.Context, req *order.UpdateOrderStatusRequest) (*order.OrderResponse, error) {
+	status, ok := converter.OrderStatusFromProto(req.Status)
+	if !ok {
+		return nil, rpcerror.InvalidArgument(service.ErrInvalidStatus.Error(), rpcerror.Violation("status", "unknown order status"))
+	}
+
+	existing, err := s.orderService.GetOrderByID(uint(req.Id))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	cancelling := status == model.OrderStatusCancelled
+	if err := policy.CanUpdateOrderStatus(policy.FromContext(ctx), existing.UserID, cancelling); err != nil {
+		return nil, policy.Status(err)
+	}
+
+	orderResp, err := s.orderService.UpdateOrderStatus(ctx, uint(req.Id), &model.UpdateOrderStatusRequest{
+		Status: status,
+		Reason: req.Reason,
+	})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &order.OrderResponse{Order: converter.OrderToProto(orderResp)}, nil
+}
+
+func (s *orderGRPCServer) GetOrderStatusHistory(ctx context.Context, req *order.GetOrderStatusHistoryRequest) (*order.GetOrderStatusHistoryResponse, error) {
+	existing, err := s.orderService.GetOrderByID(uint(req.Id))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	if err := policy.CanReadOrder(policy.FromContext(ctx), existing.UserID); err != nil {
+		return nil, policy.Status(err)
+	}
+
+	history, err := s.orderService.GetOrderStatusHistory(uint(req.Id))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &order.GetOrderStatusHistoryResponse{Changes: converter.StatusChangesToProto(history)}, nil
+}
+
+func (s *orderGRPCServer) DeleteOrder(ctx context.Context, req *order.DeleteOrderRequest) (*order.DeleteOrderResponse, error) {
+	if err := policy.CanDeleteOrder(policy.FromContext(ctx)); err != nil {
+		return nil, policy.Status(err)
+	}
+
+	err := s.orderService.DeleteOrder(uint(req.Id))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &order.DeleteOrderResponse{
+		Success: true,
+		Message: "Order deleted successfully",
+	}, nil
+}
+
+// runMigrate runs a migrate subcommand against the service database
+func runMigrate(cfg *config.Config, args []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	db, err := cfg.Database.Connect()
+	if err != nil {
+		return err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
+
+	migrator, err := migrate.New(sqlDB, migrations.FS, cfg.Migrations)
+	if err != nil {
+		return err
+	}
+	return migrate.Command(ctx, migrator, args, os.Stdout)
+}
+
 func main() {
-	// Initialize database configuration
-	dbConfig := config.NewDatabaseConfig()
+	// Load configuration from an optional YAML file, the environment and flags
+	cfg, args, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		logging.Fatal("Failed to load configuration", "error", err)
+	}
+
+	// Log JSON lines at the configured level
+	logging.Setup("order-service", cfg.Log)
+
+	// `order-service migrate up|down|status` manages the schema and exits
+	if len(args) > 0 {
+		if args[0] != "migrate" {
+			logging.Fatal("Unknown command", "command", args[0])
+		}
+		if err := runMigrate(cfg, args[1:]); err != nil {
+			logging.Fatal("Migration failed", "error", err)
+		}
+		return
+	}
+
+	// Coordinate the servers and shut them down gracefully on SIGTERM
+	manager := lifecycle.New(cfg.Shutdown)
+
+	// Export traces; closed last so spans from the shutdown are flushed
+	tracerProvider, err := tracing.Setup(context.Background(), "order-service", cfg.Tracing)
+	if err != nil {
+		logging.Fatal("Failed to set up tracing", "error", err)
+	}
+	manager.OnShutdownClose("tracer provider", tracerProvider)
 
 	// Connect to database
-	db, err := dbConfig.Connect()
+	db, err := cfg.Database.Connect()
 	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
+		logging.Fatal("Failed to connect to database", "error", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		logging.Fatal("Failed to get database connection pool", "error", err)
+	}
+	manager.OnShutdown("database connection pool", sqlDB.Close)
+	if err := metrics.InstrumentGORM(db, cfg.Database.DBName); err != nil {
+		logging.Fatal("Failed to instrument database", "error", err)
+	}
+	if err := tracing.InstrumentGORM(db, cfg.Database.DBName); err != nil {
+		logging.Fatal("Failed to instrument database", "error", err)
+	}
+
+	// Apply pending migrations unless a separate job runs them
+	if cfg.Migrations.SkipOnStart {
+		slog.Info("Skipping database migrations")
+	} else {
+		migrator, err := migrate.New(sqlDB, migrations.FS, cfg.Migrations)
+		if err != nil {
+			logging.Fatal("Failed to load database migrations", "error", err)
+		}
+		applied, err := migrator.Up(context.Background())
+		if err != nil {
+			logging.Fatal("Failed to run database migrations", "error", err)
+		}
+		slog.Info("Database migrations completed", "applied", len(applied))
 	}
 
-	// Initialize repository
+	// Initialize repositories
 	orderRepo := repository.NewOrderRepository(db)
+	idempotencyRepo := repository.NewIdempotencyRepository(db)
 
-	// Run database migrations
-	if err := orderRepo.Migrate(); err != nil {
-		log.Fatal("Failed to run database migrations:", err)
+	// Relay domain events from the outbox to the configured publisher
+	publisher, err := outbox.NewPublisher(&cfg.Outbox)
+	if err != nil {
+		logging.Fatal("Failed to create outbox publisher", "error", err)
 	}
-	log.Println("Database migrations completed successfully")
+	manager.Go("Outbox relay", outbox.NewRelay(db, publisher, &cfg.Outbox).Run)
+	if closer, ok := publisher.(io.Closer); ok {
+		manager.OnShutdownClose("outbox publisher", closer)
+	}
+	slog.Info("Publishing outbox events", "publisher", cfg.Outbox.Publisher)
 
 	// Initialize user client
-	userClient, err := service.NewUserClient("localhost:50051")
+	// Identities signed by the gateway are verified and re-signed for the user service
+	signer := policy.NewSigner(cfg.Identity)
+	userClient, err := service.NewUserClient(cfg.UserService.Addr(), &cfg.UserService, signer)
 	if err != nil {
-		log.Fatal("Failed to connect to user service:", err)
+		logging.Fatal("Failed to connect to user service", "error", err)
 	}
-	defer userClient.Close()
-	log.Println("Connected to user service via gRPC")
+	manager.OnShutdownClose("user service client", userClient)
+	slog.Info("User service gRPC client initialized", "addr", cfg.UserService.Addr())
 
 	// Initialize service
-	orderService := service.NewOrderService(orderRepo, userClient)
+	orderService := service.NewOrderService(orderRepo, idempotencyRepo, userClient, &cfg.Idempotency)
+	manager.Go("Idempotency key cleanup", orderService.PurgeIdempotencyKeys)
 
 	// Initialize handler
 	orderHandler := handler.NewOrderHandler(orderService)
 
+	// Readiness checks
+	checker := health.NewChecker("order-service", cfg.Health)
+	checker.Add("shutdown", manager.ReadyCheck)
+	checker.Add("database", sqlDB.PingContext)
+	// Orders can still be read while the user service is down
+	checker.AddNonCritical("user_service", userClient.Check)
+
+	// Setup gRPC server
+	grpcServer := grpc.NewServer(
+		grpc.StatsHandler(tracing.ServerHandler()),
+		grpc.ChainUnaryInterceptor(
+			logging.UnaryServerInterceptor(),
+			metrics.UnaryServerInterceptor(),
+			policy.UnaryServerInterceptor(signer),
+		),
+	)
+	order.RegisterOrderServiceServer(grpcServer, &orderGRPCServer{orderService: orderService})
+	reflection.Register(grpcServer)
+
+	// Serve grpc.health.v1 from the readiness checks
+	grpcHealth := health.NewGRPCServer(order.OrderService_ServiceDesc.ServiceName)
+	healthpb.RegisterHealthServer(grpcServer, grpcHealth)
+	manager.Go("gRPC health watcher", func(ctx context.Context) {
+		checker.WatchGRPC(ctx, grpcHealth, order.OrderService_ServiceDesc.ServiceName)
+	})
+	manager.OnNotReady(grpcHealth.Shutdown)
+	manager.AddGRPCServer("gRPC server", cfg.GRPCAddr(), grpcServer)
+
 	// Setup Gin router
-	r := gin.Default()
+	r := gin.New()
+
+	// Record request metrics by route
+	r.Use(metrics.Middleware())
+
+	// Continue the trace started by the gateway
+	r.Use(tracing.Middleware("order-service"))
+
+	// Log requests with the request ID forwarded by the gateway
+	r.Use(logging.Middleware(), logging.Recovery())
 
 	// Add CORS middleware
 	r.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, Idempotency-Key")
 		
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -82,17 +435,21 @@ func main() {
 		c.Next()
 	})
 
+	// Read the identity signed by the API gateway
+	r.Use(policy.Middleware(signer))
+
 	// API routes
 	api := r.Group("/api/v1")
 	{
 		// Order routes
-		orders := api.Group("/orders")
+		orders := api.Group("/orders", policy.RequireAuthenticated())
 		{
 			orders.POST("", orderHandler.CreateOrder)
 			orders.GET("", orderHandler.GetAllOrders)
 			orders.GET("/:id", orderHandler.GetOrderByID)
 			orders.GET("/user/:user_id", orderHandler.GetOrdersByUserID)
 			orders.PUT("/:id/status", orderHandler.UpdateOrderStatus)
+			orders.GET("/:id/history", orderHandler.GetOrderStatusHistory)
 			orders.DELETE("/:id", orderHandler.DeleteOrder)
 		}
 	}
@@ -100,19 +457,30 @@ func main() {
 	// Swagger documentation
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
-	// Health check endpoint
-	r.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{
-			"status": "ok",
-			"service": "order-service",
-		})
+	// Prometheus metrics, including the user service circuit breaker state
+	r.GET("/metrics", metrics.Handler())
+
+	// Health check endpoints
+	r.GET("/livez", checker.LivenessHandler())
+	r.GET("/readyz", checker.ReadinessHandler())
+	// Kept for probes and clients that predate /readyz
+	r.GET("/health", checker.ReadinessHandler())
+
+	manager.AddHTTPServer("HTTP server", &http.Server{
+		Addr:              cfg.HTTPAddr(),
+		Handler:           r,
+		ReadHeaderTimeout: 10 * time.Second,
 	})
 
-	// Start server
-	log.Println("Order service starting on port 8081...")
-	log.Println("Swagger documentation available at: http://localhost:8081/swagger/index.html")
+	// Start servers and block until shutdown
+	slog.Info("Order service starting",
+		"http_port", cfg.HTTPPort,
+		"grpc_port", cfg.GRPCPort,
+		"swagger", fmt.Sprintf("http://localhost:%d/swagger/index.html", cfg.HTTPPort),
+	)
 	
-	if err := r.Run(":8081"); err != nil {
-		log.Fatal("Failed to start server:", err)
+	if err := manager.Run(context.Background()); err != nil {
+		logging.Fatal("Server failed", "error", err)
 	}
+	slog.Info("Shutdown complete")
 } 
\ No newline at end of file
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"bweng/internal/order/model"
+	"bweng/internal/order/service"
+	"bweng/internal/rpcerror"
+)
+
+func TestToStatusInvalidTransition(t *testing.T) {
+	// The service wraps the error with the attempted move
+	err := toStatus(fmt.Errorf("%w: %s -> %s", service.ErrInvalidStatusTransition, model.OrderStatusShipped, model.OrderStatusCancelled))
+	if code := status.Code(err); code != codes.FailedPrecondition {
+		t.Errorf("code = %s, want FailedPrecondition", code)
+	}
+	if reason := rpcerror.Reason(err); reason != rpcerror.ReasonInvalidStatusTransition {
+		t.Errorf("reason = %q, want %q", reason, rpcerror.ReasonInvalidStatusTransition)
+	}
+
+	if code := status.Code(toStatus(service.ErrInvalidStatus)); code != codes.InvalidArgument {
+		t.Errorf("unknown status code = %s, want InvalidArgument", code)
+	}
+}
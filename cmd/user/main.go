@@ -2,19 +2,41 @@ package main
 
 import (
 	"context"
-	"log"
-	"net"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
 
 	_ "bweng/docs"
 	"bweng/api/proto/user"
+	"bweng/internal/converter"
+	"bweng/internal/health"
+	"bweng/internal/lifecycle"
+	"bweng/internal/logging"
+	"bweng/internal/metrics"
+	"bweng/internal/migrate"
+	"bweng/internal/pagination"
+	"bweng/internal/outbox"
+	"bweng/internal/policy"
+	"bweng/internal/rpcerror"
+	"bweng/internal/tracing"
 	"bweng/internal/user/config"
 	"bweng/internal/user/handler"
+	"bweng/internal/user/migrations"
 	"bweng/internal/user/model"
 	"bweng/internal/user/repository"
 	"bweng/internal/user/service"
@@ -43,6 +65,7 @@ import (
 type userGRPCServer struct {
 	user.UnimplementedUserServiceServer
 	userService *service.UserService
+	authService *service.AuthService
 }
 
 func (s *userGRPCServer) CreateUser(ctx context.Context, req *user.CreateUserRequest) (*user.UserResponse, error) {
@@ -51,126 +74,117 @@ func (s *userGRPCServer) CreateUser(ctx context.Context, req *user.CreateUserReq
 		Email:     req.Email,
 		FirstName: req.FirstName,
 		LastName:  req.LastName,
+		Password:  req.Password,
+	}
+	if err := rpcerror.Validate(createReq); err != nil {
+		return nil, err
 	}
 
 	userResp, err := s.userService.CreateUser(createReq)
 	if err != nil {
-		return &user.UserResponse{Error: err.Error()}, nil
+		return nil, toStatus(err)
 	}
 
-	return &user.UserResponse{
-		User: &user.User{
-			Id:        uint64(userResp.ID),
-			Username:  userResp.Username,
-			Email:     userResp.Email,
-			FirstName: userResp.FirstName,
-			LastName:  userResp.LastName,
-		},
-	}, nil
+	return &user.UserResponse{User: converter.UserToProto(userResp)}, nil
 }
 
 func (s *userGRPCServer) GetUserByID(ctx context.Context, req *user.GetUserByIDRequest) (*user.UserResponse, error) {
-	userResp, err := s.userService.GetUserByID(uint(req.Id))
+	if err := policy.CanReadUser(policy.FromContext(ctx), uint(req.Id)); err != nil {
+		return nil, policy.Status(err)
+	}
+
+	userResp, err := s.userService.GetUserByID(ctx, uint(req.Id))
 	if err != nil {
-		return &user.UserResponse{Error: err.Error()}, nil
+		return nil, toStatus(err)
 	}
 
-	return &user.UserResponse{
-		User: &user.User{
-			Id:        uint64(userResp.ID),
-			Username:  userResp.Username,
-			Email:     userResp.Email,
-			FirstName: userResp.FirstName,
-			LastName:  userResp.LastName,
-		},
-	}, nil
+	return &user.UserResponse{User: converter.UserToProto(userResp)}, nil
 }
 
 func (s *userGRPCServer) GetUserByEmail(ctx context.Context, req *user.GetUserByEmailRequest) (*user.UserResponse, error) {
-	userResp, err := s.userService.GetUserByEmail(req.Email)
+	if err := policy.CanLookupUserByEmail(policy.FromContext(ctx)); err != nil {
+		return nil, policy.Status(err)
+	}
+
+	userResp, err := s.userService.GetUserByEmail(ctx, req.Email)
 	if err != nil {
-		return &user.UserResponse{Error: err.Error()}, nil
+		return nil, toStatus(err)
 	}
 
-	return &user.UserResponse{
-		User: &user.User{
-			Id:        uint64(userResp.ID),
-			Username:  userResp.Username,
-			Email:     userResp.Email,
-			FirstName: userResp.FirstName,
-			LastName:  userResp.LastName,
-		},
-	}, nil
+	return &user.UserResponse{User: converter.UserToProto(userResp)}, nil
 }
 
 func (s *userGRPCServer) GetUserByUsername(ctx context.Context, req *user.GetUserByUsernameRequest) (*user.UserResponse, error) {
-	userResp, err := s.userService.GetUserByUsername(req.Username)
+	if err := policy.CanLookupUserByUsername(policy.FromContext(ctx), req.Username); err != nil {
+		return nil, policy.Status(err)
+	}
+
+	userResp, err := s.userService.GetUserByUsername(ctx, req.Username)
 	if err != nil {
-		return &user.UserResponse{Error: err.Error()}, nil
+		return nil, toStatus(err)
 	}
 
-	return &user.UserResponse{
-		User: &user.User{
-			Id:        uint64(userResp.ID),
-			Username:  userResp.Username,
-			Email:     userResp.Email,
-			FirstName: userResp.FirstName,
-			LastName:  userResp.LastName,
-		},
-	}, nil
+	return &user.UserResponse{User: converter.UserToProto(userResp)}, nil
 }
 
 func (s *userGRPCServer) GetAllUsers(ctx context.Context, req *user.GetAllUsersRequest) (*user.GetAllUsersResponse, error) {
-	users, err := s.userService.GetAllUsers()
-	if err != nil {
-		return &user.GetAllUsersResponse{}, err
+	if err := policy.CanListUsers(policy.FromContext(ctx)); err != nil {
+		return nil, policy.Status(err)
 	}
 
-	var userList []*user.User
-	for _, u := range users {
-		userList = append(userList, &user.User{
-			Id:        uint64(u.ID),
-			Username:  u.Username,
-			Email:     u.Email,
-			FirstName: u.FirstName,
-			LastName:  u.LastName,
-		})
+	page, err := s.userService.ListUsers(&model.ListUsersRequest{
+		PageSize:    int(req.PageSize),
+		PageToken:   req.PageToken,
+		NamePrefix:  req.NamePrefix,
+		EmailPrefix: req.EmailPrefix,
+		SortBy:      req.SortBy,
+		SortOrder:   req.SortOrder,
+	})
+	if err != nil {
+		return nil, toStatus(err)
 	}
 
-	return &user.GetAllUsersResponse{Users: userList}, nil
+	return &user.GetAllUsersResponse{Users: converter.UsersToProto(page.Users), NextPageToken: page.NextPageToken}, nil
 }
 
 func (s *userGRPCServer) UpdateUser(ctx context.Context, req *user.UpdateUserRequest) (*user.UserResponse, error) {
+	principal := policy.FromContext(ctx)
+	if err := policy.CanUpdateUser(principal, uint(req.Id)); err != nil {
+		return nil, policy.Status(err)
+	}
+	if req.Role != "" {
+		if err := policy.CanAssignRole(principal); err != nil {
+			return nil, policy.Status(err)
+		}
+	}
+
 	updateReq := &model.UpdateUserRequest{
 		Username:  req.Username,
 		Email:     req.Email,
 		FirstName: req.FirstName,
 		LastName:  req.LastName,
+		Role:      req.Role,
+	}
+	if err := rpcerror.Validate(updateReq); err != nil {
+		return nil, err
 	}
 
 	userResp, err := s.userService.UpdateUser(uint(req.Id), updateReq)
 	if err != nil {
-		return &user.UserResponse{Error: err.Error()}, nil
+		return nil, toStatus(err)
 	}
 
-	return &user.UserResponse{
-		User: &user.User{
-			Id:        uint64(userResp.ID),
-			Username:  userResp.Username,
-			Email:     userResp.Email,
-			FirstName: userResp.FirstName,
-			LastName:  userResp.LastName,
-		},
-	}, nil
+	return &user.UserResponse{User: converter.UserToProto(userResp)}, nil
 }
 
 func (s *userGRPCServer) DeleteUser(ctx context.Context, req *user.DeleteUserRequest) (*user.DeleteUserResponse, error) {
+	if err := policy.CanDeleteUser(policy.FromContext(ctx)); err != nil {
+		return nil, policy.Status(err)
+	}
+
 	err := s.userService.DeleteUser(uint(req.Id))
 	if err != nil {
-		return &user.DeleteUserResponse{
-			Success: false,
-			Message: err.Error(),
-		}, nil
+		return nil, toStatus(err)
 	}
 
 	return &user.DeleteUserResponse{
@@ -179,50 +193,216 @@ func (s *userGRPCServer) DeleteUser(ctx context.Context, req *user.DeleteUserReq
 	}, nil
 }
 
+func (s *userGRPCServer) Login(ctx context.Context, req *user.LoginRequest) (*user.TokenResponse, error) {
+	tokens, err := s.authService.Login(ctx, &model.LoginRequest{
+		Login:    req.Login,
+		Password: req.Password,
+	})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return converter.TokensToProto(tokens), nil
+}
+
+func (s *userGRPCServer) RefreshToken(ctx context.Context, req *user.RefreshTokenRequest) (*user.TokenResponse, error) {
+	tokens, err := s.authService.Refresh(ctx, &model.RefreshTokenRequest{
+		RefreshToken: req.RefreshToken,
+	})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return converter.TokensToProto(tokens), nil
+}
+
+func (s *userGRPCServer) Logout(ctx context.Context, req *user.LogoutRequest) (*user.LogoutResponse, error) {
+	err := s.authService.Logout(ctx, &model.LogoutRequest{
+		RefreshToken: req.RefreshToken,
+		All:          req.All,
+	})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &user.LogoutResponse{
+		Success: true,
+		Message: "Logged out successfully",
+	}, nil
+}
+
+// toStatus converts a user or auth service error to a gRPC status error
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, service.ErrUserNotFound):
+		return rpcerror.New(codes.NotFound, rpcerror.ReasonUserNotFound, err.Error(), nil)
+	case errors.Is(err, service.ErrUserExists):
+		return rpcerror.New(codes.AlreadyExists, rpcerror.ReasonUserAlreadyExists, err.Error(), nil)
+	case errors.Is(err, service.ErrInvalidCredentials):
+		return rpcerror.New(codes.Unauthenticated, rpcerror.ReasonInvalidCredentials, err.Error(), nil)
+	case errors.Is(err, service.ErrInvalidToken), errors.Is(err, service.ErrTokenRevoked):
+		return rpcerror.New(codes.Unauthenticated, rpcerror.ReasonInvalidToken, err.Error(), nil)
+	case errors.Is(err, pagination.ErrInvalidPageToken):
+		return rpcerror.InvalidArgument(err.Error(), rpcerror.Violation("page_token", err.Error()))
+	case errors.Is(err, pagination.ErrInvalidSort):
+		return rpcerror.InvalidArgument(err.Error(), rpcerror.Violation("sort_by", err.Error()))
+	case errors.Is(err, policy.ErrUnauthenticated), errors.Is(err, policy.ErrForbidden):
+		return policy.Status(err)
+	}
+	return rpcerror.Internal(err)
+}
+
+// runMigrate runs a migrate subcommand against the service database
+func runMigrate(cfg *config.Config, args []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	db, err := cfg.Database.Connect()
+	if err != nil {
+		return err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
+
+	migrator, err := migrate.New(sqlDB, migrations.FS, cfg.Migrations)
+	if err != nil {
+		return err
+	}
+	return migrate.Command(ctx, migrator, args, os.Stdout)
+}
+
 func main() {
-	// Initialize database configuration
-	dbConfig := config.NewDatabaseConfig()
+	// Load configuration from an optional YAML file, the environment and flags
+	cfg, args, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		logging.Fatal("Failed to load configuration", "error", err)
+	}
+
+	// Log JSON lines at the configured level
+	logging.Setup("user-service", cfg.Log)
+
+	// `user-service migrate up|down|status` manages the schema and exits
+	if len(args) > 0 {
+		if args[0] != "migrate" {
+			logging.Fatal("Unknown command", "command", args[0])
+		}
+		if err := runMigrate(cfg, args[1:]); err != nil {
+			logging.Fatal("Migration failed", "error", err)
+		}
+		return
+	}
+
+	// Coordinate the servers and shut them down gracefully on SIGTERM
+	manager := lifecycle.New(cfg.Shutdown)
+
+	// Export traces; closed last so spans from the shutdown are flushed
+	tracerProvider, err := tracing.Setup(context.Background(), "user-service", cfg.Tracing)
+	if err != nil {
+		logging.Fatal("Failed to set up tracing", "error", err)
+	}
+	manager.OnShutdownClose("tracer provider", tracerProvider)
 
 	// Connect to database
-	db, err := dbConfig.Connect()
+	db, err := cfg.Database.Connect()
+	if err != nil {
+		logging.Fatal("Failed to connect to database", "error", err)
+	}
+	sqlDB, err := db.DB()
 	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
+		logging.Fatal("Failed to get database connection pool", "error", err)
+	}
+	manager.OnShutdown("database connection pool", sqlDB.Close)
+	if err := metrics.InstrumentGORM(db, cfg.Database.DBName); err != nil {
+		logging.Fatal("Failed to instrument database", "error", err)
+	}
+	if err := tracing.InstrumentGORM(db, cfg.Database.DBName); err != nil {
+		logging.Fatal("Failed to instrument database", "error", err)
+	}
+
+	// Apply pending migrations unless a separate job runs them
+	if cfg.Migrations.SkipOnStart {
+		slog.Info("Skipping database migrations")
+	} else {
+		migrator, err := migrate.New(sqlDB, migrations.FS, cfg.Migrations)
+		if err != nil {
+			logging.Fatal("Failed to load database migrations", "error", err)
+		}
+		applied, err := migrator.Up(context.Background())
+		if err != nil {
+			logging.Fatal("Failed to run database migrations", "error", err)
+		}
+		slog.Info("Database migrations completed", "applied", len(applied))
 	}
 
-	// Initialize repository
+	// Initialize repositories
 	userRepo := repository.NewUserRepository(db)
+	tokenRepo := repository.NewTokenRepository(db)
 
-	// Run database migrations
-	if err := userRepo.Migrate(); err != nil {
-		log.Fatal("Failed to run database migrations:", err)
+	// Relay domain events from the outbox to the configured publisher
+	publisher, err := outbox.NewPublisher(&cfg.Outbox)
+	if err != nil {
+		logging.Fatal("Failed to create outbox publisher", "error", err)
 	}
-	log.Println("Database migrations completed successfully")
+	manager.Go("Outbox relay", outbox.NewRelay(db, publisher, &cfg.Outbox).Run)
+	if closer, ok := publisher.(io.Closer); ok {
+		manager.OnShutdownClose("outbox publisher", closer)
+	}
+	slog.Info("Publishing outbox events", "publisher", cfg.Outbox.Publisher)
 
-	// Initialize service
+	// Initialize services
 	userService := service.NewUserService(userRepo)
+	authService := service.NewAuthService(userRepo, tokenRepo, &cfg.Auth)
 
-	// Initialize handler
+	// Initialize handlers
 	userHandler := handler.NewUserHandler(userService)
+	authHandler := handler.NewAuthHandler(authService)
+
+	// Readiness checks
+	checker := health.NewChecker("user-service", cfg.Health)
+	checker.Add("shutdown", manager.ReadyCheck)
+	checker.Add("database", sqlDB.PingContext)
+
+	// Identities are only trusted when signed by the gateway or a service
+	signer := policy.NewSigner(cfg.Identity)
+
+	// Setup gRPC server
+	grpcServer := grpc.NewServer(
+		grpc.StatsHandler(tracing.ServerHandler()),
+		grpc.ChainUnaryInterceptor(
+			logging.UnaryServerInterceptor(),
+			metrics.UnaryServerInterceptor(),
+			policy.UnaryServerInterceptor(signer),
+		),
+	)
+	user.RegisterUserServiceServer(grpcServer, &userGRPCServer{userService: userService, authService: authService})
+	reflection.Register(grpcServer)
+
+	// Serve grpc.health.v1 from the readiness checks
+	grpcHealth := health.NewGRPCServer(user.UserService_ServiceDesc.ServiceName)
+	healthpb.RegisterHealthServer(grpcServer, grpcHealth)
+	manager.Go("gRPC health watcher", func(ctx context.Context) {
+		checker.WatchGRPC(ctx, grpcHealth, user.UserService_ServiceDesc.ServiceName)
+	})
+	manager.OnNotReady(grpcHealth.Shutdown)
+	manager.AddGRPCServer("gRPC server", cfg.GRPCAddr(), grpcServer)
 
-	// Start gRPC server in a goroutine
-	go func() {
-		lis, err := net.Listen("tcp", ":50051")
-		if err != nil {
-			log.Fatalf("Failed to listen for gRPC: %v", err)
-		}
+	// Setup Gin router
+	r := gin.New()
 
-		s := grpc.NewServer()
-		user.RegisterUserServiceServer(s, &userGRPCServer{userService: userService})
-		reflection.Register(s)
+	// Record request metrics by route
+	r.Use(metrics.Middleware())
 
-		log.Println("gRPC server starting on port 50051...")
-		if err := s.Serve(lis); err != nil {
-			log.Fatalf("Failed to serve gRPC: %v", err)
-		}
-	}()
+	// Continue the trace started by the gateway
+	r.Use(tracing.Middleware("user-service"))
 
-	// Setup Gin router
-	r := gin.Default()
+	// Log requests with the request ID forwarded by the gateway
+	r.Use(logging.Middleware(), logging.Recovery())
 
 	// Add CORS middleware
 	r.Use(func(c *gin.Context) {
@@ -238,39 +418,63 @@ func main() {
 		c.Next()
 	})
 
+	// Read the identity signed by the API gateway
+	r.Use(policy.Middleware(signer))
+
 	// API routes
 	api := r.Group("/api/v1")
 	{
+		// Auth routes
+		auth := api.Group("/auth")
+		{
+			auth.POST("/login", authHandler.Login)
+			auth.POST("/refresh", authHandler.Refresh)
+			auth.POST("/logout", authHandler.Logout)
+		}
+
 		// User routes
 		users := api.Group("/users")
 		{
+			// Registration is public, everything else requires an authenticated caller
 			users.POST("", userHandler.CreateUser)
-			users.GET("", userHandler.GetAllUsers)
-			users.GET("/:id", userHandler.GetUserByID)
-			users.GET("/email", userHandler.GetUserByEmail)
-			users.GET("/username", userHandler.GetUserByUsername)
-			users.PUT("/:id", userHandler.UpdateUser)
-			users.DELETE("/:id", userHandler.DeleteUser)
+
+			protected := users.Group("", policy.RequireAuthenticated())
+			protected.GET("", userHandler.GetAllUsers)
+			protected.GET("/:id", userHandler.GetUserByID)
+			protected.GET("/email", userHandler.GetUserByEmail)
+			protected.GET("/username", userHandler.GetUserByUsername)
+			protected.PUT("/:id", userHandler.UpdateUser)
+			protected.DELETE("/:id", userHandler.DeleteUser)
 		}
 	}
 
 	// Swagger documentation
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
-	// Health check endpoint
-	r.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{
-			"status": "ok",
-			"service": "user-service",
-		})
+	// Prometheus metrics
+	r.GET("/metrics", metrics.Handler())
+
+	// Health check endpoints
+	r.GET("/livez", checker.LivenessHandler())
+	r.GET("/readyz", checker.ReadinessHandler())
+	// Kept for probes and clients that predate /readyz
+	r.GET("/health", checker.ReadinessHandler())
+
+	manager.AddHTTPServer("HTTP server", &http.Server{
+		Addr:              cfg.HTTPAddr(),
+		Handler:           r,
+		ReadHeaderTimeout: 10 * time.Second,
 	})
 
-	// Start HTTP server
-	log.Println("User service starting on port 8080...")
-	log.Println("Swagger documentation available at: http://localhost:8080/swagger/index.html")
-	log.Println("gRPC server available at: localhost:50051")
+	// Start servers and block until shutdown
+	slog.Info("User service starting",
+		"http_port", cfg.HTTPPort,
+		"grpc_port", cfg.GRPCPort,
+		"swagger", fmt.Sprintf("http://localhost:%d/swagger/index.html", cfg.HTTPPort),
+	)
 	
-	if err := r.Run(":8080"); err != nil {
-		log.Fatal("Failed to start server:", err)
+	if err := manager.Run(context.Background()); err != nil {
+		logging.Fatal("Server failed", "error", err)
 	}
+	slog.Info("Shutdown complete")
 } 
\ No newline at end of file
@@ -0,0 +1,474 @@
+package main
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Trusted headers set by the gateway after verifying a token.
+// Incoming values are always stripped so clients cannot spoof them.
+const (
+	HeaderUserID    = "X-User-ID"
+	HeaderUsername  = "X-Username"
+	HeaderUserRoles = "X-User-Roles"
+)
+
+// developmentSecret matches the user service's fallback signing secret
+const developmentSecret = "bweng-development-secret"
+
+var (
+	ErrMissingToken = errors.New("missing bearer token")
+	ErrUnknownKey   = errors.New("no verification key for token")
+)
+
+// AuthConfig represents the token verification configuration
+type AuthConfig struct {
+	HMACSecret    string
+	PublicKeyFile string
+	JWKSFile      string
+	JWKSURL       string
+	JWKSRefresh   time.Duration
+	Issuer        string
+}
+
+// NewAuthConfigFromEnv loads the token verification configuration from the environment
+func NewAuthConfigFromEnv() *AuthConfig {
+	cfg := &AuthConfig{
+		HMACSecret:    os.Getenv("JWT_SECRET"),
+		PublicKeyFile: os.Getenv("JWT_PUBLIC_KEY_FILE"),
+		JWKSFile:      os.Getenv("JWT_JWKS_FILE"),
+		JWKSURL:       os.Getenv("JWT_JWKS_URL"),
+		JWKSRefresh:   15 * time.Minute,
+		Issuer:        os.Getenv("JWT_ISSUER"),
+	}
+
+	if v := os.Getenv("JWT_JWKS_REFRESH"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.JWKSRefresh = d
+		} else {
+			slog.Warn("Invalid JWT_JWKS_REFRESH", "value", v, "error", err)
+		}
+	}
+
+	if cfg.HMACSecret == "" && cfg.PublicKeyFile == "" && cfg.JWKSFile == "" && cfg.JWKSURL == "" {
+		slog.Warn("No JWT verification keys configured, using insecure development secret")
+		cfg.HMACSecret = developmentSecret
+	}
+
+	return cfg
+}
+
+// Principal represents the verified identity of a request
+type Principal struct {
+	Subject  string
+	Username string
+	Roles    []string
+}
+
+// Authenticator verifies bearer tokens against the configured keys
+type Authenticator struct {
+	config     *AuthConfig
+	hmacSecret []byte
+	httpClient *http.Client
+
+	mu          sync.RWMutex
+	rsaKeys     map[string]*rsa.PublicKey
+	lastFetched time.Time
+}
+
+// NewAuthenticator creates a new authenticator and loads its keys
+func NewAuthenticator(cfg *AuthConfig) (*Authenticator, error) {
+	a := &Authenticator{
+		config:     cfg,
+		rsaKeys:    make(map[string]*rsa.PublicKey),
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+	}
+	if cfg.HMACSecret != "" {
+		a.hmacSecret = []byte(cfg.HMACSecret)
+	}
+
+	if cfg.PublicKeyFile != "" {
+		data, err := os.ReadFile(cfg.PublicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read public key file: %w", err)
+		}
+		key, err := parseRSAPublicKeyPEM(data)
+		if err != nil {
+			return nil, err
+		}
+		a.rsaKeys[""] = key
+	}
+
+	if cfg.JWKSFile != "" {
+		data, err := os.ReadFile(cfg.JWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWKS file: %w", err)
+		}
+		keys, err := parseJWKS(data)
+		if err != nil {
+			return nil, err
+		}
+		for kid, key := range keys {
+			a.rsaKeys[kid] = key
+		}
+	}
+
+	if cfg.JWKSURL != "" {
+		if err := a.refreshJWKS(); err != nil {
+			// The endpoint may come up after the gateway, keys are fetched again on demand
+			slog.Warn("Failed to fetch JWKS", "url", cfg.JWKSURL, "error", err)
+		}
+	}
+
+	return a, nil
+}
+
+// Authenticate verifies the bearer token of a request and returns its principal
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	tokenString, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || tokenString == "" {
+		return nil, ErrMissingToken
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(a.validMethods()),
+		jwt.WithExpirationRequired(),
+	}
+	if a.config.Issuer != "" {
+		options = append(options, jwt.WithIssuer(a.config.Issuer))
+	}
+
+	claims := jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(tokenString, claims, a.keyFunc, options...); err != nil {
+		return nil, err
+	}
+
+	// Refresh tokens from the user service must not be usable as access tokens
+	if typ, ok := claims["typ"].(string); ok && typ != "access" {
+		return nil, errors.New("token is not an access token")
+	}
+
+	subject, err := claims.GetSubject()
+	if err != nil || subject == "" {
+		return nil, errors.New("token has no subject")
+	}
+
+	principal := &Principal{Subject: subject, Roles: rolesFromClaims(claims)}
+	if username, ok := claims["username"].(string); ok {
+		principal.Username = username
+	}
+	return principal, nil
+}
+
+// validMethods returns the signing algorithms the configured keys can verify
+func (a *Authenticator) validMethods() []string {
+	var methods []string
+	if a.hmacSecret != nil {
+		methods = append(methods, "HS256", "HS384", "HS512")
+	}
+	if len(a.rsaKeys) > 0 || a.config.JWKSURL != "" {
+		methods = append(methods, "RS256", "RS384", "RS512")
+	}
+	return methods
+}
+
+// keyFunc resolves the verification key for a parsed token
+func (a *Authenticator) keyFunc(token *jwt.Token) (interface{}, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if a.hmacSecret == nil {
+			return nil, ErrUnknownKey
+		}
+		return a.hmacSecret, nil
+	case *jwt.SigningMethodRSA:
+		kid, _ := token.Header["kid"].(string)
+		if key := a.rsaKey(kid); key != nil {
+			return key, nil
+		}
+		// The issuer may have rotated keys since the last fetch
+		if a.config.JWKSURL != "" && a.canRefetch() {
+			if err := a.refreshJWKS(); err != nil {
+				slog.Warn("Failed to refresh JWKS", "error", err)
+			}
+			if key := a.rsaKey(kid); key != nil {
+				return key, nil
+			}
+		}
+		return nil, ErrUnknownKey
+	default:
+		return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+	}
+}
+
+// rsaKey looks up an RSA key by key ID, falling back to a single unnamed key
+func (a *Authenticator) rsaKey(kid string) *rsa.PublicKey {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if key, ok := a.rsaKeys[kid]; ok {
+		return key
+	}
+	if len(a.rsaKeys) == 1 && kid == "" {
+		for _, key := range a.rsaKeys {
+			return key
+		}
+	}
+	return nil
+}
+
+// canRefetch limits JWKS refetches triggered by unknown key IDs
+func (a *Authenticator) canRefetch() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return time.Since(a.lastFetched) > 30*time.Second
+}
+
+// refreshJWKS fetches the JWKS endpoint and merges its keys
+func (a *Authenticator) refreshJWKS() error {
+	resp, err := a.httpClient.Get(a.config.JWKSURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for kid, key := range keys {
+		a.rsaKeys[kid] = key
+	}
+	a.lastFetched = time.Now()
+	return nil
+}
+
+// StartJWKSRefresh periodically refetches the JWKS endpoint until stop is closed
+func (a *Authenticator) StartJWKSRefresh(stop <-chan struct{}) {
+	if a.config.JWKSURL == "" || a.config.JWKSRefresh <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(a.config.JWKSRefresh)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := a.refreshJWKS(); err != nil {
+					slog.Warn("Failed to refresh JWKS", "error", err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// rolesFromClaims reads roles from either a "roles" array or a single "role" claim
+func rolesFromClaims(claims jwt.MapClaims) []string {
+	var roles []string
+	switch v := claims["roles"].(type) {
+	case []interface{}:
+		for _, r := range v {
+			if s, ok := r.(string); ok && s != "" {
+				roles = append(roles, s)
+			}
+		}
+	case string:
+		for _, r := range strings.Split(v, ",") {
+			if r = strings.TrimSpace(r); r != "" {
+				roles = append(roles, r)
+			}
+		}
+	}
+	if role, ok := claims["role"].(string); ok && role != "" {
+		roles = append(roles, role)
+	}
+	return roles
+}
+
+// jwk represents a single JSON Web Key
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// parseJWKS parses the RSA signing keys of a JSON Web Key Set
+func parseJWKS(data []byte) (map[string]*rsa.PublicKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("invalid JWKS: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus for key %q: %w", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exponent for key %q: %w", k.Kid, err)
+		}
+
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	return keys, nil
+}
+
+// parseRSAPublicKeyPEM parses a PEM encoded RSA public key or certificate
+func parseRSAPublicKeyPEM(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("invalid PEM public key")
+	}
+
+	if block.Type == "CERTIFICATE" {
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		key, ok := cert.PublicKey.(*rsa.PublicKey)
+		if !ok {
+			return nil, errors.New("certificate does not contain an RSA key")
+		}
+		return key, nil
+	}
+
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("public key is not an RSA key")
+	}
+	return key, nil
+}
+
+// isPublicPath reports whether a request matches one of the given public path rules.
+// Rules take the form "[METHOD ]/path", where a trailing "/*" matches any sub-path.
+func isPublicPath(rules []string, method, path string) bool {
+	for _, rule := range rules {
+		ruleMethod, rulePath, found := strings.Cut(rule, " ")
+		if !found {
+			ruleMethod, rulePath = "", rule
+		}
+		if ruleMethod != "" && !strings.EqualFold(ruleMethod, method) {
+			continue
+		}
+
+		if prefix, ok := strings.CutSuffix(rulePath, "/*"); ok {
+			if path == prefix || strings.HasPrefix(path, prefix+"/") {
+				return true
+			}
+			continue
+		}
+		if path == rulePath {
+			return true
+		}
+	}
+	return false
+}
+
+// AuthMiddleware verifies bearer tokens for routed requests and forwards the
+// verified identity to upstream services as headers signed by signer
+func AuthMiddleware(auth *Authenticator, signer *IdentitySigner) RouteMiddleware {
+	return func(c *gin.Context, service *ServiceConfig) {
+		if isPublicPath(service.PublicPaths, c.Request.Method, c.Request.URL.Path) {
+			return
+		}
+
+		principal, err := auth.Authenticate(c.Request)
+		if err != nil {
+			c.Header("WWW-Authenticate", `Bearer realm="bweng"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error":  "Unauthorized",
+				"detail": err.Error(),
+			})
+			return
+		}
+
+		roles := strings.Join(principal.Roles, ",")
+		c.Request.Header.Set(HeaderUserID, principal.Subject)
+		if principal.Username != "" {
+			c.Request.Header.Set(HeaderUsername, principal.Username)
+		}
+		if roles != "" {
+			c.Request.Header.Set(HeaderUserRoles, roles)
+		}
+		c.Request.Header.Set(HeaderIdentitySignature, signer.Sign(principal.Subject, principal.Username, roles))
+		c.Set("principal", principal)
+	}
+}
+
+// RoleAdmin is the role allowed to inspect the gateway's routes and upstreams
+const RoleAdmin = "admin"
+
+// RequireRole only lets requests through with a verified bearer token
+// carrying role. It guards the gateway's own endpoints that expose internal
+// upstream addresses and route configuration.
+func RequireRole(auth *Authenticator, role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, err := auth.Authenticate(c.Request)
+		if err != nil {
+			c.Header("WWW-Authenticate", `Bearer realm="bweng"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error":  "Unauthorized",
+				"detail": err.Error(),
+			})
+			return
+		}
+		if !slices.Contains(principal.Roles, role) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "Forbidden",
+			})
+			return
+		}
+		c.Set("principal", principal)
+	}
+}
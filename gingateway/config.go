@@ -0,0 +1,391 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/netip"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// GatewayConfig holds the gateway listen port and upstream service addresses.
+// The upstream addresses are used by the built-in routes only.
+type GatewayConfig struct {
+	Port            int    `yaml:"port"`
+	UserServiceURL  string `yaml:"user_service_url"`
+	OrderServiceURL string `yaml:"order_service_url"`
+	// ShutdownTimeout bounds draining in-flight requests on shutdown
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	// DrainDelay is how long the health check fails before the server stops
+	DrainDelay time.Duration `yaml:"shutdown_drain_delay"`
+	// HealthCheckTimeout bounds each upstream readiness check
+	HealthCheckTimeout time.Duration `yaml:"health_check_timeout"`
+	// TracingExporter is none, otlp or file
+	TracingExporter string `yaml:"tracing_exporter"`
+	// OTLPEndpoint is the collector URL, e.g. http://otel-collector:4317
+	OTLPEndpoint string `yaml:"otlp_endpoint"`
+	// TracingFile is the file the file exporter appends spans to
+	TracingFile string `yaml:"tracing_file"`
+	// TracingSampleRatio is the fraction of new traces recorded
+	TracingSampleRatio float64 `yaml:"tracing_sample_ratio"`
+	// LogLevel is debug, info, warn or error
+	LogLevel string `yaml:"log_level"`
+	// LogFormat is json or text
+	LogFormat string `yaml:"log_format"`
+	// RoutesFile is a YAML or JSON routes file replacing the built-in routes
+	RoutesFile string `yaml:"routes_file"`
+	// RoutesReloadInterval is how often the routes file is checked for
+	// changes; zero reloads it on SIGHUP only
+	RoutesReloadInterval time.Duration `yaml:"routes_reload_interval"`
+	// UpstreamDialTimeout bounds connecting to an upstream
+	UpstreamDialTimeout time.Duration `yaml:"upstream_dial_timeout"`
+	// UpstreamKeepAlive is the TCP keep-alive probe interval of upstream
+	// connections; zero disables the probes
+	UpstreamKeepAlive time.Duration `yaml:"upstream_keep_alive"`
+	// UpstreamIdleConnTimeout is how long an idle upstream connection is kept
+	UpstreamIdleConnTimeout time.Duration `yaml:"upstream_idle_conn_timeout"`
+	// UpstreamMaxIdleConns is the number of idle connections kept per upstream
+	UpstreamMaxIdleConns int `yaml:"upstream_max_idle_conns"`
+	// UpstreamMaxConns limits the connections per upstream; zero is unlimited
+	UpstreamMaxConns int `yaml:"upstream_max_conns"`
+	// UpstreamResponseHeaderTimeout bounds waiting for the response headers
+	// of an upstream; zero leaves it to the route timeout
+	UpstreamResponseHeaderTimeout time.Duration `yaml:"upstream_response_header_timeout"`
+	// UpstreamHTTP2 is auto, h2c or off
+	UpstreamHTTP2 string `yaml:"upstream_http2"`
+	// TrustedProxies are the IPs and CIDRs of the load balancers in front of
+	// the gateway whose X-Forwarded-For is believed; none by default, so the
+	// client IP is the address of the connection
+	TrustedProxies []string `yaml:"trusted_proxies"`
+	// APIKeys are the API keys known to api_key rate limits. Requests with
+	// any other key are counted by client IP.
+	APIKeys []string `yaml:"api_keys"`
+}
+
+// DefaultGatewayConfig returns the configuration for local development
+func DefaultGatewayConfig() *GatewayConfig {
+	return &GatewayConfig{
+		Port:                 8082,
+		UserServiceURL:       "http://localhost:8080",
+		OrderServiceURL:      "http://localhost:8081",
+		ShutdownTimeout:      20 * time.Second,
+		DrainDelay:           5 * time.Second,
+		HealthCheckTimeout:   2 * time.Second,
+		TracingExporter:      TracingExporterNone,
+		OTLPEndpoint:         "http://localhost:4317",
+		TracingFile:          "traces.jsonl",
+		TracingSampleRatio:   1,
+		LogLevel:             "info",
+		LogFormat:            "json",
+		RoutesReloadInterval: 5 * time.Second,
+
+		UpstreamDialTimeout:     5 * time.Second,
+		UpstreamKeepAlive:       30 * time.Second,
+		UpstreamIdleConnTimeout: 90 * time.Second,
+		UpstreamMaxIdleConns:    64,
+		UpstreamHTTP2:           UpstreamHTTP2Auto,
+	}
+}
+
+// LoadGatewayConfig loads the configuration from the defaults, an optional
+// YAML file given with -config or CONFIG_FILE, the environment and flags,
+// in increasing order of precedence, and validates it
+func LoadGatewayConfig(args []string) (*GatewayConfig, error) {
+	cfg := DefaultGatewayConfig()
+
+	// Parse flags first to find the config file, but apply them last
+	fs := flag.NewFlagSet("api-gateway", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML configuration file")
+	port := fs.Int("port", cfg.Port, "HTTP listen port (env API_GATEWAY_PORT)")
+	userURL := fs.String("user-service-url", cfg.UserServiceURL, "User service base URL (env USER_SERVICE_URL)")
+	orderURL := fs.String("order-service-url", cfg.OrderServiceURL, "Order service base URL (env ORDER_SERVICE_URL)")
+	shutdownTimeout := fs.Duration("shutdown-timeout", cfg.ShutdownTimeout, "Deadline for draining requests on shutdown (env SHUTDOWN_TIMEOUT)")
+	drainDelay := fs.Duration("shutdown-drain-delay", cfg.DrainDelay, "How long the health check fails before the server stops (env SHUTDOWN_DRAIN_DELAY)")
+	healthTimeout := fs.Duration("health-check-timeout", cfg.HealthCheckTimeout, "Deadline of each upstream readiness check (env HEALTH_CHECK_TIMEOUT)")
+	tracingExporter := fs.String("tracing-exporter", cfg.TracingExporter, "Span exporter: none, otlp or file (env TRACING_EXPORTER)")
+	otlpEndpoint := fs.String("otlp-endpoint", cfg.OTLPEndpoint, "OTLP gRPC collector URL (env OTEL_EXPORTER_OTLP_ENDPOINT)")
+	tracingFile := fs.String("tracing-file", cfg.TracingFile, "File the file exporter appends spans to (env TRACING_FILE)")
+	logLevel := fs.String("log-level", cfg.LogLevel, "Minimum log level: debug, info, warn or error (env LOG_LEVEL)")
+	logFormat := fs.String("log-format", cfg.LogFormat, "Log output format: json or text (env LOG_FORMAT)")
+	routesFile := fs.String("routes-file", cfg.RoutesFile, "YAML or JSON routes file replacing the built-in routes (env GATEWAY_ROUTES_FILE)")
+	routesReloadInterval := fs.Duration("routes-reload-interval", cfg.RoutesReloadInterval, "How often the routes file is checked for changes, 0 for SIGHUP only (env GATEWAY_ROUTES_RELOAD_INTERVAL)")
+	upstreamDialTimeout := fs.Duration("upstream-dial-timeout", cfg.UpstreamDialTimeout, "Deadline for connecting to an upstream (env GATEWAY_UPSTREAM_DIAL_TIMEOUT)")
+	upstreamKeepAlive := fs.Duration("upstream-keep-alive", cfg.UpstreamKeepAlive, "TCP keep-alive interval of upstream connections, 0 to disable (env GATEWAY_UPSTREAM_KEEP_ALIVE)")
+	upstreamIdleConnTimeout := fs.Duration("upstream-idle-conn-timeout", cfg.UpstreamIdleConnTimeout, "How long idle upstream connections are kept (env GATEWAY_UPSTREAM_IDLE_CONN_TIMEOUT)")
+	upstreamMaxIdleConns := fs.Int("upstream-max-idle-conns", cfg.UpstreamMaxIdleConns, "Idle connections kept per upstream (env GATEWAY_UPSTREAM_MAX_IDLE_CONNS)")
+	upstreamMaxConns := fs.Int("upstream-max-conns", cfg.UpstreamMaxConns, "Connection limit per upstream, 0 for unlimited (env GATEWAY_UPSTREAM_MAX_CONNS)")
+	upstreamResponseHeaderTimeout := fs.Duration("upstream-response-header-timeout", cfg.UpstreamResponseHeaderTimeout, "Deadline for upstream response headers, 0 for none (env GATEWAY_UPSTREAM_RESPONSE_HEADER_TIMEOUT)")
+	upstreamHTTP2 := fs.String("upstream-http2", cfg.UpstreamHTTP2, "HTTP/2 to upstreams: auto, h2c or off (env GATEWAY_UPSTREAM_HTTP2)")
+	trustedProxies := fs.String("trusted-proxies", "", "Comma-separated IPs and CIDRs of proxies whose X-Forwarded-For is trusted (env GATEWAY_TRUSTED_PROXIES)")
+	sampleRatio := fs.Float64("tracing-sample-ratio", cfg.TracingSampleRatio, "Fraction of new traces that are recorded (env TRACING_SAMPLE_RATIO)")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("config: unexpected arguments %v", fs.Args())
+	}
+
+	if *configFile != "" {
+		data, err := os.ReadFile(*configFile)
+		if err != nil {
+			return nil, fmt.Errorf("config: %w", err)
+		}
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("config: %s: %w", *configFile, err)
+		}
+	}
+
+	if v := os.Getenv("API_GATEWAY_PORT"); v != "" {
+		p, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("config: API_GATEWAY_PORT: %w", err)
+		}
+		cfg.Port = p
+	}
+	if v := os.Getenv("USER_SERVICE_URL"); v != "" {
+		cfg.UserServiceURL = v
+	}
+	if v := os.Getenv("ORDER_SERVICE_URL"); v != "" {
+		cfg.OrderServiceURL = v
+	}
+	if v := os.Getenv("TRACING_EXPORTER"); v != "" {
+		cfg.TracingExporter = v
+	}
+	if v := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); v != "" {
+		cfg.OTLPEndpoint = v
+	}
+	if v := os.Getenv("TRACING_FILE"); v != "" {
+		cfg.TracingFile = v
+	}
+	if v := os.Getenv("LOG_LEVEL"); v != "" {
+		cfg.LogLevel = v
+	}
+	if v := os.Getenv("LOG_FORMAT"); v != "" {
+		cfg.LogFormat = v
+	}
+	if v := os.Getenv("GATEWAY_ROUTES_FILE"); v != "" {
+		cfg.RoutesFile = v
+	}
+	if v := os.Getenv("GATEWAY_UPSTREAM_HTTP2"); v != "" {
+		cfg.UpstreamHTTP2 = v
+	}
+	if v := os.Getenv("GATEWAY_TRUSTED_PROXIES"); v != "" {
+		cfg.TrustedProxies = splitList(v)
+	}
+	if v := os.Getenv("GATEWAY_API_KEYS"); v != "" {
+		cfg.APIKeys = splitList(v)
+	}
+	if v := os.Getenv("TRACING_SAMPLE_RATIO"); v != "" {
+		r, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("config: TRACING_SAMPLE_RATIO: %w", err)
+		}
+		cfg.TracingSampleRatio = r
+	}
+	for name, d := range map[string]*time.Duration{
+		"SHUTDOWN_TIMEOUT":               &cfg.ShutdownTimeout,
+		"SHUTDOWN_DRAIN_DELAY":           &cfg.DrainDelay,
+		"HEALTH_CHECK_TIMEOUT":           &cfg.HealthCheckTimeout,
+		"GATEWAY_ROUTES_RELOAD_INTERVAL": &cfg.RoutesReloadInterval,
+
+		"GATEWAY_UPSTREAM_DIAL_TIMEOUT":            &cfg.UpstreamDialTimeout,
+		"GATEWAY_UPSTREAM_KEEP_ALIVE":              &cfg.UpstreamKeepAlive,
+		"GATEWAY_UPSTREAM_IDLE_CONN_TIMEOUT":       &cfg.UpstreamIdleConnTimeout,
+		"GATEWAY_UPSTREAM_RESPONSE_HEADER_TIMEOUT": &cfg.UpstreamResponseHeaderTimeout,
+	} {
+		if v := os.Getenv(name); v != "" {
+			parsed, err := time.ParseDuration(v)
+			if err != nil {
+				return nil, fmt.Errorf("config: %s: %w", name, err)
+			}
+			*d = parsed
+		}
+	}
+
+	for name, n := range map[string]*int{
+		"GATEWAY_UPSTREAM_MAX_IDLE_CONNS": &cfg.UpstreamMaxIdleConns,
+		"GATEWAY_UPSTREAM_MAX_CONNS":      &cfg.UpstreamMaxConns,
+	} {
+		if v := os.Getenv(name); v != "" {
+			parsed, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("config: %s: %w", name, err)
+			}
+			*n = parsed
+		}
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "port":
+			cfg.Port = *port
+		case "user-service-url":
+			cfg.UserServiceURL = *userURL
+		case "order-service-url":
+			cfg.OrderServiceURL = *orderURL
+		case "shutdown-timeout":
+			cfg.ShutdownTimeout = *shutdownTimeout
+		case "shutdown-drain-delay":
+			cfg.DrainDelay = *drainDelay
+		case "health-check-timeout":
+			cfg.HealthCheckTimeout = *healthTimeout
+		case "tracing-exporter":
+			cfg.TracingExporter = *tracingExporter
+		case "otlp-endpoint":
+			cfg.OTLPEndpoint = *otlpEndpoint
+		case "tracing-file":
+			cfg.TracingFile = *tracingFile
+		case "tracing-sample-ratio":
+			cfg.TracingSampleRatio = *sampleRatio
+		case "log-level":
+			cfg.LogLevel = *logLevel
+		case "log-format":
+			cfg.LogFormat = *logFormat
+		case "routes-file":
+			cfg.RoutesFile = *routesFile
+		case "routes-reload-interval":
+			cfg.RoutesReloadInterval = *routesReloadInterval
+		case "upstream-dial-timeout":
+			cfg.UpstreamDialTimeout = *upstreamDialTimeout
+		case "upstream-keep-alive":
+			cfg.UpstreamKeepAlive = *upstreamKeepAlive
+		case "upstream-idle-conn-timeout":
+			cfg.UpstreamIdleConnTimeout = *upstreamIdleConnTimeout
+		case "upstream-max-idle-conns":
+			cfg.UpstreamMaxIdleConns = *upstreamMaxIdleConns
+		case "upstream-max-conns":
+			cfg.UpstreamMaxConns = *upstreamMaxConns
+		case "upstream-response-header-timeout":
+			cfg.UpstreamResponseHeaderTimeout = *upstreamResponseHeaderTimeout
+		case "upstream-http2":
+			cfg.UpstreamHTTP2 = *upstreamHTTP2
+		case "trusted-proxies":
+			cfg.TrustedProxies = splitList(*trustedProxies)
+		}
+	})
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("config: invalid configuration: %w", err)
+	}
+	return cfg, nil
+}
+
+// Validate checks the port range, shutdown timing, tracing, logging, route
+// reload, upstream transport, trusted proxy and API key settings and that
+// upstreams are absolute HTTP URLs. The routes file itself is validated when
+// it is loaded.
+func (c *GatewayConfig) Validate() error {
+	var problems []string
+	if c.Port < 1 || c.Port > 65535 {
+		problems = append(problems, fmt.Sprintf("port %d is out of range", c.Port))
+	}
+	if c.ShutdownTimeout <= 0 {
+		problems = append(problems, "shutdown_timeout must be positive")
+	}
+	if c.DrainDelay < 0 {
+		problems = append(problems, "shutdown_drain_delay must not be negative")
+	}
+	if c.HealthCheckTimeout <= 0 {
+		problems = append(problems, "health_check_timeout must be positive")
+	}
+	switch c.TracingExporter {
+	case TracingExporterNone, TracingExporterOTLP, TracingExporterFile:
+	default:
+		problems = append(problems, fmt.Sprintf("tracing_exporter %q is not one of none, otlp or file", c.TracingExporter))
+	}
+	if c.TracingSampleRatio < 0 || c.TracingSampleRatio > 1 {
+		problems = append(problems, "tracing_sample_ratio must be between 0 and 1")
+	}
+	switch c.LogLevel {
+	case "debug", "info", "warn", "error":
+	default:
+		problems = append(problems, fmt.Sprintf("log_level %q is not one of debug, info, warn or error", c.LogLevel))
+	}
+	if c.LogFormat != "json" && c.LogFormat != "text" {
+		problems = append(problems, fmt.Sprintf("log_format %q is not one of json or text", c.LogFormat))
+	}
+	if c.RoutesReloadInterval < 0 {
+		problems = append(problems, "routes_reload_interval must not be negative")
+	}
+	if c.UpstreamDialTimeout <= 0 {
+		problems = append(problems, "upstream_dial_timeout must be positive")
+	}
+	if c.UpstreamKeepAlive < 0 || c.UpstreamIdleConnTimeout < 0 || c.UpstreamResponseHeaderTimeout < 0 {
+		problems = append(problems, "upstream_keep_alive, upstream_idle_conn_timeout and upstream_response_header_timeout must not be negative")
+	}
+	if c.UpstreamMaxIdleConns < 0 || c.UpstreamMaxConns < 0 {
+		problems = append(problems, "upstream_max_idle_conns and upstream_max_conns must not be negative")
+	}
+	switch c.UpstreamHTTP2 {
+	case UpstreamHTTP2Auto, UpstreamHTTP2H2C, UpstreamHTTP2Off:
+	default:
+		problems = append(problems, fmt.Sprintf("upstream_http2 %q is not one of auto, h2c or off", c.UpstreamHTTP2))
+	}
+	for _, proxy := range c.TrustedProxies {
+		if !validIPOrCIDR(proxy) {
+			problems = append(problems, fmt.Sprintf("trusted_proxies: %q is not an IP or CIDR", proxy))
+		}
+	}
+	for i, key := range c.APIKeys {
+		if key == "" {
+			problems = append(problems, fmt.Sprintf("api_keys[%d] is empty", i))
+		}
+	}
+	if err := validateUpstreamURL(c.UserServiceURL); err != nil {
+		problems = append(problems, fmt.Sprintf("user_service_url: %v", err))
+	}
+	if err := validateUpstreamURL(c.OrderServiceURL); err != nil {
+		problems = append(problems, fmt.Sprintf("order_service_url: %v", err))
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Addr returns the HTTP listen address
+func (c *GatewayConfig) Addr() string {
+	return fmt.Sprintf(":%d", c.Port)
+}
+
+// validateUpstreamURL checks that target is an absolute http or https URL
+func validateUpstreamURL(target string) error {
+	u, err := url.Parse(target)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q is not an absolute http(s) URL", target)
+	}
+	return nil
+}
+
+// splitList splits a comma-separated list, dropping empty items
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// validIPOrCIDR reports whether s is an IP address or a CIDR
+func validIPOrCIDR(s string) bool {
+	if _, err := netip.ParsePrefix(s); err == nil {
+		return true
+	}
+	_, err := netip.ParseAddr(s)
+	return err == nil
+}
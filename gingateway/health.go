@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Health statuses
+const (
+	healthOK          = "ok"
+	healthDegraded    = "degraded"
+	healthUnavailable = "unavailable"
+	healthError       = "error"
+)
+
+// HealthCheckResult is the outcome of a single readiness check
+type HealthCheckResult struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Critical  bool    `json:"critical"`
+	Target    string  `json:"target,omitempty"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// HealthReport is the outcome of all readiness checks
+type HealthReport struct {
+	Status  string                       `json:"status"`
+	Service string                       `json:"service"`
+	Checks  map[string]HealthCheckResult `json:"checks"`
+}
+
+// LivenessHandler reports that the gateway process is up. It runs no
+// upstream checks so an upstream outage does not get the gateway restarted.
+func (g *Gateway) LivenessHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"status":  healthOK,
+			"service": "api-gateway",
+		})
+	}
+}
+
+// ReadinessHandler reports whether the gateway accepts traffic together with
+// the readiness of every upstream. Upstream failures only degrade the report
+// since the gateway still serves routes to the remaining upstreams.
+func (g *Gateway) ReadinessHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report := g.checkReadiness(c.Request.Context())
+		code := http.StatusOK
+		if report.Status == healthUnavailable {
+			code = http.StatusServiceUnavailable
+		}
+		c.JSON(code, report)
+	}
+}
+
+// checkReadiness checks the shutdown state and each distinct upstream concurrently
+func (g *Gateway) checkReadiness(ctx context.Context) *HealthReport {
+	report := &HealthReport{
+		Status:  healthOK,
+		Service: "api-gateway",
+		Checks:  make(map[string]HealthCheckResult),
+	}
+
+	shutdown := HealthCheckResult{Status: healthOK, Critical: true}
+	if !g.ready.Load() {
+		shutdown.Status = healthError
+		shutdown.Error = "shutting down"
+		report.Status = healthUnavailable
+	}
+	report.Checks["shutdown"] = shutdown
+
+	// Endpoints shared by several routes are checked once
+	table := g.Routes()
+	results := make(map[string]HealthCheckResult)
+	for _, service := range table.services {
+		for _, target := range service.Upstreams {
+			results[target] = HealthCheckResult{}
+		}
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for target := range results {
+		wg.Add(1)
+		go func(target string) {
+			defer wg.Done()
+
+			start := time.Now()
+			err := g.checkUpstream(ctx, target)
+			result := HealthCheckResult{
+				Status:    healthOK,
+				LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+				Target:    target,
+			}
+			if err != nil {
+				result.Status = healthError
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			results[target] = result
+		}(target)
+	}
+	wg.Wait()
+
+	// A route is ready while any of its endpoints is
+	for _, service := range table.services {
+		report.Checks[service.Name] = routeHealth(service.Upstreams, results)
+		if report.Checks[service.Name].Status != healthOK && report.Status == healthOK {
+			report.Status = healthDegraded
+		}
+	}
+
+	return report
+}
+
+// routeHealth combines the results of the endpoints of a route. The route
+// is degraded while some endpoints fail and in error once all of them do.
+func routeHealth(targets []string, results map[string]HealthCheckResult) HealthCheckResult {
+	if len(targets) == 1 {
+		return results[targets[0]]
+	}
+
+	combined := HealthCheckResult{Status: healthOK, Target: strings.Join(targets, ",")}
+	var failed []string
+	for _, target := range targets {
+		result := results[target]
+		combined.LatencyMS = max(combined.LatencyMS, result.LatencyMS)
+		if result.Status != healthOK {
+			failed = append(failed, fmt.Sprintf("%s: %s", target, result.Error))
+		}
+	}
+	switch len(failed) {
+	case 0:
+	case len(targets):
+		combined.Status = healthError
+	default:
+		combined.Status = healthDegraded
+	}
+	combined.Error = strings.Join(failed, "; ")
+	return combined
+}
+
+// checkUpstream calls the readiness endpoint of an upstream service
+func (g *Gateway) checkUpstream(ctx context.Context, target string) error {
+	ctx, cancel := context.WithTimeout(ctx, g.healthTimeout)
+	defer cancel()
+
+	return probe(ctx, g.healthClient, strings.TrimSuffix(target, "/")+"/readyz")
+}
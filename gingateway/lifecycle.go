@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Serve runs srv until the process receives SIGINT or SIGTERM, then fails
+// the health check, waits for the drain delay so load balancers stop routing
+// to the gateway, and drains in-flight requests within the shutdown timeout
+func (g *Gateway) Serve(srv *http.Server, cfg *GatewayConfig) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+	g.ready.Store(true)
+
+	select {
+	case err := <-serveErr:
+		g.ready.Store(false)
+		return err
+	case <-ctx.Done():
+		// A second signal terminates the process immediately
+		stop()
+	}
+
+	slog.Info("Shutdown signal received")
+	g.ready.Store(false)
+	if cfg.DrainDelay > 0 {
+		slog.Info("Draining before stopping the server", "drain_delay", cfg.DrainDelay.String())
+		time.Sleep(cfg.DrainDelay)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -1,40 +1,99 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
 	"net/http"
-	"net/http/httputil"
-	"net/url"
-	"strings"
+	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gin-contrib/cors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-// ServiceConfig represents a microservice configuration
+// ServiceConfig represents a route to a microservice
 type ServiceConfig struct {
-	Name     string
-	BasePath string
-	Target   string
+	Name     string `yaml:"name"`
+	BasePath string `yaml:"base_path"`
+	// Target is the single upstream of the route, a shorthand for Upstreams
+	Target string `yaml:"target"`
+	// Upstreams are the endpoints requests are balanced across
+	Upstreams []string `yaml:"upstreams"`
+	// LoadBalancer is round_robin, least_connections or consistent_hash
+	LoadBalancer string `yaml:"load_balancer"`
+	// HashOn is the consistent hash key: client_ip, path or header:<Name>
+	HashOn string `yaml:"hash_on"`
+	// HealthCheck probes the endpoints; nil disables active checks
+	HealthCheck *ActiveHealthCheck `yaml:"health_check"`
+	// PassiveHealthCheck ejects endpoints whose requests keep failing
+	PassiveHealthCheck PassiveHealthCheck `yaml:"passive_health_check"`
+	// Methods, Hosts and Headers restrict the route to matching requests;
+	// a header value of "*" only requires the header to be present
+	Methods []string          `yaml:"methods"`
+	Hosts   []string          `yaml:"hosts"`
+	Headers map[string]string `yaml:"headers"`
+	// StripPrefix removes BasePath from the path sent upstream
+	StripPrefix bool `yaml:"strip_prefix"`
+	// Rewrite rewrites the upstream path with the first matching rule
+	Rewrite []RewriteRule `yaml:"rewrite"`
+	// Timeout bounds each proxied request; zero leaves it unbounded
+	Timeout time.Duration `yaml:"timeout"`
+	// PublicPaths lists "[METHOD ]/path" rules that bypass token verification
+	PublicPaths []string `yaml:"public_paths"`
+	// Middleware names the route middleware run before proxying, in order
+	Middleware []string `yaml:"middleware"`
+	// RateLimits are enforced by the ratelimit middleware
+	RateLimits []*RateLimit `yaml:"rate_limits"`
+	// chain is Middleware resolved when the route table is built
+	chain []RouteMiddleware
+	// pool balances requests across Upstreams
+	pool *Pool
 }
 
 // Gateway represents the API Gateway
 type Gateway struct {
-	services map[string]*ServiceConfig
+	// routes is replaced as a whole when the routes file is reloaded
+	routes atomic.Pointer[RouteTable]
+	// middleware are the route middleware available to routes by name
+	middleware map[string]RouteMiddleware
+	// rejectedChecksum is the last routes file that failed validation
+	rejectedChecksum string
+	// ready is false until the server starts and again once shutdown begins
+	ready atomic.Bool
+	// healthClient calls upstream readiness endpoints
+	healthClient  *http.Client
+	healthTimeout time.Duration
+	// proxies are the reverse proxies by upstream URL, built once and kept
+	// across route reloads so their connections are reused
+	proxiesMu sync.Mutex
+	proxies   map[string]*upstreamProxy
+	// newTransport builds the transport of an upstream proxy
+	newTransport func() *http.Transport
+	buffers      *bufferPool
 }
 
 // NewGateway creates a new API Gateway
-func NewGateway() *Gateway {
+func NewGateway(cfg *GatewayConfig) *Gateway {
 	return &Gateway{
-		services: make(map[string]*ServiceConfig),
+		middleware:    make(map[string]RouteMiddleware),
+		healthClient:  &http.Client{},
+		healthTimeout: cfg.HealthCheckTimeout,
+		proxies:       make(map[string]*upstreamProxy),
+		newTransport:  func() *http.Transport { return NewUpstreamTransport(cfg) },
+		buffers:       newBufferPool(),
 	}
 }
 
-// RegisterService registers a microservice with the gateway
-func (g *Gateway) RegisterService(config *ServiceConfig) {
-	g.services[config.Name] = config
-	log.Printf("Registered service: %s -> %s", config.BasePath, config.Target)
+// RegisterMiddleware makes a route middleware available to routes by name.
+// Middleware must be registered before routes are loaded.
+func (g *Gateway) RegisterMiddleware(name string, mw RouteMiddleware) {
+	g.middleware[name] = mw
 }
 
 // ProxyHandler handles the proxy routing
@@ -42,14 +101,8 @@ func (g *Gateway) ProxyHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		path := c.Request.URL.Path
 		
-		// Find the service for this path
-		var targetService *ServiceConfig
-		for _, service := range g.services {
-			if strings.HasPrefix(path, service.BasePath) {
-				targetService = service
-				break
-			}
-		}
+		// Find the route for this request
+		targetService := g.Routes().Match(c.Request)
 
 		if targetService == nil {
 			c.JSON(http.StatusNotFound, gin.H{
@@ -58,148 +111,213 @@ func (g *Gateway) ProxyHandler() gin.HandlerFunc {
 			})
 			return
 		}
+		c.Set(routeKey, targetService.BasePath)
+
+		// Identity headers are only ever set by the auth middleware
+		c.Request.Header.Del(HeaderUserID)
+		c.Request.Header.Del(HeaderUsername)
+		c.Request.Header.Del(HeaderUserRoles)
+		c.Request.Header.Del(HeaderIdentitySignature)
+
+		for _, mw := range targetService.chain {
+			mw(c, targetService)
+			if c.IsAborted() {
+				return
+			}
+		}
 
-		// Create reverse proxy
-		targetURL, err := url.Parse(targetService.Target)
+		// Pick an endpoint of the route's pool
+		endpoint, err := targetService.pool.Pick(c.Request, c.ClientIP())
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Invalid target URL",
+			slog.WarnContext(c.Request.Context(), "No upstream endpoint available", "upstream", targetService.Name)
+			upstreamErrors.WithLabelValues(targetService.Name).Inc()
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"error": "Service unavailable",
 			})
 			return
 		}
 
-		proxy := httputil.NewSingleHostReverseProxy(targetURL)
-		
-		// Add custom director to modify the request
-		originalDirector := proxy.Director
-		proxy.Director = func(req *http.Request) {
-			originalDirector(req)
-			req.Host = targetURL.Host
-			
-			// Log the request
-			log.Printf("Proxying request: %s %s -> %s", req.Method, req.URL.Path, targetService.Target)
+		// The endpoint's proxy reads the route from the request context
+		req, state := withProxyRequest(c.Request, targetService)
+		if targetService.Timeout > 0 {
+			ctx, cancel := context.WithTimeout(req.Context(), targetService.Timeout)
+			defer cancel()
+			req = req.WithContext(ctx)
 		}
 
-		// Add error handler
-		proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
-			log.Printf("Proxy error: %v", err)
-			w.WriteHeader(http.StatusBadGateway)
-			w.Write([]byte("Service unavailable"))
-		}
-
-		// Serve the request
-		proxy.ServeHTTP(c.Writer, c.Request)
-	}
-}
-
-// HealthCheckHandler handles health check requests
-func (g *Gateway) HealthCheckHandler() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{
-			"status": "ok",
-			"service": "api-gateway",
-			"timestamp": time.Now().Unix(),
-			"services": len(g.services),
-		})
+		// Serve the request. The proxy panics with http.ErrAbortHandler when
+		// copying the response fails midway, so the outcome is recorded in a
+		// deferred call.
+		start := time.Now()
+		served := false
+		endpoint.inFlight.Add(1)
+		defer func() {
+			endpoint.inFlight.Add(-1)
+			status := c.Writer.Status()
+			if state.failed || !served {
+				status = 0
+			}
+			// Clients hanging up say nothing about the endpoint's health
+			if !clientGone(c.Request.Context()) {
+				targetService.pool.Done(endpoint, status)
+			}
+			observeUpstream(targetService.Name, c.Writer.Status(), time.Since(start))
+		}()
+		endpoint.proxy.ServeHTTP(c.Writer, req)
+		served = true
 	}
 }
 
-// ServicesHandler returns information about registered services
+// ServicesHandler returns the live routes and the version of their configuration
 func (g *Gateway) ServicesHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		services := make([]gin.H, 0)
-		for name, service := range g.services {
+		table := g.Routes()
+		services := make([]gin.H, 0, len(table.services))
+		for _, service := range table.services {
 			services = append(services, gin.H{
-				"name":      name,
-				"base_path": service.BasePath,
-				"target":    service.Target,
+				"name":                 service.Name,
+				"base_path":            service.BasePath,
+				"upstreams":            service.pool.Status(),
+				"load_balancer":        service.LoadBalancer,
+				"hash_on":              service.HashOn,
+				"health_check":         service.HealthCheck,
+				"passive_health_check": service.PassiveHealthCheck,
+				"methods":              service.Methods,
+				"hosts":                service.Hosts,
+				"headers":              service.Headers,
+				"strip_prefix":         service.StripPrefix,
+				"rewrite":              service.Rewrite,
+				"timeout":              service.Timeout.String(),
+				"public_paths":         service.PublicPaths,
+				"middleware":           service.Middleware,
+				"rate_limits":          service.RateLimits,
 			})
 		}
 		
 		c.JSON(http.StatusOK, gin.H{
-			"services": services,
+			"version":   table.Version,
+			"checksum":  table.Checksum,
+			"source":    table.Source,
+			"loaded_at": table.LoadedAt,
+			"services":  services,
 		})
 	}
 }
 
 func main() {
+	// Load configuration from an optional YAML file, the environment and flags
+	cfg, err := LoadGatewayConfig(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		fatal("Failed to load configuration", "error", err)
+	}
+
+	// Log JSON lines at the configured level
+	SetupLogger(cfg)
+
+	// Export traces of proxied requests
+	tracerProvider, err := SetupTracing(context.Background(), cfg)
+	if err != nil {
+		fatal("Failed to set up tracing", "error", err)
+	}
+	defer func() {
+		if err := tracerProvider.Close(); err != nil {
+			slog.Error("Failed to close tracer provider", "error", err)
+		}
+	}()
+
 	// Create gateway
-	gateway := NewGateway()
+	gateway := NewGateway(cfg)
 
-	// Register microservices
-	gateway.RegisterService(&ServiceConfig{
-		Name:     "user-service",
-		BasePath: "/api/v1/users",
-		Target:   "http://localhost:8080",
-	})
+	// Initialize token verification
+	authenticator, err := NewAuthenticator(NewAuthConfigFromEnv())
+	if err != nil {
+		fatal("Failed to initialize authenticator", "error", err)
+	}
+	stopJWKSRefresh := make(chan struct{})
+	defer close(stopJWKSRefresh)
+	authenticator.StartJWKSRefresh(stopJWKSRefresh)
+
+	// Rate limit buckets of this replica
+	rateLimits := NewMemoryRateLimitStore()
+	sweepCtx, stopSweeping := context.WithCancel(context.Background())
+	defer stopSweeping()
+	rateLimits.StartSweeping(sweepCtx, time.Minute)
+
+	// Route middleware that routes select by name
+	gateway.RegisterMiddleware(middlewareAuth, AuthMiddleware(authenticator, NewIdentitySignerFromEnv()))
+	gateway.RegisterMiddleware(middlewareRateLimit, RateLimitMiddleware(rateLimits, NewAPIKeySet(cfg.APIKeys)))
 
-	gateway.RegisterService(&ServiceConfig{
-		Name:     "order-service",
-		BasePath: "/api/v1/orders",
-		Target:   "http://localhost:8081",
-	})
+	// Load the routes and keep them in sync with the routes file
+	if err := gateway.LoadRoutes(cfg); err != nil {
+		fatal("Failed to load routes", "error", err)
+	}
+	if cfg.RoutesFile != "" {
+		watchCtx, stopWatching := context.WithCancel(context.Background())
+		defer stopWatching()
+		go gateway.WatchRoutes(watchCtx, cfg.RoutesFile, cfg.RoutesReloadInterval)
+	}
 
 	// Setup Gin router
-	r := gin.Default()
+	r := gin.New()
+	// Only believe X-Forwarded-For from the configured load balancers
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		fatal("Failed to set trusted proxies", "error", err)
+	}
+	r.Use(MetricsMiddleware())
+	r.Use(TracingMiddleware())
+	r.Use(LoggingMiddleware(), RecoveryMiddleware())
 
 	// Add CORS middleware
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Content-Length", "Accept-Encoding", "X-CSRF-Token", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Content-Length", "Accept-Encoding", "X-CSRF-Token", "Authorization", HeaderRequestID, HeaderAPIKey},
+		ExposeHeaders:    []string{"Content-Length", HeaderRequestID, "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy", "Retry-After"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
 
-	// Add request logging middleware
-	r.Use(func(c *gin.Context) {
-		start := time.Now()
-		path := c.Request.URL.Path
-		raw := c.Request.URL.RawQuery
+	// Health check endpoints
+	r.GET("/livez", gateway.LivenessHandler())
+	r.GET("/readyz", gateway.ReadinessHandler())
+	// Kept for probes and clients that predate /readyz
+	r.GET("/health", gateway.ReadinessHandler())
 
-		// Process request
-		c.Next()
-
-		// Log request details
-		latency := time.Since(start)
-		clientIP := c.ClientIP()
-		method := c.Request.Method
-		statusCode := c.Writer.Status()
-
-		if raw != "" {
-			path = path + "?" + raw
-		}
-
-		log.Printf("[GATEWAY] %v | %3d | %13v | %15s | %-7s %s",
-			time.Now().Format("2006/01/02 - 15:04:05"),
-			statusCode,
-			latency,
-			clientIP,
-			method,
-			path,
-		)
-	})
-
-	// Health check endpoint
-	r.GET("/health", gateway.HealthCheckHandler())
+	// Prometheus metrics
+	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
+	// Route inspection shows internal upstream addresses, so only admins
+	// may use it
+	admin := r.Group("", RequireRole(authenticator, RoleAdmin))
 	// Services info endpoint
-	r.GET("/services", gateway.ServicesHandler())
+	admin.GET("/services", gateway.ServicesHandler())
+	// Dry run of the routing of a described request
+	admin.GET("/routes/explain", gateway.ExplainHandler())
 
 	// API routes - proxy to microservices
-	api := r.Group("/api/v1")
+	api := r.Group(apiPrefix)
 	{
-		// All requests to /api/v1/* will be proxied
+		// All requests to /api/v1/* run their route's middleware and are proxied
 		api.Any("/*path", gateway.ProxyHandler())
 	}
 
 	// Start server
-	log.Println("API Gateway starting on port 8082...")
-	log.Println("Health check: http://localhost:8082/health")
-	log.Println("Services info: http://localhost:8082/services")
+	slog.Info("API Gateway starting",
+		"port", cfg.Port,
+		"health", fmt.Sprintf("http://localhost:%d/readyz", cfg.Port),
+		"services", fmt.Sprintf("http://localhost:%d/services", cfg.Port),
+	)
 	
-	if err := r.Run(":8082"); err != nil {
-		log.Fatal("Failed to start gateway:", err)
+	srv := &http.Server{
+		Addr:              cfg.Addr(),
+		Handler:           r,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	if err := gateway.Serve(srv, cfg); err != nil {
+		fatal("Gateway failed", "error", err)
 	}
+	slog.Info("Shutdown complete")
 } 
\ No newline at end of file
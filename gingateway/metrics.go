@@ -0,0 +1,105 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// routeKey is the Gin context key under which the proxy stores the base
+// path of the matched service, used as route label instead of /api/v1/*path
+const routeKey = "gateway.route"
+
+// unmatchedRoute labels requests that matched no route, so arbitrary paths
+// do not create new time series
+const unmatchedRoute = "unmatched"
+
+// latencyBuckets covers fast health checks up to slow upstream calls
+var latencyBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests by method, route and status code.",
+	}, []string{"method", "route", "status"})
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by method, route and status code.",
+		Buckets: latencyBuckets,
+	}, []string{"method", "route", "status"})
+	httpRequestsInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "http_requests_in_flight",
+		Help: "HTTP requests currently being served.",
+	})
+
+	upstreamRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_upstream_requests_total",
+		Help: "Requests proxied to an upstream service by service and status code.",
+	}, []string{"service", "status"})
+	upstreamRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gateway_upstream_request_duration_seconds",
+		Help:    "Latency of requests proxied to an upstream service.",
+		Buckets: latencyBuckets,
+	}, []string{"service"})
+	upstreamErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_upstream_errors_total",
+		Help: "Requests that could not be proxied because the upstream service was unreachable or failed.",
+	}, []string{"service"})
+
+	upstreamEndpointHealthy = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gateway_upstream_endpoint_healthy",
+		Help: "Whether an upstream endpoint passes its active health checks (1) or not (0).",
+	}, []string{"service", "endpoint"})
+	upstreamEjections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_upstream_ejections_total",
+		Help: "Upstream endpoints ejected after consecutive failed requests.",
+	}, []string{"service", "endpoint"})
+
+	rateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_rate_limited_requests_total",
+		Help: "Requests rejected with 429 by service and rate limit key.",
+	}, []string{"service", "key"})
+	rateLimitErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_rate_limit_errors_total",
+		Help: "Requests let through because the rate limit store failed.",
+	}, []string{"service"})
+
+	routeReloads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_route_reloads_total",
+		Help: "Reloads of the routes file by result, success or failure.",
+	}, []string{"result"})
+)
+
+// MetricsMiddleware records the count and latency of every request,
+// labelled by the Gin route template or, for proxied requests, the base
+// path of the matched service
+func MetricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		httpRequestsInFlight.Inc()
+		defer httpRequestsInFlight.Dec()
+
+		c.Next()
+
+		route := c.GetString(routeKey)
+		if route == "" {
+			route = c.FullPath()
+		}
+		if route == "" {
+			route = unmatchedRoute
+		}
+		status := strconv.Itoa(c.Writer.Status())
+
+		httpRequests.WithLabelValues(c.Request.Method, route, status).Inc()
+		httpRequestDuration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// observeUpstream records a request proxied to service
+func observeUpstream(service string, status int, elapsed time.Duration) {
+	upstreamRequests.WithLabelValues(service, strconv.Itoa(status)).Inc()
+	upstreamRequestDuration.WithLabelValues(service).Observe(elapsed.Seconds())
+}
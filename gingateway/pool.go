@@ -0,0 +1,452 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Load balancing strategies
+const (
+	LoadBalancerRoundRobin       = "round_robin"
+	LoadBalancerLeastConnections = "least_connections"
+	LoadBalancerConsistentHash   = "consistent_hash"
+)
+
+// Consistent hash keys; "header:<Name>" hashes the value of a header
+const (
+	HashOnClientIP = "client_ip"
+	HashOnPath     = "path"
+	hashOnHeader   = "header:"
+)
+
+// ringReplicas is the number of points each endpoint gets on the hash ring,
+// which spreads keys evenly across a few endpoints
+const ringReplicas = 100
+
+// ErrNoHealthyEndpoint is returned when every endpoint of a pool is
+// unhealthy or ejected
+var ErrNoHealthyEndpoint = errors.New("no healthy upstream endpoint")
+
+// ActiveHealthCheck probes every endpoint of a pool periodically. An
+// endpoint is taken out of rotation after UnhealthyThreshold failed probes
+// in a row and re-admitted after HealthyThreshold successful probes in a row.
+type ActiveHealthCheck struct {
+	Path               string        `yaml:"path" json:"path"`
+	Interval           time.Duration `yaml:"interval" json:"interval"`
+	Timeout            time.Duration `yaml:"timeout" json:"timeout"`
+	HealthyThreshold   int           `yaml:"healthy_threshold" json:"healthy_threshold"`
+	UnhealthyThreshold int           `yaml:"unhealthy_threshold" json:"unhealthy_threshold"`
+}
+
+// PassiveHealthCheck ejects an endpoint for EjectionTime after MaxFailures
+// proxied requests in a row failed with a 5xx status or a transport error.
+// A MaxFailures of zero disables passive ejection.
+type PassiveHealthCheck struct {
+	MaxFailures  int           `yaml:"max_failures" json:"max_failures"`
+	EjectionTime time.Duration `yaml:"ejection_time" json:"ejection_time"`
+}
+
+// applyDefaults fills in the unset probe settings
+func (h *ActiveHealthCheck) applyDefaults() {
+	if h.Path == "" {
+		h.Path = "/readyz"
+	}
+	if h.Interval == 0 {
+		h.Interval = 10 * time.Second
+	}
+	if h.Timeout == 0 {
+		h.Timeout = 2 * time.Second
+	}
+	if h.HealthyThreshold == 0 {
+		h.HealthyThreshold = 2
+	}
+	if h.UnhealthyThreshold == 0 {
+		h.UnhealthyThreshold = 3
+	}
+}
+
+// Endpoint is one instance of an upstream service
+type Endpoint struct {
+	URL *url.URL
+	// proxy is shared by every endpoint with the same URL
+	proxy *httputil.ReverseProxy
+	// healthy is cleared by active health checks
+	healthy atomic.Bool
+	// ejectedUntil is the UnixNano time passive ejection ends
+	ejectedUntil atomic.Int64
+	// inFlight is shared with the endpoint of earlier route tables, whose
+	// requests may still be running
+	inFlight *atomic.Int64
+	// failures counts proxied requests that failed in a row
+	failures atomic.Int64
+	// probe streaks are only touched by the health check goroutine
+	probeSuccesses int
+	probeFailures  int
+}
+
+// available reports whether the endpoint may receive requests at now
+func (e *Endpoint) available(now time.Time) bool {
+	return e.healthy.Load() && now.UnixNano() >= e.ejectedUntil.Load()
+}
+
+// EndpointStatus is the state of an endpoint shown on /services
+type EndpointStatus struct {
+	URL          string     `json:"url"`
+	Healthy      bool       `json:"healthy"`
+	Available    bool       `json:"available"`
+	EjectedUntil *time.Time `json:"ejected_until,omitempty"`
+	InFlight     int64      `json:"in_flight"`
+	Failures     int64      `json:"consecutive_failures"`
+}
+
+// ringPoint is a position of an endpoint on the consistent hash ring
+type ringPoint struct {
+	hash     uint64
+	endpoint *Endpoint
+}
+
+// Pool balances the requests of a route across its endpoints
+type Pool struct {
+	route       string
+	strategy    string
+	hashOn      string
+	endpoints   []*Endpoint
+	ring        []ringPoint
+	next        atomic.Uint64
+	active      *ActiveHealthCheck
+	passive     PassiveHealthCheck
+	stopChecks  context.CancelFunc
+	checksGroup sync.WaitGroup
+}
+
+// newPool creates the pool of a route from its upstream URLs
+func newPool(route string, targets []string, strategy, hashOn string, active *ActiveHealthCheck, passive PassiveHealthCheck) (*Pool, error) {
+	p := &Pool{
+		route:    route,
+		strategy: strategy,
+		hashOn:   hashOn,
+		active:   active,
+		passive:  passive,
+	}
+	for _, target := range targets {
+		u, err := url.Parse(target)
+		if err != nil {
+			return nil, err
+		}
+		e := &Endpoint{URL: u, inFlight: new(atomic.Int64)}
+		e.healthy.Store(true)
+		p.endpoints = append(p.endpoints, e)
+	}
+
+	if strategy == LoadBalancerConsistentHash {
+		for _, e := range p.endpoints {
+			for i := 0; i < ringReplicas; i++ {
+				p.ring = append(p.ring, ringPoint{hash: hashKey(e.URL.String() + "#" + strconv.Itoa(i)), endpoint: e})
+			}
+		}
+		sort.Slice(p.ring, func(i, j int) bool { return p.ring[i].hash < p.ring[j].hash })
+	}
+	return p, nil
+}
+
+// Pick chooses the endpoint for a request among the available endpoints.
+// clientIP is the hash key of the client_ip consistent hash.
+func (p *Pool) Pick(r *http.Request, clientIP string) (*Endpoint, error) {
+	now := time.Now()
+
+	if p.strategy == LoadBalancerConsistentHash {
+		var key string
+		switch {
+		case p.hashOn == HashOnPath:
+			key = r.URL.Path
+		case strings.HasPrefix(p.hashOn, hashOnHeader):
+			key = r.Header.Get(strings.TrimPrefix(p.hashOn, hashOnHeader))
+		default:
+			key = clientIP
+		}
+		h := hashKey(key)
+		start := sort.Search(len(p.ring), func(i int) bool { return p.ring[i].hash >= h })
+		// Walk the ring past unavailable endpoints so only their keys move
+		for i := 0; i < len(p.ring); i++ {
+			point := p.ring[(start+i)%len(p.ring)]
+			if point.endpoint.available(now) {
+				return point.endpoint, nil
+			}
+		}
+		return nil, ErrNoHealthyEndpoint
+	}
+
+	offset := int(p.next.Add(1) - 1)
+	var picked *Endpoint
+	for i := range p.endpoints {
+		e := p.endpoints[(offset+i)%len(p.endpoints)]
+		if !e.available(now) {
+			continue
+		}
+		if p.strategy != LoadBalancerLeastConnections {
+			return e, nil
+		}
+		// Starting at the round-robin offset spreads ties evenly
+		if picked == nil || e.inFlight.Load() < picked.inFlight.Load() {
+			picked = e
+		}
+	}
+	if picked == nil {
+		return nil, ErrNoHealthyEndpoint
+	}
+	return picked, nil
+}
+
+// Done records the outcome of a request proxied to e for passive health
+// checking. A status of 0 means the request failed before a response.
+func (p *Pool) Done(e *Endpoint, status int) {
+	if status > 0 && status < http.StatusInternalServerError {
+		e.failures.Store(0)
+		return
+	}
+	if p.passive.MaxFailures <= 0 {
+		return
+	}
+	if e.failures.Add(1) < int64(p.passive.MaxFailures) {
+		return
+	}
+
+	e.failures.Store(0)
+	e.ejectedUntil.Store(time.Now().Add(p.passive.EjectionTime).UnixNano())
+	upstreamEjections.WithLabelValues(p.route, e.URL.String()).Inc()
+	slog.Warn("Ejected upstream endpoint after consecutive failures",
+		"upstream", p.route,
+		"endpoint", e.URL.String(),
+		"failures", p.passive.MaxFailures,
+		"ejection_time", p.passive.EjectionTime.String(),
+	)
+}
+
+// Status returns the state of every endpoint
+func (p *Pool) Status() []EndpointStatus {
+	now := time.Now()
+	statuses := make([]EndpointStatus, 0, len(p.endpoints))
+	for _, e := range p.endpoints {
+		status := EndpointStatus{
+			URL:       e.URL.String(),
+			Healthy:   e.healthy.Load(),
+			Available: e.available(now),
+			InFlight:  e.inFlight.Load(),
+			Failures:  e.failures.Load(),
+		}
+		if until := time.Unix(0, e.ejectedUntil.Load()); until.After(now) {
+			status.EjectedUntil = &until
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// inherit carries over the health, ejection and in-flight requests of the
+// endpoints of old with the same URL, so reloading the routes does not put
+// unhealthy or ejected endpoints back into rotation. The health checks of
+// old must be stopped.
+func (p *Pool) inherit(old *Pool) {
+	previous := make(map[string]*Endpoint, len(old.endpoints))
+	for _, e := range old.endpoints {
+		previous[e.URL.String()] = e
+	}
+	for _, e := range p.endpoints {
+		prev, ok := previous[e.URL.String()]
+		if !ok {
+			continue
+		}
+		e.healthy.Store(prev.healthy.Load())
+		e.ejectedUntil.Store(prev.ejectedUntil.Load())
+		e.failures.Store(prev.failures.Load())
+		e.inFlight = prev.inFlight
+		e.probeSuccesses = prev.probeSuccesses
+		e.probeFailures = prev.probeFailures
+	}
+}
+
+// startHealthChecks probes the endpoints until stopHealthChecks is called
+func (p *Pool) startHealthChecks(client *http.Client) {
+	for _, e := range p.endpoints {
+		healthy := 0.0
+		if e.healthy.Load() {
+			healthy = 1
+		}
+		upstreamEndpointHealthy.WithLabelValues(p.route, e.URL.String()).Set(healthy)
+	}
+	if p.active == nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.stopChecks = cancel
+	p.checksGroup.Add(1)
+	go func() {
+		defer p.checksGroup.Done()
+		ticker := time.NewTicker(p.active.Interval)
+		defer ticker.Stop()
+		for {
+			p.probeAll(ctx, client)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// stopHealthChecks stops probing and drops the endpoint metrics, once the
+// pool is no longer part of the live routes
+func (p *Pool) stopHealthChecks() {
+	if p.stopChecks != nil {
+		p.stopChecks()
+		p.checksGroup.Wait()
+	}
+	for _, e := range p.endpoints {
+		upstreamEndpointHealthy.DeleteLabelValues(p.route, e.URL.String())
+	}
+}
+
+// probeAll probes every endpoint concurrently and updates its health
+func (p *Pool) probeAll(ctx context.Context, client *http.Client) {
+	var wg sync.WaitGroup
+	for _, e := range p.endpoints {
+		wg.Add(1)
+		go func(e *Endpoint) {
+			defer wg.Done()
+			probeCtx, cancel := context.WithTimeout(ctx, p.active.Timeout)
+			defer cancel()
+			err := probe(probeCtx, client, strings.TrimSuffix(e.URL.String(), "/")+p.active.Path)
+			if ctx.Err() != nil {
+				return
+			}
+			p.recordProbe(e, err)
+		}(e)
+	}
+	wg.Wait()
+}
+
+// recordProbe updates the probe streaks of e and flips its health once a
+// threshold is reached
+func (p *Pool) recordProbe(e *Endpoint, err error) {
+	if err == nil {
+		e.probeFailures = 0
+		e.probeSuccesses++
+		if !e.healthy.Load() && e.probeSuccesses >= p.active.HealthyThreshold {
+			e.healthy.Store(true)
+			upstreamEndpointHealthy.WithLabelValues(p.route, e.URL.String()).Set(1)
+			slog.Info("Upstream endpoint is healthy again", "upstream", p.route, "endpoint", e.URL.String())
+		}
+		return
+	}
+
+	e.probeSuccesses = 0
+	e.probeFailures++
+	if e.healthy.Load() && e.probeFailures >= p.active.UnhealthyThreshold {
+		e.healthy.Store(false)
+		upstreamEndpointHealthy.WithLabelValues(p.route, e.URL.String()).Set(0)
+		slog.Warn("Upstream endpoint is unhealthy", "upstream", p.route, "endpoint", e.URL.String(), "error", err)
+	}
+}
+
+// probe calls an endpoint URL and expects a 2xx response
+func probe(ctx context.Context, client *http.Client, target string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s returned %d", target, resp.StatusCode)
+	}
+	return nil
+}
+
+// hashKey hashes a consistent hash key or ring point. FNV alone maps keys
+// differing in their last byte close together, so the hash is mixed with
+// the murmur3 finalizer to spread them around the ring.
+func hashKey(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// validatePool checks the load balancing and health check settings of a
+// route, filling in defaults, and returns the problems found
+func (s *ServiceConfig) validatePool(label string) []string {
+	var problems []string
+	switch {
+	case s.Target != "" && len(s.Upstreams) > 0:
+		problems = append(problems, label+": set either target or upstreams")
+	case s.Target != "":
+		s.Upstreams = []string{s.Target}
+	case len(s.Upstreams) == 0:
+		problems = append(problems, label+": target or upstreams is required")
+	}
+	seen := make(map[string]bool)
+	for _, target := range s.Upstreams {
+		if err := validateUpstreamURL(target); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: upstream: %v", label, err))
+		}
+		if seen[target] {
+			problems = append(problems, fmt.Sprintf("%s: upstream %q is listed twice", label, target))
+		}
+		seen[target] = true
+	}
+
+	switch s.LoadBalancer {
+	case "":
+		s.LoadBalancer = LoadBalancerRoundRobin
+	case LoadBalancerRoundRobin, LoadBalancerLeastConnections, LoadBalancerConsistentHash:
+	default:
+		problems = append(problems, fmt.Sprintf("%s: load_balancer %q is not one of %s, %s or %s", label, s.LoadBalancer,
+			LoadBalancerRoundRobin, LoadBalancerLeastConnections, LoadBalancerConsistentHash))
+	}
+	if s.HashOn == "" {
+		s.HashOn = HashOnClientIP
+	}
+	if s.HashOn != HashOnClientIP && s.HashOn != HashOnPath && (!strings.HasPrefix(s.HashOn, hashOnHeader) || s.HashOn == hashOnHeader) {
+		problems = append(problems, fmt.Sprintf("%s: hash_on %q is not one of %s, %s or header:<Name>", label, s.HashOn, HashOnClientIP, HashOnPath))
+	}
+
+	if h := s.HealthCheck; h != nil {
+		h.applyDefaults()
+		if !strings.HasPrefix(h.Path, "/") {
+			problems = append(problems, fmt.Sprintf("%s: health_check path %q does not start with /", label, h.Path))
+		}
+		if h.Interval < 0 || h.Timeout < 0 || h.HealthyThreshold < 0 || h.UnhealthyThreshold < 0 {
+			problems = append(problems, label+": health_check settings must not be negative")
+		}
+	}
+	if s.PassiveHealthCheck.MaxFailures < 0 || s.PassiveHealthCheck.EjectionTime < 0 {
+		problems = append(problems, label+": passive_health_check settings must not be negative")
+	}
+	if s.PassiveHealthCheck.MaxFailures > 0 && s.PassiveHealthCheck.EjectionTime == 0 {
+		s.PassiveHealthCheck.EjectionTime = 30 * time.Second
+	}
+	return problems
+}
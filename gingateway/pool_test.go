@@ -0,0 +1,377 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newTestPool creates a pool of endpoints a, b and c
+func newTestPool(t *testing.T, strategy, hashOn string, passive PassiveHealthCheck) *Pool {
+	t.Helper()
+	pool, err := newPool("test", []string{"http://a:8080", "http://b:8080", "http://c:8080"}, strategy, hashOn, nil, passive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pool
+}
+
+// pick picks an endpoint for a request to path and returns its host
+func pick(t *testing.T, pool *Pool, path, clientIP string) string {
+	t.Helper()
+	e, err := pool.Pick(httptest.NewRequest(http.MethodGet, path, nil), clientIP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e.URL.Host
+}
+
+func TestPoolPickRoundRobin(t *testing.T) {
+	pool := newTestPool(t, LoadBalancerRoundRobin, "", PassiveHealthCheck{})
+
+	var got []string
+	for i := 0; i < 6; i++ {
+		got = append(got, pick(t, pool, "/", ""))
+	}
+	want := []string{"a:8080", "b:8080", "c:8080", "a:8080", "b:8080", "c:8080"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("picks = %v, want %v", got, want)
+		}
+	}
+
+	// Unavailable endpoints are skipped
+	pool.endpoints[1].healthy.Store(false)
+	for i := 0; i < 6; i++ {
+		if host := pick(t, pool, "/", ""); host == "b:8080" {
+			t.Fatal("picked unhealthy endpoint b")
+		}
+	}
+
+	for _, e := range pool.endpoints {
+		e.healthy.Store(false)
+	}
+	if _, err := pool.Pick(httptest.NewRequest(http.MethodGet, "/", nil), ""); !errors.Is(err, ErrNoHealthyEndpoint) {
+		t.Fatalf("Pick without available endpoints = %v, want ErrNoHealthyEndpoint", err)
+	}
+}
+
+func TestPoolPickLeastConnections(t *testing.T) {
+	pool := newTestPool(t, LoadBalancerLeastConnections, "", PassiveHealthCheck{})
+	pool.endpoints[0].inFlight.Store(3)
+	pool.endpoints[1].inFlight.Store(1)
+	pool.endpoints[2].inFlight.Store(2)
+
+	for i := 0; i < 3; i++ {
+		if host := pick(t, pool, "/", ""); host != "b:8080" {
+			t.Fatalf("pick %d = %s, want the least loaded b:8080", i, host)
+		}
+	}
+
+	// The least loaded endpoint is skipped while ejected
+	pool.endpoints[1].ejectedUntil.Store(time.Now().Add(time.Hour).UnixNano())
+	if host := pick(t, pool, "/", ""); host != "c:8080" {
+		t.Fatalf("pick with b ejected = %s, want c:8080", host)
+	}
+
+	// Ties are spread by the round-robin offset
+	for _, e := range pool.endpoints {
+		e.inFlight.Store(0)
+	}
+	pool.endpoints[1].ejectedUntil.Store(0)
+	seen := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		seen[pick(t, pool, "/", "")] = true
+	}
+	if len(seen) != 3 {
+		t.Fatalf("tied picks went to %v, want every endpoint", seen)
+	}
+}
+
+func TestPoolPickConsistentHash(t *testing.T) {
+	tests := []struct {
+		name   string
+		hashOn string
+		req    func(key string) (*http.Request, string)
+	}{
+		{"client ip", HashOnClientIP, func(key string) (*http.Request, string) {
+			return httptest.NewRequest(http.MethodGet, "/", nil), key
+		}},
+		{"path", HashOnPath, func(key string) (*http.Request, string) {
+			return httptest.NewRequest(http.MethodGet, "/items/"+key, nil), "198.51.100.1"
+		}},
+		{"header", hashOnHeader + "X-User-ID", func(key string) (*http.Request, string) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("X-User-ID", key)
+			return r, "198.51.100.1"
+		}},
+	}
+	keys := []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11", "12"}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := newTestPool(t, LoadBalancerConsistentHash, tt.hashOn, PassiveHealthCheck{})
+			pickKey := func(key string) *Endpoint {
+				t.Helper()
+				r, clientIP := tt.req(key)
+				e, err := pool.Pick(r, clientIP)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return e
+			}
+
+			// The same key always goes to the same endpoint
+			before := make(map[string]*Endpoint)
+			used := make(map[*Endpoint]bool)
+			for _, key := range keys {
+				before[key] = pickKey(key)
+				used[before[key]] = true
+				if again := pickKey(key); again != before[key] {
+					t.Fatalf("key %s moved from %s to %s", key, before[key].URL.Host, again.URL.Host)
+				}
+			}
+			if len(used) < 2 {
+				t.Fatalf("all keys hashed to one endpoint")
+			}
+
+			// Taking an endpoint down only moves its own keys
+			down := before[keys[0]]
+			down.healthy.Store(false)
+			for _, key := range keys {
+				e := pickKey(key)
+				if e == down {
+					t.Fatalf("key %s picked the unhealthy endpoint", key)
+				}
+				if before[key] != down && e != before[key] {
+					t.Fatalf("key %s moved from %s to %s although its endpoint is up", key, before[key].URL.Host, e.URL.Host)
+				}
+			}
+
+			for _, e := range pool.endpoints {
+				e.healthy.Store(false)
+			}
+			r, clientIP := tt.req(keys[0])
+			if _, err := pool.Pick(r, clientIP); !errors.Is(err, ErrNoHealthyEndpoint) {
+				t.Fatalf("Pick without available endpoints = %v, want ErrNoHealthyEndpoint", err)
+			}
+		})
+	}
+}
+
+func TestPoolPassiveEjection(t *testing.T) {
+	pool := newTestPool(t, LoadBalancerRoundRobin, "", PassiveHealthCheck{MaxFailures: 3, EjectionTime: 50 * time.Millisecond})
+	e := pool.endpoints[0]
+
+	// A success resets the failure streak
+	pool.Done(e, http.StatusBadGateway)
+	pool.Done(e, 0)
+	pool.Done(e, http.StatusOK)
+	pool.Done(e, http.StatusServiceUnavailable)
+	if !e.available(time.Now()) {
+		t.Fatal("endpoint ejected before MaxFailures failures in a row")
+	}
+
+	// Client errors are not failures of the endpoint
+	pool.Done(e, http.StatusNotFound)
+	pool.Done(e, http.StatusInternalServerError)
+	pool.Done(e, 0)
+	pool.Done(e, http.StatusBadGateway)
+	if e.available(time.Now()) {
+		t.Fatal("endpoint not ejected after MaxFailures failures in a row")
+	}
+	for i := 0; i < 6; i++ {
+		if host := pick(t, pool, "/", ""); host == e.URL.Host {
+			t.Fatal("picked ejected endpoint")
+		}
+	}
+
+	// It is re-admitted once EjectionTime is over
+	time.Sleep(60 * time.Millisecond)
+	if !e.available(time.Now()) {
+		t.Fatal("endpoint not re-admitted after EjectionTime")
+	}
+}
+
+func TestPoolActiveHealthCheck(t *testing.T) {
+	active := &ActiveHealthCheck{}
+	active.applyDefaults()
+	pool, err := newPool("test", []string{"http://a:8080"}, LoadBalancerRoundRobin, "", active, PassiveHealthCheck{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := pool.endpoints[0]
+	probeErr := errors.New("connection refused")
+
+	// UnhealthyThreshold failed probes in a row take it out of rotation
+	pool.recordProbe(e, probeErr)
+	pool.recordProbe(e, probeErr)
+	pool.recordProbe(e, nil)
+	pool.recordProbe(e, probeErr)
+	pool.recordProbe(e, probeErr)
+	if !e.healthy.Load() {
+		t.Fatal("endpoint unhealthy before UnhealthyThreshold failures in a row")
+	}
+	pool.recordProbe(e, probeErr)
+	if e.healthy.Load() {
+		t.Fatal("endpoint healthy after UnhealthyThreshold failures in a row")
+	}
+
+	// HealthyThreshold successful probes in a row re-admit it
+	pool.recordProbe(e, nil)
+	pool.recordProbe(e, probeErr)
+	pool.recordProbe(e, nil)
+	if e.healthy.Load() {
+		t.Fatal("endpoint re-admitted before HealthyThreshold successes in a row")
+	}
+	pool.recordProbe(e, nil)
+	if !e.healthy.Load() {
+		t.Fatal("endpoint not re-admitted after HealthyThreshold successes in a row")
+	}
+}
+
+// newTestGateway returns a gateway serving routes without middleware
+func newTestGateway(t *testing.T, routes ...*ServiceConfig) *Gateway {
+	t.Helper()
+	g := NewGateway(DefaultGatewayConfig())
+	setTestRoutes(t, g, routes...)
+	t.Cleanup(func() {
+		for _, service := range g.Routes().services {
+			service.pool.stopHealthChecks()
+		}
+		g.releaseProxies(&RouteTable{})
+	})
+	return g
+}
+
+// setTestRoutes makes routes live on g
+func setTestRoutes(t *testing.T, g *Gateway, routes ...*ServiceConfig) {
+	t.Helper()
+	for _, route := range routes {
+		if route.Middleware == nil {
+			route.Middleware = []string{}
+		}
+	}
+	table, err := g.NewRouteTable(&RoutesFile{Version: "test", Routes: routes}, "test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.SetRoutes(table)
+}
+
+func TestSetRoutesKeepsEndpointState(t *testing.T) {
+	route := func(upstreams ...string) *ServiceConfig {
+		return &ServiceConfig{
+			Name:               "orders",
+			BasePath:           "/api/v1/orders",
+			Upstreams:          upstreams,
+			PassiveHealthCheck: PassiveHealthCheck{MaxFailures: 1, EjectionTime: time.Hour},
+		}
+	}
+	g := newTestGateway(t, route("http://a:8080", "http://b:8080"))
+	old := g.Routes().services[0].pool
+	a, b := old.endpoints[0], old.endpoints[1]
+	a.healthy.Store(false)
+	old.Done(b, 0)
+	b.inFlight.Add(1)
+
+	// An edit of the routes file adds endpoint c
+	setTestRoutes(t, g, route("http://a:8080", "http://b:8080", "http://c:8080"))
+	pool := g.Routes().services[0].pool
+	newA, newB, newC := pool.endpoints[0], pool.endpoints[1], pool.endpoints[2]
+	if newA.healthy.Load() {
+		t.Error("unhealthy endpoint a is healthy after reload")
+	}
+	if newB.available(time.Now()) {
+		t.Error("ejected endpoint b is available after reload")
+	}
+	if !newC.available(time.Now()) {
+		t.Error("new endpoint c is not available")
+	}
+
+	// Requests still running on the old table release the shared count
+	b.inFlight.Add(-1)
+	if n := newB.inFlight.Load(); n != 0 {
+		t.Errorf("in-flight count of b after the old request finished = %d, want 0", n)
+	}
+}
+
+func TestUpstreamProxyErrors(t *testing.T) {
+	// An upstream that refuses connections
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	g := newTestGateway(t, &ServiceConfig{Name: "down", BasePath: "/api/v1/down", Target: down.URL})
+	service := g.Routes().services[0]
+	proxy := service.pool.endpoints[0].proxy
+
+	w := httptest.NewRecorder()
+	req, state := withProxyRequest(httptest.NewRequest(http.MethodGet, "/api/v1/down", nil), service)
+	proxy.ServeHTTP(w, req)
+	if !state.failed || w.Code != http.StatusBadGateway {
+		t.Errorf("unreachable upstream: failed = %v, status %d, want failed with 502", state.failed, w.Code)
+	}
+
+	// A client hanging up is not a failure of the endpoint
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w = httptest.NewRecorder()
+	req, state = withProxyRequest(httptest.NewRequest(http.MethodGet, "/api/v1/down", nil).WithContext(ctx), service)
+	proxy.ServeHTTP(w, req)
+	if state.failed || w.Code != StatusClientClosedRequest {
+		t.Errorf("cancelled request: failed = %v, status %d, want not failed with 499", state.failed, w.Code)
+	}
+}
+
+func TestProxyHandlerReleasesAbortedRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// An upstream that announces a longer body than it sends
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		buf.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 1000\r\n\r\npartial")
+		buf.Flush()
+	}))
+	defer upstream.Close()
+
+	g := newTestGateway(t, &ServiceConfig{
+		Name:               "partial",
+		BasePath:           "/api/v1/partial",
+		Target:             upstream.URL,
+		LoadBalancer:       LoadBalancerLeastConnections,
+		PassiveHealthCheck: PassiveHealthCheck{MaxFailures: 1, EjectionTime: time.Hour},
+	})
+	r := gin.New()
+	r.Any(apiPrefix+"/*path", g.ProxyHandler())
+	gateway := httptest.NewServer(r)
+	defer gateway.Close()
+
+	resp, err := http.Get(gateway.URL + "/api/v1/partial")
+	if err == nil {
+		_, err = io.Copy(io.Discard, bufio.NewReader(resp.Body))
+		resp.Body.Close()
+	}
+	if err == nil {
+		t.Fatal("truncated response was read without an error")
+	}
+
+	e := g.Routes().services[0].pool.endpoints[0]
+	if n := e.inFlight.Load(); n != 0 {
+		t.Errorf("in-flight count after aborted request = %d, want 0", n)
+	}
+	if e.available(time.Now()) {
+		t.Error("endpoint not ejected after its response broke off")
+	}
+}
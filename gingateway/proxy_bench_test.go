@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Benchmarks of proxying a request through the gateway to a local upstream.
+// Compare the proxies built per request, as the gateway used to, with the
+// proxies built once per upstream:
+//
+//	go test -run '^$' -bench Proxy -benchmem -cpu 1,8
+
+// newBenchUpstream starts an upstream answering every request with size bytes
+func newBenchUpstream(b *testing.B, size int) *httptest.Server {
+	body := bytes.Repeat([]byte("x"), size)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Write(body)
+	}))
+	b.Cleanup(srv.Close)
+	return srv
+}
+
+// newBenchGateway returns the gateway's router with a single route to target
+func newBenchGateway(b *testing.B, cfg *GatewayConfig, target string) http.Handler {
+	g := NewGateway(cfg)
+	table, err := g.NewRouteTable(&RoutesFile{Version: "bench", Routes: []*ServiceConfig{{
+		Name:       "bench",
+		BasePath:   "/api/v1/bench",
+		Target:     target,
+		Middleware: []string{},
+	}}}, "bench", "")
+	if err != nil {
+		b.Fatal(err)
+	}
+	g.SetRoutes(table)
+	b.Cleanup(func() { g.releaseProxies(&RouteTable{}) })
+
+	r := gin.New()
+	r.Any(apiPrefix+"/*path", g.ProxyHandler())
+	return r
+}
+
+// newPerRequestProxyRouter proxies like the gateway did before proxies were
+// shared: a reverse proxy on the default transport built for every request
+func newPerRequestProxyRouter(target string) http.Handler {
+	transport := newTracingTransport(http.DefaultTransport)
+	r := gin.New()
+	r.Any(apiPrefix+"/*path", func(c *gin.Context) {
+		targetURL, err := url.Parse(target)
+		if err != nil {
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		proxy := httputil.NewSingleHostReverseProxy(targetURL)
+		proxy.Transport = transport
+		director := proxy.Director
+		proxy.Director = func(req *http.Request) {
+			director(req)
+			req.Host = targetURL.Host
+		}
+		proxy.ServeHTTP(c.Writer, c.Request)
+	})
+	return r
+}
+
+// benchmarkRouter serves h and sends GET requests through it from every
+// benchmark goroutine. The client keeps enough idle connections that only
+// the gateway's connections to the upstream differ between benchmarks.
+func benchmarkRouter(b *testing.B, h http.Handler) {
+	gateway := httptest.NewServer(h)
+	defer gateway.Close()
+	client := &http.Client{Transport: &http.Transport{MaxIdleConnsPerHost: 256}}
+	defer client.CloseIdleConnections()
+	target := gateway.URL + "/api/v1/bench/items/7"
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			resp, err := client.Get(target)
+			if err != nil {
+				b.Error(err)
+				return
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				b.Errorf("status %d", resp.StatusCode)
+				return
+			}
+		}
+	})
+}
+
+func BenchmarkProxy(b *testing.B) {
+	gin.SetMode(gin.ReleaseMode)
+	slog.SetDefault(slog.New(slog.NewJSONHandler(io.Discard, nil)))
+
+	for _, size := range []struct {
+		name  string
+		bytes int
+	}{
+		{"1KiB", 1 << 10},
+		{"64KiB", 64 << 10},
+	} {
+		upstream := newBenchUpstream(b, size.bytes)
+
+		b.Run("per-request/"+size.name, func(b *testing.B) {
+			benchmarkRouter(b, newPerRequestProxyRouter(upstream.URL))
+		})
+		b.Run("shared/"+size.name, func(b *testing.B) {
+			benchmarkRouter(b, newBenchGateway(b, DefaultGatewayConfig(), upstream.URL))
+		})
+	}
+}
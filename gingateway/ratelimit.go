@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// middlewareRateLimit applies the rate_limits of a route
+const middlewareRateLimit = "ratelimit"
+
+// HeaderAPIKey carries the API key that api_key rate limits are keyed by
+const HeaderAPIKey = "X-API-Key"
+
+// APIKeySet holds the known API keys by their hash, so buckets and shared
+// stores never see a key itself
+type APIKeySet map[string]struct{}
+
+// NewAPIKeySet creates the set of known API keys
+func NewAPIKeySet(keys []string) APIKeySet {
+	set := make(APIKeySet, len(keys))
+	for _, key := range keys {
+		set[hashAPIKey(key)] = struct{}{}
+	}
+	return set
+}
+
+// Lookup returns the hash of key if it is a known API key
+func (s APIKeySet) Lookup(key string) (string, bool) {
+	if key == "" {
+		return "", false
+	}
+	hash := hashAPIKey(key)
+	_, ok := s[hash]
+	return hash, ok
+}
+
+// hashAPIKey returns the truncated SHA-256 of an API key
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:16])
+}
+
+// Rate limit keys, the client property requests are counted by
+const (
+	RateLimitKeyClientIP   = "client_ip"
+	RateLimitKeyAPIKey     = "api_key"
+	RateLimitKeyJWTSubject = "jwt_subject"
+)
+
+// RateLimit is a token bucket holding Burst tokens and refilled with
+// Requests tokens every Per. Each request matching Methods takes a token
+// from the bucket of its key and is rejected with 429 when it is empty.
+type RateLimit struct {
+	// Key is client_ip, api_key or jwt_subject. Requests without a known API
+	// key or a verified token are counted by client IP.
+	Key      string        `yaml:"key" json:"key"`
+	Requests int           `yaml:"requests" json:"requests"`
+	Per      time.Duration `yaml:"per" json:"per"`
+	// Burst is the bucket size; it defaults to Requests
+	Burst int `yaml:"burst" json:"burst"`
+	// Methods restricts the limit to some methods; empty means all
+	Methods []string `yaml:"methods" json:"methods,omitempty"`
+}
+
+// bucket returns the token bucket of the limit
+func (l *RateLimit) bucket() TokenBucket {
+	return TokenBucket{
+		Capacity: l.Burst,
+		Rate:     float64(l.Requests) / l.Per.Seconds(),
+	}
+}
+
+// TokenBucket is the size and refill rate in tokens per second of a bucket
+type TokenBucket struct {
+	Capacity int
+	Rate     float64
+}
+
+// RateLimitResult is the state of a bucket after a request took a token
+type RateLimitResult struct {
+	Allowed bool
+	// Remaining is the number of whole tokens left
+	Remaining int
+	// Reset is how long the bucket takes to fill up again
+	Reset time.Duration
+	// RetryAfter is how long a rejected request should wait for a token
+	RetryAfter time.Duration
+}
+
+// RateLimitStore keeps the token buckets. The in-memory store limits each
+// gateway replica on its own; a store backed by a shared database such as
+// Redis implements Take atomically to limit all replicas together.
+type RateLimitStore interface {
+	// Take takes a token from the bucket of key, creating a full bucket
+	// when key has none
+	Take(ctx context.Context, key string, bucket TokenBucket) (RateLimitResult, error)
+}
+
+// memoryBucket is the state of a token bucket in the memory store
+type memoryBucket struct {
+	tokens  float64
+	updated time.Time
+	// full is when the bucket is full again and can be forgotten
+	full time.Time
+}
+
+// MemoryRateLimitStore keeps token buckets in the gateway's memory
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+	now     func() time.Time
+}
+
+// NewMemoryRateLimitStore creates an empty in-memory store
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets: make(map[string]*memoryBucket),
+		now:     time.Now,
+	}
+}
+
+// Take implements RateLimitStore
+func (s *MemoryRateLimitStore) Take(_ context.Context, key string, bucket TokenBucket) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &memoryBucket{tokens: float64(bucket.Capacity), updated: now}
+		s.buckets[key] = b
+	}
+	elapsed := now.Sub(b.updated).Seconds()
+	b.tokens = math.Min(float64(bucket.Capacity), b.tokens+elapsed*bucket.Rate)
+	b.updated = now
+
+	var result RateLimitResult
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsToDuration((1 - b.tokens) / bucket.Rate)
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = secondsToDuration((float64(bucket.Capacity) - b.tokens) / bucket.Rate)
+	b.full = now.Add(result.Reset)
+	return result, nil
+}
+
+// Sweep forgets the buckets that have filled up again, since a missing
+// bucket is created full
+func (s *MemoryRateLimitStore) Sweep() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for key, b := range s.buckets {
+		if !now.Before(b.full) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// StartSweeping sweeps the store every interval until ctx is done
+func (s *MemoryRateLimitStore) StartSweeping(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.Sweep()
+			}
+		}
+	}()
+}
+
+// secondsToDuration converts fractional seconds to a duration
+func secondsToDuration(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// rateLimitKey returns the key kind and value a request is counted by,
+// falling back to the client IP when the request has no known API key or
+// verified subject. Unknown API keys are not trusted, since any client could
+// get a fresh bucket by sending a new one.
+func rateLimitKey(c *gin.Context, kind string, apiKeys APIKeySet) (string, string) {
+	switch kind {
+	case RateLimitKeyAPIKey:
+		if hash, ok := apiKeys.Lookup(c.GetHeader(HeaderAPIKey)); ok {
+			return kind, hash
+		}
+	case RateLimitKeyJWTSubject:
+		if principal, ok := c.Get("principal"); ok {
+			return kind, principal.(*Principal).Subject
+		}
+	}
+	return RateLimitKeyClientIP, c.ClientIP()
+}
+
+// RateLimitMiddleware enforces the rate limits of the route, taking a token
+// from the bucket of every limit matching the request. It reports the limit
+// closest to running out in the RateLimit-* headers and rejects the request
+// with 429 and Retry-After once a bucket is empty. List it after auth so
+// jwt_subject limits see the verified subject. If the store fails, requests
+// are let through.
+func RateLimitMiddleware(store RateLimitStore, apiKeys APIKeySet) RouteMiddleware {
+	return func(c *gin.Context, service *ServiceConfig) {
+		var (
+			reported *RateLimit
+			result   RateLimitResult
+		)
+		for i, limit := range service.RateLimits {
+			if len(limit.Methods) > 0 && !containsFold(limit.Methods, c.Request.Method) {
+				continue
+			}
+
+			kind, value := rateLimitKey(c, limit.Key, apiKeys)
+			key := fmt.Sprintf("%s:%d:%s:%s", service.Name, i, kind, value)
+			r, err := store.Take(c.Request.Context(), key, limit.bucket())
+			if err != nil {
+				slog.WarnContext(c.Request.Context(), "Rate limit store failed, allowing request", "upstream", service.Name, "error", err)
+				rateLimitErrors.WithLabelValues(service.Name).Inc()
+				continue
+			}
+			if reported == nil || !r.Allowed || r.Remaining < result.Remaining {
+				reported, result = limit, r
+			}
+			if !r.Allowed {
+				rateLimited.WithLabelValues(service.Name, limit.Key).Inc()
+				break
+			}
+		}
+		if reported == nil {
+			return
+		}
+
+		c.Header("RateLimit-Limit", strconv.Itoa(reported.Burst))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+		c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%d;burst=%d", reported.Requests, ceilSeconds(reported.Per), reported.Burst))
+		if !result.Allowed {
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error":       "Too many requests",
+				"retry_after": ceilSeconds(result.RetryAfter),
+			})
+		}
+	}
+}
+
+// ceilSeconds rounds d up to whole seconds
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// validateRateLimits checks the rate limits of a route, filling in the
+// burst default, and returns the problems found
+func (s *ServiceConfig) validateRateLimits(label string) []string {
+	var problems []string
+	if len(s.RateLimits) > 0 && !containsFold(s.Middleware, middlewareRateLimit) {
+		problems = append(problems, fmt.Sprintf("%s: rate_limits need the %s middleware", label, middlewareRateLimit))
+	}
+	for i, limit := range s.RateLimits {
+		if limit == nil {
+			problems = append(problems, fmt.Sprintf("%s: rate_limits[%d] is empty", label, i))
+			continue
+		}
+		switch limit.Key {
+		case RateLimitKeyClientIP, RateLimitKeyAPIKey, RateLimitKeyJWTSubject:
+		default:
+			problems = append(problems, fmt.Sprintf("%s: rate_limits[%d]: key %q is not one of %s, %s or %s", label, i, limit.Key,
+				RateLimitKeyClientIP, RateLimitKeyAPIKey, RateLimitKeyJWTSubject))
+		}
+		if limit.Requests <= 0 || limit.Per <= 0 {
+			problems = append(problems, fmt.Sprintf("%s: rate_limits[%d]: requests and per must be positive", label, i))
+		}
+		if limit.Burst < 0 {
+			problems = append(problems, fmt.Sprintf("%s: rate_limits[%d]: burst must not be negative", label, i))
+		}
+		if limit.Burst == 0 {
+			limit.Burst = limit.Requests
+		}
+		for j, method := range limit.Methods {
+			limit.Methods[j] = strings.ToUpper(method)
+		}
+	}
+	return problems
+}
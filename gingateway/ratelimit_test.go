@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fakeClock is a settable clock for the memory store
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestStore() (*MemoryRateLimitStore, *fakeClock) {
+	clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+	store := NewMemoryRateLimitStore()
+	store.now = clock.Now
+	return store, clock
+}
+
+func TestMemoryRateLimitStoreTake(t *testing.T) {
+	// 2 tokens a second, holding up to 3
+	bucket := TokenBucket{Capacity: 3, Rate: 2}
+	store, clock := newTestStore()
+	take := func() RateLimitResult {
+		t.Helper()
+		r, err := store.Take(context.Background(), "k", bucket)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	for i, want := range []int{2, 1, 0} {
+		r := take()
+		if !r.Allowed || r.Remaining != want {
+			t.Fatalf("take %d = %+v, want allowed with %d remaining", i, r, want)
+		}
+	}
+	r := take()
+	if r.Allowed {
+		t.Fatalf("take from empty bucket allowed: %+v", r)
+	}
+	if r.RetryAfter != 500*time.Millisecond {
+		t.Errorf("RetryAfter = %v, want 500ms", r.RetryAfter)
+	}
+	if r.Reset != 1500*time.Millisecond {
+		t.Errorf("Reset = %v, want 1.5s", r.Reset)
+	}
+
+	clock.Advance(500 * time.Millisecond)
+	if r := take(); !r.Allowed || r.Remaining != 0 {
+		t.Fatalf("take after refill = %+v, want allowed with 0 remaining", r)
+	}
+
+	// A long pause refills the bucket up to its capacity only
+	clock.Advance(time.Hour)
+	if r := take(); !r.Allowed || r.Remaining != 2 {
+		t.Fatalf("take after pause = %+v, want allowed with 2 remaining", r)
+	}
+
+	// Other keys have buckets of their own
+	if r, _ := store.Take(context.Background(), "other", bucket); !r.Allowed || r.Remaining != 2 {
+		t.Fatalf("take from other key = %+v, want allowed with 2 remaining", r)
+	}
+}
+
+func TestMemoryRateLimitStoreSweep(t *testing.T) {
+	bucket := TokenBucket{Capacity: 2, Rate: 1}
+	store, clock := newTestStore()
+	store.Take(context.Background(), "a", bucket)
+	clock.Advance(500 * time.Millisecond)
+	store.Take(context.Background(), "b", bucket)
+
+	// a is full again after 1s, b after 1.5s
+	clock.Advance(500 * time.Millisecond)
+	store.Sweep()
+	if _, ok := store.buckets["a"]; ok {
+		t.Error("full bucket a was not swept")
+	}
+	if _, ok := store.buckets["b"]; !ok {
+		t.Error("draining bucket b was swept")
+	}
+}
+
+// newKeyContext returns a context for a request from remoteAddr with headers
+func newKeyContext(t *testing.T, trustedProxies []string, remoteAddr string, headers map[string]string) *gin.Context {
+	t.Helper()
+	c, engine := gin.CreateTestContext(httptest.NewRecorder())
+	if err := engine.SetTrustedProxies(trustedProxies); err != nil {
+		t.Fatal(err)
+	}
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/orders", nil)
+	c.Request.RemoteAddr = remoteAddr
+	for name, value := range headers {
+		c.Request.Header.Set(name, value)
+	}
+	return c
+}
+
+func TestRateLimitKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	apiKeys := NewAPIKeySet([]string{"known-key"})
+
+	tests := []struct {
+		name           string
+		kind           string
+		trustedProxies []string
+		remoteAddr     string
+		headers        map[string]string
+		principal      *Principal
+		wantKind       string
+		wantValue      string
+	}{
+		{
+			name:       "client ip",
+			kind:       RateLimitKeyClientIP,
+			remoteAddr: "203.0.113.7:4000",
+			wantKind:   RateLimitKeyClientIP,
+			wantValue:  "203.0.113.7",
+		},
+		{
+			name:       "forwarded for ignored without trusted proxies",
+			kind:       RateLimitKeyClientIP,
+			remoteAddr: "203.0.113.7:4000",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			wantKind:   RateLimitKeyClientIP,
+			wantValue:  "203.0.113.7",
+		},
+		{
+			name:           "forwarded for from trusted proxy",
+			kind:           RateLimitKeyClientIP,
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "10.1.2.3:4000",
+			headers:        map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			wantKind:       RateLimitKeyClientIP,
+			wantValue:      "198.51.100.1",
+		},
+		{
+			name:           "forwarded for from untrusted proxy",
+			kind:           RateLimitKeyClientIP,
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "203.0.113.7:4000",
+			headers:        map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			wantKind:       RateLimitKeyClientIP,
+			wantValue:      "203.0.113.7",
+		},
+		{
+			name:       "known api key",
+			kind:       RateLimitKeyAPIKey,
+			remoteAddr: "203.0.113.7:4000",
+			headers:    map[string]string{HeaderAPIKey: "known-key"},
+			wantKind:   RateLimitKeyAPIKey,
+			wantValue:  hashAPIKey("known-key"),
+		},
+		{
+			name:       "unknown api key",
+			kind:       RateLimitKeyAPIKey,
+			remoteAddr: "203.0.113.7:4000",
+			headers:    map[string]string{HeaderAPIKey: "made-up-key"},
+			wantKind:   RateLimitKeyClientIP,
+			wantValue:  "203.0.113.7",
+		},
+		{
+			name:       "missing api key",
+			kind:       RateLimitKeyAPIKey,
+			remoteAddr: "203.0.113.7:4000",
+			wantKind:   RateLimitKeyClientIP,
+			wantValue:  "203.0.113.7",
+		},
+		{
+			name:       "jwt subject",
+			kind:       RateLimitKeyJWTSubject,
+			remoteAddr: "203.0.113.7:4000",
+			principal:  &Principal{Subject: "42"},
+			wantKind:   RateLimitKeyJWTSubject,
+			wantValue:  "42",
+		},
+		{
+			name:       "anonymous jwt subject",
+			kind:       RateLimitKeyJWTSubject,
+			remoteAddr: "203.0.113.7:4000",
+			wantKind:   RateLimitKeyClientIP,
+			wantValue:  "203.0.113.7",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newKeyContext(t, tt.trustedProxies, tt.remoteAddr, tt.headers)
+			if tt.principal != nil {
+				c.Set("principal", tt.principal)
+			}
+			kind, value := rateLimitKey(c, tt.kind, apiKeys)
+			if kind != tt.wantKind || value != tt.wantValue {
+				t.Errorf("rateLimitKey = %s %q, want %s %q", kind, value, tt.wantKind, tt.wantValue)
+			}
+		})
+	}
+}
+
+// failingStore is a rate limit store that is always down
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, TokenBucket) (RateLimitResult, error) {
+	return RateLimitResult{}, errors.New("store down")
+}
+
+// serveRateLimited runs the rate limit middleware of service for a request
+func serveRateLimited(mw RouteMiddleware, service *ServiceConfig, method string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(method, "/api/v1/orders", nil)
+	c.Request.RemoteAddr = "203.0.113.7:4000"
+	mw(c, service)
+	if !c.IsAborted() {
+		c.Status(http.StatusOK)
+	}
+	c.Writer.WriteHeaderNow()
+	return w
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := &ServiceConfig{
+		Name:       "orders",
+		Middleware: []string{middlewareRateLimit},
+		RateLimits: []*RateLimit{
+			{Key: RateLimitKeyClientIP, Requests: 2, Per: time.Minute, Methods: []string{"post"}},
+			{Key: RateLimitKeyClientIP, Requests: 100, Per: time.Minute},
+		},
+	}
+	if problems := service.validateRateLimits("orders"); len(problems) > 0 {
+		t.Fatal(problems)
+	}
+	store, _ := newTestStore()
+	mw := RateLimitMiddleware(store, nil)
+
+	// The tighter POST limit is reported as the one closest to running out
+	w := serveRateLimited(mw, service, http.MethodPost)
+	if w.Code != http.StatusOK {
+		t.Fatalf("first POST status = %d", w.Code)
+	}
+	for header, want := range map[string]string{
+		"RateLimit-Limit":     "2",
+		"RateLimit-Remaining": "1",
+		"RateLimit-Reset":     "30",
+		"RateLimit-Policy":    "2;w=60;burst=2",
+	} {
+		if got := w.Header().Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+	if got := w.Header().Get("Retry-After"); got != "" {
+		t.Errorf("allowed request has Retry-After %q", got)
+	}
+
+	serveRateLimited(mw, service, http.MethodPost)
+	w = serveRateLimited(mw, service, http.MethodPost)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("third POST status = %d, want 429", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "30" {
+		t.Errorf("Retry-After = %q, want 30", got)
+	}
+	if got := w.Header().Get("RateLimit-Remaining"); got != "0" {
+		t.Errorf("RateLimit-Remaining = %q, want 0", got)
+	}
+
+	// GET only counts against the general limit, which the two allowed
+	// POSTs took from as well
+	w = serveRateLimited(mw, service, http.MethodGet)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET status = %d", w.Code)
+	}
+	if got := w.Header().Get("RateLimit-Limit"); got != "100" {
+		t.Errorf("GET RateLimit-Limit = %q, want 100", got)
+	}
+	if got := w.Header().Get("RateLimit-Remaining"); got != "97" {
+		t.Errorf("GET RateLimit-Remaining = %q, want 97", got)
+	}
+}
+
+func TestRateLimitMiddlewareStoreFailure(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := &ServiceConfig{
+		Name:       "orders",
+		Middleware: []string{middlewareRateLimit},
+		RateLimits: []*RateLimit{{Key: RateLimitKeyClientIP, Requests: 1, Per: time.Minute}},
+	}
+	service.validateRateLimits("orders")
+
+	w := serveRateLimited(RateLimitMiddleware(failingStore{}, nil), service, http.MethodGet)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want requests let through", w.Code)
+	}
+	if got := w.Header().Get("RateLimit-Limit"); got != "" {
+		t.Errorf("RateLimit-Limit = %q without a store", got)
+	}
+}
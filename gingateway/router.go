@@ -0,0 +1,281 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RewriteRule replaces the upstream path of requests whose path matches a
+// regular expression. Replace may refer to capture groups as $1 or ${name}.
+type RewriteRule struct {
+	Match   string `yaml:"match" json:"match"`
+	Replace string `yaml:"replace" json:"replace"`
+	re      *regexp.Regexp
+}
+
+// RouteCandidate explains whether a route matched a request and why not
+type RouteCandidate struct {
+	Route    string `json:"route"`
+	BasePath string `json:"base_path"`
+	Matched  bool   `json:"matched"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// RouteExplanation describes how a request would be routed and proxied
+type RouteExplanation struct {
+	Method  string `json:"method"`
+	Host    string `json:"host,omitempty"`
+	Path    string `json:"path"`
+	Matched bool   `json:"matched"`
+	Route   string `json:"route,omitempty"`
+	// UpstreamPath is the path sent to the endpoint the request is balanced to
+	UpstreamPath string `json:"upstream_path,omitempty"`
+	// Upstreams are the endpoints of the route and their state
+	Upstreams    []EndpointStatus `json:"upstreams,omitempty"`
+	LoadBalancer string           `json:"load_balancer,omitempty"`
+	// Public reports that the path bypasses token verification
+	Public     bool             `json:"public"`
+	Middleware []string         `json:"middleware,omitempty"`
+	Version    string           `json:"version"`
+	Candidates []RouteCandidate `json:"candidates"`
+}
+
+// matchesBasePath reports whether path is the base path or below it. A base
+// path only matches at a segment boundary, so /api/v1/users does not match
+// /api/v1/usersX.
+func matchesBasePath(basePath, path string) bool {
+	return path == basePath || strings.HasPrefix(path, strings.TrimSuffix(basePath, "/")+"/")
+}
+
+// specificity counts the predicates of a route, which decide between routes
+// with the same base path
+func (s *ServiceConfig) specificity() int {
+	n := len(s.Headers)
+	if len(s.Methods) > 0 {
+		n++
+	}
+	if len(s.Hosts) > 0 {
+		n++
+	}
+	return n
+}
+
+// sortRoutes orders routes for matching: longer base paths first, then
+// routes with more predicates, then in file order
+func sortRoutes(services []*ServiceConfig) []*ServiceConfig {
+	order := append([]*ServiceConfig(nil), services...)
+	sort.SliceStable(order, func(i, j int) bool {
+		if len(order[i].BasePath) != len(order[j].BasePath) {
+			return len(order[i].BasePath) > len(order[j].BasePath)
+		}
+		return order[i].specificity() > order[j].specificity()
+	})
+	return order
+}
+
+// mismatch returns why the route does not match a request, or "" if it does
+func (s *ServiceConfig) mismatch(method, host, path string, header http.Header) string {
+	if !matchesBasePath(s.BasePath, path) {
+		return fmt.Sprintf("path is not under %s", s.BasePath)
+	}
+	if len(s.Methods) > 0 && !containsFold(s.Methods, method) {
+		return fmt.Sprintf("method %s is not one of %s", method, strings.Join(s.Methods, ", "))
+	}
+	if len(s.Hosts) > 0 && !matchesHost(s.Hosts, host) {
+		return fmt.Sprintf("host %q is not one of %s", host, strings.Join(s.Hosts, ", "))
+	}
+	for _, name := range sortedKeys(s.Headers) {
+		want := s.Headers[name]
+		values, ok := header[http.CanonicalHeaderKey(name)]
+		if !ok {
+			return fmt.Sprintf("header %s is missing", name)
+		}
+		if want != "*" && !containsFold(values, want) {
+			return fmt.Sprintf("header %s is not %q", name, want)
+		}
+	}
+	return ""
+}
+
+// Match returns the route of a request: the first route in matching order
+// whose base path contains the path and whose predicates all hold
+func (t *RouteTable) Match(r *http.Request) *ServiceConfig {
+	for _, service := range t.order {
+		if service.mismatch(r.Method, r.Host, r.URL.Path, r.Header) == "" {
+			return service
+		}
+	}
+	return nil
+}
+
+// Explain evaluates every route against a request in matching order
+func (t *RouteTable) Explain(r *http.Request) *RouteExplanation {
+	explanation := &RouteExplanation{
+		Method:     r.Method,
+		Host:       r.Host,
+		Path:       r.URL.Path,
+		Version:    t.Version,
+		Candidates: make([]RouteCandidate, 0, len(t.order)),
+	}
+	for _, service := range t.order {
+		candidate := RouteCandidate{Route: service.Name, BasePath: service.BasePath}
+		switch {
+		case explanation.Matched:
+			candidate.Reason = "a preceding route matched"
+		default:
+			candidate.Reason = service.mismatch(r.Method, r.Host, r.URL.Path, r.Header)
+		}
+		if candidate.Reason == "" {
+			candidate.Matched = true
+			explanation.Matched = true
+			explanation.Route = service.Name
+			explanation.Public = isPublicPath(service.PublicPaths, r.Method, r.URL.Path)
+			explanation.Middleware = service.Middleware
+			explanation.UpstreamPath = service.rewritePath(r.URL.Path)
+			explanation.Upstreams = service.pool.Status()
+			explanation.LoadBalancer = service.LoadBalancer
+		}
+		explanation.Candidates = append(explanation.Candidates, candidate)
+	}
+	return explanation
+}
+
+// rewritePath returns the path sent upstream: the request path without the
+// base path when StripPrefix is set, then rewritten by the first matching
+// rewrite rule
+func (s *ServiceConfig) rewritePath(path string) string {
+	if s.StripPrefix {
+		path = strings.TrimPrefix(path, strings.TrimSuffix(s.BasePath, "/"))
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+	}
+	for _, rule := range s.Rewrite {
+		if rule.re.MatchString(path) {
+			return rule.re.ReplaceAllString(path, rule.Replace)
+		}
+	}
+	return path
+}
+
+// compileRouting normalizes the predicates of a route and compiles its
+// rewrite rules, returning the problems found
+func (s *ServiceConfig) compileRouting(label string) []string {
+	var problems []string
+	for i, method := range s.Methods {
+		s.Methods[i] = strings.ToUpper(method)
+		if method == "" || strings.ContainsAny(method, " /") {
+			problems = append(problems, fmt.Sprintf("%s: method %q is invalid", label, method))
+		}
+	}
+	for i, host := range s.Hosts {
+		s.Hosts[i] = strings.ToLower(host)
+		if host == "" || strings.Contains(host, "/") {
+			problems = append(problems, fmt.Sprintf("%s: host %q is invalid", label, host))
+		}
+	}
+	for name := range s.Headers {
+		if name == "" || strings.ContainsAny(name, " :") {
+			problems = append(problems, fmt.Sprintf("%s: header name %q is invalid", label, name))
+		}
+	}
+
+	rules := make([]RewriteRule, len(s.Rewrite))
+	for i, rule := range s.Rewrite {
+		re, err := regexp.Compile(rule.Match)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: rewrite[%d]: %v", label, i, err))
+			continue
+		}
+		if !strings.HasPrefix(rule.Replace, "/") {
+			problems = append(problems, fmt.Sprintf("%s: rewrite[%d]: replace %q does not start with /", label, i, rule.Replace))
+		}
+		rule.re = re
+		rules[i] = rule
+	}
+	s.Rewrite = rules
+	return problems
+}
+
+// matchesHost reports whether host, ignoring its port, is one of hosts.
+// "*.example.com" matches any subdomain of example.com.
+func matchesHost(hosts []string, host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	for _, pattern := range hosts {
+		if suffix, ok := strings.CutPrefix(pattern, "*"); ok {
+			if strings.HasSuffix(host, suffix) && len(host) > len(suffix) {
+				return true
+			}
+			continue
+		}
+		if host == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// containsFold reports whether values contains value, ignoring case
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedKeys returns the keys of m in order, so reasons are deterministic
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ExplainHandler reports which route a request would take without proxying
+// it. The request is described by the method, path and host query
+// parameters and repeated header=Name:value parameters.
+func (g *Gateway) ExplainHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		target := c.Query("path")
+		if !strings.HasPrefix(target, "/") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "path must be an absolute path"})
+			return
+		}
+		u, err := url.ParseRequestURI(target)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid path", "detail": err.Error()})
+			return
+		}
+
+		req := &http.Request{
+			Method: strings.ToUpper(c.DefaultQuery("method", http.MethodGet)),
+			Host:   c.DefaultQuery("host", c.Request.Host),
+			URL:    u,
+			Header: make(http.Header),
+		}
+		for _, h := range c.QueryArray("header") {
+			name, value, ok := strings.Cut(h, ":")
+			if !ok || strings.TrimSpace(name) == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("header %q is not of the form Name:value", h)})
+				return
+			}
+			req.Header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+		}
+
+		c.JSON(http.StatusOK, g.Routes().Explain(req))
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMatchesBasePath(t *testing.T) {
+	tests := []struct {
+		basePath string
+		path     string
+		want     bool
+	}{
+		{"/api/v1/users", "/api/v1/users", true},
+		{"/api/v1/users", "/api/v1/users/", true},
+		{"/api/v1/users", "/api/v1/users/42", true},
+		{"/api/v1/users", "/api/v1/usersX", false},
+		{"/api/v1/users", "/api/v1/users-admin/1", false},
+		{"/api/v1/users", "/api/v1/user", false},
+		{"/api/v1/users", "/api/v1", false},
+		{"/api/v1/users/", "/api/v1/users/42", true},
+		{"/api/v1/users/", "/api/v1/usersX", false},
+		{"/api/v1", "/api/v1/orders", true},
+	}
+	for _, tt := range tests {
+		if got := matchesBasePath(tt.basePath, tt.path); got != tt.want {
+			t.Errorf("matchesBasePath(%q, %q) = %v, want %v", tt.basePath, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestMatchesHost(t *testing.T) {
+	tests := []struct {
+		hosts []string
+		host  string
+		want  bool
+	}{
+		{[]string{"shop.example.com"}, "shop.example.com", true},
+		{[]string{"shop.example.com"}, "SHOP.example.com:8443", true},
+		{[]string{"shop.example.com"}, "admin.example.com", false},
+		{[]string{"*.example.com"}, "eu.shop.example.com", true},
+		{[]string{"*.example.com"}, "example.com", false},
+		{[]string{"*.example.com"}, "badexample.com", false},
+	}
+	for _, tt := range tests {
+		if got := matchesHost(tt.hosts, tt.host); got != tt.want {
+			t.Errorf("matchesHost(%v, %q) = %v, want %v", tt.hosts, tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestRouteTableMatch(t *testing.T) {
+	g := newTestGateway(t,
+		&ServiceConfig{Name: "api", BasePath: "/api/v1", Target: "http://api:8080"},
+		&ServiceConfig{Name: "users", BasePath: "/api/v1/users", Target: "http://users:8080"},
+		&ServiceConfig{Name: "users-admin", BasePath: "/api/v1/users/admin", Target: "http://admin:8080"},
+		&ServiceConfig{Name: "orders", BasePath: "/api/v1/orders", Target: "http://orders:8080"},
+		&ServiceConfig{Name: "orders-write", BasePath: "/api/v1/orders", Target: "http://orders-write:8080", Methods: []string{"post", "PUT"}},
+		&ServiceConfig{Name: "orders-v2", BasePath: "/api/v1/orders", Target: "http://orders-v2:8080", Headers: map[string]string{"X-API-Version": "2"}},
+		&ServiceConfig{Name: "orders-eu", BasePath: "/api/v1/orders", Target: "http://orders-eu:8080", Hosts: []string{"*.eu.example.com"}, Headers: map[string]string{"X-Tenant": "*"}},
+	)
+
+	tests := []struct {
+		name    string
+		method  string
+		host    string
+		path    string
+		headers map[string]string
+		want    string
+	}{
+		{name: "longest prefix", path: "/api/v1/users/admin/settings", want: "users-admin"},
+		{name: "shorter prefix", path: "/api/v1/users/42", want: "users"},
+		{name: "segment boundary", path: "/api/v1/usersX", want: "api"},
+		{name: "base path itself", path: "/api/v1/users", want: "users"},
+		{name: "method predicate", method: http.MethodPost, path: "/api/v1/orders", want: "orders-write"},
+		{name: "method predicate mismatch", method: http.MethodDelete, path: "/api/v1/orders/7", want: "orders"},
+		{name: "header predicate", path: "/api/v1/orders/7", headers: map[string]string{"X-Api-Version": "2"}, want: "orders-v2"},
+		{name: "header predicate mismatch", path: "/api/v1/orders/7", headers: map[string]string{"X-API-Version": "1"}, want: "orders"},
+		{name: "more predicates first", host: "shop.eu.example.com", path: "/api/v1/orders", headers: map[string]string{"X-Tenant": "acme", "X-API-Version": "2"}, want: "orders-eu"},
+		{name: "host predicate mismatch", host: "shop.us.example.com", path: "/api/v1/orders", headers: map[string]string{"X-Tenant": "acme"}, want: "orders"},
+		{name: "wildcard header needs the header", host: "shop.eu.example.com", path: "/api/v1/orders", want: "orders"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			r := httptest.NewRequest(method, tt.path, nil)
+			if tt.host != "" {
+				r.Host = tt.host
+			}
+			for name, value := range tt.headers {
+				r.Header.Set(name, value)
+			}
+
+			got := g.Routes().Match(r)
+			if got == nil || got.Name != tt.want {
+				t.Errorf("Match = %v, want %s", got, tt.want)
+			}
+			if explained := g.Routes().Explain(r); explained.Route != tt.want {
+				t.Errorf("Explain route = %q, want %s", explained.Route, tt.want)
+			}
+		})
+	}
+
+	if got := g.Routes().Match(httptest.NewRequest(http.MethodGet, "/health", nil)); got != nil {
+		t.Errorf("Match outside the API = %s, want none", got.Name)
+	}
+}
+
+func TestSortRoutes(t *testing.T) {
+	routes := []*ServiceConfig{
+		{Name: "api", BasePath: "/api/v1"},
+		{Name: "orders", BasePath: "/api/v1/orders"},
+		{Name: "orders-post", BasePath: "/api/v1/orders", Methods: []string{"POST"}},
+		{Name: "orders-other", BasePath: "/api/v1/orders"},
+		{Name: "orders-header", BasePath: "/api/v1/orders", Methods: []string{"POST"}, Headers: map[string]string{"X-A": "1"}},
+		{Name: "users-admin", BasePath: "/api/v1/users/admin"},
+	}
+	want := []string{"users-admin", "orders-header", "orders-post", "orders", "orders-other", "api"}
+
+	order := sortRoutes(routes)
+	for i, route := range order {
+		if route.Name != want[i] {
+			var got []string
+			for _, r := range order {
+				got = append(got, r.Name)
+			}
+			t.Fatalf("sortRoutes = %v, want %v", got, want)
+		}
+	}
+	if routes[0].Name != "api" {
+		t.Error("sortRoutes reordered its argument")
+	}
+}
+
+func TestRewritePath(t *testing.T) {
+	tests := []struct {
+		name        string
+		basePath    string
+		stripPrefix bool
+		rewrite     []RewriteRule
+		path        string
+		want        string
+	}{
+		{name: "unchanged", basePath: "/api/v1/users", path: "/api/v1/users/42", want: "/api/v1/users/42"},
+		{name: "strip prefix", basePath: "/api/v1/users", stripPrefix: true, path: "/api/v1/users/42", want: "/42"},
+		{name: "strip prefix of base path", basePath: "/api/v1/users", stripPrefix: true, path: "/api/v1/users", want: "/"},
+		{name: "strip prefix with trailing slash", basePath: "/api/v1/users/", stripPrefix: true, path: "/api/v1/users/42", want: "/42"},
+		{
+			name:     "numbered group",
+			basePath: "/api/v1/orders",
+			rewrite:  []RewriteRule{{Match: `^/api/v1/orders/(\d+)/history$`, Replace: "/internal/orders/$1/status-history"}},
+			path:     "/api/v1/orders/7/history",
+			want:     "/internal/orders/7/status-history",
+		},
+		{
+			name:     "named group",
+			basePath: "/api/v1/orders",
+			rewrite:  []RewriteRule{{Match: `^/api/v1/orders/(?P<id>\d+)$`, Replace: "/orders/${id}"}},
+			path:     "/api/v1/orders/7",
+			want:     "/orders/7",
+		},
+		{
+			name:        "first matching rule after strip prefix",
+			basePath:    "/api/v1/orders",
+			stripPrefix: true,
+			rewrite: []RewriteRule{
+				{Match: `^/(\d+)$`, Replace: "/v2/orders/$1"},
+				{Match: `^/.*$`, Replace: "/v2/catch-all"},
+			},
+			path: "/api/v1/orders/7",
+			want: "/v2/orders/7",
+		},
+		{
+			name:     "no matching rule",
+			basePath: "/api/v1/orders",
+			rewrite:  []RewriteRule{{Match: `^/api/v1/orders/(\d+)$`, Replace: "/orders/$1"}},
+			path:     "/api/v1/orders/7/items",
+			want:     "/api/v1/orders/7/items",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &ServiceConfig{BasePath: tt.basePath, StripPrefix: tt.stripPrefix, Rewrite: tt.rewrite}
+			if problems := s.compileRouting(tt.name); len(problems) > 0 {
+				t.Fatal(problems)
+			}
+			if got := s.rewritePath(tt.path); got != tt.want {
+				t.Errorf("rewritePath(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+// apiPrefix is the path under which requests are routed to upstream services
+const apiPrefix = "/api/v1"
+
+// middlewareAuth verifies bearer tokens; routes without a middleware list use it
+const middlewareAuth = "auth"
+
+// defaultMiddleware is the chain of routes that do not list their middleware
+var defaultMiddleware = []string{middlewareAuth}
+
+// RoutesFile is the declarative route configuration, read from YAML or JSON
+type RoutesFile struct {
+	// Version identifies the configuration; the checksum is used when empty
+	Version string           `yaml:"version"`
+	Routes  []*ServiceConfig `yaml:"routes"`
+}
+
+// RouteMiddleware runs for a request matched to service before it is
+// proxied. It stops the chain by aborting the request.
+type RouteMiddleware func(c *gin.Context, service *ServiceConfig)
+
+// RouteTable is an immutable, validated set of routes. Reloading replaces
+// the table as a whole, so a request sees either the old or the new routes.
+type RouteTable struct {
+	Version  string
+	Checksum string
+	// Source is the routes file, or empty for the built-in routes
+	Source   string
+	LoadedAt time.Time
+	// services are in file order, order in matching order
+	services []*ServiceConfig
+	order    []*ServiceConfig
+}
+
+// DefaultRoutes returns the built-in routes to the user and order services,
+// used when no routes file is configured
+func DefaultRoutes(cfg *GatewayConfig) *RoutesFile {
+	return &RoutesFile{
+		Version: "builtin",
+		Routes: []*ServiceConfig{
+			{
+				Name:     "user-service",
+				BasePath: "/api/v1/users",
+				Target:   cfg.UserServiceURL,
+				// Registration does not require a token
+				PublicPaths: []string{"POST /api/v1/users"},
+			},
+			{
+				Name:        "auth-service",
+				BasePath:    "/api/v1/auth",
+				Target:      cfg.UserServiceURL,
+				PublicPaths: []string{"/api/v1/auth/*"},
+			},
+			{
+				Name:     "order-service",
+				BasePath: "/api/v1/orders",
+				Target:   cfg.OrderServiceURL,
+			},
+		},
+	}
+}
+
+// ReadRoutesFile parses a YAML or JSON routes file, rejecting unknown keys,
+// and returns it with the SHA-256 checksum of its content
+func ReadRoutesFile(path string) (*RoutesFile, string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256(data)
+
+	// JSON is valid YAML, so one decoder reads both
+	file := &RoutesFile{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, "", fmt.Errorf("%s: %w", path, err)
+	}
+	return file, hex.EncodeToString(sum[:]), nil
+}
+
+// NewRouteTable validates the routes of file and resolves their middleware
+// chains against the middleware registered with the gateway
+func (g *Gateway) NewRouteTable(file *RoutesFile, checksum, source string) (*RouteTable, error) {
+	var problems []string
+	if len(file.Routes) == 0 {
+		problems = append(problems, "no routes are defined")
+	}
+
+	names := make(map[string]bool)
+	services := make([]*ServiceConfig, 0, len(file.Routes))
+	for i, route := range file.Routes {
+		if route == nil {
+			problems = append(problems, fmt.Sprintf("routes[%d] is empty", i))
+			continue
+		}
+		service := *route
+		label := fmt.Sprintf("routes[%d]", i)
+		if service.Name != "" {
+			label = fmt.Sprintf("route %q", service.Name)
+		}
+
+		switch {
+		case service.Name == "":
+			problems = append(problems, label+": name is required")
+		case names[service.Name]:
+			problems = append(problems, label+": name is used by another route")
+		}
+		names[service.Name] = true
+
+		if service.BasePath != apiPrefix && !strings.HasPrefix(service.BasePath, apiPrefix+"/") {
+			problems = append(problems, fmt.Sprintf("%s: base_path %q is not under %s", label, service.BasePath, apiPrefix))
+		}
+		problems = append(problems, service.validatePool(label)...)
+		if service.Timeout < 0 {
+			problems = append(problems, label+": timeout must not be negative")
+		}
+		problems = append(problems, service.compileRouting(label)...)
+		for _, rule := range service.PublicPaths {
+			_, rulePath, found := strings.Cut(rule, " ")
+			if !found {
+				rulePath = rule
+			}
+			if !strings.HasPrefix(rulePath, "/") {
+				problems = append(problems, fmt.Sprintf("%s: public path %q is not of the form \"[METHOD ]/path\"", label, rule))
+			}
+		}
+
+		if service.Middleware == nil {
+			service.Middleware = defaultMiddleware
+		}
+		service.chain = make([]RouteMiddleware, 0, len(service.Middleware))
+		for _, name := range service.Middleware {
+			mw, ok := g.middleware[name]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%s: unknown middleware %q", label, name))
+				continue
+			}
+			service.chain = append(service.chain, mw)
+		}
+		problems = append(problems, service.validateRateLimits(label)...)
+
+		services = append(services, &service)
+	}
+	if len(problems) > 0 {
+		return nil, errors.New(strings.Join(problems, "; "))
+	}
+	for _, service := range services {
+		pool, err := newPool(service.Name, service.Upstreams, service.LoadBalancer, service.HashOn, service.HealthCheck, service.PassiveHealthCheck)
+		if err != nil {
+			return nil, fmt.Errorf("route %q: %w", service.Name, err)
+		}
+		for _, e := range pool.endpoints {
+			e.proxy = g.proxyFor(e.URL)
+		}
+		service.pool = pool
+	}
+
+	version := file.Version
+	if version == "" {
+		version = checksum[:12]
+	}
+	return &RouteTable{
+		Version:  version,
+		Checksum: checksum,
+		Source:   source,
+		LoadedAt: time.Now(),
+		services: services,
+		order:    sortRoutes(services),
+	}, nil
+}
+
+// Routes returns the live route table
+func (g *Gateway) Routes() *RouteTable {
+	return g.routes.Load()
+}
+
+// SetRoutes replaces the live route table and moves health checking to
+// its pools. Requests already being proxied finish with the routes they
+// were matched against.
+func (g *Gateway) SetRoutes(table *RouteTable) {
+	// Endpoints keep their state across reloads when their route and URL
+	// are unchanged
+	previous := make(map[string]*Pool)
+	if old := g.Routes(); old != nil {
+		for _, service := range old.services {
+			service.pool.stopHealthChecks()
+			previous[service.Name] = service.pool
+		}
+	}
+	for _, service := range table.services {
+		if pool, ok := previous[service.Name]; ok {
+			service.pool.inherit(pool)
+		}
+		service.pool.startHealthChecks(g.healthClient)
+	}
+	g.routes.Store(table)
+	g.releaseProxies(table)
+	for _, service := range table.services {
+		slog.Info("Registered service",
+			"upstream", service.Name,
+			"base_path", service.BasePath,
+			"upstreams", service.Upstreams,
+			"load_balancer", service.LoadBalancer,
+			"middleware", service.Middleware,
+		)
+	}
+	slog.Info("Routes loaded", "version", table.Version, "routes", len(table.services), "source", table.Source)
+}
+
+// LoadRoutes loads the routes file, or the built-in routes when cfg names
+// no file, and makes them live
+func (g *Gateway) LoadRoutes(cfg *GatewayConfig) error {
+	if cfg.RoutesFile == "" {
+		file := DefaultRoutes(cfg)
+		table, err := g.NewRouteTable(file, "", "")
+		if err != nil {
+			return err
+		}
+		g.SetRoutes(table)
+		return nil
+	}
+
+	file, checksum, err := ReadRoutesFile(cfg.RoutesFile)
+	if err != nil {
+		return err
+	}
+	table, err := g.NewRouteTable(file, checksum, cfg.RoutesFile)
+	if err != nil {
+		return fmt.Errorf("%s: %w", cfg.RoutesFile, err)
+	}
+	g.SetRoutes(table)
+	return nil
+}
+
+// reloadRoutes re-reads the routes file and makes it live if it changed.
+// An unreadable or invalid file is reported and the current routes are
+// kept; an invalid file is only reported again once it changes or force is
+// set, so polling does not repeat the same error.
+func (g *Gateway) reloadRoutes(path string, force bool) error {
+	file, checksum, err := ReadRoutesFile(path)
+	if err != nil {
+		routeReloads.WithLabelValues("failure").Inc()
+		return err
+	}
+	if g.Routes().Checksum == checksum || (checksum == g.rejectedChecksum && !force) {
+		return nil
+	}
+
+	table, err := g.NewRouteTable(file, checksum, path)
+	if err != nil {
+		g.rejectedChecksum = checksum
+		routeReloads.WithLabelValues("failure").Inc()
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	g.rejectedChecksum = ""
+	g.SetRoutes(table)
+	routeReloads.WithLabelValues("success").Inc()
+	return nil
+}
+
+// WatchRoutes reloads the routes file on SIGHUP and, when interval is
+// positive, whenever its content changes, until ctx is done
+func (g *Gateway) WatchRoutes(ctx context.Context, path string, interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		force := false
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			slog.Info("Reloading routes", "source", path, "trigger", "SIGHUP")
+			force = true
+		case <-tick:
+		}
+		if err := g.reloadRoutes(path, force); err != nil {
+			slog.Error("Failed to reload routes, keeping the current routes", "version", g.Routes().Version, "error", err)
+		}
+	}
+}
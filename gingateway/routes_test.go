@@ -0,0 +1,166 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// writeRoutesFile writes a routes file into a temporary directory
+func writeRoutesFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadRoutesFile(t *testing.T) {
+	yamlPath := writeRoutesFile(t, "routes.yaml", `
+version: "2024-06-01"
+routes:
+  - name: orders
+    base_path: /api/v1/orders
+    target: http://order-service:8081
+    timeout: 5s
+`)
+	jsonPath := writeRoutesFile(t, "routes.json", `{
+  "version": "2024-06-01",
+  "routes": [{"name": "orders", "base_path": "/api/v1/orders", "target": "http://order-service:8081", "timeout": "5s"}]
+}`)
+
+	for _, path := range []string{yamlPath, jsonPath} {
+		file, checksum, err := ReadRoutesFile(path)
+		if err != nil {
+			t.Fatalf("%s: %v", filepath.Base(path), err)
+		}
+		if file.Version != "2024-06-01" || len(file.Routes) != 1 || file.Routes[0].Target != "http://order-service:8081" {
+			t.Errorf("%s: read %+v", filepath.Base(path), file)
+		}
+		if len(checksum) != 64 {
+			t.Errorf("%s: checksum %q is not a SHA-256", filepath.Base(path), checksum)
+		}
+	}
+
+	unknown := writeRoutesFile(t, "routes.yaml", `
+routes:
+  - name: orders
+    base_path: /api/v1/orders
+    target: http://order-service:8081
+    retries: 3
+`)
+	if _, _, err := ReadRoutesFile(unknown); err == nil || !strings.Contains(err.Error(), "retries") {
+		t.Errorf("unknown key: error = %v, want it reported", err)
+	}
+}
+
+func TestNewRouteTableRejectsInvalidRoutes(t *testing.T) {
+	route := func(edit func(s *ServiceConfig)) *ServiceConfig {
+		s := &ServiceConfig{Name: "orders", BasePath: "/api/v1/orders", Target: "http://order-service:8081"}
+		if edit != nil {
+			edit(s)
+		}
+		return s
+	}
+
+	tests := []struct {
+		name   string
+		routes []*ServiceConfig
+		want   string
+	}{
+		{"no routes", nil, "no routes are defined"},
+		{"empty route", []*ServiceConfig{nil}, "routes[0] is empty"},
+		{"missing name", []*ServiceConfig{route(func(s *ServiceConfig) { s.Name = "" })}, "name is required"},
+		{"duplicate name", []*ServiceConfig{route(nil), route(func(s *ServiceConfig) { s.BasePath = "/api/v1/orders2" })}, "name is used by another route"},
+		{"base path outside the api", []*ServiceConfig{route(func(s *ServiceConfig) { s.BasePath = "/orders" })}, "is not under /api/v1"},
+		{"base path sharing the api prefix", []*ServiceConfig{route(func(s *ServiceConfig) { s.BasePath = "/api/v1orders" })}, "is not under /api/v1"},
+		{"missing target", []*ServiceConfig{route(func(s *ServiceConfig) { s.Target = "" })}, "target or upstreams is required"},
+		{"target and upstreams", []*ServiceConfig{route(func(s *ServiceConfig) { s.Upstreams = []string{"http://a:8081"} })}, "set either target or upstreams"},
+		{"relative target", []*ServiceConfig{route(func(s *ServiceConfig) { s.Target = "order-service:8081" })}, "is not an absolute http(s) URL"},
+		{"duplicate upstream", []*ServiceConfig{route(func(s *ServiceConfig) {
+			s.Target, s.Upstreams = "", []string{"http://a:8081", "http://a:8081"}
+		})}, "is listed twice"},
+		{"unknown load balancer", []*ServiceConfig{route(func(s *ServiceConfig) { s.LoadBalancer = "random" })}, `load_balancer "random"`},
+		{"invalid hash key", []*ServiceConfig{route(func(s *ServiceConfig) { s.HashOn = "header:" })}, `hash_on "header:"`},
+		{"negative timeout", []*ServiceConfig{route(func(s *ServiceConfig) { s.Timeout = -1 })}, "timeout must not be negative"},
+		{"invalid method", []*ServiceConfig{route(func(s *ServiceConfig) { s.Methods = []string{"GET /"} })}, `method "GET /" is invalid`},
+		{"invalid host", []*ServiceConfig{route(func(s *ServiceConfig) { s.Hosts = []string{"example.com/shop"} })}, "is invalid"},
+		{"invalid header name", []*ServiceConfig{route(func(s *ServiceConfig) { s.Headers = map[string]string{"X-A:": "1"} })}, "header name"},
+		{"invalid rewrite pattern", []*ServiceConfig{route(func(s *ServiceConfig) {
+			s.Rewrite = []RewriteRule{{Match: "(", Replace: "/x"}}
+		})}, "rewrite[0]"},
+		{"relative rewrite", []*ServiceConfig{route(func(s *ServiceConfig) {
+			s.Rewrite = []RewriteRule{{Match: "^/api/v1/orders$", Replace: "orders"}}
+		})}, "does not start with /"},
+		{"relative public path", []*ServiceConfig{route(func(s *ServiceConfig) { s.PublicPaths = []string{"GET orders"} })}, "public path"},
+		{"unknown middleware", []*ServiceConfig{route(func(s *ServiceConfig) { s.Middleware = []string{"gzip"} })}, `unknown middleware "gzip"`},
+		{"rate limits without the middleware", []*ServiceConfig{route(func(s *ServiceConfig) {
+			s.RateLimits = []*RateLimit{{Key: RateLimitKeyClientIP, Requests: 1, Per: 1}}
+		})}, "rate_limits need the ratelimit middleware"},
+		{"unknown rate limit key", []*ServiceConfig{route(func(s *ServiceConfig) {
+			s.Middleware = []string{middlewareRateLimit}
+			s.RateLimits = []*RateLimit{{Key: "user_agent", Requests: 1, Per: 1}}
+		})}, `key "user_agent"`},
+	}
+
+	g := NewGateway(DefaultGatewayConfig())
+	noop := func(*gin.Context, *ServiceConfig) {}
+	g.RegisterMiddleware(middlewareAuth, noop)
+	g.RegisterMiddleware(middlewareRateLimit, noop)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table, err := g.NewRouteTable(&RoutesFile{Routes: tt.routes}, strings.Repeat("0", 64), "routes.yaml")
+			if err == nil {
+				t.Fatalf("NewRouteTable accepted the routes: %+v", table)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %q, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+
+	// Every problem of a file is reported at once
+	_, err := g.NewRouteTable(&RoutesFile{Routes: []*ServiceConfig{
+		route(func(s *ServiceConfig) { s.Name = "" }),
+		route(func(s *ServiceConfig) { s.Target = "" }),
+	}}, strings.Repeat("0", 64), "routes.yaml")
+	if err == nil || !strings.Contains(err.Error(), "name is required") || !strings.Contains(err.Error(), "target or upstreams is required") {
+		t.Errorf("error = %v, want both problems", err)
+	}
+}
+
+func TestNewRouteTableDefaults(t *testing.T) {
+	g := NewGateway(DefaultGatewayConfig())
+	g.RegisterMiddleware(middlewareAuth, func(*gin.Context, *ServiceConfig) {})
+	checksum := strings.Repeat("ab", 32)
+
+	table, err := g.NewRouteTable(&RoutesFile{Routes: []*ServiceConfig{{
+		Name:     "orders",
+		BasePath: "/api/v1/orders",
+		Target:   "http://order-service:8081",
+		Methods:  []string{"post"},
+		Hosts:    []string{"Shop.Example.com"},
+	}}}, checksum, "routes.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.releaseProxies(&RouteTable{})
+
+	if table.Version != checksum[:12] {
+		t.Errorf("Version = %q, want the short checksum", table.Version)
+	}
+	s := table.services[0]
+	if len(s.Middleware) != 1 || s.Middleware[0] != middlewareAuth {
+		t.Errorf("Middleware = %v, want the default auth chain", s.Middleware)
+	}
+	if len(s.Upstreams) != 1 || s.Upstreams[0] != s.Target || s.LoadBalancer != LoadBalancerRoundRobin {
+		t.Errorf("Upstreams = %v, LoadBalancer = %q, want the target balanced round robin", s.Upstreams, s.LoadBalancer)
+	}
+	if s.Methods[0] != "POST" || s.Hosts[0] != "shop.example.com" {
+		t.Errorf("Methods = %v, Hosts = %v, want them normalized", s.Methods, s.Hosts)
+	}
+}
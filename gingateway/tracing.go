@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+)
+
+// Span exporters
+const (
+	// TracingExporterNone records no spans but still forwards incoming trace context
+	TracingExporterNone = "none"
+	// TracingExporterOTLP sends spans to an OpenTelemetry collector over gRPC
+	TracingExporterOTLP = "otlp"
+	// TracingExporterFile appends spans as JSON to a local file for development
+	TracingExporterFile = "file"
+)
+
+// serviceName identifies the gateway's spans
+const serviceName = "api-gateway"
+
+// tracingFlushTimeout bounds exporting the buffered spans on shutdown
+const tracingFlushTimeout = 5 * time.Second
+
+// untracedPaths are probe and scrape endpoints that would only add noise
+var untracedPaths = map[string]bool{
+	"/livez":   true,
+	"/readyz":  true,
+	"/health":  true,
+	"/metrics": true,
+}
+
+// TracerProvider owns the gateway's tracer provider and flushes it on Close
+type TracerProvider struct {
+	tp   *sdktrace.TracerProvider
+	file *os.File
+}
+
+// SetupTracing installs the W3C trace context propagator and, unless the
+// exporter is none, a tracer provider exporting the gateway's spans
+func SetupTracing(ctx context.Context, cfg *GatewayConfig) (*TracerProvider, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	p := &TracerProvider{}
+	var exporter sdktrace.SpanExporter
+	switch cfg.TracingExporter {
+	case TracingExporterNone:
+		return p, nil
+	case TracingExporterOTLP:
+		exp, err := otlptracegrpc.New(ctx, otlptracegrpc.WithEndpointURL(cfg.OTLPEndpoint))
+		if err != nil {
+			return nil, fmt.Errorf("tracing: create OTLP exporter: %w", err)
+		}
+		exporter = exp
+	case TracingExporterFile:
+		f, err := os.OpenFile(cfg.TracingFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("tracing: %w", err)
+		}
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("tracing: create file exporter: %w", err)
+		}
+		p.file = f
+		exporter = exp
+	default:
+		return nil, fmt.Errorf("tracing: unknown exporter %q", cfg.TracingExporter)
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithSchemaURL(semconv.SchemaURL),
+		resource.WithAttributes(semconv.ServiceName(serviceName)),
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+		// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES take precedence
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("tracing: %w", err)
+	}
+
+	p.tp = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.TracingSampleRatio))),
+	)
+	otel.SetTracerProvider(p.tp)
+	return p, nil
+}
+
+// Close exports the buffered spans and stops the exporter
+func (p *TracerProvider) Close() error {
+	var err error
+	if p.tp != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), tracingFlushTimeout)
+		defer cancel()
+		err = p.tp.Shutdown(ctx)
+	}
+	if p.file != nil {
+		err = errors.Join(err, p.file.Close())
+	}
+	return err
+}
+
+// TracingMiddleware starts a server span for every request, continuing the
+// trace of an incoming traceparent header
+func TracingMiddleware() gin.HandlerFunc {
+	return otelgin.Middleware(serviceName, otelgin.WithFilter(func(r *http.Request) bool {
+		return !untracedPaths[r.URL.Path]
+	}))
+}
+
+// newTracingTransport wraps base so every proxied request gets a client span
+// and carries its trace context upstream in the traceparent header
+func newTracingTransport(base http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(base, otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+		return "proxy " + r.Method
+	}))
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// HTTP/2 modes of upstream connections
+const (
+	// UpstreamHTTP2Auto negotiates HTTP/2 with TLS upstreams and uses
+	// HTTP/1.1 with cleartext ones
+	UpstreamHTTP2Auto = "auto"
+	// UpstreamHTTP2H2C speaks HTTP/2 with prior knowledge to cleartext
+	// upstreams, which must support h2c
+	UpstreamHTTP2H2C = "h2c"
+	// UpstreamHTTP2Off only uses HTTP/1.1
+	UpstreamHTTP2Off = "off"
+)
+
+// proxyBufferSize is the size of the buffers copying response bodies
+const proxyBufferSize = 32 * 1024
+
+// StatusClientClosedRequest is logged for requests the client gave up on
+// before the upstream answered, as nginx does
+const StatusClientClosedRequest = 499
+
+// NewUpstreamTransport builds the transport of one upstream from the
+// connection settings of cfg
+func NewUpstreamTransport(cfg *GatewayConfig) *http.Transport {
+	keepAlive := cfg.UpstreamKeepAlive
+	if keepAlive == 0 {
+		// net.Dialer treats zero as the default interval and negative as off
+		keepAlive = -1
+	}
+	dialer := &net.Dialer{
+		Timeout:   cfg.UpstreamDialTimeout,
+		KeepAlive: keepAlive,
+	}
+
+	t := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          cfg.UpstreamMaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.UpstreamMaxIdleConns,
+		MaxConnsPerHost:       cfg.UpstreamMaxConns,
+		IdleConnTimeout:       cfg.UpstreamIdleConnTimeout,
+		ResponseHeaderTimeout: cfg.UpstreamResponseHeaderTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	switch cfg.UpstreamHTTP2 {
+	case UpstreamHTTP2H2C:
+		t.Protocols = new(http.Protocols)
+		t.Protocols.SetHTTP2(true)
+		t.Protocols.SetUnencryptedHTTP2(true)
+	case UpstreamHTTP2Off:
+		t.ForceAttemptHTTP2 = false
+		t.Protocols = new(http.Protocols)
+		t.Protocols.SetHTTP1(true)
+	}
+	return t
+}
+
+// upstreamProxy is the reverse proxy of one upstream URL and the transport
+// holding its connections
+type upstreamProxy struct {
+	proxy     *httputil.ReverseProxy
+	transport *http.Transport
+}
+
+// proxyRequest is the state of a proxied request that the shared proxies
+// read from the request context
+type proxyRequest struct {
+	service *ServiceConfig
+	// failed is set when no response was received from the upstream
+	failed bool
+}
+
+// proxyRequestKey is the context key of the *proxyRequest
+type proxyRequestKey struct{}
+
+// withProxyRequest returns r carrying the state of its proxying to service
+func withProxyRequest(r *http.Request, service *ServiceConfig) (*http.Request, *proxyRequest) {
+	pr := &proxyRequest{service: service}
+	return r.WithContext(context.WithValue(r.Context(), proxyRequestKey{}, pr)), pr
+}
+
+// bufferPool recycles the buffers of the reverse proxies
+type bufferPool struct {
+	pool sync.Pool
+}
+
+func newBufferPool() *bufferPool {
+	return &bufferPool{pool: sync.Pool{New: func() any {
+		b := make([]byte, proxyBufferSize)
+		return &b
+	}}}
+}
+
+func (p *bufferPool) Get() []byte {
+	return *p.pool.Get().(*[]byte)
+}
+
+func (p *bufferPool) Put(b []byte) {
+	p.pool.Put(&b)
+}
+
+// newUpstreamProxy builds the reverse proxy of target. The route of each
+// request comes from its proxyRequest, so one proxy serves every route
+// balancing to target.
+func (g *Gateway) newUpstreamProxy(target *url.URL) *upstreamProxy {
+	transport := g.newTransport()
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.Transport = newTracingTransport(transport)
+	proxy.BufferPool = g.buffers
+
+	director := proxy.Director
+	proxy.Director = func(req *http.Request) {
+		pr := req.Context().Value(proxyRequestKey{}).(*proxyRequest)
+		if upstreamPath := pr.service.rewritePath(req.URL.Path); upstreamPath != req.URL.Path {
+			req.URL.Path, req.URL.RawPath = upstreamPath, ""
+		}
+		director(req)
+		req.Host = target.Host
+
+		slog.DebugContext(req.Context(), "Proxying request",
+			"method", req.Method,
+			"path", req.URL.Path,
+			"target", target.String(),
+		)
+	}
+
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		pr := r.Context().Value(proxyRequestKey{}).(*proxyRequest)
+		if clientGone(r.Context()) {
+			slog.DebugContext(r.Context(), "Client closed request", "upstream", pr.service.Name, "endpoint", target.String())
+			w.WriteHeader(StatusClientClosedRequest)
+			return
+		}
+		pr.failed = true
+		slog.ErrorContext(r.Context(), "Proxy error", "upstream", pr.service.Name, "endpoint", target.String(), "error", err)
+		upstreamErrors.WithLabelValues(pr.service.Name).Inc()
+		if errors.Is(err, context.DeadlineExceeded) {
+			w.WriteHeader(http.StatusGatewayTimeout)
+			w.Write([]byte("Service timed out"))
+			return
+		}
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte("Service unavailable"))
+	}
+
+	return &upstreamProxy{proxy: proxy, transport: transport}
+}
+
+// clientGone reports whether the client closed the request of ctx, as
+// opposed to the route timeout expiring
+func clientGone(ctx context.Context) bool {
+	return errors.Is(ctx.Err(), context.Canceled)
+}
+
+// proxyFor returns the reverse proxy of target, keeping the proxy and its
+// connections of earlier route tables when target is still routed to
+func (g *Gateway) proxyFor(target *url.URL) *httputil.ReverseProxy {
+	g.proxiesMu.Lock()
+	defer g.proxiesMu.Unlock()
+
+	key := target.String()
+	up, ok := g.proxies[key]
+	if !ok {
+		up = g.newUpstreamProxy(target)
+		g.proxies[key] = up
+	}
+	return up.proxy
+}
+
+// releaseProxies drops the proxies of upstreams table no longer routes to
+// and closes their idle connections. Requests still using them finish on
+// their current connection.
+func (g *Gateway) releaseProxies(table *RouteTable) {
+	live := make(map[string]bool)
+	for _, service := range table.services {
+		for _, e := range service.pool.endpoints {
+			live[e.URL.String()] = true
+		}
+	}
+
+	g.proxiesMu.Lock()
+	defer g.proxiesMu.Unlock()
+	for key, up := range g.proxies {
+		if !live[key] {
+			up.transport.CloseIdleConnections()
+			delete(g.proxies, key)
+		}
+	}
+}
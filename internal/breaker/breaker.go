@@ -0,0 +1,184 @@
+package breaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrOpen is returned by Allow while the breaker rejects calls
+var ErrOpen = errors.New("circuit breaker is open")
+
+// State is the state of a circuit breaker
+type State int
+
+const (
+	// StateClosed lets every call through
+	StateClosed State = iota
+	// StateOpen rejects every call until the open timeout has passed
+	StateOpen
+	// StateHalfOpen lets a limited number of probe calls through
+	StateHalfOpen
+)
+
+// String returns the state name
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// Config holds circuit breaker thresholds
+type Config struct {
+	// FailureThreshold is the number of consecutive failures that opens the breaker
+	FailureThreshold int
+	// OpenTimeout is how long the breaker stays open before probing again
+	OpenTimeout time.Duration
+	// HalfOpenMaxCalls is the number of concurrent probe calls while half-open
+	HalfOpenMaxCalls int
+}
+
+// Ticket identifies a call let through by Allow. It is handed back to
+// Record with the call's outcome, or to Release if the call has none.
+type Ticket struct {
+	// generation is the state period the call was admitted in
+	generation uint64
+}
+
+// Breaker is a consecutive-failure circuit breaker. Callers ask Allow before
+// each call and report its outcome with Record, or hand the ticket back with
+// Release when the call ended without one. Outcomes only count in the
+// state period their call was admitted in, so a slow call admitted while
+// closed cannot close the breaker or use up a probe once it is half-open.
+type Breaker struct {
+	mu       sync.Mutex
+	config   Config
+	state    State
+	failures int
+	openedAt time.Time
+	probes   int
+	// generation counts state changes
+	generation    uint64
+	onStateChange func(from, to State)
+	now           func() time.Time
+}
+
+// New creates a closed circuit breaker. onStateChange, if not nil, is called
+// on every transition while the breaker's lock is held, so it must not call
+// back into the breaker.
+func New(config Config, onStateChange func(from, to State)) *Breaker {
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = 1
+	}
+	if config.HalfOpenMaxCalls <= 0 {
+		config.HalfOpenMaxCalls = 1
+	}
+	return &Breaker{
+		config:        config,
+		onStateChange: onStateChange,
+		now:           time.Now,
+	}
+}
+
+// Allow reports whether a call may proceed and returns the ticket to record
+// its outcome with. It returns ErrOpen while the breaker is open or all
+// half-open probes are in flight.
+func (b *Breaker) Allow() (Ticket, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == StateOpen {
+		if b.now().Sub(b.openedAt) < b.config.OpenTimeout {
+			return Ticket{}, ErrOpen
+		}
+		b.setState(StateHalfOpen)
+	}
+
+	if b.state == StateHalfOpen {
+		if b.probes >= b.config.HalfOpenMaxCalls {
+			return Ticket{}, ErrOpen
+		}
+		b.probes++
+	}
+	return Ticket{generation: b.generation}, nil
+}
+
+// Record reports the outcome of a call that Allow let through. Outcomes of
+// calls admitted before the last state change are ignored.
+func (b *Breaker) Record(ticket Ticket, success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if ticket.generation != b.generation {
+		return
+	}
+
+	switch b.state {
+	case StateClosed:
+		if success {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.config.FailureThreshold {
+			b.open()
+		}
+	case StateHalfOpen:
+		b.probes--
+		if success {
+			b.setState(StateClosed)
+			return
+		}
+		b.open()
+	}
+}
+
+// Release hands back the ticket of a call that ended without telling
+// whether the dependency is healthy, e.g. because the caller gave up. It frees
+// the call's probe while half-open and leaves the state and failure count as
+// they are.
+func (b *Breaker) Release(ticket Ticket) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if ticket.generation != b.generation {
+		return
+	}
+	if b.state == StateHalfOpen {
+		b.probes--
+	}
+}
+
+// State returns the current state
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// open moves the breaker to the open state
+func (b *Breaker) open() {
+	b.openedAt = b.now()
+	b.setState(StateOpen)
+}
+
+// setState changes the state and notifies the listener
+func (b *Breaker) setState(state State) {
+	if b.state == state {
+		return
+	}
+	from := b.state
+	b.state = state
+	b.generation++
+	b.failures = 0
+	b.probes = 0
+	if b.onStateChange != nil {
+		b.onStateChange(from, state)
+	}
+}
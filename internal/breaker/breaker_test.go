@@ -0,0 +1,204 @@
+package breaker
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testBreaker is a breaker on a settable clock that records its transitions
+type testBreaker struct {
+	*Breaker
+	now         time.Time
+	transitions []string
+}
+
+func newTestBreaker(config Config) *testBreaker {
+	tb := &testBreaker{now: time.Unix(1_700_000_000, 0)}
+	tb.Breaker = New(config, func(from, to State) {
+		tb.transitions = append(tb.transitions, from.String()+"->"+to.String())
+	})
+	tb.Breaker.now = func() time.Time { return tb.now }
+	return tb
+}
+
+// allow admits a call and fails the test if it is rejected
+func (tb *testBreaker) allow(t *testing.T) Ticket {
+	t.Helper()
+	ticket, err := tb.Allow()
+	if err != nil {
+		t.Fatalf("Allow in state %s = %v, want the call admitted", tb.State(), err)
+	}
+	return ticket
+}
+
+// reject fails the test unless Allow rejects the call
+func (tb *testBreaker) reject(t *testing.T) {
+	t.Helper()
+	if _, err := tb.Allow(); !errors.Is(err, ErrOpen) {
+		t.Fatalf("Allow in state %s = %v, want ErrOpen", tb.State(), err)
+	}
+}
+
+// trip opens the breaker with FailureThreshold failed calls
+func (tb *testBreaker) trip(t *testing.T) {
+	t.Helper()
+	for i := 0; i < tb.config.FailureThreshold; i++ {
+		tb.Record(tb.allow(t), false)
+	}
+	if tb.State() != StateOpen {
+		t.Fatalf("state after %d failures = %s, want open", tb.config.FailureThreshold, tb.State())
+	}
+}
+
+func TestBreakerOpensAfterConsecutiveFailures(t *testing.T) {
+	b := newTestBreaker(Config{FailureThreshold: 3, OpenTimeout: time.Minute})
+
+	// A success resets the failure count
+	b.Record(b.allow(t), false)
+	b.Record(b.allow(t), false)
+	b.Record(b.allow(t), true)
+	b.Record(b.allow(t), false)
+	b.Record(b.allow(t), false)
+	if b.State() != StateClosed {
+		t.Fatalf("state = %s, want closed before 3 failures in a row", b.State())
+	}
+
+	b.Record(b.allow(t), false)
+	if b.State() != StateOpen {
+		t.Fatalf("state = %s, want open after 3 failures in a row", b.State())
+	}
+	b.reject(t)
+
+	b.now = b.now.Add(59 * time.Second)
+	b.reject(t)
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	tests := []struct {
+		name        string
+		success     bool
+		wantState   State
+		transitions []string
+	}{
+		{"successful probe closes", true, StateClosed, []string{"closed->open", "open->half-open", "half-open->closed"}},
+		{"failed probe reopens", false, StateOpen, []string{"closed->open", "open->half-open", "half-open->open"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestBreaker(Config{FailureThreshold: 1, OpenTimeout: time.Minute, HalfOpenMaxCalls: 2})
+			b.trip(t)
+
+			// Only HalfOpenMaxCalls probes are let through once the timeout passed
+			b.now = b.now.Add(time.Minute)
+			probe := b.allow(t)
+			b.allow(t)
+			if b.State() != StateHalfOpen {
+				t.Fatalf("state = %s, want half-open", b.State())
+			}
+			b.reject(t)
+
+			b.Record(probe, tt.success)
+			if b.State() != tt.wantState {
+				t.Fatalf("state after probe = %s, want %s", b.State(), tt.wantState)
+			}
+			if !reflect.DeepEqual(b.transitions, tt.transitions) {
+				t.Errorf("transitions = %v, want %v", b.transitions, tt.transitions)
+			}
+		})
+	}
+}
+
+func TestBreakerFailedProbeRestartsOpenTimeout(t *testing.T) {
+	b := newTestBreaker(Config{FailureThreshold: 1, OpenTimeout: time.Minute})
+	b.trip(t)
+	b.now = b.now.Add(time.Minute)
+	b.Record(b.allow(t), false)
+
+	b.now = b.now.Add(30 * time.Second)
+	b.reject(t)
+	b.now = b.now.Add(30 * time.Second)
+	b.allow(t)
+}
+
+func TestBreakerIgnoresCallsFromEarlierStates(t *testing.T) {
+	b := newTestBreaker(Config{FailureThreshold: 1, OpenTimeout: time.Minute, HalfOpenMaxCalls: 1})
+
+	// A slow call is admitted while closed, then the breaker trips and
+	// goes half-open with its single probe in flight
+	slow := b.allow(t)
+	b.Record(b.allow(t), false)
+	b.now = b.now.Add(time.Minute)
+	probe := b.allow(t)
+
+	// The slow call's success neither closes the breaker nor frees a probe
+	b.Record(slow, true)
+	if b.State() != StateHalfOpen {
+		t.Fatalf("state after stale success = %s, want half-open", b.State())
+	}
+	b.reject(t)
+
+	// Its failure does not reopen the breaker either
+	b.Record(slow, false)
+	if b.State() != StateHalfOpen {
+		t.Fatalf("state after stale failure = %s, want half-open", b.State())
+	}
+
+	b.Record(probe, true)
+	if b.State() != StateClosed {
+		t.Fatalf("state after probe = %s, want closed", b.State())
+	}
+}
+
+func TestBreakerIgnoresLateProbes(t *testing.T) {
+	b := newTestBreaker(Config{FailureThreshold: 2, OpenTimeout: time.Minute, HalfOpenMaxCalls: 2})
+	b.trip(t)
+	b.now = b.now.Add(time.Minute)
+	first := b.allow(t)
+	second := b.allow(t)
+
+	// The first probe closes the breaker; the second one finishing later
+	// does not count against the new closed period
+	b.Record(first, true)
+	b.Record(second, false)
+	b.Record(b.allow(t), false)
+	if b.State() != StateClosed {
+		t.Fatalf("state = %s, want closed with one failure in the new period", b.State())
+	}
+	b.Record(b.allow(t), false)
+	if b.State() != StateOpen {
+		t.Fatalf("state = %s, want open after two failures", b.State())
+	}
+}
+
+func TestBreakerRelease(t *testing.T) {
+	b := newTestBreaker(Config{FailureThreshold: 2, OpenTimeout: time.Minute, HalfOpenMaxCalls: 1})
+
+	// A released call does not reset the failure count
+	b.Record(b.allow(t), false)
+	b.Release(b.allow(t))
+	b.Record(b.allow(t), false)
+	if b.State() != StateOpen {
+		t.Fatalf("state = %s, want open after two failures around a released call", b.State())
+	}
+
+	// A released probe neither closes nor reopens the breaker, and frees
+	// its slot for the next probe
+	b.now = b.now.Add(time.Minute)
+	b.Release(b.allow(t))
+	if b.State() != StateHalfOpen {
+		t.Fatalf("state after released probe = %s, want half-open", b.State())
+	}
+	probe := b.allow(t)
+	b.reject(t)
+
+	// A stale release does not free the probe in flight
+	b.Release(Ticket{})
+	b.reject(t)
+
+	b.Record(probe, true)
+	if b.State() != StateClosed {
+		t.Fatalf("state after probe = %s, want closed", b.State())
+	}
+}
@@ -0,0 +1,311 @@
+// Package config loads typed service configuration. Values are layered in
+// increasing order of precedence:
+//
+//  1. the defaults already set on the configuration struct
+//  2. an optional YAML file given with -config or CONFIG_FILE
+//  3. environment variables
+//  4. command line flags
+//
+// and the result is validated before it is returned. Struct fields opt in to
+// each source with tags:
+//
+//	HTTPPort int `yaml:"http_port" env:"USER_SERVICE_PORT" flag:"http-port" usage:"HTTP listen port" validate:"min=1,max=65535"`
+//
+// Nested structs are walked recursively. Leaf fields may be strings, bools,
+// integers, floats, time.Duration or comma separated []string.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFileEnv names the environment variable holding the YAML file path
+const ConfigFileEnv = "CONFIG_FILE"
+
+// Validator is implemented by configurations with checks that struct tags
+// cannot express, such as constraints between fields
+type Validator interface {
+	Validate() error
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// field is a leaf configuration value together with its sources
+type field struct {
+	path  string
+	value reflect.Value
+	env   string
+	flag  string
+	usage string
+}
+
+// flagValue records a flag so it can be applied after the file and environment
+type flagValue struct {
+	raw    string
+	isBool bool
+}
+
+func (v *flagValue) String() string     { return v.raw }
+func (v *flagValue) Set(s string) error { v.raw = s; return nil }
+func (v *flagValue) IsBoolFlag() bool   { return v.isBool }
+
+// Load fills cfg, a pointer to a struct holding its defaults, from the YAML
+// file, the environment and args, then validates it. Positional arguments
+// left after the flags are returned. flag.ErrHelp is returned when -h or
+// -help was given.
+func Load(name string, args []string, cfg interface{}) ([]string, error) {
+	root := reflect.ValueOf(cfg)
+	if root.Kind() != reflect.Ptr || root.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("config: %T is not a pointer to a struct", cfg)
+	}
+
+	var fields []field
+	if err := collect(root.Elem(), "", &fields); err != nil {
+		return nil, err
+	}
+
+	// Parse flags first to find the config file, but apply them last
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv(ConfigFileEnv), "path to a YAML configuration file")
+	flagValues := make(map[string]*flagValue)
+	for _, f := range fields {
+		if f.flag == "" {
+			continue
+		}
+		v := &flagValue{raw: formatValue(f.value), isBool: f.value.Kind() == reflect.Bool}
+		flagValues[f.flag] = v
+		fs.Var(v, f.flag, usage(f))
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *configFile != "" {
+		if err := loadFile(*configFile, cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, f := range fields {
+		if f.env == "" {
+			continue
+		}
+		if raw, ok := os.LookupEnv(f.env); ok && raw != "" {
+			if err := setValue(f.value, raw); err != nil {
+				return nil, fmt.Errorf("config: %s: %w", f.env, err)
+			}
+		}
+	}
+
+	var flagErr error
+	fs.Visit(func(fl *flag.Flag) {
+		v, ok := flagValues[fl.Name]
+		if !ok || flagErr != nil {
+			return
+		}
+		for _, f := range fields {
+			if f.flag == fl.Name {
+				if err := setValue(f.value, v.raw); err != nil {
+					flagErr = fmt.Errorf("config: -%s: %w", fl.Name, err)
+				}
+			}
+		}
+	})
+	if flagErr != nil {
+		return nil, flagErr
+	}
+
+	if err := validate(cfg); err != nil {
+		return nil, err
+	}
+	return fs.Args(), nil
+}
+
+// collect walks a struct and records its leaf fields
+func collect(v reflect.Value, prefix string, fields *[]field) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		path := yamlName(sf)
+		if path == "-" {
+			path = sf.Name
+		}
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Struct && fv.Type() != durationType {
+			if err := collect(fv, path, fields); err != nil {
+				return err
+			}
+			continue
+		}
+		if !supported(fv) {
+			return fmt.Errorf("config: %s: unsupported type %s", path, fv.Type())
+		}
+
+		*fields = append(*fields, field{
+			path:  path,
+			value: fv,
+			env:   sf.Tag.Get("env"),
+			flag:  sf.Tag.Get("flag"),
+			usage: sf.Tag.Get("usage"),
+		})
+	}
+	return nil
+}
+
+// yamlName returns the YAML key of a struct field
+func yamlName(sf reflect.StructField) string {
+	name, _, _ := strings.Cut(sf.Tag.Get("yaml"), ",")
+	if name == "" {
+		return strings.ToLower(sf.Name)
+	}
+	return name
+}
+
+// usage describes a flag, including its environment variable
+func usage(f field) string {
+	if f.env == "" {
+		return f.usage
+	}
+	return fmt.Sprintf("%s (env %s)", f.usage, f.env)
+}
+
+// loadFile decodes a YAML file over cfg, rejecting unknown keys
+func loadFile(path string, cfg interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("config: %s: %w", path, err)
+	}
+	return nil
+}
+
+// supported reports whether a leaf field type can be set from a string
+func supported(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	case reflect.Slice:
+		return v.Type().Elem().Kind() == reflect.String
+	}
+	return false
+}
+
+// setValue parses raw into a leaf field
+func setValue(v reflect.Value, raw string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	}
+	return nil
+}
+
+// formatValue formats a leaf field as flag default
+func formatValue(v reflect.Value) string {
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String()
+	}
+	if v.Kind() == reflect.Slice {
+		return strings.Join(v.Interface().([]string), ",")
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// validate checks the validate tags of cfg and its Validate method
+func validate(cfg interface{}) error {
+	v := validator.New()
+	v.RegisterTagNameFunc(yamlName)
+
+	if err := v.Struct(cfg); err != nil {
+		var verrs validator.ValidationErrors
+		if !errors.As(err, &verrs) {
+			return fmt.Errorf("config: %w", err)
+		}
+		msgs := make([]string, 0, len(verrs))
+		for _, fe := range verrs {
+			// Drop the root struct name from the namespace
+			_, path, _ := strings.Cut(fe.Namespace(), ".")
+			msg := fmt.Sprintf("%s failed %q", path, fe.Tag())
+			if fe.Param() != "" {
+				msg = fmt.Sprintf("%s failed %q (%s)", path, fe.Tag(), fe.Param())
+			}
+			msgs = append(msgs, msg)
+		}
+		return fmt.Errorf("config: invalid configuration: %s", strings.Join(msgs, "; "))
+	}
+
+	if c, ok := cfg.(Validator); ok {
+		if err := c.Validate(); err != nil {
+			return fmt.Errorf("config: invalid configuration: %w", err)
+		}
+	}
+	return nil
+}
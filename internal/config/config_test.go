@@ -0,0 +1,375 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serverConfig exercises every source and supported leaf type
+type serverConfig struct {
+	Name     string        `yaml:"name" env:"TEST_NAME" flag:"name" validate:"required"`
+	Host     string        `yaml:"host" env:"TEST_HOST" flag:"host"`
+	Port     int           `yaml:"port" env:"TEST_PORT" flag:"port" validate:"gt=0"`
+	Timeout  time.Duration `yaml:"timeout" env:"TEST_TIMEOUT" flag:"timeout" validate:"gt=0"`
+	Debug    bool          `yaml:"debug" env:"TEST_DEBUG" flag:"debug"`
+	Database databaseConfig
+}
+
+type databaseConfig struct {
+	MaxConns uint16   `yaml:"max_conns" env:"TEST_DB_MAX_CONNS" flag:"db-max-conns"`
+	Ratio    float64  `yaml:"ratio" env:"TEST_DB_RATIO" flag:"db-ratio"`
+	Replicas []string `yaml:"replicas" env:"TEST_DB_REPLICAS" flag:"db-replicas"`
+}
+
+func defaultServerConfig() *serverConfig {
+	return &serverConfig{
+		Name:    "default",
+		Host:    "default",
+		Port:    8080,
+		Timeout: 5 * time.Second,
+		Database: databaseConfig{
+			MaxConns: 10,
+			Ratio:    0.5,
+		},
+	}
+}
+
+// setEnv sets the test variables, leaving the others empty
+func setEnv(t *testing.T, env map[string]string) {
+	t.Helper()
+	for _, name := range []string{
+		ConfigFileEnv, "TEST_NAME", "TEST_HOST", "TEST_PORT", "TEST_TIMEOUT", "TEST_DEBUG",
+		"TEST_DB_MAX_CONNS", "TEST_DB_RATIO", "TEST_DB_REPLICAS",
+	} {
+		t.Setenv(name, env[name])
+	}
+}
+
+// writeFile writes a YAML configuration file and returns its path
+func writeFile(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeFile(t, "name: file\nhost: file\nport: 9000\ndatabase:\n  max_conns: 20\n")
+	setEnv(t, map[string]string{
+		ConfigFileEnv: path,
+		"TEST_NAME":   "env",
+		"TEST_PORT":   "9100",
+	})
+
+	cfg := defaultServerConfig()
+	if _, err := Load("test", []string{"-port", "9200"}, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	// Timeout keeps its default, Host and MaxConns come from the file, Name
+	// from the environment and Port from the flag
+	want := defaultServerConfig()
+	want.Name = "env"
+	want.Host = "file"
+	want.Port = 9200
+	want.Database.MaxConns = 20
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("Load = %+v, want %+v", cfg, want)
+	}
+}
+
+func TestLoadConfigFlagOverridesEnv(t *testing.T) {
+	setEnv(t, map[string]string{ConfigFileEnv: writeFile(t, "name: env file\n")})
+	path := writeFile(t, "name: flag file\n")
+
+	cfg := defaultServerConfig()
+	if _, err := Load("test", []string{"-config", path}, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "flag file" {
+		t.Errorf("Name = %q, want the file given with -config", cfg.Name)
+	}
+}
+
+func TestLoadFileErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		want     string
+	}{
+		{"unknown key", "name: file\nport_number: 9000\n", "field port_number not found"},
+		{"wrong type", "port: ninety\n", "cannot unmarshal"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setEnv(t, map[string]string{ConfigFileEnv: writeFile(t, tt.contents)})
+			_, err := Load("test", nil, defaultServerConfig())
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+
+	setEnv(t, nil)
+	if _, err := Load("test", []string{"-config", filepath.Join(t.TempDir(), "missing.yaml")}, defaultServerConfig()); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Load with a missing file = %v, want os.ErrNotExist", err)
+	}
+}
+
+func TestLoadEmptyFile(t *testing.T) {
+	setEnv(t, map[string]string{ConfigFileEnv: writeFile(t, "")})
+	cfg := defaultServerConfig()
+	if _, err := Load("test", nil, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg, defaultServerConfig()) {
+		t.Errorf("Load = %+v, want the defaults", cfg)
+	}
+}
+
+func TestSetValue(t *testing.T) {
+	tests := []struct {
+		name   string
+		env    map[string]string
+		args   []string
+		modify func(cfg *serverConfig)
+	}{
+		{
+			name:   "duration from env",
+			env:    map[string]string{"TEST_TIMEOUT": "1m30s"},
+			modify: func(cfg *serverConfig) { cfg.Timeout = 90 * time.Second },
+		},
+		{
+			name:   "duration from flag",
+			args:   []string{"-timeout", "250ms"},
+			modify: func(cfg *serverConfig) { cfg.Timeout = 250 * time.Millisecond },
+		},
+		{
+			name:   "bool from env",
+			env:    map[string]string{"TEST_DEBUG": "true"},
+			modify: func(cfg *serverConfig) { cfg.Debug = true },
+		},
+		{
+			name:   "bare bool flag",
+			args:   []string{"-debug"},
+			modify: func(cfg *serverConfig) { cfg.Debug = true },
+		},
+		{
+			name:   "bool flag overriding env",
+			env:    map[string]string{"TEST_DEBUG": "1"},
+			args:   []string{"-debug=false"},
+			modify: func(cfg *serverConfig) {},
+		},
+		{
+			name:   "unsigned integer",
+			env:    map[string]string{"TEST_DB_MAX_CONNS": "65535"},
+			modify: func(cfg *serverConfig) { cfg.Database.MaxConns = 65535 },
+		},
+		{
+			name:   "float",
+			args:   []string{"-db-ratio", "0.25"},
+			modify: func(cfg *serverConfig) { cfg.Database.Ratio = 0.25 },
+		},
+		{
+			name:   "comma separated list",
+			env:    map[string]string{"TEST_DB_REPLICAS": " a, b,,c "},
+			modify: func(cfg *serverConfig) { cfg.Database.Replicas = []string{"a", "b", "c"} },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setEnv(t, tt.env)
+			cfg := defaultServerConfig()
+			if _, err := Load("test", tt.args, cfg); err != nil {
+				t.Fatal(err)
+			}
+			want := defaultServerConfig()
+			tt.modify(want)
+			if !reflect.DeepEqual(cfg, want) {
+				t.Errorf("Load = %+v, want %+v", cfg, want)
+			}
+		})
+	}
+}
+
+func TestSetValueErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+		want string
+	}{
+		{"duration without unit", map[string]string{"TEST_TIMEOUT": "30"}, nil, "config: TEST_TIMEOUT: time: missing unit"},
+		{"invalid bool", map[string]string{"TEST_DEBUG": "yes"}, nil, "config: TEST_DEBUG: strconv.ParseBool"},
+		{"invalid integer", nil, []string{"-port", "http"}, "config: -port: strconv.ParseInt"},
+		{"integer overflow", map[string]string{"TEST_DB_MAX_CONNS": "65536"}, nil, "value out of range"},
+		{"negative unsigned", nil, []string{"-db-max-conns", "-1"}, "config: -db-max-conns: strconv.ParseUint"},
+		{"invalid float", map[string]string{"TEST_DB_RATIO": "half"}, nil, "config: TEST_DB_RATIO: strconv.ParseFloat"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setEnv(t, tt.env)
+			_, err := Load("test", tt.args, defaultServerConfig())
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadUnsupportedType(t *testing.T) {
+	setEnv(t, nil)
+	tests := []struct {
+		name string
+		cfg  interface{}
+		want string
+	}{
+		{"map", &struct {
+			Labels map[string]string `yaml:"labels" env:"TEST_LABELS"`
+		}{}, "config: labels: unsupported type map[string]string"},
+		{"int slice", &struct {
+			Nested struct {
+				Ports []int `yaml:"ports"`
+			} `yaml:"nested"`
+		}{}, "config: nested.ports: unsupported type []int"},
+		{"pointer", &struct {
+			Port *int
+		}{}, "config: port: unsupported type *int"},
+		{"not a pointer", serverConfig{}, "is not a pointer to a struct"},
+		{"pointer to a non-struct", new(string), "is not a pointer to a struct"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load("test", nil, tt.cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// exporterConfig mirrors the cross-field rules of the service configurations
+type exporterConfig struct {
+	Exporter  string        `yaml:"exporter" env:"TEST_EXPORTER" flag:"exporter" validate:"oneof=none otlp file"`
+	Endpoint  string        `yaml:"endpoint" flag:"endpoint" validate:"required_if=Exporter otlp"`
+	File      string        `yaml:"file" flag:"file" validate:"required_if=Exporter file"`
+	Interval  time.Duration `yaml:"interval" flag:"interval" validate:"gt=0"`
+	BatchSize int           `yaml:"batch_size" flag:"batch-size" validate:"gt=0"`
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"defaults", nil, ""},
+		{"otlp with endpoint", []string{"-exporter", "otlp", "-endpoint", "localhost:4317"}, ""},
+		{"file with file", []string{"-exporter", "file", "-file", "spans.json"}, ""},
+		{"otlp without endpoint", []string{"-exporter", "otlp"}, `endpoint failed "required_if" (Exporter otlp)`},
+		{"file without file", []string{"-exporter", "file", "-endpoint", "localhost:4317"}, `file failed "required_if" (Exporter file)`},
+		{"unknown exporter", []string{"-exporter", "jaeger"}, `exporter failed "oneof" (none otlp file)`},
+		{"empty exporter", []string{"-exporter", ""}, `exporter failed "oneof"`},
+		{"zero duration", []string{"-interval", "0s"}, `interval failed "gt" (0)`},
+		{"negative duration", []string{"-interval", "-1s"}, `interval failed "gt" (0)`},
+		{"zero integer", []string{"-batch-size", "0"}, `batch_size failed "gt" (0)`},
+		{"every failure", []string{"-exporter", "otlp", "-batch-size", "0", "-interval", "0s"},
+			`config: invalid configuration: endpoint failed "required_if" (Exporter otlp); interval failed "gt" (0); batch_size failed "gt" (0)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setEnv(t, nil)
+			cfg := &exporterConfig{Exporter: "none", Interval: time.Second, BatchSize: 100}
+			_, err := Load("test", tt.args, cfg)
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("Load = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// limitsConfig checks a constraint between fields in Validate
+type limitsConfig struct {
+	Min int `yaml:"min" flag:"min"`
+	Max int `yaml:"max" flag:"max"`
+}
+
+func (c *limitsConfig) Validate() error {
+	if c.Min > c.Max {
+		return errors.New("min is greater than max")
+	}
+	return nil
+}
+
+func TestValidateMethod(t *testing.T) {
+	setEnv(t, nil)
+	if _, err := Load("test", []string{"-min", "2", "-max", "3"}, &limitsConfig{}); err != nil {
+		t.Errorf("Load = %v, want nil", err)
+	}
+	_, err := Load("test", []string{"-min", "4", "-max", "3"}, &limitsConfig{})
+	if err == nil || err.Error() != "config: invalid configuration: min is greater than max" {
+		t.Errorf("Load = %v, want the Validate error", err)
+	}
+}
+
+func TestLoadArgs(t *testing.T) {
+	setEnv(t, nil)
+
+	args, err := Load("test", []string{"-port", "9000", "migrate", "down", "-2"}, defaultServerConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"migrate", "down", "-2"}; !reflect.DeepEqual(args, want) {
+		t.Errorf("args = %q, want %q", args, want)
+	}
+
+	args, err = Load("test", []string{"-debug", "--", "-port"}, defaultServerConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"-port"}; !reflect.DeepEqual(args, want) {
+		t.Errorf("args after -- = %q, want %q", args, want)
+	}
+
+	args, err = Load("test", nil, defaultServerConfig())
+	if err != nil || len(args) != 0 {
+		t.Errorf("Load without args = %q, %v, want no args", args, err)
+	}
+}
+
+func TestLoadFlagErrors(t *testing.T) {
+	setEnv(t, nil)
+	tests := []struct {
+		name string
+		args []string
+		want error
+		text string
+	}{
+		{"unknown flag", []string{"-verbose"}, nil, "flag provided but not defined: -verbose"},
+		{"missing value", []string{"-port"}, nil, "flag needs an argument: -port"},
+		{"help", []string{"-h"}, flag.ErrHelp, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := defaultServerConfig()
+			_, err := Load("test", tt.args, cfg)
+			if err == nil || (tt.want != nil && !errors.Is(err, tt.want)) || !strings.Contains(err.Error(), tt.text) {
+				t.Errorf("Load = %v, want %v %q", err, tt.want, tt.text)
+			}
+		})
+	}
+}
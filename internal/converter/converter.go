@@ -0,0 +1,26 @@
+// Package converter translates between the service models and their
+// protobuf messages. Conversions are lossless in both directions, so
+// FromProto(ToProto(x)) returns x.
+package converter
+
+import (
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// timestampToProto converts a time, leaving the zero time unset
+func timestampToProto(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+// timestampFromProto converts a timestamp, mapping an unset one to the zero time
+func timestampFromProto(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
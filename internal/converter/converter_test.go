@@ -0,0 +1,55 @@
+package converter
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+var (
+	testCreatedAt = time.Date(2024, time.March, 1, 9, 30, 0, 123456789, time.UTC)
+	testUpdatedAt = time.Date(2024, time.March, 2, 17, 45, 10, 0, time.UTC)
+)
+
+// assertAllFieldsSet fails the test for every field of msg, including fields
+// of nested messages, that the conversion left unset
+func assertAllFieldsSet(t *testing.T, msg proto.Message) {
+	t.Helper()
+	checkFieldsSet(t, msg.ProtoReflect(), string(msg.ProtoReflect().Descriptor().Name()))
+}
+
+func checkFieldsSet(t *testing.T, m protoreflect.Message, path string) {
+	t.Helper()
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		name := path + "." + string(field.Name())
+		if !m.Has(field) {
+			t.Errorf("%s is not set", name)
+			continue
+		}
+
+		switch {
+		case field.IsList() && field.Message() != nil:
+			list := m.Get(field).List()
+			for j := 0; j < list.Len(); j++ {
+				checkFieldsSet(t, list.Get(j).Message(), name)
+			}
+		case field.Message() != nil && !field.IsMap():
+			if field.Message().FullName() != "google.protobuf.Timestamp" {
+				checkFieldsSet(t, m.Get(field).Message(), name)
+			}
+		}
+	}
+}
+
+func TestTimestampZeroValues(t *testing.T) {
+	if ts := timestampToProto(time.Time{}); ts != nil {
+		t.Errorf("timestampToProto(zero) = %v, want nil", ts)
+	}
+	if got := timestampFromProto(nil); !got.IsZero() {
+		t.Errorf("timestampFromProto(nil) = %v, want zero time", got)
+	}
+}
@@ -0,0 +1,194 @@
+package converter
+
+import (
+	"bweng/api/proto/order"
+	"bweng/internal/money"
+	ordermodel "bweng/internal/order/model"
+)
+
+// orderStatusToProto maps model order statuses to the proto enum
+var orderStatusToProto = map[ordermodel.OrderStatus]order.OrderStatus{
+	ordermodel.OrderStatusPending:   order.OrderStatus_PENDING,
+	ordermodel.OrderStatusConfirmed: order.OrderStatus_CONFIRMED,
+	ordermodel.OrderStatusShipped:   order.OrderStatus_SHIPPED,
+	ordermodel.OrderStatusDelivered: order.OrderStatus_DELIVERED,
+	ordermodel.OrderStatusCancelled: order.OrderStatus_CANCELLED,
+}
+
+// orderStatusFromProto maps proto enum values to model order statuses
+var orderStatusFromProto = map[order.OrderStatus]ordermodel.OrderStatus{
+	order.OrderStatus_PENDING:   ordermodel.OrderStatusPending,
+	order.OrderStatus_CONFIRMED: ordermodel.OrderStatusConfirmed,
+	order.OrderStatus_SHIPPED:   ordermodel.OrderStatusShipped,
+	order.OrderStatus_DELIVERED: ordermodel.OrderStatusDelivered,
+	order.OrderStatus_CANCELLED: ordermodel.OrderStatusCancelled,
+}
+
+// OrderStatusToProto converts an order status to the proto enum.
+// It reports false for unknown statuses.
+func OrderStatusToProto(status ordermodel.OrderStatus) (order.OrderStatus, bool) {
+	s, ok := orderStatusToProto[status]
+	return s, ok
+}
+
+// OrderStatusFromProto converts a proto enum value to an order status.
+// It reports false for unknown values.
+func OrderStatusFromProto(status order.OrderStatus) (ordermodel.OrderStatus, bool) {
+	s, ok := orderStatusFromProto[status]
+	return s, ok
+}
+
+// MoneyToProto converts an amount to the proto Money message
+func MoneyToProto(m money.Money) *order.Money {
+	return &order.Money{
+		MinorUnits: m.MinorUnits,
+		Currency:   m.Currency,
+	}
+}
+
+// MoneyFromProto converts a proto Money message to an amount
+func MoneyFromProto(m *order.Money) money.Money {
+	if m == nil {
+		return money.Money{}
+	}
+	return money.Money{
+		MinorUnits: m.MinorUnits,
+		Currency:   m.Currency,
+	}
+}
+
+// OrderToProto converts an OrderResponse to the proto Order message
+func OrderToProto(o *ordermodel.OrderResponse) *order.Order {
+	if o == nil {
+		return nil
+	}
+
+	items := make([]*order.OrderItem, len(o.Items))
+	for i, item := range o.Items {
+		items[i] = &order.OrderItem{
+			Id:          uint64(item.ID),
+			ProductName: item.ProductName,
+			UnitPrice:   MoneyToProto(item.UnitPrice),
+			Quantity:    int32(item.Quantity),
+			Subtotal:    MoneyToProto(item.Subtotal),
+		}
+	}
+
+	status, _ := OrderStatusToProto(o.Status)
+	return &order.Order{
+		Id:        uint64(o.ID),
+		UserId:    uint64(o.UserID),
+		Items:     items,
+		Currency:  o.Currency,
+		Total:     MoneyToProto(o.Total),
+		Status:    status,
+		CreatedAt: timestampToProto(o.CreatedAt),
+		UpdatedAt: timestampToProto(o.UpdatedAt),
+	}
+}
+
+// OrderFromProto converts a proto Order message to an OrderResponse
+func OrderFromProto(o *order.Order) *ordermodel.OrderResponse {
+	if o == nil {
+		return nil
+	}
+
+	items := make([]*ordermodel.OrderItemResponse, len(o.Items))
+	for i, item := range o.Items {
+		items[i] = &ordermodel.OrderItemResponse{
+			ID:          uint(item.Id),
+			ProductName: item.ProductName,
+			UnitPrice:   MoneyFromProto(item.UnitPrice),
+			Quantity:    int(item.Quantity),
+			Subtotal:    MoneyFromProto(item.Subtotal),
+		}
+	}
+
+	status, _ := OrderStatusFromProto(o.Status)
+	return &ordermodel.OrderResponse{
+		ID:        uint(o.Id),
+		UserID:    uint(o.UserId),
+		Items:     items,
+		Currency:  o.Currency,
+		Total:     MoneyFromProto(o.Total),
+		Status:    status,
+		CreatedAt: timestampFromProto(o.CreatedAt),
+		UpdatedAt: timestampFromProto(o.UpdatedAt),
+	}
+}
+
+// OrdersToProto converts a list of OrderResponses to proto Order messages
+func OrdersToProto(orders []*ordermodel.OrderResponse) []*order.Order {
+	orderList := make([]*order.Order, len(orders))
+	for i, o := range orders {
+		orderList[i] = OrderToProto(o)
+	}
+	return orderList
+}
+
+// StatusChangeToProto converts a status history entry to the proto message
+func StatusChangeToProto(entry *ordermodel.OrderStatusHistoryResponse) *order.OrderStatusChange {
+	toStatus, _ := OrderStatusToProto(entry.ToStatus)
+	change := &order.OrderStatusChange{
+		ToStatus:  toStatus,
+		ActorId:   uint64(entry.ActorID),
+		Actor:     entry.Actor,
+		Reason:    entry.Reason,
+		CreatedAt: timestampToProto(entry.CreatedAt),
+	}
+	// The entry recorded when an order is created has no previous status
+	if from, ok := OrderStatusToProto(entry.FromStatus); ok {
+		change.FromStatus = &from
+	}
+	return change
+}
+
+// StatusChangeFromProto converts a proto status change to a history entry
+func StatusChangeFromProto(change *order.OrderStatusChange) *ordermodel.OrderStatusHistoryResponse {
+	toStatus, _ := OrderStatusFromProto(change.ToStatus)
+	entry := &ordermodel.OrderStatusHistoryResponse{
+		ToStatus:  toStatus,
+		ActorID:   uint(change.ActorId),
+		Actor:     change.Actor,
+		Reason:    change.Reason,
+		CreatedAt: timestampFromProto(change.CreatedAt),
+	}
+	if change.FromStatus != nil {
+		entry.FromStatus, _ = OrderStatusFromProto(change.GetFromStatus())
+	}
+	return entry
+}
+
+// StatusChangesToProto converts status history entries to proto messages
+func StatusChangesToProto(history []*ordermodel.OrderStatusHistoryResponse) []*order.OrderStatusChange {
+	changes := make([]*order.OrderStatusChange, len(history))
+	for i, entry := range history {
+		changes[i] = StatusChangeToProto(entry)
+	}
+	return changes
+}
+
+// CreateOrderRequestFromProto converts a proto CreateOrderRequest
+func CreateOrderRequestFromProto(req *order.CreateOrderRequest) *ordermodel.CreateOrderRequest {
+	createReq := &ordermodel.CreateOrderRequest{
+		UserID:         uint(req.UserId),
+		Currency:       req.Currency,
+		ProductName:    req.ProductName,
+		Price:          req.Price,
+		Quantity:       int(req.Quantity),
+		IdempotencyKey: req.IdempotencyKey,
+	}
+	for _, item := range req.Items {
+		itemReq := ordermodel.CreateOrderItemRequest{
+			ProductName: item.ProductName,
+			Price:       item.Price,
+			Quantity:    int(item.Quantity),
+		}
+		if item.UnitPrice != nil {
+			unitPrice := MoneyFromProto(item.UnitPrice)
+			itemReq.UnitPrice = &unitPrice
+		}
+		createReq.Items = append(createReq.Items, itemReq)
+	}
+	return createReq
+}
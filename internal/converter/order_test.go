@@ -0,0 +1,177 @@
+package converter
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"bweng/api/proto/order"
+	"bweng/internal/money"
+	ordermodel "bweng/internal/order/model"
+)
+
+func testOrder() *ordermodel.OrderResponse {
+	return &ordermodel.OrderResponse{
+		ID:     10,
+		UserID: 42,
+		Items: []*ordermodel.OrderItemResponse{
+			{
+				ID:          1,
+				ProductName: "iPhone 15",
+				UnitPrice:   money.Money{MinorUnits: 99999, Currency: "USD"},
+				Quantity:    2,
+				Subtotal:    money.Money{MinorUnits: 199998, Currency: "USD"},
+			},
+			{
+				ID:          2,
+				ProductName: "Case",
+				UnitPrice:   money.Money{MinorUnits: 1999, Currency: "USD"},
+				Quantity:    1,
+				Subtotal:    money.Money{MinorUnits: 1999, Currency: "USD"},
+			},
+		},
+		Currency:  "USD",
+		Total:     money.Money{MinorUnits: 201997, Currency: "USD"},
+		Status:    ordermodel.OrderStatusShipped,
+		CreatedAt: testCreatedAt,
+		UpdatedAt: testUpdatedAt,
+	}
+}
+
+func TestOrderToProtoSetsAllFields(t *testing.T) {
+	assertAllFieldsSet(t, OrderToProto(testOrder()))
+}
+
+func TestOrderRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		order *ordermodel.OrderResponse
+	}{
+		{name: "full", order: testOrder()},
+		{name: "no items", order: &ordermodel.OrderResponse{
+			ID:     3,
+			UserID: 1,
+			Items:  []*ordermodel.OrderItemResponse{},
+			Status: ordermodel.OrderStatusPending,
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := OrderFromProto(OrderToProto(tt.order))
+			if !reflect.DeepEqual(got, tt.order) {
+				t.Errorf("round trip = %+v, want %+v", got, tt.order)
+			}
+		})
+	}
+}
+
+func TestOrderProtoRoundTrip(t *testing.T) {
+	msg := &order.Order{
+		Id:     5,
+		UserId: 9,
+		Items: []*order.OrderItem{{
+			Id:          1,
+			ProductName: "Book",
+			UnitPrice:   &order.Money{MinorUnits: 1500, Currency: "JPY"},
+			Quantity:    3,
+			Subtotal:    &order.Money{MinorUnits: 4500, Currency: "JPY"},
+		}},
+		Currency:  "JPY",
+		Total:     &order.Money{MinorUnits: 4500, Currency: "JPY"},
+		Status:    order.OrderStatus_DELIVERED,
+		CreatedAt: timestamppb.New(testCreatedAt),
+		UpdatedAt: timestamppb.New(testUpdatedAt),
+	}
+
+	got := OrderToProto(OrderFromProto(msg))
+	if !proto.Equal(got, msg) {
+		t.Errorf("round trip = %v, want %v", got, msg)
+	}
+}
+
+func TestOrderStatusRoundTrip(t *testing.T) {
+	statuses := []ordermodel.OrderStatus{
+		ordermodel.OrderStatusPending,
+		ordermodel.OrderStatusConfirmed,
+		ordermodel.OrderStatusShipped,
+		ordermodel.OrderStatusDelivered,
+		ordermodel.OrderStatusCancelled,
+	}
+
+	for _, status := range statuses {
+		protoStatus, ok := OrderStatusToProto(status)
+		if !ok {
+			t.Errorf("OrderStatusToProto(%q) reported unknown status", status)
+			continue
+		}
+		if got, ok := OrderStatusFromProto(protoStatus); !ok || got != status {
+			t.Errorf("OrderStatusFromProto(%v) = %q, %v, want %q", protoStatus, got, ok, status)
+		}
+	}
+
+	if _, ok := OrderStatusToProto("lost"); ok {
+		t.Error("OrderStatusToProto accepted an unknown status")
+	}
+	if _, ok := OrderStatusFromProto(order.OrderStatus(99)); ok {
+		t.Error("OrderStatusFromProto accepted an unknown value")
+	}
+}
+
+func TestStatusChangeRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry *ordermodel.OrderStatusHistoryResponse
+	}{
+		{name: "transition", entry: &ordermodel.OrderStatusHistoryResponse{
+			FromStatus: ordermodel.OrderStatusPending,
+			ToStatus:   ordermodel.OrderStatusConfirmed,
+			ActorID:    42,
+			Actor:      "johndoe",
+			Reason:     "Payment received",
+			CreatedAt:  testCreatedAt,
+		}},
+		{name: "creation", entry: &ordermodel.OrderStatusHistoryResponse{
+			ToStatus:  ordermodel.OrderStatusPending,
+			Actor:     "system",
+			CreatedAt: testCreatedAt,
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := StatusChangeFromProto(StatusChangeToProto(tt.entry))
+			if !reflect.DeepEqual(got, tt.entry) {
+				t.Errorf("round trip = %+v, want %+v", got, tt.entry)
+			}
+		})
+	}
+}
+
+func TestCreateOrderRequestFromProto(t *testing.T) {
+	req := &order.CreateOrderRequest{
+		UserId:   42,
+		Currency: "EUR",
+		Items: []*order.CreateOrderItem{
+			{ProductName: "Lamp", Quantity: 2, UnitPrice: &order.Money{MinorUnits: 2500, Currency: "EUR"}},
+			{ProductName: "Bulb", Quantity: 4, Price: 1.5},
+		},
+		IdempotencyKey: "retry-1",
+	}
+
+	want := &ordermodel.CreateOrderRequest{
+		UserID:   42,
+		Currency: "EUR",
+		Items: []ordermodel.CreateOrderItemRequest{
+			{ProductName: "Lamp", Quantity: 2, UnitPrice: &money.Money{MinorUnits: 2500, Currency: "EUR"}},
+			{ProductName: "Bulb", Quantity: 4, Price: 1.5},
+		},
+		IdempotencyKey: "retry-1",
+	}
+
+	if got := CreateOrderRequestFromProto(req); !reflect.DeepEqual(got, want) {
+		t.Errorf("CreateOrderRequestFromProto = %+v, want %+v", got, want)
+	}
+}
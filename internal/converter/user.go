@@ -0,0 +1,59 @@
+package converter
+
+import (
+	"bweng/api/proto/user"
+	usermodel "bweng/internal/user/model"
+)
+
+// UserToProto converts a UserResponse to the proto User message
+func UserToProto(u *usermodel.UserResponse) *user.User {
+	if u == nil {
+		return nil
+	}
+	return &user.User{
+		Id:        uint64(u.ID),
+		Username:  u.Username,
+		Email:     u.Email,
+		FirstName: u.FirstName,
+		LastName:  u.LastName,
+		Role:      u.Role,
+		CreatedAt: timestampToProto(u.CreatedAt),
+		UpdatedAt: timestampToProto(u.UpdatedAt),
+	}
+}
+
+// UserFromProto converts a proto User message to a UserResponse
+func UserFromProto(u *user.User) *usermodel.UserResponse {
+	if u == nil {
+		return nil
+	}
+	return &usermodel.UserResponse{
+		ID:        uint(u.Id),
+		Username:  u.Username,
+		Email:     u.Email,
+		FirstName: u.FirstName,
+		LastName:  u.LastName,
+		Role:      u.Role,
+		CreatedAt: timestampFromProto(u.CreatedAt),
+		UpdatedAt: timestampFromProto(u.UpdatedAt),
+	}
+}
+
+// UsersToProto converts a list of UserResponses to proto User messages
+func UsersToProto(users []*usermodel.UserResponse) []*user.User {
+	userList := make([]*user.User, len(users))
+	for i, u := range users {
+		userList[i] = UserToProto(u)
+	}
+	return userList
+}
+
+// TokensToProto converts a TokenResponse to the proto TokenResponse message
+func TokensToProto(tokens *usermodel.TokenResponse) *user.TokenResponse {
+	return &user.TokenResponse{
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		TokenType:    tokens.TokenType,
+		ExpiresIn:    tokens.ExpiresIn,
+	}
+}
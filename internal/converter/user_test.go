@@ -0,0 +1,100 @@
+package converter
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"bweng/api/proto/user"
+	usermodel "bweng/internal/user/model"
+)
+
+func testUser() *usermodel.UserResponse {
+	return &usermodel.UserResponse{
+		ID:        42,
+		Username:  "johndoe",
+		Email:     "john@example.com",
+		FirstName: "John",
+		LastName:  "Doe",
+		Role:      "admin",
+		CreatedAt: testCreatedAt,
+		UpdatedAt: testUpdatedAt,
+	}
+}
+
+func TestUserToProtoSetsAllFields(t *testing.T) {
+	assertAllFieldsSet(t, UserToProto(testUser()))
+}
+
+func TestUserToProtoTimestamps(t *testing.T) {
+	got := UserToProto(testUser())
+	if !got.CreatedAt.AsTime().Equal(testCreatedAt) {
+		t.Errorf("CreatedAt = %v, want %v", got.CreatedAt.AsTime(), testCreatedAt)
+	}
+	if !got.UpdatedAt.AsTime().Equal(testUpdatedAt) {
+		t.Errorf("UpdatedAt = %v, want %v", got.UpdatedAt.AsTime(), testUpdatedAt)
+	}
+}
+
+func TestUserRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		user *usermodel.UserResponse
+	}{
+		{name: "full", user: testUser()},
+		{name: "zero timestamps", user: &usermodel.UserResponse{ID: 1, Username: "jane", Role: "customer"}},
+		{name: "empty", user: &usermodel.UserResponse{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := UserFromProto(UserToProto(tt.user))
+			if !reflect.DeepEqual(got, tt.user) {
+				t.Errorf("round trip = %+v, want %+v", got, tt.user)
+			}
+		})
+	}
+}
+
+func TestUserProtoRoundTrip(t *testing.T) {
+	msg := &user.User{
+		Id:        7,
+		Username:  "jane",
+		Email:     "jane@example.com",
+		FirstName: "Jane",
+		LastName:  "Roe",
+		Role:      "support",
+		CreatedAt: timestamppb.New(testCreatedAt),
+		UpdatedAt: timestamppb.New(testUpdatedAt),
+	}
+
+	got := UserToProto(UserFromProto(msg))
+	if !proto.Equal(got, msg) {
+		t.Errorf("round trip = %v, want %v", got, msg)
+	}
+}
+
+func TestUserConversionsHandleNil(t *testing.T) {
+	if got := UserToProto(nil); got != nil {
+		t.Errorf("UserToProto(nil) = %v, want nil", got)
+	}
+	if got := UserFromProto(nil); got != nil {
+		t.Errorf("UserFromProto(nil) = %v, want nil", got)
+	}
+}
+
+func TestUsersToProto(t *testing.T) {
+	users := []*usermodel.UserResponse{testUser(), {ID: 2, Username: "jane"}}
+
+	got := UsersToProto(users)
+	if len(got) != len(users) {
+		t.Fatalf("len = %d, want %d", len(got), len(users))
+	}
+	for i, u := range got {
+		if !reflect.DeepEqual(UserFromProto(u), users[i]) {
+			t.Errorf("users[%d] = %+v, want %+v", i, UserFromProto(u), users[i])
+		}
+	}
+}
@@ -0,0 +1,199 @@
+// Package health runs liveness and readiness checks and serves them over
+// HTTP and the standard grpc.health.v1 Health service.
+package health
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Report and check statuses
+const (
+	StatusOK          = "ok"
+	StatusDegraded    = "degraded"
+	StatusUnavailable = "unavailable"
+	StatusError       = "error"
+)
+
+// Config holds health check timing
+type Config struct {
+	// Timeout bounds each individual check
+	Timeout time.Duration `yaml:"timeout" env:"HEALTH_CHECK_TIMEOUT" flag:"health-check-timeout" usage:"Deadline of each readiness check" validate:"gt=0"`
+	// Interval is how often the gRPC health status is re-evaluated
+	Interval time.Duration `yaml:"interval" env:"HEALTH_CHECK_INTERVAL" flag:"health-check-interval" usage:"How often the gRPC health status is updated" validate:"gt=0"`
+}
+
+// DefaultConfig returns the health check defaults
+func DefaultConfig() Config {
+	return Config{
+		Timeout:  2 * time.Second,
+		Interval: 5 * time.Second,
+	}
+}
+
+// Check reports a dependency as healthy by returning nil
+type Check func(ctx context.Context) error
+
+// check is a named readiness check
+type check struct {
+	name     string
+	fn       Check
+	critical bool
+}
+
+// CheckResult is the outcome of a single check
+type CheckResult struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Critical  bool    `json:"critical"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report is the outcome of all readiness checks
+type Report struct {
+	Status  string                 `json:"status"`
+	Service string                 `json:"service"`
+	Checks  map[string]CheckResult `json:"checks"`
+}
+
+// Ready reports whether every critical check passed
+func (r *Report) Ready() bool {
+	return r.Status != StatusUnavailable
+}
+
+// Checker runs the readiness checks of a service
+type Checker struct {
+	service string
+	config  Config
+	checks  []check
+}
+
+// NewChecker creates a new checker for the named service
+func NewChecker(service string, cfg Config) *Checker {
+	return &Checker{
+		service: service,
+		config:  cfg,
+	}
+}
+
+// Add registers a critical check; the service is not ready while it fails
+func (c *Checker) Add(name string, fn Check) {
+	c.checks = append(c.checks, check{name: name, fn: fn, critical: true})
+}
+
+// AddNonCritical registers a check that is reported but only degrades the
+// service, for dependencies the service can partially work without
+func (c *Checker) AddNonCritical(name string, fn Check) {
+	c.checks = append(c.checks, check{name: name, fn: fn})
+}
+
+// Run runs all checks concurrently, each under the configured timeout
+func (c *Checker) Run(ctx context.Context) *Report {
+	report := &Report{
+		Status:  StatusOK,
+		Service: c.service,
+		Checks:  make(map[string]CheckResult, len(c.checks)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, chk := range c.checks {
+		wg.Add(1)
+		go func(chk check) {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, c.config.Timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := chk.fn(checkCtx)
+			result := CheckResult{
+				Status:    StatusOK,
+				LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+				Critical:  chk.critical,
+			}
+			if err != nil {
+				result.Status = StatusError
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[chk.name] = result
+			if err != nil {
+				if chk.critical {
+					report.Status = StatusUnavailable
+				} else if report.Status == StatusOK {
+					report.Status = StatusDegraded
+				}
+			}
+		}(chk)
+	}
+	wg.Wait()
+
+	return report
+}
+
+// LivenessHandler reports that the process is up and serving HTTP. It runs
+// no dependency checks so a failing database does not get the pod restarted.
+func (c *Checker) LivenessHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, gin.H{
+			"status":  StatusOK,
+			"service": c.service,
+		})
+	}
+}
+
+// ReadinessHandler runs the checks and responds 503 if a critical one failed
+func (c *Checker) ReadinessHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		report := c.Run(ctx.Request.Context())
+		code := http.StatusOK
+		if !report.Ready() {
+			code = http.StatusServiceUnavailable
+		}
+		ctx.JSON(code, report)
+	}
+}
+
+// NewGRPCServer creates a grpc.health.v1 server reporting NOT_SERVING until
+// WatchGRPC first runs the checks
+func NewGRPCServer(services ...string) *grpchealth.Server {
+	srv := grpchealth.NewServer()
+	srv.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	for _, service := range services {
+		srv.SetServingStatus(service, healthpb.HealthCheckResponse_NOT_SERVING)
+	}
+	return srv
+}
+
+// WatchGRPC re-evaluates the checks every interval and publishes the result
+// on srv for the overall server and each named service until ctx is cancelled
+func (c *Checker) WatchGRPC(ctx context.Context, srv *grpchealth.Server, services ...string) {
+	ticker := time.NewTicker(c.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		status := healthpb.HealthCheckResponse_SERVING
+		if !c.Run(ctx).Ready() {
+			status = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+		srv.SetServingStatus("", status)
+		for _, service := range services {
+			srv.SetServingStatus(service, status)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,271 @@
+// Package lifecycle runs a service's servers and background workers and
+// shuts them down gracefully on SIGINT, SIGTERM or the failure of any one
+// of them.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// ErrShuttingDown is reported by ReadyCheck once shutdown has begun
+var ErrShuttingDown = errors.New("shutting down")
+
+// Config holds shutdown timing
+type Config struct {
+	// ShutdownTimeout bounds draining in-flight requests and stopping workers
+	ShutdownTimeout time.Duration `yaml:"timeout" env:"SHUTDOWN_TIMEOUT" flag:"shutdown-timeout" usage:"Deadline for draining requests on shutdown" validate:"gt=0"`
+	// DrainDelay is how long readiness fails before the servers stop
+	// accepting connections, so load balancers stop routing to the process
+	DrainDelay time.Duration `yaml:"drain_delay" env:"SHUTDOWN_DRAIN_DELAY" flag:"shutdown-drain-delay" usage:"How long readiness fails before servers stop" validate:"gte=0"`
+}
+
+// DefaultConfig returns shutdown timing that fits in the default Kubernetes
+// termination grace period of 30 seconds
+func DefaultConfig() Config {
+	return Config{
+		ShutdownTimeout: 20 * time.Second,
+		DrainDelay:      5 * time.Second,
+	}
+}
+
+// server is a listening server managed by a Manager
+type server struct {
+	name     string
+	addr     string
+	serve    func(lis net.Listener) error
+	shutdown func(ctx context.Context) error
+}
+
+// closer is a resource released after all servers and workers have stopped
+type closer struct {
+	name  string
+	close func() error
+}
+
+// Manager coordinates the servers, workers and resources of a process
+type Manager struct {
+	config  Config
+	ready   atomic.Bool
+	servers []server
+	workers map[string]func(ctx context.Context)
+	closers []closer
+	// notReady is called once readiness starts failing
+	notReady []func()
+}
+
+// New creates a new lifecycle manager
+func New(cfg Config) *Manager {
+	return &Manager{
+		config:  cfg,
+		workers: make(map[string]func(ctx context.Context)),
+	}
+}
+
+// Ready reports whether the process is serving and not shutting down
+func (m *Manager) Ready() bool {
+	return m.ready.Load()
+}
+
+// ReadyCheck is a readiness check that fails once shutdown has begun
+func (m *Manager) ReadyCheck(ctx context.Context) error {
+	if !m.Ready() {
+		return ErrShuttingDown
+	}
+	return nil
+}
+
+// OnNotReady registers a function called when shutdown begins, before the
+// drain delay, e.g. to mark a gRPC health service NOT_SERVING
+func (m *Manager) OnNotReady(fn func()) {
+	m.notReady = append(m.notReady, fn)
+}
+
+// setNotReady fails readiness and notifies the registered functions
+func (m *Manager) setNotReady() {
+	if m.ready.Swap(false) {
+		for _, fn := range m.notReady {
+			fn()
+		}
+	}
+}
+
+// AddHTTPServer registers an HTTP server listening on srv.Addr
+func (m *Manager) AddHTTPServer(name string, srv *http.Server) {
+	m.servers = append(m.servers, server{
+		name: name,
+		addr: srv.Addr,
+		serve: func(lis net.Listener) error {
+			if err := srv.Serve(lis); !errors.Is(err, http.ErrServerClosed) {
+				return err
+			}
+			return nil
+		},
+		shutdown: srv.Shutdown,
+	})
+}
+
+// AddGRPCServer registers a gRPC server listening on addr
+func (m *Manager) AddGRPCServer(name, addr string, srv *grpc.Server) {
+	m.servers = append(m.servers, server{
+		name:  name,
+		addr:  addr,
+		serve: srv.Serve,
+		shutdown: func(ctx context.Context) error {
+			done := make(chan struct{})
+			go func() {
+				srv.GracefulStop()
+				close(done)
+			}()
+
+			select {
+			case <-done:
+				return nil
+			case <-ctx.Done():
+				// Abort the calls that did not finish in time
+				srv.Stop()
+				return ctx.Err()
+			}
+		},
+	})
+}
+
+// Go registers a background worker that runs until its context is cancelled
+func (m *Manager) Go(name string, run func(ctx context.Context)) {
+	m.workers[name] = run
+}
+
+// OnShutdown registers a function that releases a resource once all servers
+// and workers have stopped. Functions run in reverse registration order.
+func (m *Manager) OnShutdown(name string, fn func() error) {
+	m.closers = append(m.closers, closer{name: name, close: fn})
+}
+
+// OnShutdownClose is OnShutdown for an io.Closer
+func (m *Manager) OnShutdownClose(name string, c io.Closer) {
+	m.OnShutdown(name, c.Close)
+}
+
+// Run starts everything and blocks until ctx is cancelled, the process is
+// signalled or a server fails, then shuts down gracefully. It returns the
+// error of the server that failed, if any.
+func (m *Manager) Run(ctx context.Context) error {
+	ctx, stopSignals := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+
+	// Bind every listener before serving so a taken port fails startup
+	listeners := make([]net.Listener, 0, len(m.servers))
+	for _, s := range m.servers {
+		lis, err := net.Listen("tcp", s.addr)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			m.close()
+			return fmt.Errorf("%s: %w", s.name, err)
+		}
+		listeners = append(listeners, lis)
+	}
+
+	// Listeners queue connections from here on, so the process can report ready
+	m.ready.Store(true)
+
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	var workers sync.WaitGroup
+	for name, run := range m.workers {
+		workers.Add(1)
+		go func(name string, run func(ctx context.Context)) {
+			defer workers.Done()
+			run(workerCtx)
+			slog.Info("Worker stopped", "worker", name)
+		}(name, run)
+	}
+
+	serveErrs := make(chan error, len(m.servers))
+	for i, s := range m.servers {
+		go func(s server, lis net.Listener) {
+			slog.Info("Server listening", "server", s.name, "addr", lis.Addr().String())
+			err := s.serve(lis)
+			if err != nil {
+				err = fmt.Errorf("%s: %w", s.name, err)
+			}
+			serveErrs <- err
+		}(s, listeners[i])
+	}
+
+	var runErr error
+	select {
+	case <-ctx.Done():
+		// A second signal terminates the process immediately
+		stopSignals()
+		slog.Info("Shutdown signal received")
+		// Fail readiness first and give load balancers time to notice
+		m.setNotReady()
+		if m.config.DrainDelay > 0 {
+			slog.Info("Draining before stopping servers", "drain_delay", m.config.DrainDelay.String())
+			time.Sleep(m.config.DrainDelay)
+		}
+	case runErr = <-serveErrs:
+		m.setNotReady()
+		if runErr == nil {
+			runErr = errors.New("server stopped unexpectedly")
+		}
+		slog.Error("Shutting down after server failure", "error", runErr)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), m.config.ShutdownTimeout)
+	defer cancel()
+
+	var servers sync.WaitGroup
+	for _, s := range m.servers {
+		servers.Add(1)
+		go func(s server) {
+			defer servers.Done()
+			if err := s.shutdown(shutdownCtx); err != nil {
+				slog.Error("Server shutdown failed", "server", s.name, "error", err)
+				return
+			}
+			slog.Info("Server stopped", "server", s.name)
+		}(s)
+	}
+	servers.Wait()
+
+	stopWorkers()
+	workersDone := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(workersDone)
+	}()
+	select {
+	case <-workersDone:
+	case <-shutdownCtx.Done():
+		slog.Warn("Background workers did not stop before the shutdown deadline")
+	}
+
+	m.close()
+	return runErr
+}
+
+// close releases the registered resources in reverse order
+func (m *Manager) close() {
+	for i := len(m.closers) - 1; i >= 0; i-- {
+		c := m.closers[i]
+		if err := c.close(); err != nil {
+			slog.Error("Failed to close resource", "resource", c.name, "error", err)
+		}
+	}
+}
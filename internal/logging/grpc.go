@@ -0,0 +1,68 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// healthMethodPrefix identifies health checks, logged at debug level only
+const healthMethodPrefix = "/grpc.health.v1.Health/"
+
+// UnaryServerInterceptor assigns every call an ID, taken from the
+// x-request-id metadata or generated, and logs the call once it completes
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		var id string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(MetadataRequestID); len(values) > 0 {
+				id = values[0]
+			}
+		}
+		ctx = WithRequestID(ctx, requestIDOrNew(id))
+
+		resp, err := handler(ctx, req)
+
+		code := status.Code(err)
+		level := slog.LevelInfo
+		switch code {
+		case codes.OK:
+			if strings.HasPrefix(info.FullMethod, healthMethodPrefix) {
+				level = slog.LevelDebug
+			}
+		case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unavailable, codes.DeadlineExceeded:
+			level = slog.LevelError
+		default:
+			level = slog.LevelWarn
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", info.FullMethod),
+			slog.String("code", code.String()),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+		}
+		if err != nil {
+			attrs = append(attrs, slog.String("error", status.Convert(err).Message()))
+		}
+		slog.LogAttrs(ctx, level, "gRPC call", attrs...)
+		return resp, err
+	}
+}
+
+// UnaryClientInterceptor forwards the request ID of ctx in the outgoing
+// x-request-id metadata
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if id := RequestID(ctx); id != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, MetadataRequestID, id)
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
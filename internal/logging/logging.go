@@ -0,0 +1,80 @@
+// Package logging configures the process-wide slog logger and carries
+// request IDs through HTTP headers, gRPC metadata and contexts so every log
+// line of a request can be correlated.
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"os"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Config holds the logger settings
+type Config struct {
+	Level  string `yaml:"level" env:"LOG_LEVEL" flag:"log-level" usage:"Minimum log level: debug, info, warn or error" validate:"oneof=debug info warn error"`
+	Format string `yaml:"format" env:"LOG_FORMAT" flag:"log-format" usage:"Log output format: json or text" validate:"oneof=json text"`
+}
+
+// DefaultConfig returns JSON logging at info level
+func DefaultConfig() Config {
+	return Config{
+		Level:  "info",
+		Format: "json",
+	}
+}
+
+// Setup installs a logger writing to standard error as the slog default.
+// Output of the standard log package is routed through it as well.
+func Setup(service string, cfg Config) *slog.Logger {
+	var level slog.Level
+	// The level was validated with the configuration
+	_ = level.UnmarshalText([]byte(cfg.Level))
+
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler = slog.NewJSONHandler(os.Stderr, opts)
+	if cfg.Format == "text" {
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	}
+
+	logger := slog.New(contextHandler{handler}).With("service", service)
+	slog.SetDefault(logger)
+	return logger
+}
+
+// Fatal logs msg with args at error level and exits the process
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// contextHandler adds the request ID and trace context of a record's
+// context to the record
+type contextHandler struct {
+	slog.Handler
+}
+
+// Handle adds request_id, trace_id and span_id when the context has them
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(
+			slog.String("trace_id", sc.TraceID().String()),
+			slog.String("span_id", sc.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+// WithAttrs keeps the context handler around loggers created with With
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup keeps the context handler around loggers created with WithGroup
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
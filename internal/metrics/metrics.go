@@ -0,0 +1,16 @@
+// Package metrics instruments HTTP handlers, gRPC servers and clients and
+// GORM with Prometheus metrics registered on the default registry.
+package metrics
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// latencyBuckets covers fast database queries up to slow upstream calls
+var latencyBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Handler serves the default registry in the Prometheus exposition format
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
@@ -0,0 +1,84 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// ErrUsage is returned for an unknown or malformed migrate subcommand
+var ErrUsage = errors.New("usage: migrate up | down [N] | status")
+
+// Command runs the migrate subcommand given by args, writing its report to w.
+//
+//	up        apply all pending migrations
+//	down [N]  revert the last N applied migrations (default 1)
+//	status    list the migrations and whether they were applied
+func Command(ctx context.Context, m *Migrator, args []string, w io.Writer) error {
+	if len(args) == 0 {
+		return ErrUsage
+	}
+
+	switch args[0] {
+	case "up":
+		if len(args) != 1 {
+			return ErrUsage
+		}
+		done, err := m.Up(ctx)
+		for _, migration := range done {
+			fmt.Fprintf(w, "applied  %s\n", migration)
+		}
+		if err == nil && len(done) == 0 {
+			fmt.Fprintln(w, "no pending migrations")
+		}
+		return err
+
+	case "down":
+		steps := 1
+		if len(args) > 2 {
+			return ErrUsage
+		}
+		if len(args) == 2 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n <= 0 {
+				return ErrUsage
+			}
+			steps = n
+		}
+		done, err := m.Down(ctx, steps)
+		for _, migration := range done {
+			fmt.Fprintf(w, "reverted %s\n", migration)
+		}
+		if err == nil && len(done) == 0 {
+			fmt.Fprintln(w, "no applied migrations")
+		}
+		return err
+
+	case "status":
+		if len(args) != 1 {
+			return ErrUsage
+		}
+		statuses, err := m.Status(ctx)
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		for _, s := range statuses {
+			state, at := "pending", ""
+			if s.Applied {
+				state, at = "applied", s.AppliedAt.Format(time.RFC3339)
+				if s.Modified {
+					state = "modified"
+				}
+			}
+			fmt.Fprintf(tw, "%04d\t%s\t%s\t%s\n", s.Version, s.Name, state, at)
+		}
+		tw.Flush()
+		return err
+
+	default:
+		return ErrUsage
+	}
+}
@@ -0,0 +1,405 @@
+// Package migrate applies versioned SQL migrations to a PostgreSQL database.
+//
+// Migrations are pairs of files named <version>_<name>.up.sql and
+// <version>_<name>.down.sql. Applied versions are recorded in the
+// schema_migrations table together with a checksum of their up file, so a
+// migration that is edited after it ran is detected instead of silently
+// diverging. A PostgreSQL advisory lock serializes processes that migrate
+// the same database at the same time.
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"log/slog"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidMigration = errors.New("invalid migration file")
+	ErrChecksumMismatch = errors.New("migration was modified after it was applied")
+	ErrUnknownMigration = errors.New("applied migration has no file")
+	ErrNoDownMigration  = errors.New("migration cannot be reverted")
+	ErrLockTimeout      = errors.New("timed out waiting for the migration lock")
+)
+
+// historyTable records the applied migrations
+const historyTable = "schema_migrations"
+
+// lockPollInterval is how often a waiting process retries the advisory lock
+const lockPollInterval = 500 * time.Millisecond
+
+// Config holds migration settings
+type Config struct {
+	// SkipOnStart leaves migrations to a separate `migrate up` run, such as
+	// a Kubernetes Job, instead of applying them before serving
+	SkipOnStart bool `yaml:"skip_on_start" env:"SKIP_MIGRATIONS" flag:"skip-migrations" usage:"Do not apply migrations at startup"`
+	// LockTimeout bounds waiting for another process applying migrations
+	LockTimeout time.Duration `yaml:"lock_timeout" env:"MIGRATION_LOCK_TIMEOUT" flag:"migration-lock-timeout" usage:"How long to wait for another process applying migrations" validate:"gt=0"`
+}
+
+// DefaultConfig applies migrations at startup
+func DefaultConfig() Config {
+	return Config{
+		LockTimeout: time.Minute,
+	}
+}
+
+// Migration is a versioned schema change
+type Migration struct {
+	Version int64
+	Name    string
+	// Checksum is the SHA-256 of the up file
+	Checksum string
+	up       string
+	down     string
+	hasDown  bool
+}
+
+// String returns the file name prefix of the migration
+func (m *Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// Status is the state of a migration in the database
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+	// Modified reports that the up file changed after it was applied
+	Modified bool
+}
+
+// applied is a row of the history table
+type applied struct {
+	checksum  string
+	appliedAt time.Time
+}
+
+// Migrator applies the migrations of one service to its database
+type Migrator struct {
+	db         *sql.DB
+	config     Config
+	migrations []*Migration
+}
+
+// New reads the migrations in the root directory of fsys
+func New(db *sql.DB, fsys fs.FS, cfg Config) (*Migrator, error) {
+	migrations, err := load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{
+		db:         db,
+		config:     cfg,
+		migrations: migrations,
+	}, nil
+}
+
+// load parses the migration files of fsys, sorted by version
+func load(fsys fs.FS) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+
+		base := strings.TrimSuffix(entry.Name(), ".sql")
+		base, direction := strings.TrimSuffix(base, path.Ext(base)), strings.TrimPrefix(path.Ext(base), ".")
+		rawVersion, name, ok := strings.Cut(base, "_")
+		version, err := strconv.ParseInt(rawVersion, 10, 64)
+		if !ok || err != nil || version <= 0 || name == "" || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("%w: %s is not named <version>_<name>.up.sql or .down.sql", ErrInvalidMigration, entry.Name())
+		}
+
+		body, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("%w: version %d is used by %s and %s", ErrInvalidMigration, version, m.Name, name)
+		}
+
+		if direction == "up" {
+			sum := sha256.Sum256(body)
+			m.up = string(body)
+			m.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			m.down = string(body)
+			m.hasDown = true
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Checksum == "" {
+			return nil, fmt.Errorf("%w: %s has no up file", ErrInvalidMigration, m)
+		}
+		migrations = append(migrations, m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Up applies all pending migrations in version order, each in its own
+// transaction, and returns the migrations it applied
+func (m *Migrator) Up(ctx context.Context) ([]*Migration, error) {
+	var done []*Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		history, err := m.verify(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := history[migration.Version]; ok {
+				continue
+			}
+
+			start := time.Now()
+			err := inTx(ctx, conn, func(tx *sql.Tx) error {
+				if _, err := tx.ExecContext(ctx, migration.up); err != nil {
+					return err
+				}
+				_, err := tx.ExecContext(ctx,
+					"INSERT INTO "+historyTable+" (version, name, checksum) VALUES ($1, $2, $3)",
+					migration.Version, migration.Name, migration.Checksum)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migration %s: %w", migration, err)
+			}
+
+			slog.InfoContext(ctx, "Applied migration",
+				"version", migration.Version,
+				"name", migration.Name,
+				"latency_ms", float64(time.Since(start).Microseconds())/1000,
+			)
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down reverts the last steps applied migrations, newest first, and returns
+// the migrations it reverted
+func (m *Migrator) Down(ctx context.Context, steps int) ([]*Migration, error) {
+	var done []*Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		history, err := m.verify(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := history[migration.Version]; !ok {
+				continue
+			}
+			if !migration.hasDown {
+				return fmt.Errorf("%w: %s has no down file", ErrNoDownMigration, migration)
+			}
+
+			err := inTx(ctx, conn, func(tx *sql.Tx) error {
+				if _, err := tx.ExecContext(ctx, migration.down); err != nil {
+					return err
+				}
+				_, err := tx.ExecContext(ctx, "DELETE FROM "+historyTable+" WHERE version = $1", migration.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migration %s: %w", migration, err)
+			}
+
+			slog.InfoContext(ctx, "Reverted migration", "version", migration.Version, "name", migration.Name)
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Status reports every known migration and whether it was applied
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	history, err := readHistory(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{Migration: *migration}
+		if row, ok := history[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = row.appliedAt
+			status.Modified = row.checksum != migration.Checksum
+		}
+		statuses = append(statuses, status)
+	}
+	for version := range history {
+		if !m.known(version) {
+			return statuses, fmt.Errorf("%w: version %d", ErrUnknownMigration, version)
+		}
+	}
+	return statuses, nil
+}
+
+// verify creates the history table if needed and checks that every applied
+// migration still has an unmodified file
+func (m *Migrator) verify(ctx context.Context, conn *sql.Conn) (map[int64]applied, error) {
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+historyTable+` (
+		version bigint PRIMARY KEY,
+		name text NOT NULL,
+		checksum text NOT NULL,
+		applied_at timestamptz NOT NULL DEFAULT now()
+	)`); err != nil {
+		return nil, err
+	}
+
+	history, err := readHistory(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	for version := range history {
+		if !m.known(version) {
+			return nil, fmt.Errorf("%w: version %d", ErrUnknownMigration, version)
+		}
+	}
+	for _, migration := range m.migrations {
+		if row, ok := history[migration.Version]; ok && row.checksum != migration.Checksum {
+			return nil, fmt.Errorf("%w: %s", ErrChecksumMismatch, migration)
+		}
+	}
+	return history, nil
+}
+
+// known reports whether a migration file exists for version
+func (m *Migrator) known(version int64) bool {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// readHistory returns the applied migrations by version. A database that
+// was never migrated has no history table and no applied migrations.
+func readHistory(ctx context.Context, conn *sql.Conn) (map[int64]applied, error) {
+	var exists bool
+	if err := conn.QueryRowContext(ctx, "SELECT to_regclass($1) IS NOT NULL", historyTable).Scan(&exists); err != nil {
+		return nil, err
+	}
+	history := make(map[int64]applied)
+	if !exists {
+		return history, nil
+	}
+
+	rows, err := conn.QueryContext(ctx, "SELECT version, checksum, applied_at FROM "+historyTable)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version int64
+		var row applied
+		if err := rows.Scan(&version, &row.checksum, &row.appliedAt); err != nil {
+			return nil, err
+		}
+		history[version] = row
+	}
+	return history, rows.Err()
+}
+
+// withLock runs fn on a dedicated connection holding the migration advisory
+// lock, so replicas starting together migrate one after the other
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	key := lockKey()
+	deadline := time.Now().Add(m.config.LockTimeout)
+	for waiting := false; ; {
+		var locked bool
+		if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&locked); err != nil {
+			return err
+		}
+		if locked {
+			break
+		}
+		if time.Now().After(deadline) {
+			return ErrLockTimeout
+		}
+		if !waiting {
+			slog.InfoContext(ctx, "Waiting for another process to finish migrating")
+			waiting = true
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+	defer func() {
+		// The lock is also released when the connection closes
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key); err != nil {
+			slog.Warn("Failed to release the migration lock", "error", err)
+		}
+	}()
+
+	return fn(conn)
+}
+
+// lockKey derives the advisory lock key from the history table name
+func lockKey() int64 {
+	h := fnv.New64a()
+	h.Write([]byte("bweng:" + historyTable))
+	return int64(h.Sum64())
+}
+
+// inTx runs fn in a transaction on conn, rolling back if it fails
+func inTx(ctx context.Context, conn *sql.Conn, fn func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,115 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// files builds a migration directory from file names and contents
+func files(contents map[string]string) fstest.MapFS {
+	fsys := fstest.MapFS{}
+	for name, body := range contents {
+		fsys[name] = &fstest.MapFile{Data: []byte(body)}
+	}
+	return fsys
+}
+
+func TestLoad(t *testing.T) {
+	migrations, err := load(files(map[string]string{
+		"0010_add_index.up.sql":       "CREATE INDEX i ON t (c);",
+		"0002_create_table.up.sql":    "CREATE TABLE t (c int);",
+		"0002_create_table.down.sql":  "DROP TABLE t;",
+		"0001_create_schema.up.sql":   "CREATE SCHEMA s;",
+		"0001_create_schema.down.sql": "DROP SCHEMA s;",
+		"README.md":                   "not a migration",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, m := range migrations {
+		names = append(names, m.String())
+	}
+	if got, want := strings.Join(names, " "), "0001_create_schema 0002_create_table 0010_add_index"; got != want {
+		t.Fatalf("migrations = %s, want %s sorted by version", got, want)
+	}
+
+	sum := sha256.Sum256([]byte("CREATE TABLE t (c int);"))
+	table := migrations[1]
+	if table.Checksum != hex.EncodeToString(sum[:]) {
+		t.Errorf("checksum = %s, want the SHA-256 of the up file", table.Checksum)
+	}
+	if table.up != "CREATE TABLE t (c int);" || table.down != "DROP TABLE t;" || !table.hasDown {
+		t.Errorf("0002 = up %q, down %q, want both files", table.up, table.down)
+	}
+	if migrations[2].hasDown {
+		t.Error("0010 has a down migration, want none")
+	}
+}
+
+func TestLoadRejectsInvalidFiles(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{"no version", map[string]string{"create_table.up.sql": ""}, "create_table.up.sql is not named"},
+		{"non-numeric version", map[string]string{"v1_create_table.up.sql": ""}, "v1_create_table.up.sql is not named"},
+		{"zero version", map[string]string{"0000_create_table.up.sql": ""}, "0000_create_table.up.sql is not named"},
+		{"negative version", map[string]string{"-1_create_table.up.sql": ""}, "is not named"},
+		{"no name", map[string]string{"0001_.up.sql": ""}, "0001_.up.sql is not named"},
+		{"no direction", map[string]string{"0001_create_table.sql": ""}, "0001_create_table.sql is not named"},
+		{"unknown direction", map[string]string{"0001_create_table.sideways.sql": ""}, "is not named"},
+		{"duplicate version", map[string]string{
+			"0001_create_table.up.sql": "",
+			"0001_create_index.up.sql": "",
+		}, "version 1 is used by"},
+		{"down without up", map[string]string{
+			"0001_create_table.up.sql":   "",
+			"0002_create_index.down.sql": "",
+		}, "0002_create_index has no up file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := load(files(tt.files))
+			if !errors.Is(err, ErrInvalidMigration) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("load = %v, want ErrInvalidMigration containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandUsage(t *testing.T) {
+	m, err := New(nil, files(map[string]string{"0001_create_table.up.sql": ""}), DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Malformed arguments are rejected before the database is touched, so a
+	// Migrator without one is enough
+	for _, args := range [][]string{
+		nil,
+		{"sideways"},
+		{"up", "1"},
+		{"down", "0"},
+		{"down", "-1"},
+		{"down", "x"},
+		{"down", "1", "2"},
+		{"status", "all"},
+	} {
+		var out bytes.Buffer
+		if err := Command(context.Background(), m, args, &out); !errors.Is(err, ErrUsage) {
+			t.Errorf("Command(%q) = %v, want ErrUsage", args, err)
+		}
+		if out.Len() != 0 {
+			t.Errorf("Command(%q) wrote %q, want nothing", args, out.String())
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package migrate_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	"bweng/internal/dbtest"
+	"bweng/internal/migrate"
+)
+
+// applied is the migration directory dbtest applies before each test
+var applied = fstest.MapFS{
+	"0001_create_widgets.up.sql":   {Data: []byte("CREATE TABLE widgets (id bigint PRIMARY KEY);")},
+	"0001_create_widgets.down.sql": {Data: []byte("DROP TABLE widgets;")},
+	"0002_add_name.up.sql":         {Data: []byte("ALTER TABLE widgets ADD COLUMN name text;")},
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+		want  error
+	}{
+		{"unchanged", applied, nil},
+		{"pending migration", fstest.MapFS{
+			"0001_create_widgets.up.sql": applied["0001_create_widgets.up.sql"],
+			"0002_add_name.up.sql":       applied["0002_add_name.up.sql"],
+			"0003_add_index.up.sql":      {Data: []byte("CREATE INDEX widgets_name ON widgets (name);")},
+		}, nil},
+		{"edited up file", fstest.MapFS{
+			"0001_create_widgets.up.sql": applied["0001_create_widgets.up.sql"],
+			"0002_add_name.up.sql":       {Data: []byte("ALTER TABLE widgets ADD COLUMN name text NOT NULL;")},
+		}, migrate.ErrChecksumMismatch},
+		{"deleted file", fstest.MapFS{
+			"0001_create_widgets.up.sql": applied["0001_create_widgets.up.sql"],
+		}, migrate.ErrUnknownMigration},
+		{"renumbered file", fstest.MapFS{
+			"0001_create_widgets.up.sql": applied["0001_create_widgets.up.sql"],
+			"0003_add_name.up.sql":       applied["0002_add_name.up.sql"],
+		}, migrate.ErrUnknownMigration},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := dbtest.Open(t, applied).DB()
+			if err != nil {
+				t.Fatal(err)
+			}
+			m, err := migrate.New(db, tt.files, migrate.DefaultConfig())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := context.Background()
+			done, err := m.Up(ctx)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Up = %v, want %v", err, tt.want)
+			}
+			if tt.want == nil {
+				return
+			}
+			if len(done) != 0 {
+				t.Errorf("Up applied %v after failing verification, want nothing", done)
+			}
+			// Down verifies the history before reverting anything as well
+			if done, err := m.Down(ctx, 1); !errors.Is(err, tt.want) || len(done) != 0 {
+				t.Errorf("Down = %v, %v, want %v", done, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is used for amounts that are given without a currency
+const DefaultCurrency = "USD"
+
+var (
+	ErrUnknownCurrency  = errors.New("unknown currency")
+	ErrInvalidAmount    = errors.New("invalid amount")
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+	ErrOverflow         = errors.New("amount out of range")
+)
+
+// currencyExponents maps supported ISO-4217 codes to their number of minor unit digits
+var currencyExponents = map[string]int{
+	"AED": 2, "AUD": 2, "BGN": 2, "BHD": 3, "BRL": 2, "CAD": 2, "CHF": 2,
+	"CLP": 0, "CNY": 2, "CZK": 2, "DKK": 2, "EGP": 2, "EUR": 2, "GBP": 2,
+	"HKD": 2, "HUF": 2, "IDR": 2, "ILS": 2, "INR": 2, "ISK": 0, "JOD": 3,
+	"JPY": 0, "KRW": 0, "KWD": 3, "MXN": 2, "NOK": 2, "NZD": 2, "OMR": 3,
+	"PLN": 2, "QAR": 2, "RON": 2, "RUB": 2, "SAR": 2, "SEK": 2, "SGD": 2,
+	"THB": 2, "TND": 3, "TRY": 2, "TWD": 2, "UAH": 2, "USD": 2, "VND": 0,
+	"ZAR": 2,
+}
+
+// Money is an exact amount in the minor units of an ISO-4217 currency,
+// e.g. 1999 USD is $19.99 and 1999 JPY is ¥1999
+type Money struct {
+	MinorUnits int64  `json:"minor_units" example:"99999"`
+	Currency   string `json:"currency" example:"USD"`
+}
+
+// NormalizeCurrency upper-cases a currency code and checks that it is supported
+func NormalizeCurrency(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if _, ok := currencyExponents[code]; !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownCurrency, code)
+	}
+	return code, nil
+}
+
+// Exponent returns the number of minor unit digits of a currency
+func Exponent(currency string) (int, error) {
+	code, err := NormalizeCurrency(currency)
+	if err != nil {
+		return 0, err
+	}
+	return currencyExponents[code], nil
+}
+
+// New creates an amount from minor units
+func New(minorUnits int64, currency string) (Money, error) {
+	code, err := NormalizeCurrency(currency)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{MinorUnits: minorUnits, Currency: code}, nil
+}
+
+// Parse parses a decimal amount such as "19.99" without going through
+// floating point. Amounts with more fractional digits than the currency
+// allows are rejected rather than rounded.
+func Parse(amount, currency string) (Money, error) {
+	exponent, err := Exponent(currency)
+	if err != nil {
+		return Money{}, err
+	}
+
+	s := strings.TrimSpace(amount)
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+
+	whole, fraction, _ := strings.Cut(s, ".")
+	if whole == "" || len(fraction) > exponent || !isDigits(whole) || !isDigits(fraction) {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, amount)
+	}
+
+	units, err := strconv.ParseInt(whole+fraction+strings.Repeat("0", exponent-len(fraction)), 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("%w: %q", ErrOverflow, amount)
+	}
+	if negative {
+		units = -units
+	}
+
+	return New(units, currency)
+}
+
+// FromFloat converts a floating point amount, rounding to the nearest minor
+// unit. It exists for clients that still send prices as JSON numbers.
+func FromFloat(amount float64, currency string) (Money, error) {
+	exponent, err := Exponent(currency)
+	if err != nil {
+		return Money{}, err
+	}
+	if math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return Money{}, ErrInvalidAmount
+	}
+
+	units := math.Round(amount * math.Pow10(exponent))
+	if units >= math.MaxInt64 || units < math.MinInt64 {
+		return Money{}, ErrOverflow
+	}
+
+	return New(int64(units), currency)
+}
+
+// Add returns the sum of two amounts of the same currency
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, other.Currency)
+	}
+
+	sum := m.MinorUnits + other.MinorUnits
+	if (other.MinorUnits > 0 && sum < m.MinorUnits) || (other.MinorUnits < 0 && sum > m.MinorUnits) {
+		return Money{}, ErrOverflow
+	}
+
+	return Money{MinorUnits: sum, Currency: m.Currency}, nil
+}
+
+// Multiply returns the amount multiplied by a quantity
+func (m Money) Multiply(quantity int64) (Money, error) {
+	if quantity == 0 || m.MinorUnits == 0 {
+		return Money{Currency: m.Currency}, nil
+	}
+
+	// MinInt64 * -1 wraps around to MinInt64, which the division does not catch
+	product := m.MinorUnits * quantity
+	if product/quantity != m.MinorUnits || (m.MinorUnits == -1 && quantity == math.MinInt64) || (m.MinorUnits == math.MinInt64 && quantity == -1) {
+		return Money{}, ErrOverflow
+	}
+
+	return Money{MinorUnits: product, Currency: m.Currency}, nil
+}
+
+// Decimal formats the amount as a decimal string such as "19.99"
+func (m Money) Decimal() string {
+	exponent := currencyExponents[m.Currency]
+	digits := strconv.FormatUint(absUnits(m.MinorUnits), 10)
+	if exponent > 0 {
+		if len(digits) <= exponent {
+			digits = strings.Repeat("0", exponent-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-exponent] + "." + digits[len(digits)-exponent:]
+	}
+	if m.MinorUnits < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+// String formats the amount with its currency, e.g. "19.99 USD"
+func (m Money) String() string {
+	return m.Decimal() + " " + m.Currency
+}
+
+// absUnits returns the magnitude of a minor unit amount
+func absUnits(units int64) uint64 {
+	if units < 0 {
+		return uint64(-(units + 1)) + 1
+	}
+	return uint64(units)
+}
+
+// isDigits reports whether s consists only of ASCII digits
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,118 @@
+package money
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		amount   string
+		currency string
+		want     Money
+		wantErr  error
+	}{
+		{"19.99", "USD", Money{1999, "USD"}, nil},
+		{"19.9", "usd", Money{1990, "USD"}, nil},
+		{"19", "USD", Money{1900, "USD"}, nil},
+		{" 0.05 ", "EUR", Money{5, "EUR"}, nil},
+		{"-3.50", "USD", Money{-350, "USD"}, nil},
+		{"1999", "JPY", Money{1999, "JPY"}, nil},
+		{"1.234", "KWD", Money{1234, "KWD"}, nil},
+		{"92233720368547758.07", "USD", Money{math.MaxInt64, "USD"}, nil},
+		{"19.999", "USD", Money{}, ErrInvalidAmount},
+		{"19.5", "JPY", Money{}, ErrInvalidAmount},
+		{"", "USD", Money{}, ErrInvalidAmount},
+		{".50", "USD", Money{}, ErrInvalidAmount},
+		{"1,50", "USD", Money{}, ErrInvalidAmount},
+		{"+1.50", "USD", Money{}, ErrInvalidAmount},
+		{"1e3", "USD", Money{}, ErrInvalidAmount},
+		{"--1", "USD", Money{}, ErrInvalidAmount},
+		{"92233720368547758.08", "USD", Money{}, ErrOverflow},
+		{"1.00", "XXX", Money{}, ErrUnknownCurrency},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.amount, tt.currency)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("Parse(%q, %q) error = %v, want %v", tt.amount, tt.currency, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Parse(%q, %q) = %+v, want %+v", tt.amount, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestAdd(t *testing.T) {
+	tests := []struct {
+		a, b    Money
+		want    Money
+		wantErr error
+	}{
+		{Money{1999, "USD"}, Money{1, "USD"}, Money{2000, "USD"}, nil},
+		{Money{100, "USD"}, Money{-250, "USD"}, Money{-150, "USD"}, nil},
+		{Money{math.MaxInt64 - 1, "USD"}, Money{1, "USD"}, Money{math.MaxInt64, "USD"}, nil},
+		{Money{math.MaxInt64, "USD"}, Money{1, "USD"}, Money{}, ErrOverflow},
+		{Money{math.MinInt64, "USD"}, Money{-1, "USD"}, Money{}, ErrOverflow},
+		{Money{100, "USD"}, Money{100, "EUR"}, Money{}, ErrCurrencyMismatch},
+	}
+	for _, tt := range tests {
+		got, err := tt.a.Add(tt.b)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%+v.Add(%+v) error = %v, want %v", tt.a, tt.b, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%+v.Add(%+v) = %+v, want %+v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestMultiply(t *testing.T) {
+	tests := []struct {
+		m        Money
+		quantity int64
+		want     Money
+		wantErr  error
+	}{
+		{Money{1999, "USD"}, 3, Money{5997, "USD"}, nil},
+		{Money{1999, "USD"}, 0, Money{0, "USD"}, nil},
+		{Money{0, "USD"}, math.MaxInt64, Money{0, "USD"}, nil},
+		{Money{-5, "USD"}, 2, Money{-10, "USD"}, nil},
+		{Money{math.MaxInt64, "USD"}, 1, Money{math.MaxInt64, "USD"}, nil},
+		{Money{math.MaxInt64 / 2, "USD"}, 3, Money{}, ErrOverflow},
+		{Money{1 << 32, "USD"}, 1 << 32, Money{}, ErrOverflow},
+		{Money{math.MinInt64, "USD"}, -1, Money{}, ErrOverflow},
+		{Money{-1, "USD"}, math.MinInt64, Money{}, ErrOverflow},
+	}
+	for _, tt := range tests {
+		got, err := tt.m.Multiply(tt.quantity)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%+v.Multiply(%d) error = %v, want %v", tt.m, tt.quantity, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%+v.Multiply(%d) = %+v, want %+v", tt.m, tt.quantity, got, tt.want)
+		}
+	}
+}
+
+func TestDecimal(t *testing.T) {
+	tests := []struct {
+		m    Money
+		want string
+	}{
+		{Money{1999, "USD"}, "19.99"},
+		{Money{5, "USD"}, "0.05"},
+		{Money{-350, "USD"}, "-3.50"},
+		{Money{1999, "JPY"}, "1999"},
+		{Money{1234, "KWD"}, "1.234"},
+		{Money{math.MinInt64, "USD"}, "-92233720368547758.08"},
+	}
+	for _, tt := range tests {
+		if got := tt.m.Decimal(); got != tt.want {
+			t.Errorf("%+v.Decimal() = %q, want %q", tt.m, got, tt.want)
+		}
+	}
+}
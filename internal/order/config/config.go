@@ -0,0 +1,70 @@
+package config
+
+import (
+	"fmt"
+
+	loader "bweng/internal/config"
+	"bweng/internal/health"
+	"bweng/internal/lifecycle"
+	"bweng/internal/logging"
+	"bweng/internal/migrate"
+	"bweng/internal/outbox"
+	"bweng/internal/policy"
+	"bweng/internal/tracing"
+)
+
+// Config is the order service configuration
+type Config struct {
+	HTTPPort    int               `yaml:"http_port" env:"ORDER_SERVICE_PORT" flag:"http-port" usage:"HTTP listen port" validate:"min=1,max=65535"`
+	GRPCPort    int               `yaml:"grpc_port" env:"ORDER_SERVICE_GRPC_PORT" flag:"grpc-port" usage:"gRPC listen port" validate:"min=1,max=65535,nefield=HTTPPort"`
+	Database    DatabaseConfig    `yaml:"database"`
+	UserService UserClientConfig  `yaml:"user_service"`
+	Identity    policy.Config     `yaml:"identity"`
+	Idempotency IdempotencyConfig `yaml:"idempotency"`
+	Outbox      outbox.Config     `yaml:"outbox"`
+	Shutdown    lifecycle.Config  `yaml:"shutdown"`
+	Health      health.Config     `yaml:"health"`
+	Tracing     tracing.Config    `yaml:"tracing"`
+	Log         logging.Config    `yaml:"log"`
+	Migrations  migrate.Config    `yaml:"migrations"`
+}
+
+// Default returns the configuration for local development
+func Default() *Config {
+	return &Config{
+		HTTPPort:    8081,
+		GRPCPort:    50052,
+		Database:    defaultDatabaseConfig(),
+		UserService: defaultUserClientConfig(),
+		Identity:    policy.DefaultConfig(),
+		Idempotency: defaultIdempotencyConfig(),
+		Outbox:      outbox.DefaultConfig(),
+		Shutdown:    lifecycle.DefaultConfig(),
+		Health:      health.DefaultConfig(),
+		Tracing:     tracing.DefaultConfig(),
+		Log:         logging.DefaultConfig(),
+		Migrations:  migrate.DefaultConfig(),
+	}
+}
+
+// Load loads the configuration from the defaults, an optional YAML file,
+// the environment and the command line arguments. The arguments left after
+// the flags select a subcommand such as migrate.
+func Load(args []string) (*Config, []string, error) {
+	cfg := Default()
+	rest, err := loader.Load("order-service", args, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cfg, rest, nil
+}
+
+// HTTPAddr returns the HTTP listen address
+func (c *Config) HTTPAddr() string {
+	return fmt.Sprintf(":%d", c.HTTPPort)
+}
+
+// GRPCAddr returns the gRPC listen address
+func (c *Config) GRPCAddr() string {
+	return fmt.Sprintf(":%d", c.GRPCPort)
+}
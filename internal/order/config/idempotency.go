@@ -0,0 +1,26 @@
+package config
+
+import (
+	"time"
+)
+
+// IdempotencyConfig holds idempotency key configuration
+type IdempotencyConfig struct {
+	// KeyTTL is how long a key and its stored response are kept
+	KeyTTL time.Duration `yaml:"key_ttl" env:"IDEMPOTENCY_KEY_TTL" flag:"idempotency-key-ttl" usage:"How long idempotency keys are kept" validate:"gt=0"`
+	// PendingTimeout is how long a key without a response is held for the
+	// request that reserved it; after that the request is assumed to have
+	// died before creating its order and a retry takes the key over
+	PendingTimeout time.Duration `yaml:"pending_timeout" env:"IDEMPOTENCY_PENDING_TIMEOUT" flag:"idempotency-pending-timeout" usage:"How long a key without a response blocks retries" validate:"gt=0"`
+	// CleanupInterval is how often expired keys are deleted
+	CleanupInterval time.Duration `yaml:"cleanup_interval" env:"IDEMPOTENCY_CLEANUP_INTERVAL" flag:"idempotency-cleanup-interval" usage:"How often expired idempotency keys are deleted" validate:"gt=0"`
+}
+
+// defaultIdempotencyConfig returns the idempotency key defaults
+func defaultIdempotencyConfig() IdempotencyConfig {
+	return IdempotencyConfig{
+		KeyTTL:          24 * time.Hour,
+		PendingTimeout:  time.Minute,
+		CleanupInterval: 10 * time.Minute,
+	}
+}
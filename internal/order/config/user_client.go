@@ -0,0 +1,43 @@
+package config
+
+import (
+	"net"
+	"strconv"
+	"time"
+)
+
+// UserClientConfig holds the address and call policy for the user service gRPC client
+type UserClientConfig struct {
+	Host string `yaml:"host" env:"USER_SERVICE_GRPC_HOST" flag:"user-service-host" usage:"User service gRPC host" validate:"required"`
+	Port int    `yaml:"port" env:"USER_SERVICE_GRPC_PORT" flag:"user-service-port" usage:"User service gRPC port" validate:"min=1,max=65535"`
+	// CallTimeout bounds each attempt of a call
+	CallTimeout time.Duration `yaml:"timeout" env:"USER_CLIENT_TIMEOUT" flag:"user-client-timeout" usage:"Deadline of each user service call attempt" validate:"gt=0"`
+	// MaxRetries is the number of retries after an Unavailable response
+	MaxRetries int `yaml:"max_retries" env:"USER_CLIENT_MAX_RETRIES" flag:"user-client-max-retries" usage:"Retries of an Unavailable user service call" validate:"min=0"`
+	// RetryBackoff is the initial backoff, doubled after every retry up to MaxRetryBackoff
+	RetryBackoff    time.Duration `yaml:"retry_backoff" env:"USER_CLIENT_RETRY_BACKOFF" flag:"user-client-retry-backoff" usage:"Initial retry backoff" validate:"gt=0"`
+	MaxRetryBackoff time.Duration `yaml:"max_retry_backoff" env:"USER_CLIENT_MAX_RETRY_BACKOFF" flag:"user-client-max-retry-backoff" usage:"Maximum retry backoff" validate:"gtefield=RetryBackoff"`
+	// BreakerFailureThreshold consecutive failures open the circuit breaker
+	BreakerFailureThreshold int `yaml:"breaker_threshold" env:"USER_CLIENT_BREAKER_THRESHOLD" flag:"user-client-breaker-threshold" usage:"Consecutive failures that open the circuit breaker" validate:"min=1"`
+	// BreakerOpenTimeout is how long the breaker fails fast before probing again
+	BreakerOpenTimeout time.Duration `yaml:"breaker_open_timeout" env:"USER_CLIENT_BREAKER_OPEN_TIMEOUT" flag:"user-client-breaker-open-timeout" usage:"How long the circuit breaker stays open" validate:"gt=0"`
+}
+
+// defaultUserClientConfig returns the user client defaults
+func defaultUserClientConfig() UserClientConfig {
+	return UserClientConfig{
+		Host:                    "localhost",
+		Port:                    50051,
+		CallTimeout:             2 * time.Second,
+		MaxRetries:              2,
+		RetryBackoff:            100 * time.Millisecond,
+		MaxRetryBackoff:         time.Second,
+		BreakerFailureThreshold: 5,
+		BreakerOpenTimeout:      30 * time.Second,
+	}
+}
+
+// Addr returns the user service gRPC address
+func (c *UserClientConfig) Addr() string {
+	return net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+}
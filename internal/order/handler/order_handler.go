@@ -1,11 +1,15 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"bweng/internal/money"
 	"bweng/internal/order/model"
+	"bweng/internal/pagination"
+	"bweng/internal/policy"
 	"bweng/internal/order/service"
 )
 
@@ -23,14 +27,21 @@ func NewOrderHandler(orderService *service.OrderService) *OrderHandler {
 
 // CreateOrder godoc
 // @Summary Create a new order
-// @Description Create a new order with the provided information
+// @Description Create a new order from a list of items priced in minor units of an ISO-4217 currency. All items must share the order currency. The single product_name/price/quantity shape and float item prices are still accepted.
 // @Tags orders
 // @Accept json
 // @Produce json
 // @Param order body model.CreateOrderRequest true "Order information"
+// @Param Idempotency-Key header string false "Key that makes retries of this request return the original response"
 // @Success 201 {object} model.OrderResponse
 // @Failure 400 {object} map[string]interface{}
 // @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 422 {object} map[string]interface{}
+// @Failure 503 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Security ApiKeyAuth
 // @Router /orders [post]
 func (h *OrderHandler) CreateOrder(c *gin.Context) {
 	var req model.CreateOrderRequest
@@ -39,12 +50,43 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 		return
 	}
 
+	if err := policy.CanCreateOrder(policy.FromGin(c), req.UserID); err != nil {
+		policy.AbortWithError(c, err)
+		return
+	}
+
+	req.IdempotencyKey = c.GetHeader("Idempotency-Key")
+
 	order, err := h.orderService.CreateOrder(c.Request.Context(), &req)
 	if err != nil {
+		if err == service.ErrInvalidIdempotencyKey {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err == service.ErrIdempotencyKeyReused {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		if err == service.ErrIdempotencyKeyInProgress {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err == service.ErrNoOrderItems || err == service.ErrMixedItems || err == service.ErrMixedPrices || err == service.ErrInvalidPrice || isMoneyError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		if err == service.ErrUserNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 			return
 		}
+		if errors.Is(err, service.ErrUserServiceUnavailable) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": service.ErrUserServiceUnavailable.Error()})
+			return
+		}
+		if err == policy.ErrUnauthenticated || err == policy.ErrForbidden {
+			policy.AbortWithError(c, err)
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -61,6 +103,9 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 // @Param id path int true "Order ID"
 // @Success 200 {object} model.OrderResponse
 // @Failure 404 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Security ApiKeyAuth
 // @Router /orders/{id} [get]
 func (h *OrderHandler) GetOrderByID(c *gin.Context) {
 	idStr := c.Param("id")
@@ -80,18 +125,28 @@ func (h *OrderHandler) GetOrderByID(c *gin.Context) {
 		return
 	}
 
+	if err := policy.CanReadOrder(policy.FromGin(c), order.UserID); err != nil {
+		policy.AbortWithError(c, err)
+		return
+	}
+
 	c.JSON(http.StatusOK, order)
 }
 
 // GetOrdersByUserID godoc
 // @Summary Get orders by user ID
-// @Description Retrieve all orders for a specific user
+// @Description Retrieve a page of orders for a specific user
 // @Tags orders
 // @Accept json
 // @Produce json
 // @Param user_id path int true "User ID"
-// @Success 200 {array} model.OrderResponse
+// @Param page_size query int false "Page size (default 20, max 100)"
+// @Param page_token query string false "Token of the page to retrieve"
+// @Success 200 {object} model.OrderListResponse
 // @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Security ApiKeyAuth
 // @Router /orders/user/{user_id} [get]
 func (h *OrderHandler) GetOrdersByUserID(c *gin.Context) {
 	userIDStr := c.Param("user_id")
@@ -101,9 +156,21 @@ func (h *OrderHandler) GetOrdersByUserID(c *gin.Context) {
 		return
 	}
 
-	orders, err := h.orderService.GetOrdersByUserID(uint(userID))
+	if err := policy.CanReadOrder(policy.FromGin(c), uint(userID)); err != nil {
+		policy.AbortWithError(c, err)
+		return
+	}
+
+	var req model.ListOrdersRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.UserID = uint(userID)
+
+	orders, err := h.orderService.ListOrders(&req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		h.listError(c, err)
 		return
 	}
 
@@ -111,26 +178,74 @@ func (h *OrderHandler) GetOrdersByUserID(c *gin.Context) {
 }
 
 // GetAllOrders godoc
-// @Summary Get all orders
-// @Description Retrieve all orders in the system
+// @Summary List orders
+// @Description Retrieve a page of orders, optionally filtered by status, user, creation time and total
 // @Tags orders
 // @Accept json
 // @Produce json
-// @Success 200 {array} model.OrderResponse
+// @Param page_size query int false "Page size (default 20, max 100)"
+// @Param page_token query string false "Token of the page to retrieve"
+// @Param status query string false "Order status" Enums(pending, confirmed, shipped, delivered, cancelled)
+// @Param user_id query int false "User ID"
+// @Param created_after query string false "Only orders created at or after this RFC 3339 time"
+// @Param created_before query string false "Only orders created before this RFC 3339 time"
+// @Param currency query string false "ISO-4217 currency code, required with min_price and max_price"
+// @Param min_price query string false "Minimum order total as a decimal amount"
+// @Param max_price query string false "Maximum order total as a decimal amount"
+// @Param sort_by query string false "Sort field" Enums(id, created_at, price)
+// @Param sort_order query string false "Sort order" Enums(asc, desc)
+// @Success 200 {object} model.OrderListResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Security ApiKeyAuth
 // @Router /orders [get]
 func (h *OrderHandler) GetAllOrders(c *gin.Context) {
-	orders, err := h.orderService.GetAllOrders()
+	var req model.ListOrdersRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Customers may list their own orders by filtering on their user ID
+	principal := policy.FromGin(c)
+	authErr := policy.CanListOrders(principal)
+	if req.UserID != 0 {
+		authErr = policy.CanReadOrder(principal, req.UserID)
+	}
+	if authErr != nil {
+		policy.AbortWithError(c, authErr)
+		return
+	}
+
+	orders, err := h.orderService.ListOrders(&req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		h.listError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, orders)
 }
 
+// listError writes the response for a failed list request
+func (h *OrderHandler) listError(c *gin.Context, err error) {
+	if errors.Is(err, pagination.ErrInvalidPageToken) || errors.Is(err, pagination.ErrInvalidSort) ||
+		err == service.ErrCurrencyRequired || isMoneyError(err) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+// isMoneyError reports whether err is caused by an invalid amount or currency
+func isMoneyError(err error) bool {
+	return errors.Is(err, money.ErrUnknownCurrency) || errors.Is(err, money.ErrInvalidAmount) ||
+		errors.Is(err, money.ErrCurrencyMismatch) || errors.Is(err, money.ErrOverflow)
+}
+
 // UpdateOrderStatus godoc
 // @Summary Update order status
-// @Description Update an existing order's status
+// @Description Move an order to a new status. Allowed transitions are pending -> confirmed -> shipped -> delivered; orders can be cancelled until they are shipped.
 // @Tags orders
 // @Accept json
 // @Produce json
@@ -139,6 +254,10 @@ func (h *OrderHandler) GetAllOrders(c *gin.Context) {
 // @Success 200 {object} model.OrderResponse
 // @Failure 400 {object} map[string]interface{}
 // @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Security ApiKeyAuth
 // @Router /orders/{id}/status [put]
 func (h *OrderHandler) UpdateOrderStatus(c *gin.Context) {
 	idStr := c.Param("id")
@@ -154,8 +273,32 @@ func (h *OrderHandler) UpdateOrderStatus(c *gin.Context) {
 		return
 	}
 
-	order, err := h.orderService.UpdateOrderStatus(uint(id), &req)
+	existing, err := h.orderService.GetOrderByID(uint(id))
+	if err != nil {
+		if err == service.ErrOrderNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	cancelling := req.Status == model.OrderStatusCancelled
+	if err := policy.CanUpdateOrderStatus(policy.FromGin(c), existing.UserID, cancelling); err != nil {
+		policy.AbortWithError(c, err)
+		return
+	}
+
+	order, err := h.orderService.UpdateOrderStatus(c.Request.Context(), uint(id), &req)
 	if err != nil {
+		if err == service.ErrInvalidStatus {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, service.ErrInvalidStatusTransition) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		if err == service.ErrOrderNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
 			return
@@ -167,6 +310,52 @@ func (h *OrderHandler) UpdateOrderStatus(c *gin.Context) {
 	c.JSON(http.StatusOK, order)
 }
 
+// GetOrderStatusHistory godoc
+// @Summary Get order status history
+// @Description Retrieve the status transitions of an order, oldest first
+// @Tags orders
+// @Accept json
+// @Produce json
+// @Param id path int true "Order ID"
+// @Success 200 {array} model.OrderStatusHistoryResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Security ApiKeyAuth
+// @Router /orders/{id}/history [get]
+func (h *OrderHandler) GetOrderStatusHistory(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	order, err := h.orderService.GetOrderByID(uint(id))
+	if err != nil {
+		if err == service.ErrOrderNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := policy.CanReadOrder(policy.FromGin(c), order.UserID); err != nil {
+		policy.AbortWithError(c, err)
+		return
+	}
+
+	history, err := h.orderService.GetOrderStatusHistory(uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
 // DeleteOrder godoc
 // @Summary Delete order
 // @Description Delete an order by their ID
@@ -176,6 +365,9 @@ func (h *OrderHandler) UpdateOrderStatus(c *gin.Context) {
 // @Param id path int true "Order ID"
 // @Success 204 "No Content"
 // @Failure 404 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Security ApiKeyAuth
 // @Router /orders/{id} [delete]
 func (h *OrderHandler) DeleteOrder(c *gin.Context) {
 	idStr := c.Param("id")
@@ -185,6 +377,11 @@ func (h *OrderHandler) DeleteOrder(c *gin.Context) {
 		return
 	}
 
+	if err := policy.CanDeleteOrder(policy.FromGin(c)); err != nil {
+		policy.AbortWithError(c, err)
+		return
+	}
+
 	err = h.orderService.DeleteOrder(uint(id))
 	if err != nil {
 		if err == service.ErrOrderNotFound {
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"bweng/internal/dbtest"
+	"bweng/internal/order/migrations"
+	"bweng/internal/order/model"
+	"bweng/internal/order/repository"
+	"bweng/internal/order/service"
+	"bweng/internal/policy"
+)
+
+func TestUpdateOrderStatus(t *testing.T) {
+	db := dbtest.Open(t, migrations.FS)
+	repo := repository.NewOrderRepository(db)
+	h := NewOrderHandler(service.NewOrderService(repo, repository.NewIdempotencyRepository(db), nil, nil))
+
+	now := time.Now()
+	order := &model.Order{
+		UserID:     3,
+		Items:      []model.OrderItem{{ProductName: "Widget", UnitPriceMinor: 1999, Quantity: 1, SubtotalMinor: 1999}},
+		Currency:   "USD",
+		TotalMinor: 1999,
+		Status:     model.OrderStatusPending,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if err := repo.Create(context.Background(), order, &model.OrderStatusHistory{Actor: "system", CreatedAt: now}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		admin := &policy.Principal{UserID: 1, Username: "admin", Roles: []policy.Role{policy.RoleAdmin}}
+		c.Request = c.Request.WithContext(policy.NewContext(c.Request.Context(), admin))
+	})
+	r.PUT("/orders/:id/status", h.UpdateOrderStatus)
+
+	path := "/orders/" + strconv.FormatUint(uint64(order.ID), 10) + "/status"
+	steps := []struct {
+		status model.OrderStatus
+		want   int
+	}{
+		{model.OrderStatusShipped, http.StatusConflict},
+		{model.OrderStatusConfirmed, http.StatusOK},
+		{model.OrderStatusConfirmed, http.StatusConflict},
+		{"refunded", http.StatusBadRequest},
+		{model.OrderStatusShipped, http.StatusOK},
+		{model.OrderStatusCancelled, http.StatusConflict},
+	}
+	for _, step := range steps {
+		body, _ := json.Marshal(model.UpdateOrderStatusRequest{Status: step.status})
+		req := httptest.NewRequest(http.MethodPut, path, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != step.want {
+			t.Fatalf("PUT status %s = %d %s, want %d", step.status, w.Code, w.Body, step.want)
+		}
+	}
+
+	var entries []model.OrderStatusHistory
+	if err := db.Where("order_id = ?", order.ID).Order("id").Find(&entries).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 || entries[1].ToStatus != model.OrderStatusConfirmed || entries[2].ToStatus != model.OrderStatusShipped {
+		t.Errorf("history = %+v, want the creation and the two accepted moves", entries)
+	}
+}
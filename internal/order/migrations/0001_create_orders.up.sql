@@ -0,0 +1,32 @@
+-- Databases created before versioned migrations already have the tables;
+-- the amount columns are added if they are missing and the legacy columns
+-- are converted by 0005_convert_legacy_order_amounts.
+CREATE TABLE IF NOT EXISTS orders (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    status text NOT NULL DEFAULT 'pending',
+    created_at timestamptz,
+    updated_at timestamptz
+);
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS currency char(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS total_minor bigint NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_orders_user_id ON orders (user_id);
+CREATE INDEX IF NOT EXISTS idx_orders_status ON orders (status);
+CREATE INDEX IF NOT EXISTS idx_orders_created_at ON orders (created_at);
+
+CREATE TABLE IF NOT EXISTS order_items (
+    id bigserial PRIMARY KEY,
+    order_id bigint NOT NULL,
+    product_name text NOT NULL,
+    quantity bigint NOT NULL,
+    created_at timestamptz,
+    updated_at timestamptz,
+    CONSTRAINT fk_orders_items FOREIGN KEY (order_id) REFERENCES orders (id) ON DELETE CASCADE
+);
+
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS unit_price_minor bigint NOT NULL DEFAULT 0;
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS subtotal_minor bigint NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_order_items_order_id ON order_items (order_id);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS order_status_history (
+    id bigserial PRIMARY KEY,
+    order_id bigint NOT NULL,
+    from_status text,
+    to_status text NOT NULL,
+    actor_id bigint,
+    actor text NOT NULL,
+    reason text,
+    created_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_status_history_order_id ON order_status_history (order_id);
@@ -0,0 +1,2 @@
+-- The legacy columns are not restored; the converted amounts stay valid.
+SELECT 1;
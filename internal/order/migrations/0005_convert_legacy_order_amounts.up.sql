@@ -0,0 +1,30 @@
+-- Orders created before line items existed kept a single product in
+-- product_name, price and quantity columns. They become one-item orders.
+-- Legacy prices were USD amounts and are converted to cents.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+               WHERE table_schema = current_schema() AND table_name = 'orders' AND column_name = 'product_name') THEN
+        INSERT INTO order_items (order_id, product_name, unit_price_minor, quantity, subtotal_minor, created_at, updated_at)
+            SELECT id, product_name, ROUND(price * 100), quantity, ROUND(price * 100) * quantity, created_at, updated_at FROM orders
+            WHERE NOT EXISTS (SELECT 1 FROM order_items WHERE order_items.order_id = orders.id);
+        UPDATE orders SET total_minor = ROUND(price * 100) * quantity, currency = 'USD';
+        ALTER TABLE orders DROP COLUMN product_name, DROP COLUMN price, DROP COLUMN quantity;
+    END IF;
+END $$;
+
+-- Floating point amounts from before currencies existed become USD cents.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+               WHERE table_schema = current_schema() AND table_name = 'orders' AND column_name = 'total') THEN
+        UPDATE orders SET total_minor = ROUND(total * 100), currency = 'USD';
+        ALTER TABLE orders DROP COLUMN total;
+    END IF;
+
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+               WHERE table_schema = current_schema() AND table_name = 'order_items' AND column_name = 'unit_price') THEN
+        UPDATE order_items SET unit_price_minor = ROUND(unit_price * 100), subtotal_minor = ROUND(subtotal * 100);
+        ALTER TABLE order_items DROP COLUMN unit_price, DROP COLUMN subtotal;
+    END IF;
+END $$;
@@ -0,0 +1,9 @@
+// Package migrations embeds the SQL migrations of the order service
+package migrations
+
+import "embed"
+
+// FS holds the versioned migration files
+//
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,11 @@
+package model
+
+// Outbox aggregate and event types of the order service.
+// OrderCreated carries the order with its items as payload and
+// OrderStatusChanged the recorded status transition.
+const (
+	AggregateOrder = "order"
+
+	EventOrderCreated       = "OrderCreated"
+	EventOrderStatusChanged = "OrderStatusChanged"
+)
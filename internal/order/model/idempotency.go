@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+// IdempotencyKey stores the outcome of an order creation made with an
+// Idempotency-Key. Keys are scoped to the calling user.
+type IdempotencyKey struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	UserID      uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_idempotency_keys_user_key"`
+	Key         string    `json:"key" gorm:"size:255;not null;uniqueIndex:idx_idempotency_keys_user_key"`
+	Fingerprint string    `json:"fingerprint" gorm:"size:64;not null"`
+	Response    []byte    `json:"response"`
+	ExpiresAt   time.Time `json:"expires_at" gorm:"not null;index"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Completed reports whether the original request finished and its response was stored
+func (k *IdempotencyKey) Completed() bool {
+	return k.Response != nil
+}
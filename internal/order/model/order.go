@@ -1,6 +1,10 @@
 package model
 
-import "time"
+import (
+	"time"
+
+	"bweng/internal/money"
+)
 
 // OrderStatus represents the status of an order
 type OrderStatus string
@@ -13,41 +17,157 @@ const (
 	OrderStatusCancelled OrderStatus = "cancelled"
 )
 
-// Order represents an order in the system
+// orderTransitions lists the statuses each status may move to.
+// Orders can only be cancelled before they are shipped.
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	OrderStatusPending:   {OrderStatusConfirmed, OrderStatusCancelled},
+	OrderStatusConfirmed: {OrderStatusShipped, OrderStatusCancelled},
+	OrderStatusShipped:   {OrderStatusDelivered},
+}
+
+// Valid reports whether the status is a known order status
+func (s OrderStatus) Valid() bool {
+	switch s {
+	case OrderStatusPending, OrderStatusConfirmed, OrderStatusShipped, OrderStatusDelivered, OrderStatusCancelled:
+		return true
+	}
+	return false
+}
+
+// CanTransitionTo reports whether an order may move from s to next
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, allowed := range orderTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// Order represents an order in the system.
+// Amounts are stored in minor units of the order currency.
 type Order struct {
-	ID          uint        `json:"id" gorm:"primaryKey"`
-	UserID      uint        `json:"user_id" gorm:"not null"`
-	ProductName string      `json:"product_name" gorm:"not null"`
-	Price       float64     `json:"price" gorm:"not null"`
-	Quantity    int         `json:"quantity" gorm:"not null"`
-	Status      OrderStatus `json:"status" gorm:"not null;default:'pending'"`
-	CreatedAt   time.Time   `json:"created_at"`
-	UpdatedAt   time.Time   `json:"updated_at"`
+	ID         uint        `json:"id" gorm:"primaryKey"`
+	UserID     uint        `json:"user_id" gorm:"not null;index"`
+	Items      []OrderItem `json:"items" gorm:"foreignKey:OrderID;constraint:OnDelete:CASCADE"`
+	Currency   string      `json:"currency" gorm:"type:char(3);not null;default:'USD'"`
+	TotalMinor int64       `json:"total_minor" gorm:"not null;default:0"`
+	Status     OrderStatus `json:"status" gorm:"not null;default:'pending';index"`
+	CreatedAt  time.Time   `json:"created_at" gorm:"index"`
+	UpdatedAt  time.Time   `json:"updated_at"`
+}
+
+// OrderItem represents a single product line of an order
+type OrderItem struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	OrderID        uint      `json:"order_id" gorm:"not null;index"`
+	ProductName    string    `json:"product_name" gorm:"not null"`
+	UnitPriceMinor int64     `json:"unit_price_minor" gorm:"not null;default:0"`
+	Quantity       int       `json:"quantity" gorm:"not null"`
+	SubtotalMinor  int64     `json:"subtotal_minor" gorm:"not null;default:0"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
 }
 
-// CreateOrderRequest represents the request to create a new order
+// CreateOrderRequest represents the request to create a new order.
+// Orders are normally created from Items; the single-product fields are
+// still accepted for older clients and are treated as a one-item order.
+// Currency defaults to the currency of the item prices, or USD.
 type CreateOrderRequest struct {
-	UserID      uint    `json:"user_id" binding:"required" example:"1"`
-	ProductName string  `json:"product_name" binding:"required" example:"iPhone 15"`
-	Price       float64 `json:"price" binding:"required,gt=0" example:"999.99"`
-	Quantity    int     `json:"quantity" binding:"required,gt=0" example:"1"`
+	UserID   uint                     `json:"user_id" binding:"required" example:"1"`
+	Currency string                   `json:"currency,omitempty" example:"USD"`
+	Items    []CreateOrderItemRequest `json:"items" binding:"omitempty,dive"`
+
+	ProductName string  `json:"product_name,omitempty" example:"iPhone 15"`
+	Price       float64 `json:"price,omitempty" binding:"omitempty,gt=0" example:"999.99"`
+	Quantity    int     `json:"quantity,omitempty" binding:"omitempty,gt=0" example:"1"`
+
+	// IdempotencyKey is taken from the Idempotency-Key header
+	IdempotencyKey string `json:"-" swaggerignore:"true"`
+}
+
+// CreateOrderItemRequest represents a product line of a new order.
+// UnitPrice is exact; Price is the deprecated floating point form.
+type CreateOrderItemRequest struct {
+	ProductName string       `json:"product_name" binding:"required" example:"iPhone 15"`
+	UnitPrice   *money.Money `json:"unit_price,omitempty"`
+	Price       float64      `json:"price,omitempty" binding:"omitempty,gt=0" example:"999.99"`
+	Quantity    int          `json:"quantity" binding:"required,gt=0" example:"1"`
 }
 
 // UpdateOrderStatusRequest represents the request to update order status
 type UpdateOrderStatusRequest struct {
 	Status OrderStatus `json:"status" binding:"required" example:"confirmed"`
+	Reason string      `json:"reason" example:"Payment received"`
+}
+
+// OrderStatusHistory records a single status transition of an order
+type OrderStatusHistory struct {
+	ID         uint        `json:"id" gorm:"primaryKey"`
+	OrderID    uint        `json:"order_id" gorm:"not null;index"`
+	FromStatus OrderStatus `json:"from_status"`
+	ToStatus   OrderStatus `json:"to_status" gorm:"not null"`
+	ActorID    uint        `json:"actor_id"`
+	Actor      string      `json:"actor" gorm:"not null"`
+	Reason     string      `json:"reason"`
+	CreatedAt  time.Time   `json:"created_at"`
+}
+
+// TableName overrides the pluralized table name
+func (OrderStatusHistory) TableName() string {
+	return "order_status_history"
+}
+
+// OrderStatusHistoryResponse represents a status transition in responses
+type OrderStatusHistoryResponse struct {
+	FromStatus OrderStatus `json:"from_status,omitempty" example:"pending"`
+	ToStatus   OrderStatus `json:"to_status" example:"confirmed"`
+	ActorID    uint        `json:"actor_id,omitempty" example:"1"`
+	Actor      string      `json:"actor" example:"johndoe"`
+	Reason     string      `json:"reason,omitempty" example:"Payment received"`
+	CreatedAt  time.Time   `json:"created_at"`
+}
+
+// ListOrdersRequest represents pagination, filter and sort options for listing orders
+type ListOrdersRequest struct {
+	PageSize      int         `form:"page_size" json:"page_size" example:"20"`
+	PageToken     string      `form:"page_token" json:"page_token"`
+	Status        OrderStatus `form:"status" json:"status" binding:"omitempty,oneof=pending confirmed shipped delivered cancelled" example:"pending"`
+	UserID        uint        `form:"user_id" json:"user_id" example:"1"`
+	CreatedAfter  time.Time   `form:"created_after" json:"created_after" time_format:"2006-01-02T15:04:05Z07:00"`
+	CreatedBefore time.Time   `form:"created_before" json:"created_before" time_format:"2006-01-02T15:04:05Z07:00"`
+	Currency      string      `form:"currency" json:"currency" example:"USD"`
+	MinPrice      string      `form:"min_price" json:"min_price" example:"10.00"`
+	MaxPrice      string      `form:"max_price" json:"max_price" example:"1000.00"`
+	SortBy        string      `form:"sort_by" json:"sort_by" binding:"omitempty,oneof=id created_at price" example:"created_at"`
+	SortOrder     string      `form:"sort_order" json:"sort_order" binding:"omitempty,oneof=asc desc" example:"desc"`
+}
+
+// OrderListResponse represents a page of orders
+type OrderListResponse struct {
+	Orders        []*OrderResponse `json:"orders"`
+	NextPageToken string           `json:"next_page_token,omitempty"`
 }
 
 // OrderResponse represents the response for order operations
 type OrderResponse struct {
+	ID        uint                 `json:"id" example:"1"`
+	UserID    uint                 `json:"user_id" example:"1"`
+	Items     []*OrderItemResponse `json:"items"`
+	Currency  string               `json:"currency" example:"USD"`
+	Total     money.Money          `json:"total"`
+	Status    OrderStatus          `json:"status" example:"pending"`
+	CreatedAt time.Time            `json:"created_at"`
+	UpdatedAt time.Time            `json:"updated_at"`
+}
+
+// OrderItemResponse represents a product line in order responses
+type OrderItemResponse struct {
 	ID          uint        `json:"id" example:"1"`
-	UserID      uint        `json:"user_id" example:"1"`
 	ProductName string      `json:"product_name" example:"iPhone 15"`
-	Price       float64     `json:"price" example:"999.99"`
-	Quantity    int         `json:"quantity" example:"1"`
-	Status      OrderStatus `json:"status" example:"pending"`
-	CreatedAt   time.Time   `json:"created_at"`
-	UpdatedAt   time.Time   `json:"updated_at"`
+	UnitPrice   money.Money `json:"unit_price"`
+	Quantity    int         `json:"quantity" example:"2"`
+	Subtotal    money.Money `json:"subtotal"`
 }
 
 // OrderWithUser represents an order with user information
@@ -0,0 +1,49 @@
+package model
+
+import "testing"
+
+func TestCanTransitionTo(t *testing.T) {
+	statuses := []OrderStatus{
+		OrderStatusPending,
+		OrderStatusConfirmed,
+		OrderStatusShipped,
+		OrderStatusDelivered,
+		OrderStatusCancelled,
+	}
+	allowed := map[[2]OrderStatus]bool{
+		{OrderStatusPending, OrderStatusConfirmed}:   true,
+		{OrderStatusPending, OrderStatusCancelled}:   true,
+		{OrderStatusConfirmed, OrderStatusShipped}:   true,
+		{OrderStatusConfirmed, OrderStatusCancelled}: true,
+		{OrderStatusShipped, OrderStatusDelivered}:   true,
+	}
+
+	for _, from := range statuses {
+		for _, to := range statuses {
+			want := allowed[[2]OrderStatus{from, to}]
+			if got := from.CanTransitionTo(to); got != want {
+				t.Errorf("%s.CanTransitionTo(%s) = %v, want %v", from, to, got, want)
+			}
+		}
+	}
+
+	// Unknown statuses neither leave nor enter the state machine
+	for _, status := range statuses {
+		if OrderStatus("refunded").CanTransitionTo(status) || status.CanTransitionTo("refunded") || status.CanTransitionTo("") {
+			t.Errorf("transition between %s and an unknown status is allowed", status)
+		}
+	}
+}
+
+func TestOrderStatusValid(t *testing.T) {
+	for _, status := range []OrderStatus{OrderStatusPending, OrderStatusConfirmed, OrderStatusShipped, OrderStatusDelivered, OrderStatusCancelled} {
+		if !status.Valid() {
+			t.Errorf("%s.Valid() = false, want true", status)
+		}
+	}
+	for _, status := range []OrderStatus{"", "refunded", "Pending", " pending"} {
+		if status.Valid() {
+			t.Errorf("%q.Valid() = true, want false", status)
+		}
+	}
+}
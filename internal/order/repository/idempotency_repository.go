@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"bweng/internal/order/model"
+)
+
+// ErrIdempotencyKeyLost is returned when the reservation of an idempotency
+// key was taken over by another request before the order was created
+var ErrIdempotencyKeyLost = errors.New("idempotency key reservation was taken over")
+
+// IdempotencyCompletion stores the response of an order creation on the
+// idempotency key it was reserved under
+type IdempotencyCompletion struct {
+	KeyID uint
+	// Response builds the stored response of the created order
+	Response func(order *model.Order) ([]byte, error)
+}
+
+// IdempotencyRepository handles idempotency key data operations
+type IdempotencyRepository struct {
+	db *gorm.DB
+}
+
+// NewIdempotencyRepository creates a new idempotency key repository
+func NewIdempotencyRepository(db *gorm.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{
+		db: db,
+	}
+}
+
+// Reserve stores a new key unless the user already holds a live key with
+// the same value. It returns the stored key and whether it was created by
+// this call; when it was not, the existing key is returned. A key is replaced
+// once it has expired, or when it has no response and was reserved before
+// staleBefore: the request holding it failed or died before creating its
+// order, since the response is stored together with the order.
+func (r *IdempotencyRepository) Reserve(ctx context.Context, key *model.IdempotencyKey, staleBefore time.Time) (*model.IdempotencyKey, bool, error) {
+	var existing model.IdempotencyKey
+	created := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(key)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 1 {
+			created = true
+			return nil
+		}
+
+		byUserKey := map[string]interface{}{"user_id": key.UserID, "key": key.Key}
+		if err := tx.Where(byUserKey).First(&existing).Error; err != nil {
+			return err
+		}
+		expired := !existing.ExpiresAt.After(key.CreatedAt)
+		abandoned := !existing.Completed() && existing.CreatedAt.Before(staleBefore)
+		if !expired && !abandoned {
+			return nil
+		}
+
+		// Only one of several concurrent retries deletes the old key; the
+		// others see the key it reserved
+		deleted := tx.Delete(&model.IdempotencyKey{}, existing.ID)
+		if deleted.Error != nil {
+			return deleted.Error
+		}
+		if deleted.RowsAffected == 1 {
+			result = tx.Clauses(clause.OnConflict{DoNothing: true}).Create(key)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 1 {
+				created = true
+				return nil
+			}
+		}
+		return tx.Where(byUserKey).First(&existing).Error
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	if created {
+		return key, true, nil
+	}
+	return &existing, false, nil
+}
+
+// complete stores the response of the request that reserved a key within
+// the transaction creating its order. It fails with ErrIdempotencyKeyLost if
+// the reservation was taken over, so the order is not created twice.
+func complete(tx *gorm.DB, id uint, response []byte) error {
+	result := tx.Model(&model.IdempotencyKey{}).Where("id = ? AND response IS NULL", id).Update("response", response)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrIdempotencyKeyLost
+	}
+	return nil
+}
+
+// Release deletes a reserved key so the request can be retried
+func (r *IdempotencyRepository) Release(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Where("id = ? AND response IS NULL", id).Delete(&model.IdempotencyKey{}).Error
+}
+
+// DeleteExpired deletes the keys that expired before now and returns how
+// many were deleted
+func (r *IdempotencyRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Where("expires_at <= ?", now).Delete(&model.IdempotencyKey{})
+	return result.RowsAffected, result.Error
+}
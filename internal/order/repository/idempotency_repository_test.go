@@ -0,0 +1,209 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"bweng/internal/dbtest"
+	"bweng/internal/order/migrations"
+	"bweng/internal/order/model"
+)
+
+// newKey returns an unsaved key of user 3 created at now
+func newKey(key, fingerprint string, now time.Time) *model.IdempotencyKey {
+	return &model.IdempotencyKey{
+		UserID:      3,
+		Key:         key,
+		Fingerprint: fingerprint,
+		ExpiresAt:   now.Add(time.Hour),
+		CreatedAt:   now,
+	}
+}
+
+// count returns the number of rows of model
+func count(t *testing.T, db *gorm.DB, model interface{}) int64 {
+	t.Helper()
+	var n int64
+	if err := db.Model(model).Count(&n).Error; err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestReserve(t *testing.T) {
+	db := dbtest.Open(t, migrations.FS)
+	repo := NewIdempotencyRepository(db)
+	ctx := context.Background()
+	now := time.Now()
+	staleBefore := now.Add(-time.Minute)
+
+	first, created, err := repo.Reserve(ctx, newKey("k", "a", now), staleBefore)
+	if err != nil || !created {
+		t.Fatalf("Reserve = %v, %v, want a new key", created, err)
+	}
+
+	// A retry sees the reservation, whatever its body
+	existing, created, err := repo.Reserve(ctx, newKey("k", "b", now.Add(time.Second)), staleBefore)
+	if err != nil || created || existing.ID != first.ID || existing.Fingerprint != "a" || existing.Completed() {
+		t.Fatalf("Reserve again = %+v, %v, %v, want the pending key", existing, created, err)
+	}
+
+	// Keys are scoped to the user
+	other := newKey("k", "a", now)
+	other.UserID = 4
+	if _, created, err := repo.Reserve(ctx, other, staleBefore); err != nil || !created {
+		t.Fatalf("Reserve for another user = %v, %v, want a new key", created, err)
+	}
+
+	// Once the request holding it dies, a retry takes the key over
+	takeover, created, err := repo.Reserve(ctx, newKey("k", "c", now.Add(2*time.Minute)), now.Add(time.Minute))
+	if err != nil || !created || takeover.ID == first.ID {
+		t.Fatalf("Reserve after PendingTimeout = %+v, %v, %v, want a new key", takeover, created, err)
+	}
+	if n := count(t, db, &model.IdempotencyKey{}); n != 2 {
+		t.Errorf("%d keys stored, want the takeover and the other user's", n)
+	}
+}
+
+func TestReserveCompleted(t *testing.T) {
+	db := dbtest.Open(t, migrations.FS)
+	repo := NewIdempotencyRepository(db)
+	ctx := context.Background()
+	now := time.Now()
+
+	key, _, err := repo.Reserve(ctx, newKey("k", "a", now), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Transaction(func(tx *gorm.DB) error { return complete(tx, key.ID, []byte(`{"id":1}`)) }); err != nil {
+		t.Fatal(err)
+	}
+
+	// A completed key is kept however long ago it was reserved
+	existing, created, err := repo.Reserve(ctx, newKey("k", "a", now.Add(59*time.Minute)), now.Add(59*time.Minute))
+	if err != nil || created || existing.ID != key.ID || string(existing.Response) != `{"id":1}` {
+		t.Fatalf("Reserve = %+v, %v, %v, want the completed key", existing, created, err)
+	}
+
+	// until it expires
+	replaced, created, err := repo.Reserve(ctx, newKey("k", "b", now.Add(time.Hour)), now)
+	if err != nil || !created || replaced.ID == key.ID || replaced.Completed() {
+		t.Fatalf("Reserve after expiry = %+v, %v, %v, want a new key", replaced, created, err)
+	}
+
+	// and cannot be completed twice
+	err = db.Transaction(func(tx *gorm.DB) error { return complete(tx, replaced.ID, []byte(`{"id":2}`)) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Transaction(func(tx *gorm.DB) error { return complete(tx, replaced.ID, []byte(`{"id":3}`)) })
+	if !errors.Is(err, ErrIdempotencyKeyLost) {
+		t.Errorf("complete twice = %v, want ErrIdempotencyKeyLost", err)
+	}
+}
+
+func TestCreateIdempotencyKeyLost(t *testing.T) {
+	db := dbtest.Open(t, migrations.FS)
+	repo := NewIdempotencyRepository(db)
+	orders := NewOrderRepository(db)
+	ctx := context.Background()
+	now := time.Now()
+
+	original, _, err := repo.Reserve(ctx, newKey("k", "a", now), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The original request stalls past PendingTimeout and a retry takes over
+	takeover, created, err := repo.Reserve(ctx, newKey("k", "a", now.Add(2*time.Minute)), now.Add(time.Minute))
+	if err != nil || !created {
+		t.Fatalf("takeover = %v, %v, want a new key", created, err)
+	}
+
+	create := func(keyID uint) (*model.Order, error) {
+		order := &model.Order{
+			UserID:     3,
+			Items:      []model.OrderItem{{ProductName: "Widget", UnitPriceMinor: 1999, Quantity: 1, SubtotalMinor: 1999}},
+			Currency:   "USD",
+			TotalMinor: 1999,
+			Status:     model.OrderStatusPending,
+		}
+		entry := &model.OrderStatusHistory{Actor: "system", CreatedAt: now}
+		return order, orders.Create(ctx, order, entry, &IdempotencyCompletion{
+			KeyID:    keyID,
+			Response: func(order *model.Order) ([]byte, error) { return []byte(`{"id":1}`), nil },
+		})
+	}
+
+	// The stalled request must not create a second order
+	if _, err := create(original.ID); !errors.Is(err, ErrIdempotencyKeyLost) {
+		t.Fatalf("Create with the lost key = %v, want ErrIdempotencyKeyLost", err)
+	}
+	for _, m := range []interface{}{&model.Order{}, &model.OrderItem{}, &model.OrderStatusHistory{}} {
+		if n := count(t, db, m); n != 0 {
+			t.Errorf("%d %T rows left by the rolled back order, want 0", n, m)
+		}
+	}
+	var events int64
+	if err := db.Table("outbox_events").Count(&events).Error; err != nil {
+		t.Fatal(err)
+	}
+	if events != 0 {
+		t.Errorf("%d outbox events recorded for the rolled back order, want 0", events)
+	}
+
+	// while the request that took the key over completes it
+	if _, err := create(takeover.ID); err != nil {
+		t.Fatal(err)
+	}
+	var stored model.IdempotencyKey
+	if err := db.First(&stored, takeover.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if string(stored.Response) != `{"id":1}` {
+		t.Errorf("stored response = %q, want the order response", stored.Response)
+	}
+}
+
+func TestReleaseAndDeleteExpired(t *testing.T) {
+	db := dbtest.Open(t, migrations.FS)
+	repo := NewIdempotencyRepository(db)
+	ctx := context.Background()
+	now := time.Now()
+
+	pending, _, err := repo.Reserve(ctx, newKey("pending", "a", now), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	completed, _, err := repo.Reserve(ctx, newKey("completed", "a", now), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Transaction(func(tx *gorm.DB) error { return complete(tx, completed.ID, []byte(`{}`)) }); err != nil {
+		t.Fatal(err)
+	}
+
+	// Release only deletes keys without a response
+	for _, id := range []uint{pending.ID, completed.ID} {
+		if err := repo.Release(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var remaining []model.IdempotencyKey
+	if err := db.Find(&remaining).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(remaining) != 1 || remaining[0].ID != completed.ID {
+		t.Fatalf("keys after Release = %+v, want the completed key", remaining)
+	}
+
+	if deleted, err := repo.DeleteExpired(ctx, now.Add(time.Hour-time.Second)); err != nil || deleted != 0 {
+		t.Errorf("DeleteExpired before expiry = %d, %v, want 0", deleted, err)
+	}
+	if deleted, err := repo.DeleteExpired(ctx, now.Add(time.Hour)); err != nil || deleted != 1 {
+		t.Errorf("DeleteExpired at expiry = %d, %v, want 1", deleted, err)
+	}
+}
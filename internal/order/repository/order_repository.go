@@ -1,10 +1,16 @@
 package repository
 
 import (
+	"context"
 	"errors"
+	"strconv"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"bweng/internal/money"
 	"bweng/internal/order/model"
+	"bweng/internal/outbox"
+	"bweng/internal/pagination"
 )
 
 var (
@@ -24,15 +30,40 @@ func NewOrderRepository(db *gorm.DB) *OrderRepository {
 	}
 }
 
-// Create creates a new order
-func (r *OrderRepository) Create(order *model.Order) error {
-	return r.db.Create(order).Error
+// Create creates a new order together with its items and initial history
+// entry, and records an OrderCreated event. When completion is not nil, the
+// response is stored on its idempotency key in the same transaction, so a key
+// is never left without a response once its order exists.
+func (r *OrderRepository) Create(ctx context.Context, order *model.Order, entry *model.OrderStatusHistory, completion *IdempotencyCompletion) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(order).Error; err != nil {
+			return err
+		}
+
+		entry.OrderID = order.ID
+		entry.ToStatus = order.Status
+		if err := tx.Create(entry).Error; err != nil {
+			return err
+		}
+		if err := outbox.Record(tx, model.AggregateOrder, order.ID, model.EventOrderCreated, order); err != nil {
+			return err
+		}
+
+		if completion == nil {
+			return nil
+		}
+		response, err := completion.Response(order)
+		if err != nil {
+			return err
+		}
+		return complete(tx, completion.KeyID, response)
+	})
 }
 
 // GetByID retrieves an order by ID
 func (r *OrderRepository) GetByID(id uint) (*model.Order, error) {
 	var order model.Order
-	if err := r.db.First(&order, id).Error; err != nil {
+	if err := r.db.Preload("Items").First(&order, id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, ErrOrderNotFound
 		}
@@ -41,55 +72,174 @@ func (r *OrderRepository) GetByID(id uint) (*model.Order, error) {
 	return &order, nil
 }
 
-// GetByUserID retrieves all orders for a specific user
-func (r *OrderRepository) GetByUserID(userID uint) ([]*model.Order, error) {
+// orderSortColumns maps sortable API fields to columns
+var orderSortColumns = map[string]string{
+	"id":         "id",
+	"created_at": "created_at",
+	"price":      "total_minor",
+}
+
+// List retrieves a page of orders matching the request filters.
+// It returns the page together with the token of the next page, if any.
+func (r *OrderRepository) List(req *model.ListOrdersRequest) ([]*model.Order, string, error) {
+	sort, err := pagination.NewSort(req.SortBy, req.SortOrder, orderSortColumns, "id")
+	if err != nil {
+		return nil, "", err
+	}
+	cursor, err := pagination.DecodeCursor(req.PageToken, sort)
+	if err != nil {
+		return nil, "", err
+	}
+
+	query := r.db.Model(&model.Order{})
+	if req.Status != "" {
+		query = query.Where("status = ?", req.Status)
+	}
+	if req.UserID != 0 {
+		query = query.Where("user_id = ?", req.UserID)
+	}
+	if !req.CreatedAfter.IsZero() {
+		query = query.Where("created_at >= ?", req.CreatedAfter)
+	}
+	if !req.CreatedBefore.IsZero() {
+		query = query.Where("created_at < ?", req.CreatedBefore)
+	}
+	if req.Currency != "" {
+		currency, err := money.NormalizeCurrency(req.Currency)
+		if err != nil {
+			return nil, "", err
+		}
+		query = query.Where("currency = ?", currency)
+	}
+	if req.MinPrice != "" {
+		minPrice, err := money.Parse(req.MinPrice, req.Currency)
+		if err != nil {
+			return nil, "", err
+		}
+		query = query.Where("total_minor >= ?", minPrice.MinorUnits)
+	}
+	if req.MaxPrice != "" {
+		maxPrice, err := money.Parse(req.MaxPrice, req.Currency)
+		if err != nil {
+			return nil, "", err
+		}
+		query = query.Where("total_minor <= ?", maxPrice.MinorUnits)
+	}
+
+	query, err = pagination.Apply(query, sort, cursor, orderCursorValue(sort.Field))
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Fetch one extra row to find out whether another page exists
+	pageSize := pagination.PageSize(req.PageSize)
 	var orders []*model.Order
-	if err := r.db.Where("user_id = ?", userID).Find(&orders).Error; err != nil {
-		return nil, err
+	if err := query.Preload("Items").Limit(pageSize + 1).Find(&orders).Error; err != nil {
+		return nil, "", err
 	}
-	return orders, nil
+
+	if len(orders) <= pageSize {
+		return orders, "", nil
+	}
+
+	orders = orders[:pageSize]
+	last := orders[len(orders)-1]
+	return orders, pagination.EncodeCursor(sort, orderSortValue(last, sort.Field), last.ID), nil
 }
 
-// GetAll retrieves all orders
-func (r *OrderRepository) GetAll() ([]*model.Order, error) {
-	var orders []*model.Order
-	if err := r.db.Find(&orders).Error; err != nil {
-		return nil, err
+// orderSortValue returns the cursor value of an order for a sort field
+func orderSortValue(order *model.Order, field string) string {
+	switch field {
+	case "created_at":
+		return pagination.FormatTime(order.CreatedAt)
+	case "price":
+		return strconv.FormatInt(order.TotalMinor, 10)
 	}
-	return orders, nil
+	return strconv.FormatUint(uint64(order.ID), 10)
 }
 
-// UpdateStatus updates an order's status
-func (r *OrderRepository) UpdateStatus(id uint, status model.OrderStatus) (*model.Order, error) {
-	var order model.Order
-	if err := r.db.First(&order, id).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, ErrOrderNotFound
+// orderCursorValue returns the parser of cursor values for a sort field
+func orderCursorValue(field string) func(string) (interface{}, error) {
+	switch field {
+	case "created_at":
+		return pagination.ParseTime
+	case "price":
+		return func(value string) (interface{}, error) {
+			return strconv.ParseInt(value, 10, 64)
 		}
-		return nil, err
 	}
+	return pagination.ParseString
+}
+
+// UpdateStatus changes an order's status and records the transition in the
+// history and as an OrderStatusChanged event.
+// The order row is locked while check validates the move from its current status.
+func (r *OrderRepository) UpdateStatus(ctx context.Context, id uint, status model.OrderStatus, entry *model.OrderStatusHistory, check func(current model.OrderStatus) error) (*model.Order, error) {
+	var order model.Order
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrOrderNotFound
+			}
+			return err
+		}
+
+		if err := check(order.Status); err != nil {
+			return err
+		}
+
+		entry.OrderID = order.ID
+		entry.FromStatus = order.Status
+		entry.ToStatus = status
+
+		order.Status = status
+		order.UpdatedAt = entry.CreatedAt
+		if err := tx.Omit("Items").Save(&order).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(entry).Error; err != nil {
+			return err
+		}
+		if err := outbox.Record(tx, model.AggregateOrder, order.ID, model.EventOrderStatusChanged, entry); err != nil {
+			return err
+		}
 
-	order.Status = status
-	if err := r.db.Save(&order).Error; err != nil {
+		return tx.Where("order_id = ?", order.ID).Find(&order.Items).Error
+	})
+	if err != nil {
 		return nil, err
 	}
 
 	return &order, nil
 }
 
-// Delete deletes an order by ID
-func (r *OrderRepository) Delete(id uint) error {
-	result := r.db.Delete(&model.Order{}, id)
-	if result.Error != nil {
-		return result.Error
-	}
-	if result.RowsAffected == 0 {
-		return ErrOrderNotFound
+// GetStatusHistory retrieves the status transitions of an order, oldest first
+func (r *OrderRepository) GetStatusHistory(orderID uint) ([]*model.OrderStatusHistory, error) {
+	var history []*model.OrderStatusHistory
+	if err := r.db.Where("order_id = ?", orderID).Order("created_at ASC, id ASC").Find(&history).Error; err != nil {
+		return nil, err
 	}
-	return nil
+	return history, nil
 }
 
-// Migrate runs database migrations
-func (r *OrderRepository) Migrate() error {
-	return r.db.AutoMigrate(&model.Order{})
-} 
\ No newline at end of file
+// Delete deletes an order and its items by ID
+func (r *OrderRepository) Delete(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("order_id = ?", id).Delete(&model.OrderItem{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("order_id = ?", id).Delete(&model.OrderStatusHistory{}).Error; err != nil {
+			return err
+		}
+
+		result := tx.Delete(&model.Order{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrOrderNotFound
+		}
+		return nil
+	})
+}
@@ -0,0 +1,21 @@
+package service
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	ordersCreated = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "orders_created_total",
+		Help: "Orders created by currency.",
+	}, []string{"currency"})
+	orderStatusChanges = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "order_status_changes_total",
+		Help: "Order status transitions by previous and new status.",
+	}, []string{"from", "to"})
+	idempotentReplays = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "order_idempotent_replays_total",
+		Help: "Order creations answered from a stored idempotency key response.",
+	})
+)
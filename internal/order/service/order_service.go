@@ -2,55 +2,202 @@ package service
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
 	"time"
 
+	"bweng/internal/money"
+	"bweng/internal/order/config"
 	"bweng/internal/order/model"
 	"bweng/internal/order/repository"
+	"bweng/internal/policy"
 )
 
 var (
-	ErrOrderNotFound = errors.New("order not found")
-	ErrInvalidUserID = errors.New("invalid user ID")
-	ErrUserNotFound  = errors.New("user not found")
+	ErrOrderNotFound           = repository.ErrOrderNotFound
+	ErrInvalidUserID           = errors.New("invalid user ID")
+	ErrUserNotFound            = errors.New("user not found")
+	ErrNoOrderItems            = errors.New("order must contain at least one item")
+	ErrMixedItems              = errors.New("use either items or the single product fields, not both")
+	ErrMixedPrices             = errors.New("use either unit_price or the deprecated price of an item, not both")
+	ErrInvalidStatus           = errors.New("invalid order status")
+	ErrInvalidStatusTransition = errors.New("invalid order status transition")
+	ErrInvalidPrice            = errors.New("item prices must be positive")
+	ErrCurrencyRequired        = errors.New("currency is required when filtering by price")
+
+	ErrInvalidIdempotencyKey    = errors.New("idempotency key must be between 1 and 255 characters")
+	ErrIdempotencyKeyReused     = errors.New("idempotency key was already used for a different request")
+	ErrIdempotencyKeyInProgress = errors.New("a request with this idempotency key is still being processed")
 )
 
+// maxIdempotencyKeyLength is the longest accepted idempotency key
+const maxIdempotencyKeyLength = 255
+
 // OrderService handles order business logic
 type OrderService struct {
-	repo       *repository.OrderRepository
-	userClient *UserClient
+	repo            *repository.OrderRepository
+	idempotencyRepo *repository.IdempotencyRepository
+	userClient      *UserClient
+	idempotency     *config.IdempotencyConfig
 }
 
 // NewOrderService creates a new order service
-func NewOrderService(repo *repository.OrderRepository, userClient *UserClient) *OrderService {
+func NewOrderService(repo *repository.OrderRepository, idempotencyRepo *repository.IdempotencyRepository, userClient *UserClient, idempotency *config.IdempotencyConfig) *OrderService {
 	return &OrderService{
-		repo:       repo,
-		userClient: userClient,
+		repo:            repo,
+		idempotencyRepo: idempotencyRepo,
+		userClient:      userClient,
+		idempotency:     idempotency,
 	}
 }
 
-// CreateOrder creates a new order
+// CreateOrder creates a new order. When the request carries an idempotency
+// key, retries with the same key and body return the original response
+// instead of creating another order.
 func (s *OrderService) CreateOrder(ctx context.Context, req *model.CreateOrderRequest) (*model.OrderResponse, error) {
+	if req.IdempotencyKey == "" {
+		return s.createOrder(ctx, req, nil)
+	}
+	if len(req.IdempotencyKey) > maxIdempotencyKeyLength {
+		return nil, ErrInvalidIdempotencyKey
+	}
+
+	fingerprint, err := requestFingerprint(req)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	key := &model.IdempotencyKey{
+		Key:         req.IdempotencyKey,
+		Fingerprint: fingerprint,
+		ExpiresAt:   now.Add(s.idempotency.KeyTTL),
+		CreatedAt:   now,
+	}
+	if principal := policy.FromContext(ctx); principal != nil {
+		key.UserID = principal.UserID
+	}
+
+	stored, reserved, err := s.idempotencyRepo.Reserve(ctx, key, now.Add(-s.idempotency.PendingTimeout))
+	if err != nil {
+		return nil, err
+	}
+	if !reserved {
+		order, err := replayOrderResponse(stored, fingerprint)
+		if err == nil {
+			idempotentReplays.Inc()
+		}
+		return order, err
+	}
+
+	order, err := s.createOrder(ctx, req, &repository.IdempotencyCompletion{
+		KeyID: stored.ID,
+		Response: func(order *model.Order) ([]byte, error) {
+			return json.Marshal(s.toOrderResponse(order))
+		},
+	})
+	if errors.Is(err, repository.ErrIdempotencyKeyLost) {
+		// A retry took the key over after PendingTimeout and creates the order
+		return nil, ErrIdempotencyKeyInProgress
+	}
+	if err != nil {
+		// Nothing was created, so let the client retry with the same key,
+		// even if it has gone away
+		if releaseErr := s.idempotencyRepo.Release(context.WithoutCancel(ctx), stored.ID); releaseErr != nil {
+			slog.ErrorContext(ctx, "Failed to release idempotency key", "key_id", stored.ID, "error", releaseErr)
+		}
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// PurgeIdempotencyKeys deletes expired idempotency keys every
+// CleanupInterval until ctx is cancelled
+func (s *OrderService) PurgeIdempotencyKeys(ctx context.Context) {
+	ticker := time.NewTicker(s.idempotency.CleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		deleted, err := s.idempotencyRepo.DeleteExpired(ctx, time.Now())
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to delete expired idempotency keys", "error", err)
+			continue
+		}
+		if deleted > 0 {
+			slog.DebugContext(ctx, "Deleted expired idempotency keys", "count", deleted)
+		}
+	}
+}
+
+// createOrder validates and stores a new order, storing its response on the
+// idempotency key of completion if there is one
+func (s *OrderService) createOrder(ctx context.Context, req *model.CreateOrderRequest, completion *repository.IdempotencyCompletion) (*model.OrderResponse, error) {
+	// Validate the line items before calling the user service
+	itemRequests, err := lineItems(req)
+	if err != nil {
+		return nil, err
+	}
+
+	currency, prices, err := unitPrices(req.Currency, itemRequests)
+	if err != nil {
+		return nil, err
+	}
+
 	// Validate that the user exists
 	if err := s.userClient.ValidateUserExists(ctx, uint64(req.UserID)); err != nil {
-		return nil, ErrUserNotFound
+		return nil, err
 	}
 
 	now := time.Now()
+
+	items := make([]model.OrderItem, len(itemRequests))
+	total := money.Money{Currency: currency}
+	for i, item := range itemRequests {
+		subtotal, err := prices[i].Multiply(int64(item.Quantity))
+		if err != nil {
+			return nil, err
+		}
+		if total, err = total.Add(subtotal); err != nil {
+			return nil, err
+		}
+
+		items[i] = model.OrderItem{
+			ProductName:    item.ProductName,
+			UnitPriceMinor: prices[i].MinorUnits,
+			Quantity:       item.Quantity,
+			SubtotalMinor:  subtotal.MinorUnits,
+			CreatedAt:      now,
+			UpdatedAt:      now,
+		}
+	}
 	
 	order := &model.Order{
-		UserID:      req.UserID,
-		ProductName: req.ProductName,
-		Price:       req.Price,
-		Quantity:    req.Quantity,
-		Status:      model.OrderStatusPending,
-		CreatedAt:   now,
-		UpdatedAt:   now,
+		UserID:     req.UserID,
+		Items:      items,
+		Currency:   currency,
+		TotalMinor: total.MinorUnits,
+		Status:     model.OrderStatusPending,
+		CreatedAt:  now,
+		UpdatedAt:  now,
 	}
 
-	if err := s.repo.Create(order); err != nil {
+	entry := newStatusHistory(ctx, now, "")
+	if err := s.repo.Create(ctx, order, entry, completion); err != nil {
 		return nil, err
 	}
+	ordersCreated.WithLabelValues(currency).Inc()
 
 	return s.toOrderResponse(order), nil
 }
@@ -65,9 +212,14 @@ func (s *OrderService) GetOrderByID(id uint) (*model.OrderResponse, error) {
 	return s.toOrderResponse(order), nil
 }
 
-// GetOrdersByUserID retrieves all orders for a specific user
-func (s *OrderService) GetOrdersByUserID(userID uint) ([]*model.OrderResponse, error) {
-	orders, err := s.repo.GetByUserID(userID)
+// ListOrders retrieves a page of orders
+func (s *OrderService) ListOrders(req *model.ListOrdersRequest) (*model.OrderListResponse, error) {
+	// Totals are only comparable within one currency
+	if (req.MinPrice != "" || req.MaxPrice != "") && req.Currency == "" {
+		return nil, ErrCurrencyRequired
+	}
+
+	orders, nextPageToken, err := s.repo.List(req)
 	if err != nil {
 		return nil, err
 	}
@@ -77,35 +229,58 @@ func (s *OrderService) GetOrdersByUserID(userID uint) ([]*model.OrderResponse, e
 		responses[i] = s.toOrderResponse(order)
 	}
 
-	return responses, nil
+	return &model.OrderListResponse{
+		Orders:        responses,
+		NextPageToken: nextPageToken,
+	}, nil
 }
 
-// GetAllOrders retrieves all orders
-func (s *OrderService) GetAllOrders() ([]*model.OrderResponse, error) {
-	orders, err := s.repo.GetAll()
-	if err != nil {
-		return nil, err
+// UpdateOrderStatus moves an order to a new status. Only the transitions
+// pending -> confirmed -> shipped -> delivered are allowed, and an order can
+// be cancelled until it has been shipped. The caller in ctx is recorded as
+// the actor of the transition.
+func (s *OrderService) UpdateOrderStatus(ctx context.Context, id uint, req *model.UpdateOrderStatusRequest) (*model.OrderResponse, error) {
+	if !req.Status.Valid() {
+		return nil, ErrInvalidStatus
 	}
 
-	responses := make([]*model.OrderResponse, len(orders))
-	for i, order := range orders {
-		responses[i] = s.toOrderResponse(order)
+	entry := newStatusHistory(ctx, time.Now(), req.Reason)
+	var previous model.OrderStatus
+	order, err := s.repo.UpdateStatus(ctx, id, req.Status, entry, func(current model.OrderStatus) error {
+		if !current.CanTransitionTo(req.Status) {
+			return fmt.Errorf("%w: %s -> %s", ErrInvalidStatusTransition, current, req.Status)
+		}
+		previous = current
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	orderStatusChanges.WithLabelValues(string(previous), string(req.Status)).Inc()
 
-	return responses, nil
+	return s.toOrderResponse(order), nil
 }
 
-// UpdateOrderStatus updates an order's status
-func (s *OrderService) UpdateOrderStatus(id uint, req *model.UpdateOrderStatusRequest) (*model.OrderResponse, error) {
-	order, err := s.repo.UpdateStatus(id, req.Status)
+// GetOrderStatusHistory retrieves the status transitions of an order, oldest first
+func (s *OrderService) GetOrderStatusHistory(id uint) ([]*model.OrderStatusHistoryResponse, error) {
+	history, err := s.repo.GetStatusHistory(id)
 	if err != nil {
 		return nil, err
 	}
 
-	// Update the UpdatedAt timestamp
-	order.UpdatedAt = time.Now()
+	responses := make([]*model.OrderStatusHistoryResponse, len(history))
+	for i, entry := range history {
+		responses[i] = &model.OrderStatusHistoryResponse{
+			FromStatus: entry.FromStatus,
+			ToStatus:   entry.ToStatus,
+			ActorID:    entry.ActorID,
+			Actor:      entry.Actor,
+			Reason:     entry.Reason,
+			CreatedAt:  entry.CreatedAt,
+		}
+	}
 
-	return s.toOrderResponse(order), nil
+	return responses, nil
 }
 
 // DeleteOrder deletes an order by ID
@@ -113,16 +288,138 @@ func (s *OrderService) DeleteOrder(id uint) error {
 	return s.repo.Delete(id)
 }
 
+// newStatusHistory creates a history entry attributed to the caller in ctx.
+// Calls without a principal are recorded as the system actor.
+func newStatusHistory(ctx context.Context, at time.Time, reason string) *model.OrderStatusHistory {
+	entry := &model.OrderStatusHistory{
+		Actor:     "system",
+		Reason:    reason,
+		CreatedAt: at,
+	}
+	if principal := policy.FromContext(ctx); principal != nil {
+		entry.ActorID = principal.UserID
+		entry.Actor = principal.Username
+		if entry.Actor == "" {
+			entry.Actor = fmt.Sprintf("user:%d", principal.UserID)
+		}
+	}
+	return entry
+}
+
+// requestFingerprint hashes the body of a create request
+func requestFingerprint(req *model.CreateOrderRequest) (string, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// replayOrderResponse returns the stored response of an earlier request made
+// with the same idempotency key
+func replayOrderResponse(key *model.IdempotencyKey, fingerprint string) (*model.OrderResponse, error) {
+	if key.Fingerprint != fingerprint {
+		return nil, ErrIdempotencyKeyReused
+	}
+	if !key.Completed() {
+		return nil, ErrIdempotencyKeyInProgress
+	}
+
+	var order model.OrderResponse
+	if err := json.Unmarshal(key.Response, &order); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// lineItems returns the items of a create request, accepting the legacy
+// single-product shape as a one-item order
+func lineItems(req *model.CreateOrderRequest) ([]model.CreateOrderItemRequest, error) {
+	legacy := req.ProductName != "" || req.Price != 0 || req.Quantity != 0
+	if len(req.Items) > 0 {
+		if legacy {
+			return nil, ErrMixedItems
+		}
+		return req.Items, nil
+	}
+
+	if req.ProductName == "" || req.Price <= 0 || req.Quantity <= 0 {
+		return nil, ErrNoOrderItems
+	}
+	return []model.CreateOrderItemRequest{{
+		ProductName: req.ProductName,
+		Price:       req.Price,
+		Quantity:    req.Quantity,
+	}}, nil
+}
+
+// unitPrices resolves the currency of a new order and the unit price of each
+// item. Items priced with money.Money must all use the same currency, which
+// must match the requested order currency if one is given. Deprecated float
+// prices are interpreted in the resolved currency. An item may not carry both.
+func unitPrices(currency string, items []model.CreateOrderItemRequest) (string, []money.Money, error) {
+	for _, item := range items {
+		if item.UnitPrice == nil {
+			continue
+		}
+		if item.Price != 0 {
+			return "", nil, ErrMixedPrices
+		}
+		if currency == "" {
+			currency = item.UnitPrice.Currency
+		} else if !strings.EqualFold(currency, item.UnitPrice.Currency) {
+			return "", nil, fmt.Errorf("%w: all items of an order must use the same currency", money.ErrCurrencyMismatch)
+		}
+	}
+	if currency == "" {
+		currency = money.DefaultCurrency
+	}
+
+	currency, err := money.NormalizeCurrency(currency)
+	if err != nil {
+		return "", nil, err
+	}
+
+	prices := make([]money.Money, len(items))
+	for i, item := range items {
+		if item.UnitPrice != nil {
+			prices[i], err = money.New(item.UnitPrice.MinorUnits, currency)
+		} else {
+			prices[i], err = money.FromFloat(item.Price, currency)
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		if prices[i].MinorUnits <= 0 {
+			return "", nil, ErrInvalidPrice
+		}
+	}
+
+	return currency, prices, nil
+}
+
 // toOrderResponse converts an Order model to OrderResponse
 func (s *OrderService) toOrderResponse(order *model.Order) *model.OrderResponse {
+	items := make([]*model.OrderItemResponse, len(order.Items))
+	for i, item := range order.Items {
+		items[i] = &model.OrderItemResponse{
+			ID:          item.ID,
+			ProductName: item.ProductName,
+			UnitPrice:   money.Money{MinorUnits: item.UnitPriceMinor, Currency: order.Currency},
+			Quantity:    item.Quantity,
+			Subtotal:    money.Money{MinorUnits: item.SubtotalMinor, Currency: order.Currency},
+		}
+	}
+
 	return &model.OrderResponse{
-		ID:          order.ID,
-		UserID:      order.UserID,
-		ProductName: order.ProductName,
-		Price:       order.Price,
-		Quantity:    order.Quantity,
-		Status:      order.Status,
-		CreatedAt:   order.CreatedAt,
-		UpdatedAt:   order.UpdatedAt,
+		ID:        order.ID,
+		UserID:    order.UserID,
+		Items:     items,
+		Currency:  order.Currency,
+		Total:     money.Money{MinorUnits: order.TotalMinor, Currency: order.Currency},
+		Status:    order.Status,
+		CreatedAt: order.CreatedAt,
+		UpdatedAt: order.UpdatedAt,
 	}
 } 
\ No newline at end of file
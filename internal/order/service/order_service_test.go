@@ -0,0 +1,371 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"gorm.io/gorm"
+
+	"bweng/api/proto/user"
+	"bweng/internal/dbtest"
+	"bweng/internal/money"
+	"bweng/internal/order/config"
+	"bweng/internal/order/migrations"
+	"bweng/internal/order/model"
+	"bweng/internal/order/repository"
+	"bweng/internal/policy"
+)
+
+// newTestOrderService returns a service on a migrated test schema whose
+// user client has no connection
+func newTestOrderService(t *testing.T) (*OrderService, *gorm.DB) {
+	t.Helper()
+	db := dbtest.Open(t, migrations.FS)
+	s := NewOrderService(
+		repository.NewOrderRepository(db),
+		repository.NewIdempotencyRepository(db),
+		newTestUserClient(),
+		&config.IdempotencyConfig{
+			KeyTTL:          time.Hour,
+			PendingTimeout:  time.Minute,
+			CleanupInterval: time.Minute,
+		},
+	)
+	return s, db
+}
+
+// fakeUserService answers every user lookup, running onLookup first
+type fakeUserService struct {
+	user.UserServiceClient
+	lookups  int
+	onLookup func()
+}
+
+func (f *fakeUserService) GetUserByID(ctx context.Context, in *user.GetUserByIDRequest, opts ...grpc.CallOption) (*user.UserResponse, error) {
+	f.lookups++
+	if f.onLookup != nil {
+		f.onLookup()
+	}
+	return &user.UserResponse{User: &user.User{Id: in.Id}}, nil
+}
+
+// withUserService makes s look users up in users
+func withUserService(s *OrderService, users user.UserServiceClient) {
+	s.userClient.client = users
+	s.userClient.signer = policy.NewSigner(policy.DefaultConfig())
+}
+
+// createTestOrder stores an order in status without calling the user service
+func createTestOrder(t *testing.T, s *OrderService, status model.OrderStatus) uint {
+	t.Helper()
+	now := time.Now()
+	order := &model.Order{
+		UserID:     3,
+		Items:      []model.OrderItem{{ProductName: "Widget", UnitPriceMinor: 1999, Quantity: 1, SubtotalMinor: 1999}},
+		Currency:   "USD",
+		TotalMinor: 1999,
+		Status:     status,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if err := s.repo.Create(context.Background(), order, newStatusHistory(context.Background(), now, ""), nil); err != nil {
+		t.Fatal(err)
+	}
+	return order.ID
+}
+
+func TestUpdateOrderStatusInvalidStatus(t *testing.T) {
+	// Unknown statuses are rejected before the database is touched
+	s := &OrderService{}
+	for _, status := range []model.OrderStatus{"", "refunded"} {
+		if _, err := s.UpdateOrderStatus(context.Background(), 1, &model.UpdateOrderStatusRequest{Status: status}); err != ErrInvalidStatus {
+			t.Errorf("UpdateOrderStatus(%q) = %v, want ErrInvalidStatus", status, err)
+		}
+	}
+}
+
+func TestUpdateOrderStatus(t *testing.T) {
+	s, _ := newTestOrderService(t)
+	id := createTestOrder(t, s, model.OrderStatusPending)
+
+	ctx := policy.NewContext(context.Background(), &policy.Principal{UserID: 7, Username: "support", Roles: []policy.Role{policy.RoleSupport}})
+	order, err := s.UpdateOrderStatus(ctx, id, &model.UpdateOrderStatusRequest{
+		Status: model.OrderStatusConfirmed,
+		Reason: "Payment received",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Status != model.OrderStatusConfirmed || len(order.Items) != 1 {
+		t.Errorf("UpdateOrderStatus = status %s with %d items, want confirmed with 1", order.Status, len(order.Items))
+	}
+
+	history, err := s.GetOrderStatusHistory(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("history has %d entries, want the creation and the transition", len(history))
+	}
+	if created := history[0]; created.FromStatus != "" || created.ToStatus != model.OrderStatusPending || created.Actor != "system" {
+		t.Errorf("history[0] = %+v, want the creation by the system", created)
+	}
+	want := model.OrderStatusHistoryResponse{
+		FromStatus: model.OrderStatusPending,
+		ToStatus:   model.OrderStatusConfirmed,
+		ActorID:    7,
+		Actor:      "support",
+		Reason:     "Payment received",
+	}
+	got := *history[1]
+	got.CreatedAt = time.Time{}
+	if got != want {
+		t.Errorf("history[1] = %+v, want %+v", got, want)
+	}
+}
+
+func TestUpdateOrderStatusInvalidTransition(t *testing.T) {
+	tests := []struct {
+		from model.OrderStatus
+		to   model.OrderStatus
+	}{
+		{model.OrderStatusPending, model.OrderStatusShipped},
+		{model.OrderStatusPending, model.OrderStatusPending},
+		{model.OrderStatusShipped, model.OrderStatusCancelled},
+		{model.OrderStatusDelivered, model.OrderStatusPending},
+		{model.OrderStatusCancelled, model.OrderStatusConfirmed},
+	}
+
+	s, db := newTestOrderService(t)
+	for _, tt := range tests {
+		t.Run(string(tt.from)+"_to_"+string(tt.to), func(t *testing.T) {
+			id := createTestOrder(t, s, tt.from)
+
+			_, err := s.UpdateOrderStatus(context.Background(), id, &model.UpdateOrderStatusRequest{Status: tt.to})
+			if !errors.Is(err, ErrInvalidStatusTransition) {
+				t.Fatalf("UpdateOrderStatus = %v, want ErrInvalidStatusTransition", err)
+			}
+
+			// Neither the order nor its history changed
+			order, err := s.GetOrderByID(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if order.Status != tt.from {
+				t.Errorf("status = %s, want %s", order.Status, tt.from)
+			}
+			var entries int64
+			if err := db.Model(&model.OrderStatusHistory{}).Where("order_id = ?", id).Count(&entries).Error; err != nil {
+				t.Fatal(err)
+			}
+			if entries != 1 {
+				t.Errorf("history has %d entries, want only the creation", entries)
+			}
+		})
+	}
+}
+
+func TestUpdateOrderStatusNotFound(t *testing.T) {
+	s, _ := newTestOrderService(t)
+	_, err := s.UpdateOrderStatus(context.Background(), 404, &model.UpdateOrderStatusRequest{Status: model.OrderStatusConfirmed})
+	if !errors.Is(err, ErrOrderNotFound) {
+		t.Errorf("UpdateOrderStatus = %v, want ErrOrderNotFound", err)
+	}
+}
+
+// newCreateRequest returns a one-item order request of user 3
+func newCreateRequest(key string, minorUnits int64) *model.CreateOrderRequest {
+	return &model.CreateOrderRequest{
+		UserID: 3,
+		Items: []model.CreateOrderItemRequest{{
+			ProductName: "Widget",
+			UnitPrice:   &money.Money{MinorUnits: minorUnits, Currency: "USD"},
+			Quantity:    2,
+		}},
+		IdempotencyKey: key,
+	}
+}
+
+// customerContext returns a context carrying user 3 as the caller
+func customerContext() context.Context {
+	return policy.NewContext(context.Background(), &policy.Principal{UserID: 3, Username: "alice", Roles: []policy.Role{policy.RoleCustomer}})
+}
+
+func TestRequestFingerprint(t *testing.T) {
+	fingerprint := func(req *model.CreateOrderRequest) string {
+		t.Helper()
+		f, err := requestFingerprint(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+
+	// The key itself is not part of the request body
+	if fingerprint(newCreateRequest("a", 1999)) != fingerprint(newCreateRequest("b", 1999)) {
+		t.Error("requests differing only in their key have different fingerprints")
+	}
+	if fingerprint(newCreateRequest("a", 1999)) == fingerprint(newCreateRequest("a", 2999)) {
+		t.Error("requests with different prices have the same fingerprint")
+	}
+}
+
+func TestReplayOrderResponse(t *testing.T) {
+	response, _ := json.Marshal(&model.OrderResponse{ID: 42, UserID: 3, Status: model.OrderStatusPending})
+	completed := &model.IdempotencyKey{Key: "k", Fingerprint: "a", Response: response}
+	pending := &model.IdempotencyKey{Key: "k", Fingerprint: "a"}
+
+	order, err := replayOrderResponse(completed, "a")
+	if err != nil || order.ID != 42 || order.UserID != 3 || order.Status != model.OrderStatusPending {
+		t.Errorf("replay of a completed key = %+v, %v, want order 42", order, err)
+	}
+
+	tests := []struct {
+		name        string
+		key         *model.IdempotencyKey
+		fingerprint string
+		want        error
+	}{
+		{"completed with another body", completed, "b", ErrIdempotencyKeyReused},
+		{"pending with another body", pending, "b", ErrIdempotencyKeyReused},
+		{"pending with the same body", pending, "a", ErrIdempotencyKeyInProgress},
+	}
+	for _, tt := range tests {
+		if order, err := replayOrderResponse(tt.key, tt.fingerprint); err != tt.want || order != nil {
+			t.Errorf("%s: replayOrderResponse = %+v, %v, want %v", tt.name, order, err, tt.want)
+		}
+	}
+}
+
+func TestCreateOrderIdempotency(t *testing.T) {
+	s, db := newTestOrderService(t)
+	users := &fakeUserService{}
+	withUserService(s, users)
+	ctx := customerContext()
+
+	first, err := s.CreateOrder(ctx, newCreateRequest("k", 1999))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A retry gets the original response without creating another order
+	replay, err := s.CreateOrder(ctx, newCreateRequest("k", 1999))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replay.ID != first.ID || replay.Total != first.Total || len(replay.Items) != 1 {
+		t.Errorf("replay = %+v, want %+v", replay, first)
+	}
+	if users.lookups != 1 {
+		t.Errorf("user service called %d times, want once", users.lookups)
+	}
+
+	// Reusing the key for another body is rejected
+	if _, err := s.CreateOrder(ctx, newCreateRequest("k", 2999)); err != ErrIdempotencyKeyReused {
+		t.Errorf("CreateOrder with another body = %v, want ErrIdempotencyKeyReused", err)
+	}
+
+	// Keys are scoped to the caller
+	other := policy.NewContext(context.Background(), &policy.Principal{UserID: 1, Username: "admin", Roles: []policy.Role{policy.RoleAdmin}})
+	if order, err := s.CreateOrder(other, newCreateRequest("k", 1999)); err != nil || order.ID == first.ID {
+		t.Errorf("CreateOrder by another caller = %+v, %v, want a new order", order, err)
+	}
+
+	var orders int64
+	if err := db.Model(&model.Order{}).Count(&orders).Error; err != nil {
+		t.Fatal(err)
+	}
+	if orders != 2 {
+		t.Errorf("%d orders created, want 2", orders)
+	}
+}
+
+func TestCreateOrderIdempotencyInProgress(t *testing.T) {
+	s, db := newTestOrderService(t)
+	users := &fakeUserService{}
+	withUserService(s, users)
+	ctx := customerContext()
+
+	// Another request holds the key and has not finished yet
+	req := newCreateRequest("k", 1999)
+	fingerprint, err := requestFingerprint(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	if _, _, err := s.idempotencyRepo.Reserve(ctx, &model.IdempotencyKey{
+		UserID:      3,
+		Key:         "k",
+		Fingerprint: fingerprint,
+		ExpiresAt:   now.Add(time.Hour),
+		CreatedAt:   now,
+	}, now); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.CreateOrder(ctx, req); err != ErrIdempotencyKeyInProgress {
+		t.Errorf("CreateOrder = %v, want ErrIdempotencyKeyInProgress", err)
+	}
+	if users.lookups != 0 {
+		t.Errorf("user service called %d times, want no call", users.lookups)
+	}
+	var orders int64
+	if err := db.Model(&model.Order{}).Count(&orders).Error; err != nil {
+		t.Fatal(err)
+	}
+	if orders != 0 {
+		t.Errorf("%d orders created, want none", orders)
+	}
+}
+
+func TestCreateOrderIdempotencyKeyTakenOver(t *testing.T) {
+	s, db := newTestOrderService(t)
+	ctx := customerContext()
+	req := newCreateRequest("k", 1999)
+	fingerprint, err := requestFingerprint(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The request stalls in the user lookup past PendingTimeout, and a retry
+	// takes its key over before it creates the order
+	var takeover *model.IdempotencyKey
+	withUserService(s, &fakeUserService{onLookup: func() {
+		now := time.Now().Add(2 * s.idempotency.PendingTimeout)
+		key, created, err := s.idempotencyRepo.Reserve(ctx, &model.IdempotencyKey{
+			UserID:      3,
+			Key:         "k",
+			Fingerprint: fingerprint,
+			ExpiresAt:   now.Add(s.idempotency.KeyTTL),
+			CreatedAt:   now,
+		}, now.Add(-s.idempotency.PendingTimeout))
+		if err != nil || !created {
+			t.Errorf("takeover = %v, %v, want a new key", created, err)
+		}
+		takeover = key
+	}})
+
+	if _, err := s.CreateOrder(ctx, req); err != ErrIdempotencyKeyInProgress {
+		t.Fatalf("CreateOrder = %v, want ErrIdempotencyKeyInProgress", err)
+	}
+
+	var orders int64
+	if err := db.Model(&model.Order{}).Count(&orders).Error; err != nil {
+		t.Fatal(err)
+	}
+	if orders != 0 {
+		t.Errorf("%d orders created by the stalled request, want none", orders)
+	}
+	// The key stays with the request that took it over
+	var keys []model.IdempotencyKey
+	if err := db.Find(&keys).Error; err != nil {
+		t.Fatal(err)
+	}
+	if takeover == nil || len(keys) != 1 || keys[0].ID != takeover.ID || keys[0].Completed() {
+		t.Errorf("keys = %+v, want only the pending takeover", keys)
+	}
+}
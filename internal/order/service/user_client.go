@@ -2,22 +2,75 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
 
 	"bweng/api/proto/user"
+	"bweng/internal/breaker"
+	"bweng/internal/logging"
+	"bweng/internal/metrics"
+	"bweng/internal/tracing"
+	"bweng/internal/order/config"
+	"bweng/internal/policy"
+	"bweng/internal/rpcerror"
 )
 
-// UserClient handles communication with user service
+// ErrUserServiceUnavailable is returned when the user service cannot be reached
+var ErrUserServiceUnavailable = errors.New("user service unavailable")
+
+var (
+	userClientBreakerState = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "order_user_client_breaker_state",
+		Help: "State of the user service circuit breaker (0 closed, 1 open, 2 half-open).",
+	})
+	userClientBreakerTransitions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "order_user_client_breaker_transitions_total",
+		Help: "User service circuit breaker state transitions by target state.",
+	}, []string{"state"})
+	userClientCalls = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "order_user_client_calls_total",
+		Help: "User service calls by method and result (ok, error, unavailable, rejected).",
+	}, []string{"method", "result"})
+	userClientRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "order_user_client_retries_total",
+		Help: "User service call retries by method.",
+	}, []string{"method"})
+)
+
+// UserClient handles communication with user service. Every call attempt
+// gets its own deadline, Unavailable responses are retried with exponential
+// backoff, and a circuit breaker fails calls fast while the user service is
+// down.
 type UserClient struct {
-	client user.UserServiceClient
-	conn   *grpc.ClientConn
+	client  user.UserServiceClient
+	conn    *grpc.ClientConn
+	config  *config.UserClientConfig
+	breaker *breaker.Breaker
+	// signer signs the caller's identity forwarded to the user service
+	signer *policy.Signer
 }
 
 // NewUserClient creates a new user client
-func NewUserClient(userServiceAddr string) (*UserClient, error) {
-	conn, err := grpc.Dial(userServiceAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+func NewUserClient(userServiceAddr string, cfg *config.UserClientConfig, signer *policy.Signer) (*UserClient, error) {
+	conn, err := grpc.NewClient(userServiceAddr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(
+			metrics.UnaryClientInterceptor(),
+			logging.UnaryClientInterceptor(),
+		),
+		grpc.WithStatsHandler(tracing.ClientHandler()),
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -27,6 +80,17 @@ func NewUserClient(userServiceAddr string) (*UserClient, error) {
 	return &UserClient{
 		client: client,
 		conn:   conn,
+		config: cfg,
+		signer: signer,
+		breaker: breaker.New(breaker.Config{
+			FailureThreshold: cfg.BreakerFailureThreshold,
+			OpenTimeout:      cfg.BreakerOpenTimeout,
+			HalfOpenMaxCalls: 1,
+		}, func(from, to breaker.State) {
+			slog.Warn("User service circuit breaker changed state", "from", from.String(), "to", to.String())
+			userClientBreakerState.Set(float64(to))
+			userClientBreakerTransitions.WithLabelValues(to.String()).Inc()
+		}),
 	}, nil
 }
 
@@ -35,21 +99,39 @@ func (c *UserClient) Close() error {
 	return c.conn.Close()
 }
 
+// Check asks the user service's grpc.health.v1 Health service whether it is
+// serving. It bypasses the retry policy and circuit breaker so readiness
+// reflects the current state of the dependency.
+func (c *UserClient) Check(ctx context.Context) error {
+	resp, err := healthpb.NewHealthClient(c.conn).Check(ctx, &healthpb.HealthCheckRequest{
+		Service: user.UserService_ServiceDesc.ServiceName,
+	})
+	if err != nil {
+		return userServiceError(err)
+	}
+	if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("%w: %s", ErrUserServiceUnavailable, resp.Status)
+	}
+	return nil
+}
+
 // GetUserByID retrieves a user by ID from user service
 func (c *UserClient) GetUserByID(ctx context.Context, userID uint64) (*user.User, error) {
 	req := &user.GetUserByIDRequest{
 		Id: userID,
 	}
 
-	resp, err := c.client.GetUserByID(ctx, req)
+	var resp *user.UserResponse
+	err := c.call(ctx, "GetUserByID", func(ctx context.Context) error {
+		var err error
+		// Forward the caller's identity so the user service can authorize the lookup
+		resp, err = c.client.GetUserByID(c.signer.OutgoingContext(ctx), req)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	if resp.Error != "" {
-		return nil, ErrUserNotFound
-	}
-
 	return resp.User, nil
 }
 
@@ -57,4 +139,94 @@ func (c *UserClient) GetUserByID(ctx context.Context, userID uint64) (*user.User
 func (c *UserClient) ValidateUserExists(ctx context.Context, userID uint64) error {
 	_, err := c.GetUserByID(ctx, userID)
 	return err
-} 
\ No newline at end of file
+}
+
+// call runs a user service call under the client's deadline, retry and
+// circuit breaker policy and translates its error
+func (c *UserClient) call(ctx context.Context, method string, fn func(ctx context.Context) error) error {
+	backoff := c.config.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		ticket, err := c.breaker.Allow()
+		if err != nil {
+			userClientCalls.WithLabelValues(method, "rejected").Inc()
+			return fmt.Errorf("%w: %v", ErrUserServiceUnavailable, err)
+		}
+
+		attemptCtx, cancel := context.WithTimeout(ctx, c.config.CallTimeout)
+		err = fn(attemptCtx)
+		cancel()
+
+		code := status.Code(err)
+		// Only an unreachable or unresponsive user service counts against the
+		// breaker, and an attempt the caller gave up on does not count at all
+		unavailable := code == codes.Unavailable || (code == codes.DeadlineExceeded && ctx.Err() == nil)
+		if ctx.Err() != nil || code == codes.Canceled {
+			c.breaker.Release(ticket)
+		} else {
+			c.breaker.Record(ticket, !unavailable)
+		}
+
+		if err == nil {
+			userClientCalls.WithLabelValues(method, "ok").Inc()
+			return nil
+		}
+		if code != codes.Unavailable || attempt >= c.config.MaxRetries {
+			if unavailable {
+				userClientCalls.WithLabelValues(method, "unavailable").Inc()
+			} else {
+				userClientCalls.WithLabelValues(method, "error").Inc()
+			}
+			return userServiceError(err)
+		}
+
+		userClientRetries.WithLabelValues(method).Inc()
+		if err := sleep(ctx, jitter(backoff)); err != nil {
+			userClientCalls.WithLabelValues(method, "error").Inc()
+			return userServiceError(status.FromContextError(err).Err())
+		}
+		backoff *= 2
+		if backoff > c.config.MaxRetryBackoff {
+			backoff = c.config.MaxRetryBackoff
+		}
+	}
+}
+
+// jitter returns a random duration in [d/2, d) to spread out retries
+func jitter(d time.Duration) time.Duration {
+	if d <= 1 {
+		return d
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)))
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// userServiceError translates a gRPC status error from the user service
+// into the typed errors of this package
+func userServiceError(err error) error {
+	switch status.Code(err) {
+	case codes.NotFound:
+		if reason := rpcerror.Reason(err); reason == "" || reason == rpcerror.ReasonUserNotFound {
+			return ErrUserNotFound
+		}
+	case codes.Unauthenticated:
+		return policy.ErrUnauthenticated
+	case codes.PermissionDenied:
+		return policy.ErrForbidden
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return fmt.Errorf("%w: %v", ErrUserServiceUnavailable, err)
+	}
+	return fmt.Errorf("user service: %w", err)
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"bweng/internal/breaker"
+	"bweng/internal/order/config"
+)
+
+// newTestUserClient returns a client without a connection whose breaker
+// opens after one failure and probes again right away
+func newTestUserClient() *UserClient {
+	return &UserClient{
+		config: &config.UserClientConfig{
+			CallTimeout:     time.Second,
+			RetryBackoff:    time.Millisecond,
+			MaxRetryBackoff: time.Millisecond,
+		},
+		breaker: breaker.New(breaker.Config{FailureThreshold: 1, OpenTimeout: time.Nanosecond, HalfOpenMaxCalls: 1}, nil),
+	}
+}
+
+func TestUserClientCallCancelledProbe(t *testing.T) {
+	tests := []struct {
+		name string
+		// call makes the probe end because its caller gave up
+		call func(c *UserClient) error
+	}{
+		{"canceled status", func(c *UserClient) error {
+			return c.call(context.Background(), "GetUserByID", func(context.Context) error {
+				return status.Error(codes.Canceled, "context canceled")
+			})
+		}},
+		{"caller deadline", func(c *UserClient) error {
+			ctx, cancel := context.WithCancel(context.Background())
+			return c.call(ctx, "GetUserByID", func(context.Context) error {
+				cancel()
+				return status.Error(codes.DeadlineExceeded, "context deadline exceeded")
+			})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestUserClient()
+			unavailable := func(context.Context) error { return status.Error(codes.Unavailable, "connection refused") }
+			if err := c.call(context.Background(), "GetUserByID", unavailable); err == nil {
+				t.Fatal("call succeeded, want the user service unavailable")
+			}
+			if c.breaker.State() != breaker.StateOpen {
+				t.Fatalf("state = %s, want open", c.breaker.State())
+			}
+
+			time.Sleep(time.Millisecond)
+			if err := tt.call(c); err == nil {
+				t.Fatal("cancelled call succeeded")
+			}
+			if c.breaker.State() != breaker.StateHalfOpen {
+				t.Fatalf("state after cancelled probe = %s, want half-open", c.breaker.State())
+			}
+
+			// The probe slot is free again and a real answer decides the state
+			if err := c.call(context.Background(), "GetUserByID", func(context.Context) error { return nil }); err != nil {
+				t.Fatalf("probe after cancelled probe: %v", err)
+			}
+			if c.breaker.State() != breaker.StateClosed {
+				t.Errorf("state after successful probe = %s, want closed", c.breaker.State())
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package outbox
+
+import (
+	"time"
+)
+
+// Publisher names accepted by OUTBOX_PUBLISHER
+const (
+	PublisherStdout    = "stdout"
+	PublisherFile      = "file"
+	PublisherInProcess = "inprocess"
+)
+
+// Config holds outbox relay configuration
+type Config struct {
+	Publisher    string        `yaml:"publisher" env:"OUTBOX_PUBLISHER" flag:"outbox-publisher" usage:"Domain event publisher (stdout, file or inprocess)" validate:"oneof=stdout file inprocess"`
+	FilePath     string        `yaml:"file" env:"OUTBOX_FILE" flag:"outbox-file" usage:"File the file publisher appends to" validate:"required_if=Publisher file"`
+	PollInterval time.Duration `yaml:"poll_interval" env:"OUTBOX_POLL_INTERVAL" flag:"outbox-poll-interval" usage:"Outbox relay polling interval" validate:"gt=0"`
+	BatchSize    int           `yaml:"batch_size" env:"OUTBOX_BATCH_SIZE" flag:"outbox-batch-size" usage:"Events relayed per poll" validate:"min=1"`
+}
+
+// DefaultConfig returns the outbox relay defaults
+func DefaultConfig() Config {
+	return Config{
+		Publisher:    PublisherStdout,
+		FilePath:     "outbox-events.jsonl",
+		PollInterval: time.Second,
+		BatchSize:    100,
+	}
+}
@@ -0,0 +1,46 @@
+package outbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Event is a domain event waiting in the outbox table to be published
+type Event struct {
+	ID            uint            `json:"id" gorm:"primaryKey"`
+	AggregateType string          `json:"aggregate_type" gorm:"not null;index:idx_outbox_events_aggregate"`
+	AggregateID   string          `json:"aggregate_id" gorm:"not null;index:idx_outbox_events_aggregate"`
+	Type          string          `json:"type" gorm:"not null"`
+	Payload       json.RawMessage `json:"payload" gorm:"type:jsonb;not null"`
+	CreatedAt     time.Time       `json:"created_at"`
+	PublishedAt   *time.Time      `json:"published_at,omitempty" gorm:"index"`
+	Attempts      int             `json:"-" gorm:"not null;default:0"`
+	LastError     string          `json:"-"`
+}
+
+// TableName overrides the default table name
+func (Event) TableName() string {
+	return "outbox_events"
+}
+
+// Record adds an event to the outbox. tx must be the transaction that makes
+// the change the event describes, so the event is stored if and only if the
+// change is committed.
+func Record(tx *gorm.DB, aggregateType string, aggregateID uint, eventType string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", eventType, err)
+	}
+
+	return tx.Create(&Event{
+		AggregateType: aggregateType,
+		AggregateID:   strconv.FormatUint(uint64(aggregateID), 10),
+		Type:          eventType,
+		Payload:       body,
+		CreatedAt:     time.Now(),
+	}).Error
+}
@@ -0,0 +1,140 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// ErrNoSubscribers is returned by an InProcessPublisher for events no
+// handler is subscribed to, so the relay keeps them instead of dropping them
+var ErrNoSubscribers = errors.New("no handler is subscribed to the event")
+
+// Publisher delivers outbox events to downstream systems
+type Publisher interface {
+	Publish(ctx context.Context, event *Event) error
+}
+
+// Handler processes an event delivered by an InProcessPublisher
+type Handler func(ctx context.Context, event *Event) error
+
+// InProcessPublisher delivers events to handlers registered in the same
+// process
+type InProcessPublisher struct {
+	mu       sync.RWMutex
+	handlers map[string][]Handler
+}
+
+// NewInProcessPublisher creates a publisher without subscribers
+func NewInProcessPublisher() *InProcessPublisher {
+	return &InProcessPublisher{
+		handlers: make(map[string][]Handler),
+	}
+}
+
+// Subscribe registers a handler for an event type, or for all events when
+// eventType is empty
+func (p *InProcessPublisher) Subscribe(eventType string, handler Handler) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.handlers[eventType] = append(p.handlers[eventType], handler)
+}
+
+// Publish calls the handlers subscribed to the event and returns the first
+// error, or ErrNoSubscribers when there are none
+func (p *InProcessPublisher) Publish(ctx context.Context, event *Event) error {
+	p.mu.RLock()
+	handlers := append(append([]Handler(nil), p.handlers[""]...), p.handlers[event.Type]...)
+	p.mu.RUnlock()
+
+	if len(handlers) == 0 {
+		return fmt.Errorf("%w: %s", ErrNoSubscribers, event.Type)
+	}
+
+	for _, handler := range handlers {
+		if err := handler(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LogHandler logs every event it is delivered. It is the subscriber of the
+// inprocess publisher selected with OUTBOX_PUBLISHER.
+func LogHandler(ctx context.Context, event *Event) error {
+	slog.InfoContext(ctx, "Domain event published",
+		"event_id", event.ID,
+		"event_type", event.Type,
+		"aggregate_type", event.AggregateType,
+		"aggregate_id", event.AggregateID,
+	)
+	return nil
+}
+
+// WriterPublisher writes events as JSON lines
+type WriterPublisher struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterPublisher creates a publisher that writes to w
+func NewWriterPublisher(w io.Writer) *WriterPublisher {
+	return &WriterPublisher{
+		w: w,
+	}
+}
+
+// NewStdoutPublisher creates a publisher that writes to standard output
+func NewStdoutPublisher() *WriterPublisher {
+	return NewWriterPublisher(os.Stdout)
+}
+
+// NewFilePublisher creates a publisher that appends to the file at path
+func NewFilePublisher(path string) (*WriterPublisher, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open outbox file: %w", err)
+	}
+	return NewWriterPublisher(file), nil
+}
+
+// Publish writes the event as a single JSON line
+func (p *WriterPublisher) Publish(ctx context.Context, event *Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, err = p.w.Write(append(line, '\n'))
+	return err
+}
+
+// Close closes the underlying writer if it is closable
+func (p *WriterPublisher) Close() error {
+	if closer, ok := p.w.(io.Closer); ok && p.w != os.Stdout {
+		return closer.Close()
+	}
+	return nil
+}
+
+// NewPublisher creates the publisher selected by the configuration
+func NewPublisher(cfg *Config) (Publisher, error) {
+	switch cfg.Publisher {
+	case PublisherStdout:
+		return NewStdoutPublisher(), nil
+	case PublisherFile:
+		return NewFilePublisher(cfg.FilePath)
+	case PublisherInProcess:
+		publisher := NewInProcessPublisher()
+		publisher.Subscribe("", LogHandler)
+		return publisher, nil
+	}
+	return nil, fmt.Errorf("unknown outbox publisher %q", cfg.Publisher)
+}
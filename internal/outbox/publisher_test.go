@@ -0,0 +1,106 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInProcessPublisher(t *testing.T) {
+	p := NewInProcessPublisher()
+	event := &Event{ID: 1, Type: "OrderCreated"}
+
+	if err := p.Publish(context.Background(), event); !errors.Is(err, ErrNoSubscribers) {
+		t.Fatalf("Publish without handlers = %v, want ErrNoSubscribers", err)
+	}
+
+	// Handlers of all events run before the handlers of the event type, each
+	// in the order they subscribed
+	var calls []string
+	handler := func(name string) Handler {
+		return func(ctx context.Context, e *Event) error {
+			calls = append(calls, name)
+			return nil
+		}
+	}
+	p.Subscribe("OrderCreated", handler("created 1"))
+	p.Subscribe("", handler("all 1"))
+	p.Subscribe("OrderStatusChanged", handler("status changed"))
+	p.Subscribe("OrderCreated", handler("created 2"))
+	p.Subscribe("", handler("all 2"))
+
+	if err := p.Publish(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"all 1", "all 2", "created 1", "created 2"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("handlers ran as %v, want %v", calls, want)
+	}
+
+	// The first failing handler stops the delivery
+	failed := errors.New("handler failed")
+	calls = nil
+	p.Subscribe("OrderCreated", func(ctx context.Context, e *Event) error { return failed })
+	p.Subscribe("OrderCreated", handler("after failure"))
+	if err := p.Publish(context.Background(), event); !errors.Is(err, failed) {
+		t.Fatalf("Publish = %v, want the handler error", err)
+	}
+	if calls[len(calls)-1] != "created 2" {
+		t.Errorf("handlers ran as %v, want none after the failure", calls)
+	}
+
+	// Catch-all handlers also receive event types nobody subscribed to
+	calls = nil
+	if err := p.Publish(context.Background(), &Event{Type: "UserDeleted"}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(calls, []string{"all 1", "all 2"}) {
+		t.Errorf("handlers ran as %v, want the catch-all handlers", calls)
+	}
+}
+
+func TestWriterPublisher(t *testing.T) {
+	var buf bytes.Buffer
+	p := NewWriterPublisher(&buf)
+	createdAt := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	events := []*Event{
+		{ID: 7, AggregateType: "order", AggregateID: "42", Type: "OrderCreated", Payload: json.RawMessage(`{"id":42}`), CreatedAt: createdAt, Attempts: 2, LastError: "broker down"},
+		{ID: 8, AggregateType: "user", AggregateID: "3", Type: "UserDeleted", Payload: json.RawMessage(`{"id":3}`), CreatedAt: createdAt},
+	}
+	for _, event := range events {
+		if err := p.Publish(context.Background(), event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lines := strings.Split(buf.String(), "\n")
+	want := []string{
+		`{"id":7,"aggregate_type":"order","aggregate_id":"42","type":"OrderCreated","payload":{"id":42},"created_at":"2024-06-01T12:00:00Z"}`,
+		`{"id":8,"aggregate_type":"user","aggregate_id":"3","type":"UserDeleted","payload":{"id":3},"created_at":"2024-06-01T12:00:00Z"}`,
+		"",
+	}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("wrote\n%s\nwant\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestNewPublisher(t *testing.T) {
+	p, err := NewPublisher(&Config{Publisher: PublisherInProcess})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The inprocess publisher logs every event instead of keeping it
+	if err := p.Publish(context.Background(), &Event{ID: 1, Type: "OrderCreated"}); err != nil {
+		t.Errorf("inprocess Publish = %v, want the logging subscriber to take the event", err)
+	}
+
+	if _, err := NewPublisher(&Config{Publisher: "kafka"}); err == nil {
+		t.Error("NewPublisher accepted an unknown publisher")
+	}
+}
@@ -0,0 +1,95 @@
+package outbox
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Relay moves events from the outbox table to a publisher. Events are
+// delivered at least once but not necessarily in the order they were
+// recorded: replicas relay disjoint batches concurrently, and ids are taken
+// from a sequence before the recording transaction commits. A failed event
+// ends its batch and is retried on the next poll.
+type Relay struct {
+	db        *gorm.DB
+	publisher Publisher
+	interval  time.Duration
+	batchSize int
+}
+
+// NewRelay creates a new outbox relay
+func NewRelay(db *gorm.DB, publisher Publisher, cfg *Config) *Relay {
+	return &Relay{
+		db:        db,
+		publisher: publisher,
+		interval:  cfg.PollInterval,
+		batchSize: cfg.BatchSize,
+	}
+}
+
+// Run polls the outbox until ctx is cancelled
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		// Drain full batches before waiting for the next tick
+		for {
+			published, err := r.RelayOnce(ctx)
+			if err != nil {
+				slog.ErrorContext(ctx, "Outbox relay failed", "error", err)
+			}
+			if err != nil || published < r.batchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RelayOnce publishes one batch of pending events in id order and returns how
+// many were published. Rows are locked with SKIP LOCKED so several replicas
+// can relay the same table without publishing an event twice concurrently.
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	published := 0
+	var publishErr error
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var events []*Event
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("published_at IS NULL").Order("id").Limit(r.batchSize).Find(&events).Error; err != nil {
+			return err
+		}
+
+		for _, event := range events {
+			if publishErr = r.publisher.Publish(ctx, event); publishErr != nil {
+				return tx.Model(event).Updates(map[string]interface{}{
+					"attempts":   gorm.Expr("attempts + 1"),
+					"last_error": publishErr.Error(),
+				}).Error
+			}
+
+			now := time.Now()
+			if err := tx.Model(event).Update("published_at", now).Error; err != nil {
+				return err
+			}
+			published++
+		}
+		return nil
+	})
+	if err != nil {
+		// Nothing was marked as published
+		return 0, err
+	}
+
+	return published, publishErr
+}
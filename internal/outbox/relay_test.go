@@ -0,0 +1,109 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"bweng/internal/dbtest"
+	"bweng/internal/user/migrations"
+)
+
+// failingPublisher fails the events whose ids are in fail and records the
+// ids it published
+type failingPublisher struct {
+	fail      map[uint]bool
+	published []uint
+}
+
+func (p *failingPublisher) Publish(ctx context.Context, event *Event) error {
+	if p.fail[event.ID] {
+		return errors.New("broker unavailable")
+	}
+	p.published = append(p.published, event.ID)
+	return nil
+}
+
+// recordEvents adds n events to the outbox and returns their ids
+func recordEvents(t *testing.T, db *gorm.DB, n int) []uint {
+	t.Helper()
+	for i := 1; i <= n; i++ {
+		if err := Record(db, "user", uint(i), "UserCreated", map[string]int{"id": i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var ids []uint
+	if err := db.Model(&Event{}).Order("id").Pluck("id", &ids).Error; err != nil {
+		t.Fatal(err)
+	}
+	return ids
+}
+
+// loadEvents returns the outbox events by id
+func loadEvents(t *testing.T, db *gorm.DB) map[uint]*Event {
+	t.Helper()
+	var events []*Event
+	if err := db.Find(&events).Error; err != nil {
+		t.Fatal(err)
+	}
+	byID := make(map[uint]*Event)
+	for _, event := range events {
+		byID[event.ID] = event
+	}
+	return byID
+}
+
+func TestRelayOnceFailedPublish(t *testing.T) {
+	db := dbtest.Open(t, migrations.FS)
+	ids := recordEvents(t, db, 5)
+
+	publisher := &failingPublisher{fail: map[uint]bool{ids[2]: true}}
+	relay := NewRelay(db, publisher, &Config{PollInterval: time.Second, BatchSize: 10})
+
+	published, err := relay.RelayOnce(context.Background())
+	if err == nil || err.Error() != "broker unavailable" {
+		t.Fatalf("RelayOnce error = %v, want the publish error", err)
+	}
+	if published != 2 {
+		t.Errorf("RelayOnce = %d, want the 2 events before the failed one", published)
+	}
+
+	events := loadEvents(t, db)
+	for _, id := range ids[:2] {
+		if events[id].PublishedAt == nil || events[id].Attempts != 0 {
+			t.Errorf("event %d: published_at = %v, attempts = %d, want published at the first attempt", id, events[id].PublishedAt, events[id].Attempts)
+		}
+	}
+	failed := events[ids[2]]
+	if failed.PublishedAt != nil || failed.Attempts != 1 || failed.LastError != "broker unavailable" {
+		t.Errorf("failed event: published_at = %v, attempts = %d, last_error = %q, want one recorded failure", failed.PublishedAt, failed.Attempts, failed.LastError)
+	}
+	for _, id := range ids[3:] {
+		if events[id].PublishedAt != nil || events[id].Attempts != 0 {
+			t.Errorf("event %d after the failure: published_at = %v, attempts = %d, want it untouched", id, events[id].PublishedAt, events[id].Attempts)
+		}
+	}
+
+	// The next poll retries the failed event first
+	if _, err := relay.RelayOnce(context.Background()); err == nil {
+		t.Fatal("RelayOnce succeeded, want the event to fail again")
+	}
+	if attempts := loadEvents(t, db)[ids[2]].Attempts; attempts != 2 {
+		t.Errorf("attempts = %d, want 2", attempts)
+	}
+
+	delete(publisher.fail, ids[2])
+	published, err = relay.RelayOnce(context.Background())
+	if err != nil || published != 3 {
+		t.Fatalf("RelayOnce = %d, %v, want the 3 remaining events", published, err)
+	}
+	if len(publisher.published) != 5 {
+		t.Errorf("published %v, want every event once", publisher.published)
+	}
+	if published, err := relay.RelayOnce(context.Background()); err != nil || published != 0 {
+		t.Errorf("RelayOnce on an empty outbox = %d, %v, want 0", published, err)
+	}
+}
@@ -0,0 +1,156 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+var (
+	ErrInvalidPageToken = errors.New("invalid page token")
+	ErrInvalidSort      = errors.New("invalid sort option")
+)
+
+// SortOrder represents the direction of a sort
+type SortOrder string
+
+const (
+	SortAsc  SortOrder = "asc"
+	SortDesc SortOrder = "desc"
+)
+
+// Sort describes a validated sort column and direction
+type Sort struct {
+	Field  string
+	Column string
+	Order  SortOrder
+}
+
+// NewSort validates a requested sort against the allowed fields.
+// allowed maps API field names to database columns; an empty field selects
+// defaultField and an empty order defaults to ascending.
+func NewSort(field, order string, allowed map[string]string, defaultField string) (Sort, error) {
+	if field == "" {
+		field = defaultField
+	}
+	column, ok := allowed[field]
+	if !ok {
+		return Sort{}, fmt.Errorf("%w: unknown sort field %q", ErrInvalidSort, field)
+	}
+
+	switch SortOrder(strings.ToLower(order)) {
+	case "", SortAsc:
+		return Sort{Field: field, Column: column, Order: SortAsc}, nil
+	case SortDesc:
+		return Sort{Field: field, Column: column, Order: SortDesc}, nil
+	}
+	return Sort{}, fmt.Errorf("%w: unknown sort order %q", ErrInvalidSort, order)
+}
+
+// key identifies the sort so tokens cannot be replayed against a different ordering
+func (s Sort) key() string {
+	return s.Field + ":" + string(s.Order)
+}
+
+// Cursor marks the position of the last row of a page
+type Cursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v,omitempty"`
+	ID    uint   `json:"id"`
+}
+
+// PageSize clamps a requested page size into the allowed range
+func PageSize(requested int) int {
+	if requested <= 0 {
+		return DefaultPageSize
+	}
+	if requested > MaxPageSize {
+		return MaxPageSize
+	}
+	return requested
+}
+
+// EncodeCursor encodes the position of a row as an opaque page token
+func EncodeCursor(sort Sort, value string, id uint) string {
+	data, _ := json.Marshal(Cursor{Sort: sort.key(), Value: value, ID: id})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor decodes a page token produced for the same sort.
+// It returns nil for an empty token.
+func DecodeCursor(token string, sort Sort) (*Cursor, error) {
+	if token == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidPageToken
+	}
+
+	var cursor Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.Sort != sort.key() {
+		return nil, ErrInvalidPageToken
+	}
+	return &cursor, nil
+}
+
+// Apply adds keyset ordering and the cursor condition to a query.
+// value converts the cursor's stored sort value back into a column value.
+func Apply(query *gorm.DB, sort Sort, cursor *Cursor, value func(string) (interface{}, error)) (*gorm.DB, error) {
+	direction, op := "ASC", ">"
+	if sort.Order == SortDesc {
+		direction, op = "DESC", "<"
+	}
+
+	if cursor != nil {
+		if sort.Column == "id" {
+			query = query.Where("id "+op+" ?", cursor.ID)
+		} else {
+			v, err := value(cursor.Value)
+			if err != nil {
+				return nil, ErrInvalidPageToken
+			}
+			query = query.Where(
+				fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?))", sort.Column, op),
+				v, v, cursor.ID,
+			)
+		}
+	}
+
+	if sort.Column == "id" {
+		return query.Order("id " + direction), nil
+	}
+	return query.Order(sort.Column + " " + direction).Order("id " + direction), nil
+}
+
+// FormatTime formats a timestamp for use as a cursor value
+func FormatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// ParseTime parses a timestamp cursor value
+func ParseTime(value string) (interface{}, error) {
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+// ParseString returns a string cursor value unchanged
+func ParseString(value string) (interface{}, error) {
+	return value, nil
+}
+
+// LikePrefix escapes LIKE wildcards and returns a prefix match pattern
+func LikePrefix(prefix string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return replacer.Replace(prefix) + "%"
+}
@@ -0,0 +1,207 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+var orderSorts = map[string]string{
+	"id":         "id",
+	"created_at": "created_at",
+	"total":      "total_minor",
+}
+
+func TestNewSort(t *testing.T) {
+	tests := []struct {
+		field, order string
+		want         Sort
+		wantErr      error
+	}{
+		{"", "", Sort{"created_at", "created_at", SortAsc}, nil},
+		{"total", "DESC", Sort{"total", "total_minor", SortDesc}, nil},
+		{"id", "asc", Sort{"id", "id", SortAsc}, nil},
+		{"total_minor", "", Sort{}, ErrInvalidSort},
+		{"id", "descending", Sort{}, ErrInvalidSort},
+	}
+	for _, tt := range tests {
+		got, err := NewSort(tt.field, tt.order, orderSorts, "created_at")
+		if !errors.Is(err, tt.wantErr) || got != tt.want {
+			t.Errorf("NewSort(%q, %q) = %+v, %v, want %+v, %v", tt.field, tt.order, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestPageSize(t *testing.T) {
+	tests := []struct {
+		requested, want int
+	}{
+		{-1, DefaultPageSize},
+		{0, DefaultPageSize},
+		{1, 1},
+		{MaxPageSize, MaxPageSize},
+		{MaxPageSize + 1, MaxPageSize},
+	}
+	for _, tt := range tests {
+		if got := PageSize(tt.requested); got != tt.want {
+			t.Errorf("PageSize(%d) = %d, want %d", tt.requested, got, tt.want)
+		}
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	sort := Sort{Field: "created_at", Column: "created_at", Order: SortDesc}
+	at := time.Date(2024, 3, 1, 12, 30, 0, 123456789, time.FixedZone("CET", 3600))
+
+	cursor, err := DecodeCursor(EncodeCursor(sort, FormatTime(at), 42), sort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Cursor{Sort: "created_at:desc", Value: "2024-03-01T11:30:00.123456789Z", ID: 42}); *cursor != want {
+		t.Errorf("DecodeCursor = %+v, want %+v", *cursor, want)
+	}
+	value, err := ParseTime(cursor.Value)
+	if err != nil || !value.(time.Time).Equal(at) {
+		t.Errorf("ParseTime(%q) = %v, %v, want %v", cursor.Value, value, err, at)
+	}
+
+	if cursor, err := DecodeCursor("", sort); cursor != nil || err != nil {
+		t.Errorf("DecodeCursor of an empty token = %v, %v, want no cursor", cursor, err)
+	}
+}
+
+func TestDecodeCursorRejects(t *testing.T) {
+	sort := Sort{Field: "created_at", Column: "created_at", Order: SortDesc}
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"other field", EncodeCursor(Sort{Field: "total", Column: "total_minor", Order: SortDesc}, "100", 42)},
+		{"other order", EncodeCursor(Sort{Field: "created_at", Column: "created_at", Order: SortAsc}, "", 42)},
+		{"no sort", encode(`{"v":"2024-03-01T11:30:00Z","id":42}`)},
+		{"padded base64", base64.URLEncoding.EncodeToString([]byte(`{"s":"created_at:desc","id":42}`))},
+		{"standard base64", "eyJzIjoiY3JlYXRlZF9hdDpkZXNjIiwiaWQiOjQyfQ+/"},
+		{"not base64", "not a token!"},
+		{"not JSON", encode("created_at:desc,42")},
+		{"truncated JSON", encode(`{"s":"created_at:desc","id":4`)},
+		{"wrong JSON type", encode(`{"s":"created_at:desc","id":"42"}`)},
+	}
+	for _, tt := range tests {
+		if cursor, err := DecodeCursor(tt.token, sort); err != ErrInvalidPageToken {
+			t.Errorf("%s: DecodeCursor = %+v, %v, want ErrInvalidPageToken", tt.name, cursor, err)
+		}
+	}
+}
+
+// dryRun returns a database that builds statements without executing them
+func dryRun(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.Open("host=localhost"), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name     string
+		sort     Sort
+		cursor   *Cursor
+		wantSQL  string
+		wantVars []interface{}
+	}{
+		{
+			name:     "first page ascending",
+			sort:     Sort{Field: "total", Column: "total_minor", Order: SortAsc},
+			wantSQL:  `SELECT * FROM "orders" ORDER BY total_minor ASC,id ASC`,
+			wantVars: []interface{}{},
+		},
+		{
+			name:     "next page ascending",
+			sort:     Sort{Field: "total", Column: "total_minor", Order: SortAsc},
+			cursor:   &Cursor{Value: "1999", ID: 42},
+			wantSQL:  `SELECT * FROM "orders" WHERE (total_minor > $1 OR (total_minor = $2 AND id > $3)) ORDER BY total_minor ASC,id ASC`,
+			wantVars: []interface{}{"1999", "1999", uint(42)},
+		},
+		{
+			name:     "next page descending",
+			sort:     Sort{Field: "total", Column: "total_minor", Order: SortDesc},
+			cursor:   &Cursor{Value: "1999", ID: 42},
+			wantSQL:  `SELECT * FROM "orders" WHERE (total_minor < $1 OR (total_minor = $2 AND id < $3)) ORDER BY total_minor DESC,id DESC`,
+			wantVars: []interface{}{"1999", "1999", uint(42)},
+		},
+		{
+			name:     "first page by id",
+			sort:     Sort{Field: "id", Column: "id", Order: SortDesc},
+			wantSQL:  `SELECT * FROM "orders" ORDER BY id DESC`,
+			wantVars: []interface{}{},
+		},
+		{
+			name:     "next page by id ascending",
+			sort:     Sort{Field: "id", Column: "id", Order: SortAsc},
+			cursor:   &Cursor{ID: 42},
+			wantSQL:  `SELECT * FROM "orders" WHERE id > $1 ORDER BY id ASC`,
+			wantVars: []interface{}{uint(42)},
+		},
+		{
+			name:     "next page by id descending",
+			sort:     Sort{Field: "id", Column: "id", Order: SortDesc},
+			cursor:   &Cursor{ID: 42},
+			wantSQL:  `SELECT * FROM "orders" WHERE id < $1 ORDER BY id DESC`,
+			wantVars: []interface{}{uint(42)},
+		},
+	}
+
+	type order struct{ ID uint }
+	db := dryRun(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := Apply(db.Model(&order{}), tt.sort, tt.cursor, ParseString)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stmt := query.Find(&[]order{}).Statement
+			if got := stmt.SQL.String(); got != tt.wantSQL {
+				t.Errorf("SQL = %s, want %s", got, tt.wantSQL)
+			}
+			if !reflect.DeepEqual(stmt.Vars, tt.wantVars) {
+				t.Errorf("vars = %#v, want %#v", stmt.Vars, tt.wantVars)
+			}
+		})
+	}
+}
+
+func TestApplyInvalidValue(t *testing.T) {
+	type order struct{ ID uint }
+	sort := Sort{Field: "created_at", Column: "created_at", Order: SortAsc}
+	_, err := Apply(dryRun(t).Model(&order{}), sort, &Cursor{Value: "yesterday", ID: 42}, ParseTime)
+	if err != ErrInvalidPageToken {
+		t.Errorf("Apply = %v, want ErrInvalidPageToken", err)
+	}
+}
+
+func TestLikePrefix(t *testing.T) {
+	tests := []struct {
+		prefix, want string
+	}{
+		{"", `%`},
+		{"alice", `alice%`},
+		{"100%", `100\%%`},
+		{"a_b", `a\_b%`},
+		{`C:\`, `C:\\%`},
+		{`\%_`, `\\\%\_%`},
+	}
+	for _, tt := range tests {
+		if got := LikePrefix(tt.prefix); got != tt.want {
+			t.Errorf("LikePrefix(%q) = %q, want %q", tt.prefix, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package policy
+
+import (
+	"context"
+	"strconv"
+	"strings"
+)
+
+// Identity headers forwarded by the API gateway after token verification. They
+// are only trusted together with a valid HeaderIdentitySignature.
+const (
+	HeaderUserID    = "X-User-ID"
+	HeaderUsername  = "X-Username"
+	HeaderUserRoles = "X-User-Roles"
+)
+
+type principalKey struct{}
+
+// NewContext returns a copy of ctx carrying the principal
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the principal carried by ctx, or nil if there is none
+func FromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
+
+// principalFromValues builds a principal from verified identity values.
+// It returns nil when no valid user ID is present.
+func principalFromValues(userID, username, roles string) *Principal {
+	id, err := strconv.ParseUint(strings.TrimSpace(userID), 10, 64)
+	if err != nil || id == 0 {
+		return nil
+	}
+
+	return &Principal{
+		UserID:   uint(id),
+		Username: username,
+		Roles:    ParseRoles(roles),
+	}
+}
+
+// rolesString joins the principal's roles for transport
+func (p *Principal) rolesString() string {
+	roles := make([]string, len(p.Roles))
+	for i, r := range p.Roles {
+		roles[i] = string(r)
+	}
+	return strings.Join(roles, ",")
+}
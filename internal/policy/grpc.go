@@ -0,0 +1,67 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor reads signed identity metadata into the request
+// context. Unsigned or tampered identities are ignored, so the call is
+// handled as anonymous.
+func UnaryServerInterceptor(signer *Signer) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			p := signer.principal(
+				firstValue(md, HeaderUserID),
+				firstValue(md, HeaderUsername),
+				firstValue(md, HeaderUserRoles),
+				firstValue(md, HeaderIdentitySignature),
+			)
+			if p != nil {
+				ctx = NewContext(ctx, p)
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+// OutgoingContext propagates the principal of ctx to outgoing gRPC calls,
+// signed so the callee can trust it
+func (s *Signer) OutgoingContext(ctx context.Context) context.Context {
+	p := FromContext(ctx)
+	if p == nil {
+		return ctx
+	}
+
+	userID := strconv.FormatUint(uint64(p.UserID), 10)
+	roles := p.rolesString()
+	return metadata.AppendToOutgoingContext(ctx,
+		strings.ToLower(HeaderUserID), userID,
+		strings.ToLower(HeaderUsername), p.Username,
+		strings.ToLower(HeaderUserRoles), roles,
+		strings.ToLower(HeaderIdentitySignature), s.Sign(userID, p.Username, roles),
+	)
+}
+
+// Status converts a policy error into a gRPC status error
+func Status(err error) error {
+	if errors.Is(err, ErrUnauthenticated) {
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+	return status.Error(codes.PermissionDenied, err.Error())
+}
+
+// firstValue returns the first metadata value for a header name
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
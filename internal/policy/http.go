@@ -0,0 +1,51 @@
+package policy
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Middleware reads the identity headers signed by the gateway into the
+// request context. Unsigned or tampered identities are ignored, so the
+// request is handled as anonymous.
+func Middleware(signer *Signer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := signer.principal(
+			c.GetHeader(HeaderUserID),
+			c.GetHeader(HeaderUsername),
+			c.GetHeader(HeaderUserRoles),
+			c.GetHeader(HeaderIdentitySignature),
+		)
+		if p != nil {
+			c.Request = c.Request.WithContext(NewContext(c.Request.Context(), p))
+		}
+		c.Next()
+	}
+}
+
+// RequireAuthenticated rejects requests without a principal
+func RequireAuthenticated() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if FromContext(c.Request.Context()) == nil {
+			AbortWithError(c, ErrUnauthenticated)
+			return
+		}
+		c.Next()
+	}
+}
+
+// FromGin returns the principal of a Gin request
+func FromGin(c *gin.Context) *Principal {
+	return FromContext(c.Request.Context())
+}
+
+// AbortWithError writes a 401 or 403 response for a policy error
+func AbortWithError(c *gin.Context, err error) {
+	if errors.Is(err, ErrUnauthenticated) {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+}
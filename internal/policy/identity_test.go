@@ -0,0 +1,156 @@
+package policy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// newTestSigner returns a signer whose clock is at now
+func newTestSigner(secret string, now time.Time) *Signer {
+	s := NewSigner(Config{Secret: secret, MaxAge: 5 * time.Minute})
+	s.now = func() time.Time { return now }
+	return s
+}
+
+func TestSignerVerify(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	signer := newTestSigner("test-secret", now)
+	signature := signer.Sign("3", "alice", "customer")
+	ts := strconv.FormatInt(now.Unix(), 10)
+	_, mac, _ := strings.Cut(signature, ".")
+
+	signedAt := func(at time.Time) string {
+		return newTestSigner("test-secret", at).Sign("3", "alice", "customer")
+	}
+
+	tests := []struct {
+		name                    string
+		userID, username, roles string
+		signature               string
+		want                    bool
+	}{
+		{"valid", "3", "alice", "customer", signature, true},
+		{"within max age", "3", "alice", "customer", signedAt(now.Add(-5 * time.Minute)), true},
+		{"older than max age", "3", "alice", "customer", signedAt(now.Add(-5*time.Minute - time.Second)), false},
+		{"within clock skew", "3", "alice", "customer", signedAt(now.Add(5 * time.Minute)), true},
+		{"too far in the future", "3", "alice", "customer", signedAt(now.Add(5*time.Minute + time.Second)), false},
+		{"tampered user ID", "1", "alice", "customer", signature, false},
+		{"tampered username", "3", "root", "customer", signature, false},
+		{"tampered roles", "3", "alice", "admin", signature, false},
+		{"added role", "3", "alice", "customer,admin", signature, false},
+		{"tampered timestamp", "3", "alice", "customer", strconv.FormatInt(now.Unix()-1, 10) + "." + mac, false},
+		{"wrong secret", "3", "alice", "customer", newTestSigner("other-secret", now).Sign("3", "alice", "customer"), false},
+		{"no separator", "3", "alice", "customer", ts + mac, false},
+		{"non-numeric timestamp", "3", "alice", "customer", "now." + mac, false},
+		{"empty MAC", "3", "alice", "customer", ts + ".", false},
+		{"empty signature", "3", "alice", "customer", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := signer.Verify(tt.userID, tt.username, tt.roles, tt.signature); got != tt.want {
+				t.Errorf("Verify(%q, %q, %q, %q) = %v, want %v", tt.userID, tt.username, tt.roles, tt.signature, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignerPrincipal(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	signer := newTestSigner("test-secret", now)
+	signature := signer.Sign("3", "alice", "customer,support")
+
+	p := signer.principal("3", "alice", "customer,support", signature)
+	if p == nil || p.UserID != 3 || p.Username != "alice" || !p.HasRole(RoleSupport) {
+		t.Fatalf("principal = %+v, want alice with her roles", p)
+	}
+
+	tests := []struct {
+		name                               string
+		userID, username, roles, signature string
+	}{
+		{"bad signature", "3", "alice", "admin", signature},
+		{"wrong secret", "3", "alice", "customer,support", newTestSigner("other-secret", now).Sign("3", "alice", "customer,support")},
+		{"unsigned", "3", "alice", "customer,support", ""},
+		{"no identity", "", "", "", ""},
+		{"signed invalid user ID", "0", "alice", "customer", signer.Sign("0", "alice", "customer")},
+	}
+	for _, tt := range tests {
+		if p := signer.principal(tt.userID, tt.username, tt.roles, tt.signature); p != nil {
+			t.Errorf("%s: principal = %+v, want nil", tt.name, p)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	now := time.Now()
+	signer := newTestSigner("test-secret", now)
+
+	tests := []struct {
+		name      string
+		signature string
+		want      *Principal
+	}{
+		{"signed", signer.Sign("3", "alice", "customer"), &Principal{UserID: 3, Username: "alice", Roles: []Role{RoleCustomer}}},
+		{"forged", newTestSigner("other-secret", now).Sign("3", "alice", "customer"), nil},
+		{"unsigned", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *Principal
+			r := gin.New()
+			r.Use(Middleware(signer))
+			r.GET("/", func(c *gin.Context) { got = FromGin(c) })
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(HeaderUserID, "3")
+			req.Header.Set(HeaderUsername, "alice")
+			req.Header.Set(HeaderUserRoles, "customer")
+			if tt.signature != "" {
+				req.Header.Set(HeaderIdentitySignature, tt.signature)
+			}
+			r.ServeHTTP(httptest.NewRecorder(), req)
+
+			if (got == nil) != (tt.want == nil) || (got != nil && (got.UserID != tt.want.UserID || got.Username != tt.want.Username || !got.HasRole(RoleCustomer))) {
+				t.Errorf("principal = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOutgoingContextRoundTrip(t *testing.T) {
+	signer := newTestSigner("test-secret", time.Now())
+	sent := &Principal{UserID: 3, Username: "alice", Roles: []Role{RoleCustomer, RoleSupport}}
+
+	// The metadata the caller sends arrives as the callee's incoming metadata
+	out, _ := metadata.FromOutgoingContext(signer.OutgoingContext(NewContext(context.Background(), sent)))
+	incoming := func(md metadata.MD) context.Context { return metadata.NewIncomingContext(context.Background(), md) }
+	received := func(ctx context.Context) *Principal {
+		var got *Principal
+		UnaryServerInterceptor(signer)(ctx, nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			got = FromContext(ctx)
+			return nil, nil
+		})
+		return got
+	}
+
+	got := received(incoming(out))
+	if got == nil || got.UserID != sent.UserID || got.Username != sent.Username || !got.HasRole(RoleSupport) {
+		t.Fatalf("received %+v, want %+v", got, sent)
+	}
+
+	tampered := out.Copy()
+	tampered.Set(strings.ToLower(HeaderUserRoles), "admin")
+	if got := received(incoming(tampered)); got != nil {
+		t.Errorf("received %+v with tampered roles, want no principal", got)
+	}
+}
@@ -0,0 +1,184 @@
+package policy
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrUnauthenticated = errors.New("authentication required")
+	ErrForbidden       = errors.New("permission denied")
+)
+
+// Role represents a user's role in the system
+type Role string
+
+const (
+	RoleAdmin    Role = "admin"
+	RoleSupport  Role = "support"
+	RoleCustomer Role = "customer"
+)
+
+// Valid reports whether the role is one of the known roles
+func (r Role) Valid() bool {
+	switch r {
+	case RoleAdmin, RoleSupport, RoleCustomer:
+		return true
+	}
+	return false
+}
+
+// ParseRoles parses a comma separated role list, ignoring unknown roles
+func ParseRoles(value string) []Role {
+	var roles []Role
+	for _, part := range strings.Split(value, ",") {
+		role := Role(strings.ToLower(strings.TrimSpace(part)))
+		if role.Valid() {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// Principal represents the authenticated caller of a request
+type Principal struct {
+	UserID   uint
+	Username string
+	Roles    []Role
+}
+
+// HasRole reports whether the principal holds any of the given roles
+func (p *Principal) HasRole(roles ...Role) bool {
+	if p == nil {
+		return false
+	}
+	for _, have := range p.Roles {
+		for _, want := range roles {
+			if have == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsUser reports whether the principal is the given user
+func (p *Principal) IsUser(userID uint) bool {
+	return p != nil && p.UserID != 0 && p.UserID == userID
+}
+
+// authenticated returns ErrUnauthenticated for a missing principal
+func authenticated(p *Principal) error {
+	if p == nil {
+		return ErrUnauthenticated
+	}
+	return nil
+}
+
+// allow returns nil when ok is true and ErrForbidden otherwise
+func allow(ok bool) error {
+	if !ok {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// CanReadUser allows staff and the user themselves to read a user profile
+func CanReadUser(p *Principal, userID uint) error {
+	if err := authenticated(p); err != nil {
+		return err
+	}
+	return allow(p.HasRole(RoleAdmin, RoleSupport) || p.IsUser(userID))
+}
+
+// CanLookupUserByEmail allows staff to find users by email. It is checked
+// before the lookup, so callers cannot learn which addresses have accounts.
+func CanLookupUserByEmail(p *Principal) error {
+	if err := authenticated(p); err != nil {
+		return err
+	}
+	return allow(p.HasRole(RoleAdmin, RoleSupport))
+}
+
+// CanLookupUserByUsername allows staff to find users by username and users
+// to find themselves. It is checked before the lookup, so callers cannot
+// learn which usernames are taken.
+func CanLookupUserByUsername(p *Principal, username string) error {
+	if err := authenticated(p); err != nil {
+		return err
+	}
+	return allow(p.HasRole(RoleAdmin, RoleSupport) || (p.Username != "" && strings.EqualFold(p.Username, username)))
+}
+
+// CanListUsers allows staff to list all users
+func CanListUsers(p *Principal) error {
+	if err := authenticated(p); err != nil {
+		return err
+	}
+	return allow(p.HasRole(RoleAdmin, RoleSupport))
+}
+
+// CanUpdateUser allows admins and the user themselves to update a user profile
+func CanUpdateUser(p *Principal, userID uint) error {
+	if err := authenticated(p); err != nil {
+		return err
+	}
+	return allow(p.HasRole(RoleAdmin) || p.IsUser(userID))
+}
+
+// CanAssignRole allows only admins to change a user's role
+func CanAssignRole(p *Principal) error {
+	if err := authenticated(p); err != nil {
+		return err
+	}
+	return allow(p.HasRole(RoleAdmin))
+}
+
+// CanDeleteUser allows only admins to delete users
+func CanDeleteUser(p *Principal) error {
+	if err := authenticated(p); err != nil {
+		return err
+	}
+	return allow(p.HasRole(RoleAdmin))
+}
+
+// CanCreateOrder allows admins to order for anyone and users to order for themselves
+func CanCreateOrder(p *Principal, ownerID uint) error {
+	if err := authenticated(p); err != nil {
+		return err
+	}
+	return allow(p.HasRole(RoleAdmin) || p.IsUser(ownerID))
+}
+
+// CanReadOrder allows staff and the order owner to read orders
+func CanReadOrder(p *Principal, ownerID uint) error {
+	if err := authenticated(p); err != nil {
+		return err
+	}
+	return allow(p.HasRole(RoleAdmin, RoleSupport) || p.IsUser(ownerID))
+}
+
+// CanListOrders allows staff to list all orders
+func CanListOrders(p *Principal) error {
+	if err := authenticated(p); err != nil {
+		return err
+	}
+	return allow(p.HasRole(RoleAdmin, RoleSupport))
+}
+
+// CanUpdateOrderStatus allows staff to move orders through any status,
+// while owners may only cancel their own orders
+func CanUpdateOrderStatus(p *Principal, ownerID uint, cancelling bool) error {
+	if err := authenticated(p); err != nil {
+		return err
+	}
+	return allow(p.HasRole(RoleAdmin, RoleSupport) || (cancelling && p.IsUser(ownerID)))
+}
+
+// CanDeleteOrder allows only admins to delete orders
+func CanDeleteOrder(p *Principal) error {
+	if err := authenticated(p); err != nil {
+		return err
+	}
+	return allow(p.HasRole(RoleAdmin))
+}
@@ -0,0 +1,81 @@
+package policy
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+var (
+	admin    = &Principal{UserID: 1, Username: "root", Roles: []Role{RoleAdmin}}
+	support  = &Principal{UserID: 2, Username: "helpdesk", Roles: []Role{RoleSupport}}
+	owner    = &Principal{UserID: 3, Username: "alice", Roles: []Role{RoleCustomer}}
+	other    = &Principal{UserID: 4, Username: "bob", Roles: []Role{RoleCustomer}}
+	noRoles  = &Principal{UserID: 3, Username: "alice"}
+	noUserID = &Principal{Username: "ghost", Roles: []Role{RoleCustomer}}
+)
+
+// ownerID is the user that owns the resources checked in the tests
+const ownerID = 3
+
+func TestPolicies(t *testing.T) {
+	tests := []struct {
+		name  string
+		check func(p *Principal) error
+		// allowed lists the principals the policy lets through; nil is
+		// always rejected as unauthenticated and everyone else as forbidden
+		allowed []*Principal
+	}{
+		{"CanReadUser", func(p *Principal) error { return CanReadUser(p, ownerID) }, []*Principal{admin, support, owner, noRoles}},
+		{"CanLookupUserByEmail", CanLookupUserByEmail, []*Principal{admin, support}},
+		{"CanLookupUserByUsername", func(p *Principal) error { return CanLookupUserByUsername(p, "Alice") }, []*Principal{admin, support, owner, noRoles}},
+		{"CanListUsers", CanListUsers, []*Principal{admin, support}},
+		{"CanUpdateUser", func(p *Principal) error { return CanUpdateUser(p, ownerID) }, []*Principal{admin, owner, noRoles}},
+		{"CanAssignRole", CanAssignRole, []*Principal{admin}},
+		{"CanDeleteUser", CanDeleteUser, []*Principal{admin}},
+		{"CanCreateOrder", func(p *Principal) error { return CanCreateOrder(p, ownerID) }, []*Principal{admin, owner, noRoles}},
+		{"CanReadOrder", func(p *Principal) error { return CanReadOrder(p, ownerID) }, []*Principal{admin, support, owner, noRoles}},
+		{"CanListOrders", CanListOrders, []*Principal{admin, support}},
+		{"CanUpdateOrderStatus", func(p *Principal) error { return CanUpdateOrderStatus(p, ownerID, false) }, []*Principal{admin, support}},
+		{"CanUpdateOrderStatus cancelling", func(p *Principal) error { return CanUpdateOrderStatus(p, ownerID, true) }, []*Principal{admin, support, owner, noRoles}},
+		{"CanDeleteOrder", CanDeleteOrder, []*Principal{admin}},
+	}
+
+	principals := []*Principal{admin, support, owner, other, noRoles, noUserID}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.check(nil); !errors.Is(err, ErrUnauthenticated) {
+				t.Errorf("nil principal: %v, want ErrUnauthenticated", err)
+			}
+			for _, p := range principals {
+				want := ErrForbidden
+				for _, a := range tt.allowed {
+					if p == a {
+						want = nil
+					}
+				}
+				if err := tt.check(p); !errors.Is(err, want) {
+					t.Errorf("%s (%d, %v): %v, want %v", p.Username, p.UserID, p.Roles, err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestIsUser(t *testing.T) {
+	var nilPrincipal *Principal
+	if nilPrincipal.IsUser(0) || noUserID.IsUser(0) {
+		t.Error("a principal without user ID matched user 0")
+	}
+	if !owner.IsUser(ownerID) || owner.IsUser(ownerID+1) {
+		t.Error("IsUser did not match by user ID")
+	}
+}
+
+func TestParseRoles(t *testing.T) {
+	got := ParseRoles(" Admin,customer,,superuser,SUPPORT ")
+	want := []Role{RoleAdmin, RoleCustomer, RoleSupport}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseRoles = %v, want %v", got, want)
+	}
+}
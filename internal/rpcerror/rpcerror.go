@@ -0,0 +1,162 @@
+package rpcerror
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"log/slog"
+	"net"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5/pgconn"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+// Domain identifies bweng services in google.rpc.ErrorInfo details
+const Domain = "bweng"
+
+// Reasons reported in google.rpc.ErrorInfo details
+const (
+	ReasonInvalidArgument          = "INVALID_ARGUMENT"
+	ReasonUserNotFound             = "USER_NOT_FOUND"
+	ReasonUserAlreadyExists        = "USER_ALREADY_EXISTS"
+	ReasonOrderNotFound            = "ORDER_NOT_FOUND"
+	ReasonInvalidStatusTransition  = "INVALID_STATUS_TRANSITION"
+	ReasonInvalidCredentials       = "INVALID_CREDENTIALS"
+	ReasonInvalidToken             = "INVALID_TOKEN"
+	ReasonIdempotencyKeyReused     = "IDEMPOTENCY_KEY_REUSED"
+	ReasonIdempotencyKeyInProgress = "IDEMPOTENCY_KEY_IN_PROGRESS"
+	ReasonDependencyUnavailable    = "DEPENDENCY_UNAVAILABLE"
+	ReasonInternal                 = "INTERNAL"
+)
+
+// New returns a status error carrying a google.rpc.ErrorInfo detail
+func New(code codes.Code, reason, message string, metadata map[string]string) error {
+	return withDetails(status.New(code, message), &errdetails.ErrorInfo{
+		Reason:   reason,
+		Domain:   Domain,
+		Metadata: metadata,
+	})
+}
+
+// InvalidArgument returns an InvalidArgument status error describing the
+// offending request fields in a google.rpc.BadRequest detail
+func InvalidArgument(message string, violations ...*errdetails.BadRequest_FieldViolation) error {
+	st := status.New(codes.InvalidArgument, message)
+	info := &errdetails.ErrorInfo{Reason: ReasonInvalidArgument, Domain: Domain}
+	if len(violations) == 0 {
+		return withDetails(st, info)
+	}
+	return withDetails(st, info, &errdetails.BadRequest{FieldViolations: violations})
+}
+
+// Violation describes why a single request field is invalid
+func Violation(field, description string) *errdetails.BadRequest_FieldViolation {
+	return &errdetails.BadRequest_FieldViolation{
+		Field:       field,
+		Description: description,
+	}
+}
+
+// Validate checks a request against its binding tags, as the HTTP handlers
+// do, and returns an InvalidArgument error listing every failing field
+func Validate(req interface{}) error {
+	err := binding.Validator.ValidateStruct(req)
+	if err == nil {
+		return nil
+	}
+
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return InvalidArgument(err.Error())
+	}
+
+	violations := make([]*errdetails.BadRequest_FieldViolation, len(validationErrs))
+	for i, fieldErr := range validationErrs {
+		violations[i] = Violation(jsonFieldPath(req, fieldErr), fieldDescription(fieldErr))
+	}
+	return InvalidArgument("invalid request", violations...)
+}
+
+// Internal converts an unexpected error. Cancelled and timed out contexts
+// keep their codes, lost database or network connections become
+// Unavailable, and anything else becomes Internal without leaking details.
+func Internal(err error) error {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err).Err()
+	}
+
+	var netErr net.Error
+	var connectErr *pgconn.ConnectError
+	if errors.Is(err, driver.ErrBadConn) || errors.As(err, &netErr) || errors.As(err, &connectErr) {
+		slog.Error("Dependency unavailable", "error", err)
+		return New(codes.Unavailable, ReasonDependencyUnavailable, "service temporarily unavailable", nil)
+	}
+
+	slog.Error("Internal error", "error", err)
+	return New(codes.Internal, ReasonInternal, "internal error", nil)
+}
+
+// Reason returns the google.rpc.ErrorInfo reason of a status error, if any
+func Reason(err error) string {
+	st, ok := status.FromError(err)
+	if !ok {
+		return ""
+	}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && info.Domain == Domain {
+			return info.Reason
+		}
+	}
+	return ""
+}
+
+// withDetails attaches details to a status, falling back to the bare status
+func withDetails(st *status.Status, details ...protoadapt.MessageV1) error {
+	detailed, err := st.WithDetails(details...)
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
+
+// jsonFieldPath returns the JSON name of the field that failed validation,
+// including its parents, e.g. "items[0].quantity"
+func jsonFieldPath(req interface{}, fieldErr validator.FieldError) string {
+	t := reflect.TypeOf(req)
+	parts := strings.Split(fieldErr.StructNamespace(), ".")[1:]
+	path := make([]string, 0, len(parts))
+	for _, part := range parts {
+		name, index, _ := strings.Cut(part, "[")
+		for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+			t = t.Elem()
+		}
+
+		jsonName := name
+		if field, ok := t.FieldByName(name); ok {
+			if tag, _, _ := strings.Cut(field.Tag.Get("json"), ","); tag != "" && tag != "-" {
+				jsonName = tag
+			}
+			t = field.Type
+		}
+		if index != "" {
+			jsonName += "[" + index
+		}
+		path = append(path, jsonName)
+	}
+	return strings.Join(path, ".")
+}
+
+// fieldDescription describes a failed validation rule
+func fieldDescription(fieldErr validator.FieldError) string {
+	if fieldErr.Param() == "" {
+		return "failed " + fieldErr.Tag() + " validation"
+	}
+	return "failed " + fieldErr.Tag() + "=" + fieldErr.Param() + " validation"
+}
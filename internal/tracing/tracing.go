@@ -0,0 +1,130 @@
+// Package tracing sets up OpenTelemetry tracing with W3C trace context
+// propagation and instruments Gin, gRPC and GORM with it.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+)
+
+// Span exporters
+const (
+	// ExporterNone records no spans but still propagates incoming trace context
+	ExporterNone = "none"
+	// ExporterOTLP sends spans to an OpenTelemetry collector over gRPC
+	ExporterOTLP = "otlp"
+	// ExporterFile appends spans as JSON to a local file for development
+	ExporterFile = "file"
+)
+
+// flushTimeout bounds exporting the buffered spans on shutdown
+const flushTimeout = 5 * time.Second
+
+// Config holds the span exporter settings
+type Config struct {
+	Exporter string `yaml:"exporter" env:"TRACING_EXPORTER" flag:"tracing-exporter" usage:"Span exporter: none, otlp or file" validate:"oneof=none otlp file"`
+	// Endpoint is the collector URL, e.g. http://otel-collector:4317
+	Endpoint string `yaml:"endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT" flag:"otlp-endpoint" usage:"OTLP gRPC collector URL" validate:"required_if=Exporter otlp"`
+	File     string `yaml:"file" env:"TRACING_FILE" flag:"tracing-file" usage:"File the file exporter appends spans to" validate:"required_if=Exporter file"`
+	// SampleRatio is the fraction of new traces recorded. Requests that
+	// arrive with a sampled traceparent are always recorded.
+	SampleRatio float64 `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO" flag:"tracing-sample-ratio" usage:"Fraction of new traces that are recorded" validate:"gte=0,lte=1"`
+}
+
+// DefaultConfig returns the tracing defaults. No spans are exported unless
+// an exporter is configured.
+func DefaultConfig() Config {
+	return Config{
+		Exporter:    ExporterNone,
+		Endpoint:    "http://localhost:4317",
+		File:        "traces.jsonl",
+		SampleRatio: 1,
+	}
+}
+
+// Provider owns the tracer provider of a process and flushes it on Close
+type Provider struct {
+	tp   *sdktrace.TracerProvider
+	file *os.File
+}
+
+// Setup installs the W3C trace context propagator and, unless the exporter
+// is none, a tracer provider exporting the spans of the named service
+func Setup(ctx context.Context, service string, cfg Config) (*Provider, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	p := &Provider{}
+	var exporter sdktrace.SpanExporter
+	switch cfg.Exporter {
+	case ExporterNone:
+		return p, nil
+	case ExporterOTLP:
+		exp, err := otlptracegrpc.New(ctx, otlptracegrpc.WithEndpointURL(cfg.Endpoint))
+		if err != nil {
+			return nil, fmt.Errorf("tracing: create OTLP exporter: %w", err)
+		}
+		exporter = exp
+	case ExporterFile:
+		f, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("tracing: %w", err)
+		}
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("tracing: create file exporter: %w", err)
+		}
+		p.file = f
+		exporter = exp
+	default:
+		return nil, fmt.Errorf("tracing: unknown exporter %q", cfg.Exporter)
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithSchemaURL(semconv.SchemaURL),
+		resource.WithAttributes(semconv.ServiceName(service)),
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+		// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES take precedence
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("tracing: %w", err)
+	}
+
+	p.tp = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(p.tp)
+	return p, nil
+}
+
+// Close exports the buffered spans and stops the exporter
+func (p *Provider) Close() error {
+	var err error
+	if p.tp != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+		defer cancel()
+		err = p.tp.Shutdown(ctx)
+	}
+	if p.file != nil {
+		err = errors.Join(err, p.file.Close())
+	}
+	return err
+}
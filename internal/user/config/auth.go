@@ -0,0 +1,25 @@
+package config
+
+import (
+	"time"
+)
+
+// developmentSecret signs tokens when JWT_SECRET is not set
+const developmentSecret = "bweng-development-secret"
+
+// AuthConfig holds token signing configuration
+type AuthConfig struct {
+	JWTSecret       string        `yaml:"jwt_secret" env:"JWT_SECRET"`
+	Issuer          string        `yaml:"issuer" env:"JWT_ISSUER" flag:"jwt-issuer" usage:"Token issuer" validate:"required"`
+	AccessTokenTTL  time.Duration `yaml:"access_ttl" env:"JWT_ACCESS_TTL" flag:"jwt-access-ttl" usage:"Access token lifetime" validate:"gt=0"`
+	RefreshTokenTTL time.Duration `yaml:"refresh_ttl" env:"JWT_REFRESH_TTL" flag:"jwt-refresh-ttl" usage:"Refresh token lifetime" validate:"gtfield=AccessTokenTTL"`
+}
+
+// defaultAuthConfig returns the token signing defaults
+func defaultAuthConfig() AuthConfig {
+	return AuthConfig{
+		Issuer:          "bweng-user-service",
+		AccessTokenTTL:  15 * time.Minute,
+		RefreshTokenTTL: 7 * 24 * time.Hour,
+	}
+}
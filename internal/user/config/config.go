@@ -0,0 +1,75 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+
+	loader "bweng/internal/config"
+	"bweng/internal/health"
+	"bweng/internal/lifecycle"
+	"bweng/internal/logging"
+	"bweng/internal/migrate"
+	"bweng/internal/outbox"
+	"bweng/internal/policy"
+	"bweng/internal/tracing"
+)
+
+// Config is the user service configuration
+type Config struct {
+	HTTPPort   int              `yaml:"http_port" env:"USER_SERVICE_PORT" flag:"http-port" usage:"HTTP listen port" validate:"min=1,max=65535"`
+	GRPCPort   int              `yaml:"grpc_port" env:"GRPC_PORT" flag:"grpc-port" usage:"gRPC listen port" validate:"min=1,max=65535,nefield=HTTPPort"`
+	Database   DatabaseConfig   `yaml:"database"`
+	Auth       AuthConfig       `yaml:"auth"`
+	Identity   policy.Config    `yaml:"identity"`
+	Outbox     outbox.Config    `yaml:"outbox"`
+	Shutdown   lifecycle.Config `yaml:"shutdown"`
+	Health     health.Config    `yaml:"health"`
+	Tracing    tracing.Config   `yaml:"tracing"`
+	Log        logging.Config   `yaml:"log"`
+	Migrations migrate.Config   `yaml:"migrations"`
+}
+
+// Default returns the configuration for local development
+func Default() *Config {
+	return &Config{
+		HTTPPort:   8080,
+		GRPCPort:   50051,
+		Database:   defaultDatabaseConfig(),
+		Auth:       defaultAuthConfig(),
+		Identity:   policy.DefaultConfig(),
+		Outbox:     outbox.DefaultConfig(),
+		Shutdown:   lifecycle.DefaultConfig(),
+		Health:     health.DefaultConfig(),
+		Tracing:    tracing.DefaultConfig(),
+		Log:        logging.DefaultConfig(),
+		Migrations: migrate.DefaultConfig(),
+	}
+}
+
+// Load loads the configuration from the defaults, an optional YAML file,
+// the environment and the command line arguments. The arguments left after
+// the flags select a subcommand such as migrate.
+func Load(args []string) (*Config, []string, error) {
+	cfg := Default()
+	rest, err := loader.Load("user-service", args, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if cfg.Auth.JWTSecret == "" {
+		slog.Warn("JWT_SECRET is not set, using insecure development secret")
+		cfg.Auth.JWTSecret = developmentSecret
+	}
+
+	return cfg, rest, nil
+}
+
+// HTTPAddr returns the HTTP listen address
+func (c *Config) HTTPAddr() string {
+	return fmt.Sprintf(":%d", c.HTTPPort)
+}
+
+// GRPCAddr returns the gRPC listen address
+func (c *Config) GRPCAddr() string {
+	return fmt.Sprintf(":%d", c.GRPCPort)
+}
@@ -2,33 +2,37 @@ package config
 
 import (
 	"fmt"
-	"log"
-	"os"
+	"log/slog"
+	"time"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
+
+	"bweng/internal/logging"
 )
 
 // DatabaseConfig holds database configuration
 type DatabaseConfig struct {
-	Host     string
-	Port     string
-	User     string
-	Password string
-	DBName   string
-	SSLMode  string
+	Host     string `yaml:"host" env:"DB_HOST" flag:"db-host" usage:"PostgreSQL host" validate:"required"`
+	Port     string `yaml:"port" env:"DB_PORT" flag:"db-port" usage:"PostgreSQL port" validate:"required,numeric"`
+	User     string `yaml:"user" env:"DB_USER" flag:"db-user" usage:"Database username" validate:"required"`
+	Password string `yaml:"password" env:"DB_PASSWORD"`
+	DBName   string `yaml:"name" env:"DB_NAME" flag:"db-name" usage:"Database name" validate:"required"`
+	SSLMode  string `yaml:"sslmode" env:"DB_SSLMODE" flag:"db-sslmode" usage:"PostgreSQL sslmode" validate:"oneof=disable allow prefer require verify-ca verify-full"`
+	// SlowQueryThreshold is the latency above which statements are logged
+	SlowQueryThreshold time.Duration `yaml:"slow_query_threshold" env:"DB_SLOW_QUERY_THRESHOLD" flag:"db-slow-query-threshold" usage:"Log statements slower than this at warn level" validate:"gte=0"`
 }
 
-// NewDatabaseConfig creates a new database configuration
-func NewDatabaseConfig() *DatabaseConfig {
-	return &DatabaseConfig{
-		Host:     getEnv("DB_HOST", "localhost"),
-		Port:     getEnv("DB_PORT", "5432"),
-		User:     getEnv("DB_USER", "postgres"),
-		Password: getEnv("DB_PASSWORD", "postgres"),
-		DBName:   getEnv("DB_NAME", "bweng_user_db"),
-		SSLMode:  getEnv("DB_SSLMODE", "disable"),
+// defaultDatabaseConfig returns the database configuration for local development
+func defaultDatabaseConfig() DatabaseConfig {
+	return DatabaseConfig{
+		Host:               "localhost",
+		Port:               "5432",
+		User:               "postgres",
+		Password:           "postgres",
+		DBName:             "bweng_user_db",
+		SSLMode:            "disable",
+		SlowQueryThreshold: 200 * time.Millisecond,
 	}
 }
 
@@ -38,20 +42,12 @@ func (c *DatabaseConfig) Connect() (*gorm.DB, error) {
 		c.Host, c.Port, c.User, c.Password, c.DBName, c.SSLMode)
 
 	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
+		Logger: logging.NewGORMLogger(c.SlowQueryThreshold),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
-	log.Println("Database connected successfully")
+	slog.Info("Database connected", "host", c.Host, "database", c.DBName)
 	return db, nil
 }
-
-// getEnv gets environment variable with fallback
-func getEnv(key, fallback string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
-	}
-	return fallback
-} 
\ No newline at end of file
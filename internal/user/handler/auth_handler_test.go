@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+
+	"bweng/internal/dbtest"
+	"bweng/internal/user/config"
+	"bweng/internal/user/migrations"
+	"bweng/internal/user/model"
+	"bweng/internal/user/repository"
+	"bweng/internal/user/service"
+)
+
+// post sends a JSON body to the router and returns the recorded response
+func post(r http.Handler, path string, body interface{}) *httptest.ResponseRecorder {
+	payload, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestConcurrentRefresh(t *testing.T) {
+	db := dbtest.Open(t, migrations.FS)
+	userRepo := repository.NewUserRepository(db)
+	hash, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := userRepo.Create(&model.User{Username: "alice", Email: "alice@example.com", FirstName: "Alice", LastName: "Liddell", PasswordHash: string(hash)}); err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	h := NewAuthHandler(service.NewAuthService(userRepo, repository.NewTokenRepository(db), &config.AuthConfig{
+		JWTSecret:       "test-secret",
+		Issuer:          "bweng-test",
+		AccessTokenTTL:  15 * time.Minute,
+		RefreshTokenTTL: time.Hour,
+	}))
+	r := gin.New()
+	r.POST("/auth/login", h.Login)
+	r.POST("/auth/refresh", h.Refresh)
+
+	w := post(r, "/auth/login", model.LoginRequest{Login: "alice", Password: "correct horse"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("login: %d %s", w.Code, w.Body)
+	}
+	var tokens model.TokenResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &tokens); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every refresh with the same token but one is rejected as unauthorized,
+	// however the requests interleave
+	const clients = 8
+	codes := make([]int, clients)
+	var wg sync.WaitGroup
+	for i := range codes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			codes[i] = post(r, "/auth/refresh", model.RefreshTokenRequest{RefreshToken: tokens.RefreshToken}).Code
+		}(i)
+	}
+	wg.Wait()
+
+	ok := 0
+	for _, code := range codes {
+		switch code {
+		case http.StatusOK:
+			ok++
+		case http.StatusUnauthorized:
+		default:
+			t.Errorf("refresh answered %d, want 200 or 401", code)
+		}
+	}
+	if ok != 1 {
+		t.Errorf("%d refreshes succeeded, want exactly one (%v)", ok, codes)
+	}
+}
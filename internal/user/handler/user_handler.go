@@ -1,10 +1,13 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"bweng/internal/pagination"
+	"bweng/internal/policy"
 	"bweng/internal/user/model"
 	"bweng/internal/user/service"
 )
@@ -61,6 +64,9 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 // @Param id path int true "User ID"
 // @Success 200 {object} model.UserResponse
 // @Failure 404 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Security ApiKeyAuth
 // @Router /users/{id} [get]
 func (h *UserHandler) GetUserByID(c *gin.Context) {
 	idStr := c.Param("id")
@@ -70,7 +76,12 @@ func (h *UserHandler) GetUserByID(c *gin.Context) {
 		return
 	}
 
-	user, err := h.userService.GetUserByID(uint(id))
+	if err := policy.CanReadUser(policy.FromGin(c), uint(id)); err != nil {
+		policy.AbortWithError(c, err)
+		return
+	}
+
+	user, err := h.userService.GetUserByID(c.Request.Context(), uint(id))
 	if err != nil {
 		if err == service.ErrUserNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
@@ -85,13 +96,16 @@ func (h *UserHandler) GetUserByID(c *gin.Context) {
 
 // GetUserByEmail godoc
 // @Summary Get user by email
-// @Description Retrieve a user by their email address
+// @Description Retrieve a user by their email address; staff only
 // @Tags users
 // @Accept json
 // @Produce json
 // @Param email query string true "User email"
 // @Success 200 {object} model.UserResponse
 // @Failure 404 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Security ApiKeyAuth
 // @Router /users/email [get]
 func (h *UserHandler) GetUserByEmail(c *gin.Context) {
 	email := c.Query("email")
@@ -100,7 +114,12 @@ func (h *UserHandler) GetUserByEmail(c *gin.Context) {
 		return
 	}
 
-	user, err := h.userService.GetUserByEmail(email)
+	if err := policy.CanLookupUserByEmail(policy.FromGin(c)); err != nil {
+		policy.AbortWithError(c, err)
+		return
+	}
+
+	user, err := h.userService.GetUserByEmail(c.Request.Context(), email)
 	if err != nil {
 		if err == service.ErrUserNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
@@ -115,13 +134,16 @@ func (h *UserHandler) GetUserByEmail(c *gin.Context) {
 
 // GetUserByUsername godoc
 // @Summary Get user by username
-// @Description Retrieve a user by their username
+// @Description Retrieve a user by their username; staff, or users looking up themselves
 // @Tags users
 // @Accept json
 // @Produce json
 // @Param username query string true "Username"
 // @Success 200 {object} model.UserResponse
 // @Failure 404 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Security ApiKeyAuth
 // @Router /users/username [get]
 func (h *UserHandler) GetUserByUsername(c *gin.Context) {
 	username := c.Query("username")
@@ -130,7 +152,12 @@ func (h *UserHandler) GetUserByUsername(c *gin.Context) {
 		return
 	}
 
-	user, err := h.userService.GetUserByUsername(username)
+	if err := policy.CanLookupUserByUsername(policy.FromGin(c), username); err != nil {
+		policy.AbortWithError(c, err)
+		return
+	}
+
+	user, err := h.userService.GetUserByUsername(c.Request.Context(), username)
 	if err != nil {
 		if err == service.ErrUserNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
@@ -144,16 +171,41 @@ func (h *UserHandler) GetUserByUsername(c *gin.Context) {
 }
 
 // GetAllUsers godoc
-// @Summary Get all users
-// @Description Retrieve all users in the system
+// @Summary List users
+// @Description Retrieve a page of users, optionally filtered by name or email prefix
 // @Tags users
 // @Accept json
 // @Produce json
-// @Success 200 {array} model.UserResponse
+// @Param page_size query int false "Page size (default 20, max 100)"
+// @Param page_token query string false "Token of the page to retrieve"
+// @Param name_prefix query string false "Username, first name or last name prefix"
+// @Param email_prefix query string false "Email prefix"
+// @Param sort_by query string false "Sort field" Enums(id, username, email, created_at)
+// @Param sort_order query string false "Sort order" Enums(asc, desc)
+// @Success 200 {object} model.UserListResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Security ApiKeyAuth
 // @Router /users [get]
 func (h *UserHandler) GetAllUsers(c *gin.Context) {
-	users, err := h.userService.GetAllUsers()
+	if err := policy.CanListUsers(policy.FromGin(c)); err != nil {
+		policy.AbortWithError(c, err)
+		return
+	}
+
+	var req model.ListUsersRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	users, err := h.userService.ListUsers(&req)
 	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidPageToken) || errors.Is(err, pagination.ErrInvalidSort) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -172,6 +224,9 @@ func (h *UserHandler) GetAllUsers(c *gin.Context) {
 // @Success 200 {object} model.UserResponse
 // @Failure 400 {object} map[string]interface{}
 // @Failure 404 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Security ApiKeyAuth
 // @Router /users/{id} [put]
 func (h *UserHandler) UpdateUser(c *gin.Context) {
 	idStr := c.Param("id")
@@ -187,6 +242,18 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		return
 	}
 
+	principal := policy.FromGin(c)
+	if err := policy.CanUpdateUser(principal, uint(id)); err != nil {
+		policy.AbortWithError(c, err)
+		return
+	}
+	if req.Role != "" {
+		if err := policy.CanAssignRole(principal); err != nil {
+			policy.AbortWithError(c, err)
+			return
+		}
+	}
+
 	user, err := h.userService.UpdateUser(uint(id), &req)
 	if err != nil {
 		if err == service.ErrUserNotFound {
@@ -209,6 +276,9 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 // @Param id path int true "User ID"
 // @Success 204 "No Content"
 // @Failure 404 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Security ApiKeyAuth
 // @Router /users/{id} [delete]
 func (h *UserHandler) DeleteUser(c *gin.Context) {
 	idStr := c.Param("id")
@@ -218,6 +288,11 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 		return
 	}
 
+	if err := policy.CanDeleteUser(policy.FromGin(c)); err != nil {
+		policy.AbortWithError(c, err)
+		return
+	}
+
 	err = h.userService.DeleteUser(uint(id))
 	if err != nil {
 		if err == service.ErrUserNotFound {
@@ -0,0 +1,38 @@
+package model
+
+import "time"
+
+// RefreshToken represents an issued refresh token that can be revoked
+type RefreshToken struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"index;not null"`
+	TokenID   string     `json:"token_id" gorm:"uniqueIndex;not null"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// LoginRequest represents the request to authenticate a user
+type LoginRequest struct {
+	Login    string `json:"login" binding:"required" example:"johndoe"`
+	Password string `json:"password" binding:"required" example:"s3cretpass"`
+}
+
+// RefreshTokenRequest represents the request to exchange a refresh token
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// LogoutRequest represents the request to revoke refresh tokens
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+	All          bool   `json:"all" example:"false"`
+}
+
+// TokenResponse represents an issued access and refresh token pair
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type" example:"Bearer"`
+	ExpiresIn    int64  `json:"expires_in" example:"900"`
+}
@@ -0,0 +1,16 @@
+package model
+
+// Outbox aggregate and event types of the user service.
+// UserCreated and UserUpdated carry the user as payload, UserDeleted its ID.
+const (
+	AggregateUser = "user"
+
+	EventUserCreated = "UserCreated"
+	EventUserUpdated = "UserUpdated"
+	EventUserDeleted = "UserDeleted"
+)
+
+// UserDeletedEvent is the payload of a UserDeleted event
+type UserDeletedEvent struct {
+	ID uint `json:"id"`
+}
@@ -4,13 +4,15 @@ import "time"
 
 // User represents a user in the system
 type User struct {
-	ID        uint      `json:"id" gorm:"primaryKey"`
-	Username  string    `json:"username" gorm:"uniqueIndex;not null"`
-	Email     string    `json:"email" gorm:"uniqueIndex;not null"`
-	FirstName string    `json:"first_name" gorm:"not null"`
-	LastName  string    `json:"last_name" gorm:"not null"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	Username     string    `json:"username" gorm:"uniqueIndex;not null"`
+	Email        string    `json:"email" gorm:"uniqueIndex;not null"`
+	FirstName    string    `json:"first_name" gorm:"not null"`
+	LastName     string    `json:"last_name" gorm:"not null"`
+	PasswordHash string    `json:"-" gorm:"not null;default:''"`
+	Role         string    `json:"role" gorm:"not null;default:'customer'"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
 }
 
 // CreateUserRequest represents the request to create a new user
@@ -19,14 +21,17 @@ type CreateUserRequest struct {
 	Email     string `json:"email" binding:"required,email" example:"john@example.com"`
 	FirstName string `json:"first_name" binding:"required" example:"John"`
 	LastName  string `json:"last_name" binding:"required" example:"Doe"`
+	Password  string `json:"password" binding:"required,min=8" example:"s3cretpass"`
 }
 
 // UpdateUserRequest represents the request to update a user
 type UpdateUserRequest struct {
 	Username  string `json:"username" example:"johndoe"`
-	Email     string `json:"email" binding:"email" example:"john@example.com"`
+	Email     string `json:"email" binding:"omitempty,email" example:"john@example.com"`
 	FirstName string `json:"first_name" example:"John"`
 	LastName  string `json:"last_name" example:"Doe"`
+	// Role may only be changed by admins
+	Role string `json:"role" binding:"omitempty,oneof=admin support customer" example:"customer"`
 }
 
 // UserResponse represents the response for user operations
@@ -36,6 +41,23 @@ type UserResponse struct {
 	Email     string    `json:"email" example:"john@example.com"`
 	FirstName string    `json:"first_name" example:"John"`
 	LastName  string    `json:"last_name" example:"Doe"`
+	Role      string    `json:"role" example:"customer"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
-} 
\ No newline at end of file
+}
+
+// ListUsersRequest represents pagination, filter and sort options for listing users
+type ListUsersRequest struct {
+	PageSize    int    `form:"page_size" json:"page_size" example:"20"`
+	PageToken   string `form:"page_token" json:"page_token"`
+	NamePrefix  string `form:"name_prefix" json:"name_prefix" example:"jo"`
+	EmailPrefix string `form:"email_prefix" json:"email_prefix" example:"john@"`
+	SortBy      string `form:"sort_by" json:"sort_by" binding:"omitempty,oneof=id username email created_at" example:"created_at"`
+	SortOrder   string `form:"sort_order" json:"sort_order" binding:"omitempty,oneof=asc desc" example:"desc"`
+}
+
+// UserListResponse represents a page of users
+type UserListResponse struct {
+	Users         []*UserResponse `json:"users"`
+	NextPageToken string          `json:"next_page_token,omitempty"`
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"bweng/internal/user/model"
+)
+
+var (
+	ErrTokenNotFound       = errors.New("refresh token not found")
+	ErrTokenAlreadyRevoked = errors.New("refresh token already revoked")
+)
+
+// TokenRepository handles refresh token data operations
+type TokenRepository struct {
+	db *gorm.DB
+}
+
+// NewTokenRepository creates a new token repository
+func NewTokenRepository(db *gorm.DB) *TokenRepository {
+	return &TokenRepository{
+		db: db,
+	}
+}
+
+// Create stores a newly issued refresh token
+func (r *TokenRepository) Create(ctx context.Context, token *model.RefreshToken) error {
+	return r.db.WithContext(ctx).Create(token).Error
+}
+
+// GetByTokenID retrieves a refresh token by its token ID
+func (r *TokenRepository) GetByTokenID(ctx context.Context, tokenID string) (*model.RefreshToken, error) {
+	var token model.RefreshToken
+	if err := r.db.WithContext(ctx).Where("token_id = ?", tokenID).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTokenNotFound
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+// Revoke marks a refresh token as revoked. Only one of several concurrent
+// calls revokes the token; the others get ErrTokenAlreadyRevoked.
+func (r *TokenRepository) Revoke(ctx context.Context, tokenID string) error {
+	result := r.db.WithContext(ctx).Model(&model.RefreshToken{}).
+		Where("token_id = ? AND revoked_at IS NULL", tokenID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTokenAlreadyRevoked
+	}
+	return nil
+}
+
+// RevokeAllForUser revokes every active refresh token of a user
+func (r *TokenRepository) RevokeAllForUser(ctx context.Context, userID uint) error {
+	return r.db.WithContext(ctx).Model(&model.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
@@ -1,9 +1,13 @@
 package repository
 
 import (
+	"context"
 	"errors"
+	"strconv"
 
 	"gorm.io/gorm"
+	"bweng/internal/outbox"
+	"bweng/internal/pagination"
 	"bweng/internal/user/model"
 )
 
@@ -24,21 +28,26 @@ func NewUserRepository(db *gorm.DB) *UserRepository {
 	}
 }
 
-// Create creates a new user
+// Create creates a new user and records a UserCreated event
 func (r *UserRepository) Create(user *model.User) error {
-	// Check if user with same email or username already exists
-	var existingUser model.User
-	if err := r.db.Where("email = ? OR username = ?", user.Email, user.Username).First(&existingUser).Error; err == nil {
-		return ErrUserExists
-	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// Check if user with same email or username already exists
+		var existingUser model.User
+		if err := tx.Where("email = ? OR username = ?", user.Email, user.Username).First(&existingUser).Error; err == nil {
+			return ErrUserExists
+		}
 
-	return r.db.Create(user).Error
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		return outbox.Record(tx, model.AggregateUser, user.ID, model.EventUserCreated, user)
+	})
 }
 
 // GetByID retrieves a user by ID
-func (r *UserRepository) GetByID(id uint) (*model.User, error) {
+func (r *UserRepository) GetByID(ctx context.Context, id uint) (*model.User, error) {
 	var user model.User
-	if err := r.db.First(&user, id).Error; err != nil {
+	if err := r.db.WithContext(ctx).First(&user, id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, ErrUserNotFound
 		}
@@ -48,9 +57,9 @@ func (r *UserRepository) GetByID(id uint) (*model.User, error) {
 }
 
 // GetByEmail retrieves a user by email
-func (r *UserRepository) GetByEmail(email string) (*model.User, error) {
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*model.User, error) {
 	var user model.User
-	if err := r.db.Where("email = ?", email).First(&user).Error; err != nil {
+	if err := r.db.WithContext(ctx).Where("email = ?", email).First(&user).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, ErrUserNotFound
 		}
@@ -60,9 +69,9 @@ func (r *UserRepository) GetByEmail(email string) (*model.User, error) {
 }
 
 // GetByUsername retrieves a user by username
-func (r *UserRepository) GetByUsername(username string) (*model.User, error) {
+func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*model.User, error) {
 	var user model.User
-	if err := r.db.Where("username = ?", username).First(&user).Error; err != nil {
+	if err := r.db.WithContext(ctx).Where("username = ?", username).First(&user).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, ErrUserNotFound
 		}
@@ -71,25 +80,100 @@ func (r *UserRepository) GetByUsername(username string) (*model.User, error) {
 	return &user, nil
 }
 
-// GetAll retrieves all users
-func (r *UserRepository) GetAll() ([]*model.User, error) {
+// userSortColumns maps sortable API fields to columns
+var userSortColumns = map[string]string{
+	"id":         "id",
+	"username":   "username",
+	"email":      "email",
+	"created_at": "created_at",
+}
+
+// List retrieves a page of users matching the request filters.
+// It returns the page together with the token of the next page, if any.
+func (r *UserRepository) List(req *model.ListUsersRequest) ([]*model.User, string, error) {
+	sort, err := pagination.NewSort(req.SortBy, req.SortOrder, userSortColumns, "id")
+	if err != nil {
+		return nil, "", err
+	}
+	cursor, err := pagination.DecodeCursor(req.PageToken, sort)
+	if err != nil {
+		return nil, "", err
+	}
+
+	query := r.db.Model(&model.User{})
+	if req.NamePrefix != "" {
+		pattern := pagination.LikePrefix(req.NamePrefix)
+		query = query.Where("username ILIKE ? OR first_name ILIKE ? OR last_name ILIKE ?", pattern, pattern, pattern)
+	}
+	if req.EmailPrefix != "" {
+		query = query.Where("email ILIKE ?", pagination.LikePrefix(req.EmailPrefix))
+	}
+
+	parse := pagination.ParseString
+	if sort.Field == "created_at" {
+		parse = pagination.ParseTime
+	}
+	query, err = pagination.Apply(query, sort, cursor, parse)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Fetch one extra row to find out whether another page exists
+	pageSize := pagination.PageSize(req.PageSize)
 	var users []*model.User
-	if err := r.db.Find(&users).Error; err != nil {
-		return nil, err
+	if err := query.Limit(pageSize + 1).Find(&users).Error; err != nil {
+		return nil, "", err
+	}
+
+	if len(users) <= pageSize {
+		return users, "", nil
+	}
+
+	users = users[:pageSize]
+	last := users[len(users)-1]
+	return users, pagination.EncodeCursor(sort, userSortValue(last, sort.Field), last.ID), nil
+}
+
+// userSortValue returns the cursor value of a user for a sort field
+func userSortValue(user *model.User, field string) string {
+	switch field {
+	case "username":
+		return user.Username
+	case "email":
+		return user.Email
+	case "created_at":
+		return pagination.FormatTime(user.CreatedAt)
 	}
-	return users, nil
+	return strconv.FormatUint(uint64(user.ID), 10)
 }
 
-// Update updates an existing user
+// Update updates an existing user and records a UserUpdated event
 func (r *UserRepository) Update(id uint, updates *model.UpdateUserRequest) (*model.User, error) {
 	var user model.User
-	if err := r.db.First(&user, id).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, ErrUserNotFound
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&user, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUserNotFound
+			}
+			return err
+		}
+
+		applyUserUpdates(&user, updates)
+
+		if err := tx.Save(&user).Error; err != nil {
+			return err
 		}
+		return outbox.Record(tx, model.AggregateUser, user.ID, model.EventUserUpdated, &user)
+	})
+	if err != nil {
 		return nil, err
 	}
 
+	return &user, nil
+}
+
+// applyUserUpdates copies the provided fields of an update request onto a user
+func applyUserUpdates(user *model.User, updates *model.UpdateUserRequest) {
 	// Update fields if provided
 	if updates.Username != "" {
 		user.Username = updates.Username
@@ -103,27 +187,21 @@ func (r *UserRepository) Update(id uint, updates *model.UpdateUserRequest) (*mod
 	if updates.LastName != "" {
 		user.LastName = updates.LastName
 	}
-
-	if err := r.db.Save(&user).Error; err != nil {
-		return nil, err
+	if updates.Role != "" {
+		user.Role = updates.Role
 	}
-
-	return &user, nil
 }
 
-// Delete deletes a user by ID
+// Delete deletes a user by ID and records a UserDeleted event
 func (r *UserRepository) Delete(id uint) error {
-	result := r.db.Delete(&model.User{}, id)
-	if result.Error != nil {
-		return result.Error
-	}
-	if result.RowsAffected == 0 {
-		return ErrUserNotFound
-	}
-	return nil
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&model.User{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrUserNotFound
+		}
+		return outbox.Record(tx, model.AggregateUser, id, model.EventUserDeleted, &model.UserDeletedEvent{ID: id})
+	})
 }
-
-// Migrate runs database migrations
-func (r *UserRepository) Migrate() error {
-	return r.db.AutoMigrate(&model.User{})
-} 
\ No newline at end of file
@@ -0,0 +1,254 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+
+	"bweng/internal/user/config"
+	"bweng/internal/user/model"
+	"bweng/internal/user/repository"
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrInvalidToken       = errors.New("invalid or expired token")
+	ErrTokenRevoked       = errors.New("token has been revoked")
+)
+
+const (
+	tokenTypeAccess  = "access"
+	tokenTypeRefresh = "refresh"
+)
+
+// Claims represents the JWT claims issued by the user service
+type Claims struct {
+	Username  string   `json:"username"`
+	Roles     []string `json:"roles,omitempty"`
+	TokenType string   `json:"typ"`
+	jwt.RegisteredClaims
+}
+
+// AuthService handles authentication and token issuance
+type AuthService struct {
+	userRepo  *repository.UserRepository
+	tokenRepo *repository.TokenRepository
+	config    *config.AuthConfig
+}
+
+// NewAuthService creates a new auth service
+func NewAuthService(userRepo *repository.UserRepository, tokenRepo *repository.TokenRepository, cfg *config.AuthConfig) *AuthService {
+	return &AuthService{
+		userRepo:  userRepo,
+		tokenRepo: tokenRepo,
+		config:    cfg,
+	}
+}
+
+// Login verifies the user's credentials and issues a token pair
+func (s *AuthService) Login(ctx context.Context, req *model.LoginRequest) (*model.TokenResponse, error) {
+	tokens, err := s.login(ctx, req)
+	authLogins.WithLabelValues(authResult(err)).Inc()
+	return tokens, err
+}
+
+// login looks up the user by username or email and checks the password
+func (s *AuthService) login(ctx context.Context, req *model.LoginRequest) (*model.TokenResponse, error) {
+	var (
+		user *model.User
+		err  error
+	)
+	if strings.Contains(req.Login, "@") {
+		user, err = s.userRepo.GetByEmail(ctx, req.Login)
+	} else {
+		user, err = s.userRepo.GetByUsername(ctx, req.Login)
+	}
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+
+	if user.PasswordHash == "" {
+		return nil, ErrInvalidCredentials
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	return s.issueTokens(ctx, user)
+}
+
+// Refresh exchanges a valid refresh token for a new token pair.
+// The presented refresh token is revoked so it cannot be used twice.
+func (s *AuthService) Refresh(ctx context.Context, req *model.RefreshTokenRequest) (*model.TokenResponse, error) {
+	tokens, err := s.refresh(ctx, req)
+	authRefreshes.WithLabelValues(authResult(err)).Inc()
+	return tokens, err
+}
+
+// refresh validates and rotates a refresh token
+func (s *AuthService) refresh(ctx context.Context, req *model.RefreshTokenRequest) (*model.TokenResponse, error) {
+	claims, err := s.parseToken(req.RefreshToken, tokenTypeRefresh)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := s.tokenRepo.GetByTokenID(ctx, claims.ID)
+	if err != nil {
+		if errors.Is(err, repository.ErrTokenNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+	if stored.RevokedAt != nil {
+		// A revoked token being replayed indicates theft, so cut off the whole family
+		if err := s.tokenRepo.RevokeAllForUser(ctx, stored.UserID); err != nil {
+			return nil, err
+		}
+		return nil, ErrTokenRevoked
+	}
+
+	user, err := s.userRepo.GetByID(ctx, stored.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	// A concurrent refresh with the same token may have revoked it since the
+	// lookup; only the request that revokes it gets new tokens
+	if err := s.tokenRepo.Revoke(ctx, stored.TokenID); err != nil {
+		if errors.Is(err, repository.ErrTokenAlreadyRevoked) {
+			return nil, ErrTokenRevoked
+		}
+		return nil, err
+	}
+
+	return s.issueTokens(ctx, user)
+}
+
+// Logout revokes the given refresh token, or every refresh token of its owner
+func (s *AuthService) Logout(ctx context.Context, req *model.LogoutRequest) error {
+	claims, err := s.parseToken(req.RefreshToken, tokenTypeRefresh)
+	if err != nil {
+		return err
+	}
+
+	stored, err := s.tokenRepo.GetByTokenID(ctx, claims.ID)
+	if err != nil {
+		if errors.Is(err, repository.ErrTokenNotFound) {
+			return ErrInvalidToken
+		}
+		return err
+	}
+
+	if req.All {
+		return s.tokenRepo.RevokeAllForUser(ctx, stored.UserID)
+	}
+
+	if stored.RevokedAt != nil {
+		return nil
+	}
+	if err := s.tokenRepo.Revoke(ctx, stored.TokenID); err != nil && !errors.Is(err, repository.ErrTokenAlreadyRevoked) {
+		return err
+	}
+	return nil
+}
+
+// issueTokens signs a new access token and a persisted refresh token for the user
+func (s *AuthService) issueTokens(ctx context.Context, user *model.User) (*model.TokenResponse, error) {
+	now := time.Now()
+
+	accessToken, _, err := s.signToken(user, tokenTypeAccess, now, s.config.AccessTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, refreshID, err := s.signToken(user, tokenTypeRefresh, now, s.config.RefreshTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.tokenRepo.Create(ctx, &model.RefreshToken{
+		UserID:    user.ID,
+		TokenID:   refreshID,
+		ExpiresAt: now.Add(s.config.RefreshTokenTTL),
+		CreatedAt: now,
+	}); err != nil {
+		return nil, err
+	}
+
+	return &model.TokenResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(s.config.AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+// signToken signs a token of the given type for the user and returns it with its ID
+func (s *AuthService) signToken(user *model.User, tokenType string, now time.Time, ttl time.Duration) (string, string, error) {
+	tokenID, err := newTokenID()
+	if err != nil {
+		return "", "", err
+	}
+
+	claims := s.newClaims(user, tokenType, now, ttl)
+	claims.ID = tokenID
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(s.config.JWTSecret))
+	if err != nil {
+		return "", "", err
+	}
+	return signed, tokenID, nil
+}
+
+// newClaims builds the claim set shared by access and refresh tokens
+func (s *AuthService) newClaims(user *model.User, tokenType string, now time.Time, ttl time.Duration) *Claims {
+	return &Claims{
+		Username:  user.Username,
+		Roles:     []string{user.Role},
+		TokenType: tokenType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.config.Issuer,
+			Subject:   strconv.FormatUint(uint64(user.ID), 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+}
+
+// parseToken verifies a token's signature, expiry, issuer and type
+func (s *AuthService) parseToken(tokenString, tokenType string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(s.config.JWTSecret), nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(s.config.Issuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil || claims.TokenType != tokenType {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// newTokenID generates a random token identifier
+func newTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,214 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+
+	"bweng/internal/dbtest"
+	"bweng/internal/user/config"
+	"bweng/internal/user/migrations"
+	"bweng/internal/user/model"
+	"bweng/internal/user/repository"
+)
+
+// testAuthConfig signs tokens with a fixed secret
+func testAuthConfig() *config.AuthConfig {
+	return &config.AuthConfig{
+		JWTSecret:       "test-secret",
+		Issuer:          "bweng-test",
+		AccessTokenTTL:  15 * time.Minute,
+		RefreshTokenTTL: time.Hour,
+	}
+}
+
+func TestParseToken(t *testing.T) {
+	s := &AuthService{config: testAuthConfig()}
+	user := &model.User{ID: 3, Username: "alice", Role: "customer"}
+	now := time.Now()
+
+	sign := func(s *AuthService, tokenType string, at time.Time, ttl time.Duration) string {
+		t.Helper()
+		token, _, err := s.signToken(user, tokenType, at, ttl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	otherIssuer := testAuthConfig()
+	otherIssuer.Issuer = "someone-else"
+	otherSecret := testAuthConfig()
+	otherSecret.JWTSecret = "other-secret"
+	hs512, err := jwt.NewWithClaims(jwt.SigningMethodHS512, s.newClaims(user, tokenTypeRefresh, now, time.Hour)).SignedString([]byte("test-secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := s.parseToken(sign(s, tokenTypeRefresh, now, time.Hour), tokenTypeRefresh)
+	if err != nil {
+		t.Fatalf("valid refresh token: %v", err)
+	}
+	if claims.Subject != "3" || claims.Username != "alice" || claims.ID == "" {
+		t.Errorf("claims = %+v, want alice's with a token ID", claims)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"access token as refresh token", sign(s, tokenTypeAccess, now, time.Hour)},
+		{"expired", sign(s, tokenTypeRefresh, now.Add(-2*time.Hour), time.Hour)},
+		{"not yet valid", sign(s, tokenTypeRefresh, now.Add(time.Hour), time.Hour)},
+		{"other issuer", sign(&AuthService{config: otherIssuer}, tokenTypeRefresh, now, time.Hour)},
+		{"other secret", sign(&AuthService{config: otherSecret}, tokenTypeRefresh, now, time.Hour)},
+		{"other algorithm", hs512},
+		{"malformed", "not-a-token"},
+	}
+	for _, tt := range tests {
+		if _, err := s.parseToken(tt.token, tokenTypeRefresh); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: parseToken = %v, want ErrInvalidToken", tt.name, err)
+		}
+	}
+}
+
+// newTestAuthService returns an auth service on a fresh database with the
+// user alice, whose password is "correct horse"
+func newTestAuthService(t *testing.T) (*AuthService, *repository.TokenRepository, *model.User) {
+	t.Helper()
+	db := dbtest.Open(t, migrations.FS)
+	userRepo := repository.NewUserRepository(db)
+	tokenRepo := repository.NewTokenRepository(db)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &model.User{Username: "alice", Email: "alice@example.com", FirstName: "Alice", LastName: "Liddell", PasswordHash: string(hash), Role: "customer"}
+	if err := userRepo.Create(user); err != nil {
+		t.Fatal(err)
+	}
+	return NewAuthService(userRepo, tokenRepo, testAuthConfig()), tokenRepo, user
+}
+
+func TestLogin(t *testing.T) {
+	s, _, _ := newTestAuthService(t)
+	ctx := context.Background()
+
+	for _, login := range []string{"alice", "alice@example.com"} {
+		tokens, err := s.Login(ctx, &model.LoginRequest{Login: login, Password: "correct horse"})
+		if err != nil {
+			t.Fatalf("Login(%s): %v", login, err)
+		}
+		if _, err := s.parseToken(tokens.AccessToken, tokenTypeAccess); err != nil {
+			t.Errorf("Login(%s) access token: %v", login, err)
+		}
+		if _, err := s.parseToken(tokens.RefreshToken, tokenTypeRefresh); err != nil {
+			t.Errorf("Login(%s) refresh token: %v", login, err)
+		}
+	}
+
+	for _, req := range []*model.LoginRequest{
+		{Login: "alice", Password: "wrong password"},
+		{Login: "alice@example.com", Password: ""},
+		{Login: "bob", Password: "correct horse"},
+	} {
+		if _, err := s.Login(ctx, req); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("Login(%s, %q) = %v, want ErrInvalidCredentials", req.Login, req.Password, err)
+		}
+	}
+}
+
+func TestRefreshRotation(t *testing.T) {
+	s, tokenRepo, user := newTestAuthService(t)
+	ctx := context.Background()
+
+	first, err := s.Login(ctx, &model.LoginRequest{Login: "alice", Password: "correct horse"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.Refresh(ctx, &model.RefreshTokenRequest{RefreshToken: first.RefreshToken})
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatal("Refresh returned the presented refresh token")
+	}
+
+	// The rotated token is no longer valid, and presenting it again is
+	// treated as theft: every token of the user is revoked
+	if _, err := s.Refresh(ctx, &model.RefreshTokenRequest{RefreshToken: first.RefreshToken}); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("Refresh with the rotated token = %v, want ErrTokenRevoked", err)
+	}
+	if _, err := s.Refresh(ctx, &model.RefreshTokenRequest{RefreshToken: second.RefreshToken}); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Refresh with the newest token after reuse = %v, want ErrTokenRevoked", err)
+	}
+
+	// A revoked token cannot be revoked again, which is how concurrent
+	// refreshes with the same token are told apart
+	claims, err := s.parseToken(second.RefreshToken, tokenTypeRefresh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tokenRepo.Revoke(ctx, claims.ID); !errors.Is(err, repository.ErrTokenAlreadyRevoked) {
+		t.Errorf("Revoke of a revoked token = %v, want ErrTokenAlreadyRevoked", err)
+	}
+
+	// Logging in again starts a new family
+	third, err := s.Login(ctx, &model.LoginRequest{Login: user.Email, Password: "correct horse"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Refresh(ctx, &model.RefreshTokenRequest{RefreshToken: third.RefreshToken}); err != nil {
+		t.Errorf("Refresh after logging in again: %v", err)
+	}
+}
+
+func TestRefreshUnknownToken(t *testing.T) {
+	s, _, user := newTestAuthService(t)
+
+	// Correctly signed, but never stored
+	token, _, err := s.signToken(user, tokenTypeRefresh, time.Now(), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Refresh(context.Background(), &model.RefreshTokenRequest{RefreshToken: token}); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Refresh = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	s, _, _ := newTestAuthService(t)
+	ctx := context.Background()
+	login := func() *model.TokenResponse {
+		t.Helper()
+		tokens, err := s.Login(ctx, &model.LoginRequest{Login: "alice", Password: "correct horse"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tokens
+	}
+
+	first, second := login(), login()
+	if err := s.Logout(ctx, &model.LogoutRequest{RefreshToken: first.RefreshToken}); err != nil {
+		t.Fatal(err)
+	}
+	// Logging out twice is not an error
+	if err := s.Logout(ctx, &model.LogoutRequest{RefreshToken: first.RefreshToken}); err != nil {
+		t.Errorf("second Logout: %v", err)
+	}
+	if _, err := s.Refresh(ctx, &model.RefreshTokenRequest{RefreshToken: second.RefreshToken}); err != nil {
+		t.Errorf("Refresh of another session after Logout: %v", err)
+	}
+
+	third := login()
+	if err := s.Logout(ctx, &model.LogoutRequest{RefreshToken: third.RefreshToken, All: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Refresh(ctx, &model.RefreshTokenRequest{RefreshToken: third.RefreshToken}); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Refresh after logging out everywhere = %v, want ErrTokenRevoked", err)
+	}
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	usersRegistered = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "users_registered_total",
+		Help: "Users created.",
+	})
+	authLogins = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_logins_total",
+		Help: "Login attempts by result (success, invalid_credentials, error).",
+	}, []string{"result"})
+	authRefreshes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_token_refreshes_total",
+		Help: "Token refresh attempts by result (success, invalid_token, error).",
+	}, []string{"result"})
+)
+
+// authResult labels the outcome of a login or refresh
+func authResult(err error) string {
+	switch {
+	case err == nil:
+		return "success"
+	case errors.Is(err, ErrInvalidCredentials):
+		return "invalid_credentials"
+	case errors.Is(err, ErrInvalidToken), errors.Is(err, ErrTokenRevoked):
+		return "invalid_token"
+	}
+	return "error"
+}
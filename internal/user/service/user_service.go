@@ -1,16 +1,21 @@
 package service
 
 import (
-	"errors"
+	"context"
 	"time"
 
+	"golang.org/x/crypto/bcrypt"
+
+	"bweng/internal/policy"
 	"bweng/internal/user/model"
 	"bweng/internal/user/repository"
 )
 
+// Errors returned by the repository, re-exported so callers can compare
+// against the service package
 var (
-	ErrUserNotFound = errors.New("user not found")
-	ErrUserExists   = errors.New("user already exists")
+	ErrUserNotFound = repository.ErrUserNotFound
+	ErrUserExists   = repository.ErrUserExists
 )
 
 // UserService handles user business logic
@@ -27,27 +32,35 @@ func NewUserService(repo *repository.UserRepository) *UserService {
 
 // CreateUser creates a new user
 func (s *UserService) CreateUser(req *model.CreateUserRequest) (*model.UserResponse, error) {
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
 	now := time.Now()
 	
 	user := &model.User{
-		Username:  req.Username,
-		Email:     req.Email,
-		FirstName: req.FirstName,
-		LastName:  req.LastName,
-		CreatedAt: now,
-		UpdatedAt: now,
+		Username:     req.Username,
+		Email:        req.Email,
+		FirstName:    req.FirstName,
+		LastName:     req.LastName,
+		PasswordHash: string(passwordHash),
+		Role:         string(policy.RoleCustomer),
+		CreatedAt:    now,
+		UpdatedAt:    now,
 	}
 
 	if err := s.repo.Create(user); err != nil {
 		return nil, err
 	}
+	usersRegistered.Inc()
 
 	return s.toUserResponse(user), nil
 }
 
 // GetUserByID retrieves a user by ID
-func (s *UserService) GetUserByID(id uint) (*model.UserResponse, error) {
-	user, err := s.repo.GetByID(id)
+func (s *UserService) GetUserByID(ctx context.Context, id uint) (*model.UserResponse, error) {
+	user, err := s.repo.GetByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
@@ -56,8 +69,8 @@ func (s *UserService) GetUserByID(id uint) (*model.UserResponse, error) {
 }
 
 // GetUserByEmail retrieves a user by email
-func (s *UserService) GetUserByEmail(email string) (*model.UserResponse, error) {
-	user, err := s.repo.GetByEmail(email)
+func (s *UserService) GetUserByEmail(ctx context.Context, email string) (*model.UserResponse, error) {
+	user, err := s.repo.GetByEmail(ctx, email)
 	if err != nil {
 		return nil, err
 	}
@@ -66,8 +79,8 @@ func (s *UserService) GetUserByEmail(email string) (*model.UserResponse, error)
 }
 
 // GetUserByUsername retrieves a user by username
-func (s *UserService) GetUserByUsername(username string) (*model.UserResponse, error) {
-	user, err := s.repo.GetByUsername(username)
+func (s *UserService) GetUserByUsername(ctx context.Context, username string) (*model.UserResponse, error) {
+	user, err := s.repo.GetByUsername(ctx, username)
 	if err != nil {
 		return nil, err
 	}
@@ -75,9 +88,9 @@ func (s *UserService) GetUserByUsername(username string) (*model.UserResponse, e
 	return s.toUserResponse(user), nil
 }
 
-// GetAllUsers retrieves all users
-func (s *UserService) GetAllUsers() ([]*model.UserResponse, error) {
-	users, err := s.repo.GetAll()
+// ListUsers retrieves a page of users
+func (s *UserService) ListUsers(req *model.ListUsersRequest) (*model.UserListResponse, error) {
+	users, nextPageToken, err := s.repo.List(req)
 	if err != nil {
 		return nil, err
 	}
@@ -87,7 +100,10 @@ func (s *UserService) GetAllUsers() ([]*model.UserResponse, error) {
 		responses[i] = s.toUserResponse(user)
 	}
 
-	return responses, nil
+	return &model.UserListResponse{
+		Users:         responses,
+		NextPageToken: nextPageToken,
+	}, nil
 }
 
 // UpdateUser updates an existing user
@@ -116,6 +132,7 @@ func (s *UserService) toUserResponse(user *model.User) *model.UserResponse {
 		Email:     user.Email,
 		FirstName: user.FirstName,
 		LastName:  user.LastName,
+		Role:      user.Role,
 		CreatedAt: user.CreatedAt,
 		UpdatedAt: user.UpdatedAt,
 	}
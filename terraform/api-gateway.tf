@@ -1,5 +1,52 @@
 # API Gateway Terraform Configuration
-# Bu dosya, API Gateway'in deployment ve service'ini tanımlar
+# Bu dosya, API Gateway'in route ConfigMap'ini, deployment ve service'ini tanımlar
+
+# API Gateway Routes ConfigMap
+# Değişiklikler çalışan gateway'lere yeniden başlatma olmadan yansır
+resource "kubernetes_config_map" "api_gateway_routes" {
+  metadata {
+    name      = "bweng-gateway-routes"
+    namespace = var.namespace
+    labels = {
+      app       = "bweng-microservices"
+      component = "api-gateway"
+    }
+  }
+
+  data = {
+    "routes.yaml" = yamlencode({
+      version = "1"
+      routes = [
+        {
+          name         = "user-service"
+          base_path    = "/api/v1/users"
+          target       = "http://user-service:${var.user_service_port}"
+          timeout      = "30s"
+          public_paths = ["POST /api/v1/users"]
+        },
+        {
+          name         = "auth-service"
+          base_path    = "/api/v1/auth"
+          target       = "http://user-service:${var.user_service_port}"
+          timeout      = "30s"
+          public_paths = ["/api/v1/auth/*"]
+        },
+        {
+          name       = "order-service"
+          base_path  = "/api/v1/orders"
+          target     = "http://order-service:${var.order_service_port}"
+          timeout    = "30s"
+          middleware = ["auth", "ratelimit"]
+          # Her kullanıcı dakikada 10 sipariş oluşturabilir, en fazla 5'lik patlamalarla
+          rate_limits = [
+            { key = "jwt_subject", requests = 10, per = "1m", burst = 5, methods = ["POST"] },
+            { key = "client_ip", requests = 600, per = "1m" },
+          ]
+        },
+      ]
+    })
+  }
+}
 
 # API Gateway Deployment
 resource "kubernetes_deployment" "api_gateway" {
@@ -53,6 +100,26 @@ resource "kubernetes_deployment" "api_gateway" {
             }
           }
 
+          env {
+            name = "LOG_LEVEL"
+            value_from {
+              config_map_key_ref {
+                name = "bweng-config"
+                key  = "LOG_LEVEL"
+              }
+            }
+          }
+
+          env {
+            name = "API_GATEWAY_PORT"
+            value_from {
+              config_map_key_ref {
+                name = "bweng-config"
+                key  = "API_GATEWAY_PORT"
+              }
+            }
+          }
+
           env {
             name  = "USER_SERVICE_URL"
             value = "http://user-service:${var.user_service_port}"
@@ -63,6 +130,38 @@ resource "kubernetes_deployment" "api_gateway" {
             value = "http://order-service:${var.order_service_port}"
           }
 
+          env {
+            name  = "GATEWAY_ROUTES_FILE"
+            value = "/etc/api-gateway/routes.yaml"
+          }
+
+          env {
+            name = "JWT_SECRET"
+            value_from {
+              secret_key_ref {
+                name = "bweng-app-secret"
+                key  = "JWT_SECRET"
+              }
+            }
+          }
+
+          env {
+            name = "IDENTITY_SECRET"
+            value_from {
+              secret_key_ref {
+                name = "bweng-app-secret"
+                key  = "IDENTITY_SECRET"
+              }
+            }
+          }
+
+          # Route konfigürasyonu
+          volume_mount {
+            name       = "routes"
+            mount_path = "/etc/api-gateway"
+            read_only  = true
+          }
+
           # Resource Limits
           resources {
             requests = {
@@ -78,7 +177,7 @@ resource "kubernetes_deployment" "api_gateway" {
           # Health Checks
           liveness_probe {
             http_get {
-              path = "/health"
+              path = "/livez"
               port = var.api_gateway_port
             }
             initial_delay_seconds = 30
@@ -88,7 +187,7 @@ resource "kubernetes_deployment" "api_gateway" {
 
           readiness_probe {
             http_get {
-              path = "/health"
+              path = "/readyz"
               port = var.api_gateway_port
             }
             initial_delay_seconds = 5
@@ -96,6 +195,13 @@ resource "kubernetes_deployment" "api_gateway" {
             timeout_seconds       = 3
           }
         }
+
+        volume {
+          name = "routes"
+          config_map {
+            name = kubernetes_config_map.api_gateway_routes.metadata[0].name
+          }
+        }
       }
     }
   }
@@ -25,10 +25,14 @@ resource "kubernetes_config_map" "bweng_config" {
     ORDER_SERVICE_PORT = tostring(var.order_service_port)
     API_GATEWAY_PORT = tostring(var.api_gateway_port)
     GRPC_PORT = "50051"
+    ORDER_SERVICE_GRPC_PORT = "50052"
     
     # Environment
     GIN_MODE = "release"
     LOG_LEVEL = "info"
+    
+    # Migration'lar *-service-migrate job'larında çalışır
+    SKIP_MIGRATIONS = "true"
   }
 }
 
@@ -81,6 +85,8 @@ resource "kubernetes_secret" "bweng_app_secret" {
 
   data = {
     # base64 encoded password
-    DB_PASSWORD = base64encode(var.postgres_password)
+    DB_PASSWORD     = base64encode(var.postgres_password)
+    JWT_SECRET      = base64encode(var.jwt_secret)
+    IDENTITY_SECRET = base64encode(var.identity_secret)
   }
 } 
\ No newline at end of file
@@ -1,8 +1,141 @@
 # Order Service Terraform Configuration
-# Bu dosya, Order Service'in deployment ve service'ini tanımlar
+# Bu dosya, Order Service'in migration job'ını, deployment ve service'ini tanımlar
+
+# Order Service Migration Job
+# Şema migration'ları deployment'tan önce tek seferlik çalıştırılır
+resource "kubernetes_job_v1" "order_service_migrate" {
+  metadata {
+    name      = "order-service-migrate"
+    namespace = var.namespace
+    labels = {
+      app       = "bweng-microservices"
+      component = "order-service-migrate"
+    }
+  }
+
+  spec {
+    backoff_limit = 4
+
+    template {
+      metadata {
+        labels = {
+          app       = "bweng-microservices"
+          component = "order-service-migrate"
+        }
+      }
+
+      spec {
+        restart_policy = "OnFailure"
+
+        container {
+          name  = "migrate"
+          image = "${var.image_repository}-order-service:${var.image_tag}"
+          
+          image_pull_policy = "Never"
+
+          command = ["./order-service"]
+          args    = ["migrate", "up"]
+
+          # Environment Variables
+          env {
+            name = "DB_HOST"
+            value_from {
+              config_map_key_ref {
+                name = "bweng-config"
+                key  = "DB_HOST"
+              }
+            }
+          }
+
+          env {
+            name = "DB_PORT"
+            value_from {
+              config_map_key_ref {
+                name = "bweng-config"
+                key  = "DB_PORT"
+              }
+            }
+          }
+
+          env {
+            name = "DB_USER"
+            value_from {
+              config_map_key_ref {
+                name = "bweng-config"
+                key  = "DB_USER"
+              }
+            }
+          }
+
+          env {
+            name = "DB_PASSWORD"
+            value_from {
+              secret_key_ref {
+                name = "bweng-app-secret"
+                key  = "DB_PASSWORD"
+              }
+            }
+          }
+
+          env {
+            name = "DB_NAME"
+            value_from {
+              config_map_key_ref {
+                name = "bweng-config"
+                key  = "DB_NAME_ORDER"
+              }
+            }
+          }
+
+          env {
+            name = "DB_SSLMODE"
+            value_from {
+              config_map_key_ref {
+                name = "bweng-config"
+                key  = "DB_SSLMODE"
+              }
+            }
+          }
+
+          env {
+            name = "LOG_LEVEL"
+            value_from {
+              config_map_key_ref {
+                name = "bweng-config"
+                key  = "LOG_LEVEL"
+              }
+            }
+          }
+
+          # Resource Limits
+          resources {
+            requests = {
+              memory = "64Mi"
+              cpu    = "50m"
+            }
+            limits = {
+              memory = "128Mi"
+              cpu    = "100m"
+            }
+          }
+        }
+      }
+    }
+  }
+
+  wait_for_completion = true
+
+  timeouts {
+    create = "5m"
+    update = "5m"
+  }
+}
 
 # Order Service Deployment
 resource "kubernetes_deployment" "order_service" {
+  # Migration'lar tamamlanmadan pod'lar başlatılmaz
+  depends_on = [kubernetes_job_v1.order_service_migrate]
+
   metadata {
     name      = "order-service-deployment"
     namespace = var.namespace
@@ -42,6 +175,11 @@ resource "kubernetes_deployment" "order_service" {
             container_port = var.order_service_port
           }
 
+          port {
+            name           = "grpc"
+            container_port = 50052
+          }
+
           # Environment Variables
           env {
             name = "DB_HOST"
@@ -83,6 +221,16 @@ resource "kubernetes_deployment" "order_service" {
             }
           }
 
+          env {
+            name = "IDENTITY_SECRET"
+            value_from {
+              secret_key_ref {
+                name = "bweng-app-secret"
+                key  = "IDENTITY_SECRET"
+              }
+            }
+          }
+
           env {
             name = "DB_NAME"
             value_from {
@@ -113,6 +261,46 @@ resource "kubernetes_deployment" "order_service" {
             }
           }
 
+          env {
+            name = "LOG_LEVEL"
+            value_from {
+              config_map_key_ref {
+                name = "bweng-config"
+                key  = "LOG_LEVEL"
+              }
+            }
+          }
+
+          env {
+            name = "SKIP_MIGRATIONS"
+            value_from {
+              config_map_key_ref {
+                name = "bweng-config"
+                key  = "SKIP_MIGRATIONS"
+              }
+            }
+          }
+
+          env {
+            name = "ORDER_SERVICE_PORT"
+            value_from {
+              config_map_key_ref {
+                name = "bweng-config"
+                key  = "ORDER_SERVICE_PORT"
+              }
+            }
+          }
+
+          env {
+            name = "ORDER_SERVICE_GRPC_PORT"
+            value_from {
+              config_map_key_ref {
+                name = "bweng-config"
+                key  = "ORDER_SERVICE_GRPC_PORT"
+              }
+            }
+          }
+
           # gRPC Service Configuration
           env {
             name  = "USER_SERVICE_GRPC_HOST"
@@ -144,7 +332,7 @@ resource "kubernetes_deployment" "order_service" {
           # Health Checks
           liveness_probe {
             http_get {
-              path = "/health"
+              path = "/livez"
               port = var.order_service_port
             }
             initial_delay_seconds = 30
@@ -154,7 +342,7 @@ resource "kubernetes_deployment" "order_service" {
 
           readiness_probe {
             http_get {
-              path = "/health"
+              path = "/readyz"
               port = var.order_service_port
             }
             initial_delay_seconds = 5
@@ -191,6 +379,13 @@ resource "kubernetes_service" "order_service" {
       protocol    = "TCP"
     }
 
+    port {
+      name        = "grpc"
+      port        = 50052
+      target_port = 50052
+      protocol    = "TCP"
+    }
+
     type = "ClusterIP"
   }
 } 
\ No newline at end of file
@@ -1,8 +1,141 @@
 # User Service Terraform Configuration
-# Bu dosya, User Service'in deployment ve service'ini tanımlar
+# Bu dosya, User Service'in migration job'ını, deployment ve service'ini tanımlar
+
+# User Service Migration Job
+# Şema migration'ları deployment'tan önce tek seferlik çalıştırılır
+resource "kubernetes_job_v1" "user_service_migrate" {
+  metadata {
+    name      = "user-service-migrate"
+    namespace = var.namespace
+    labels = {
+      app       = "bweng-microservices"
+      component = "user-service-migrate"
+    }
+  }
+
+  spec {
+    backoff_limit = 4
+
+    template {
+      metadata {
+        labels = {
+          app       = "bweng-microservices"
+          component = "user-service-migrate"
+        }
+      }
+
+      spec {
+        restart_policy = "OnFailure"
+
+        container {
+          name  = "migrate"
+          image = "${var.image_repository}-user-service:${var.image_tag}"
+          
+          image_pull_policy = "Never"
+
+          command = ["./user-service"]
+          args    = ["migrate", "up"]
+
+          # Environment Variables
+          env {
+            name = "DB_HOST"
+            value_from {
+              config_map_key_ref {
+                name = "bweng-config"
+                key  = "DB_HOST"
+              }
+            }
+          }
+
+          env {
+            name = "DB_PORT"
+            value_from {
+              config_map_key_ref {
+                name = "bweng-config"
+                key  = "DB_PORT"
+              }
+            }
+          }
+
+          env {
+            name = "DB_USER"
+            value_from {
+              config_map_key_ref {
+                name = "bweng-config"
+                key  = "DB_USER"
+              }
+            }
+          }
+
+          env {
+            name = "DB_PASSWORD"
+            value_from {
+              secret_key_ref {
+                name = "bweng-app-secret"
+                key  = "DB_PASSWORD"
+              }
+            }
+          }
+
+          env {
+            name = "DB_NAME"
+            value_from {
+              config_map_key_ref {
+                name = "bweng-config"
+                key  = "DB_NAME_USER"
+              }
+            }
+          }
+
+          env {
+            name = "DB_SSLMODE"
+            value_from {
+              config_map_key_ref {
+                name = "bweng-config"
+                key  = "DB_SSLMODE"
+              }
+            }
+          }
+
+          env {
+            name = "LOG_LEVEL"
+            value_from {
+              config_map_key_ref {
+                name = "bweng-config"
+                key  = "LOG_LEVEL"
+              }
+            }
+          }
+
+          # Resource Limits
+          resources {
+            requests = {
+              memory = "64Mi"
+              cpu    = "50m"
+            }
+            limits = {
+              memory = "128Mi"
+              cpu    = "100m"
+            }
+          }
+        }
+      }
+    }
+  }
+
+  wait_for_completion = true
+
+  timeouts {
+    create = "5m"
+    update = "5m"
+  }
+}
 
 # User Service Deployment
 resource "kubernetes_deployment" "user_service" {
+  # Migration'lar tamamlanmadan pod'lar başlatılmaz
+  depends_on = [kubernetes_job_v1.user_service_migrate]
+
   metadata {
     name      = "user-service-deployment"
     namespace = var.namespace
@@ -88,6 +221,26 @@ resource "kubernetes_deployment" "user_service" {
             }
           }
 
+          env {
+            name = "JWT_SECRET"
+            value_from {
+              secret_key_ref {
+                name = "bweng-app-secret"
+                key  = "JWT_SECRET"
+              }
+            }
+          }
+
+          env {
+            name = "IDENTITY_SECRET"
+            value_from {
+              secret_key_ref {
+                name = "bweng-app-secret"
+                key  = "IDENTITY_SECRET"
+              }
+            }
+          }
+
           env {
             name = "DB_NAME"
             value_from {
@@ -118,6 +271,46 @@ resource "kubernetes_deployment" "user_service" {
             }
           }
 
+          env {
+            name = "LOG_LEVEL"
+            value_from {
+              config_map_key_ref {
+                name = "bweng-config"
+                key  = "LOG_LEVEL"
+              }
+            }
+          }
+
+          env {
+            name = "SKIP_MIGRATIONS"
+            value_from {
+              config_map_key_ref {
+                name = "bweng-config"
+                key  = "SKIP_MIGRATIONS"
+              }
+            }
+          }
+
+          env {
+            name = "USER_SERVICE_PORT"
+            value_from {
+              config_map_key_ref {
+                name = "bweng-config"
+                key  = "USER_SERVICE_PORT"
+              }
+            }
+          }
+
+          env {
+            name = "GRPC_PORT"
+            value_from {
+              config_map_key_ref {
+                name = "bweng-config"
+                key  = "GRPC_PORT"
+              }
+            }
+          }
+
           # Resource Limits
           resources {
             requests = {
@@ -133,7 +326,7 @@ resource "kubernetes_deployment" "user_service" {
           # Health Checks
           liveness_probe {
             http_get {
-              path = "/health"
+              path = "/livez"
               port = var.user_service_port
             }
             initial_delay_seconds = 30
@@ -143,7 +336,7 @@ resource "kubernetes_deployment" "user_service" {
 
           readiness_probe {
             http_get {
-              path = "/health"
+              path = "/readyz"
               port = var.user_service_port
             }
             initial_delay_seconds = 5
@@ -93,6 +93,21 @@ variable "postgres_password" {
   sensitive   = true
 }
 
+# Kimlik doğrulama değişkenleri
+variable "jwt_secret" {
+  description = "JWT imzalama anahtarı"
+  type        = string
+  default     = "change-me-in-production"
+  sensitive   = true
+}
+
+variable "identity_secret" {
+  description = "Gateway'in servislere ilettiği kimlik başlıklarını imzalama anahtarı"
+  type        = string
+  default     = "change-me-in-production-identity"
+  sensitive   = true
+}
+
 variable "postgres_db" {
   description = "PostgreSQL veritabanı adı"
   type        = string